@@ -2,12 +2,15 @@ package background
 
 import (
 	"context"
+	"errors"
 	"strconv"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/difyz9/ytb2bili/internal/analytics"
+	"github.com/difyz9/ytb2bili/internal/config"
+	"github.com/difyz9/ytb2bili/internal/events"
 	"github.com/difyz9/ytb2bili/internal/handler"
 	"github.com/difyz9/ytb2bili/internal/service"
 	"github.com/difyz9/ytb2bili/internal/workflow"
@@ -19,13 +22,14 @@ import (
 )
 
 const (
-	maxCronConcurrency             = 3
 	maxCronRetryCount              = 3
 	biliAutoUploadScanInterval     = 1 * time.Minute
 	maxAutoUploadVideosPerUser     = 5
 	biliSubtitleScanInterval       = 5 * time.Minute
 	maxSubtitleUploadVideosPerBatch = 10
 	youTubeFeedSyncSettingsRefreshInterval = 1 * time.Minute
+	// shutdownCancelWait 停止时取消仍在执行的步骤后，等待任务持久化中断状态并退出的时间
+	shutdownCancelWait = 10 * time.Second
 )
 
 // CronJob owns background polling and scheduled processing that does not
@@ -36,15 +40,24 @@ type CronJob struct {
 	userSettings   *service.UserSettingsClient
 	systemSettings *service.SystemSettingsClient
 	youtubeChain   *workflow.YouTubeChain
+	douyinChain    *workflow.DouyinChain
+	taskRuntime    *workflow.TaskRuntimeRegistry
 	youtubeHandler *handler.YouTubeHandler
 	biliChain      *workflow.BilibiliChain
 	accountService *biliaccount.Service
 	analytics      *analytics.Client
+	events         *events.Bus
+	resources      *workflow.ResourceGuard
+	processing     *workflow.ProcessingService
 	ticker         *time.Ticker
 	biliTicker     *time.Ticker
 	subtitleTicker *time.Ticker
 	stopChan       chan struct{}
-	semaphore      chan struct{}
+	scheduler      *videoScheduler
+	leases         *videoLeases
+	orphans        *orphanRecovery
+	lastOrphanScan time.Time
+	worker         config.WorkerConfig
 	wg             sync.WaitGroup
 	statusMu       sync.RWMutex
 	started        bool
@@ -66,19 +79,31 @@ type StatusResponse struct {
 	ActiveWorkers            int        `json:"active_workers"`
 	MaxConcurrency           int        `json:"max_concurrency"`
 	PendingRetryLimit        int        `json:"pending_retry_limit"`
+	QueuePaused              bool       `json:"queue_paused"`
+	QueuePauseReason         string     `json:"queue_pause_reason,omitempty"`
+
+	// PipelineStages 按平台列出流水线各阶段的队列深度与活跃 worker 数，未启用流水线时为空
+	PipelineStages map[string][]workflow.StageStats `json:"pipeline_stages,omitempty"`
 }
 
 type CronJobParams struct {
 	fx.In
 	Logger         *zap.Logger
 	DB             *gorm.DB
+	WorkflowCfg    config.WorkflowConfig
+	AppCfg         *config.AppConfig
 	UserSettings   *service.UserSettingsClient
 	SystemSettings *service.SystemSettingsClient
 	YoutubeChain   *workflow.YouTubeChain
+	DouyinChain    *workflow.DouyinChain         `optional:"true"`
+	TaskRuntime    *workflow.TaskRuntimeRegistry `optional:"true"`
 	YoutubeHandler *handler.YouTubeHandler
 	BiliChain      *workflow.BilibiliChain
 	AccountService *biliaccount.Service
 	Analytics      *analytics.Client
+	Events         *events.Bus                 `optional:"true"`
+	Resources      *workflow.ResourceGuard     `optional:"true"`
+	Processing     *workflow.ProcessingService `optional:"true"`
 	Lifecycle      fx.Lifecycle
 }
 
@@ -89,24 +114,41 @@ func NewCronJob(params CronJobParams) *CronJob {
 		userSettings:   params.UserSettings,
 		systemSettings: params.SystemSettings,
 		youtubeChain:   params.YoutubeChain,
+		douyinChain:    params.DouyinChain,
+		taskRuntime:    params.TaskRuntime,
 		youtubeHandler: params.YoutubeHandler,
 		biliChain:      params.BiliChain,
 		accountService: params.AccountService,
 		analytics:      params.Analytics,
+		events:         params.Events,
+		resources:      params.Resources,
+		processing:     params.Processing,
 		ticker:         time.NewTicker(5 * time.Second),
 		biliTicker:     time.NewTicker(biliAutoUploadScanInterval),
 		subtitleTicker: time.NewTicker(biliSubtitleScanInterval),
 		stopChan:       make(chan struct{}),
-		semaphore:      make(chan struct{}, maxCronConcurrency),
+		scheduler:      newVideoScheduler(params.WorkflowCfg.Scheduler),
+	}
+	if params.AppCfg != nil {
+		job.worker = params.AppCfg.Worker
 	}
+	job.leases = newVideoLeases(params.DB, job.worker, params.Events, params.Logger)
+	job.orphans = newOrphanRecovery(params.DB, job.leases.owner, params.TaskRuntime, params.Events, params.Logger)
 
 	params.Lifecycle.Append(fx.Hook{
 		OnStart: func(ctx context.Context) error {
+			if !job.worker.RunsWorker() {
+				job.logger.Info("API 模式：不启动后台处理任务，提交的视频由 worker 实例认领")
+				return nil
+			}
 			job.markStarted()
+			job.logger.Info("后台处理实例", zap.String("worker_id", job.leases.owner), zap.Duration("lease_ttl", job.leases.ttl))
 			job.logger.Info("启动视频处理定时任务（每5秒检查一次）")
 			job.logger.Info("启动YouTube feed同步定时任务（按系统设置执行）")
 			job.logger.Info("启动B站自动上传定时任务（每分钟扫描一次，按用户配置间隔执行）")
 			job.logger.Info("启动B站字幕上传定时任务（每5分钟扫描一次审核通过的视频）")
+			// 派发循环计入 wg，停止时等它退出后不会再派发新视频
+			job.wg.Add(1)
 			go job.startVideoProcessingJob()
 			go job.startBilibiliAutoUploadJob()
 			go job.startBilibiliSubtitleUploadJob()
@@ -120,7 +162,8 @@ func NewCronJob(params CronJobParams) *CronJob {
 			job.ticker.Stop()
 			job.biliTicker.Stop()
 			job.subtitleTicker.Stop()
-			job.wg.Wait()
+			job.drainTasks(ctx)
+			job.waitWorkers(ctx)
 			return nil
 		},
 	})
@@ -129,6 +172,9 @@ func NewCronJob(params CronJobParams) *CronJob {
 }
 
 func (j *CronJob) startVideoProcessingJob() {
+	defer j.wg.Done()
+	// 上次进程退出时仍在执行的视频重新排队，从最后完成的步骤继续
+	j.recoverOrphans(true)
 	for {
 		select {
 		case <-j.ticker.C:
@@ -221,37 +267,99 @@ func (j *CronJob) processVideos() {
 	j.markVideoPollStarted()
 	defer j.markVideoPollFinished()
 
-	var videos []model.Video
-	err := j.db.Where("status = ? AND retry_count < ?", model.VideoStatusPending, maxCronRetryCount).
-		Order("created_at ASC").
-		Limit(10).
-		Find(&videos).Error
+	// 先回收崩溃实例留下的过期租约，使其视频重新进入队列
+	if _, err := j.leases.ReclaimExpired(); err != nil {
+		j.logger.Error("回收过期视频租约失败", zap.Error(err))
+	}
+	if time.Since(j.lastOrphanScan) >= orphanScanInterval {
+		j.recoverOrphans(false)
+	}
+
+	// 磁盘或 CPU 超过阈值时暂停取新任务，已在处理的视频不受影响
+	if paused, _ := j.resources.Paused(); paused {
+		return
+	}
+
+	// 按优先级与用户公平调度取出本轮可派发的视频，名额已满时本轮不派发
+	videos, pending, err := j.scheduler.Next(j.db)
 	if err != nil {
-		j.recordVideoPollResult(0, err)
+		j.recordVideoPollResult(pending, err)
 		j.logger.Error("查询待处理视频失败", zap.Error(err))
 		return
 	}
-	j.recordVideoPollResult(len(videos), nil)
+	j.recordVideoPollResult(pending, nil)
 
 	if len(videos) == 0 {
 		return
 	}
 
-	j.logger.Info("发现待处理视频", zap.Int("count", len(videos)))
+	j.logger.Info("发现待处理视频", zap.Int("pending", pending), zap.Int("dispatch", len(videos)))
 
 	for _, video := range videos {
-		j.semaphore <- struct{}{}
 		j.wg.Add(1)
 		go func(v model.Video) {
 			defer func() {
 				j.wg.Done()
-				<-j.semaphore
+				j.scheduler.Release(v)
 			}()
 			j.processVideo(ctx, v)
 		}(video)
 	}
 }
 
+// drainTasks 停止时排空处理中的视频（包括本实例提交链接、续跑的任务）：
+// 任务链在下一个步骤边界停下并写入检查点，宽限期内未停下的步骤被取消，外部进程随之终止；
+// 中断的视频重新排队，下次认领后从中断的步骤继续。
+func (j *CronJob) drainTasks(ctx context.Context) {
+	grace := j.worker.ShutdownGrace()
+	if deadline, ok := ctx.Deadline(); ok {
+		// 为取消后的退出留出时间，避免超过应用的停止超时
+		if remaining := time.Until(deadline) - shutdownCancelWait; remaining < grace {
+			grace = remaining
+		}
+	}
+	if grace < 0 {
+		grace = 0
+	}
+	if running := j.taskRuntime.Running(); len(running) > 0 {
+		j.logger.Info("等待处理中的视频在步骤边界停下",
+			zap.Strings("video_ids", running),
+			zap.Duration("grace", grace))
+	}
+	if interrupted := j.taskRuntime.Shutdown(grace, shutdownCancelWait); len(interrupted) > 0 {
+		j.logger.Warn("服务停止，以下视频的处理已中断，重新排队后从中断的步骤继续",
+			zap.Int("count", len(interrupted)),
+			zap.Strings("video_ids", interrupted))
+	}
+}
+
+// waitWorkers 等待派发循环与处理中的视频退出，最多等到 ctx 结束
+func (j *CronJob) waitWorkers(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		j.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		j.logger.Warn("部分视频处理未在停止超时内退出，重启后由遗留视频回收处理", zap.Strings("video_ids", j.taskRuntime.Running()))
+	}
+}
+
+// recoverOrphans 回收没有任何实例在处理、却停在执行中状态的视频
+func (j *CronJob) recoverOrphans(startup bool) {
+	j.lastOrphanScan = time.Now()
+	recovered, err := j.orphans.Recover(startup)
+	if err != nil {
+		j.logger.Error("回收遗留的执行中视频失败", zap.Error(err))
+		return
+	}
+	if recovered > 0 {
+		j.logger.Info("已回收遗留的执行中视频", zap.Int("count", recovered), zap.Bool("startup", startup))
+	}
+}
+
 func (j *CronJob) markStarted() {
 	j.statusMu.Lock()
 	defer j.statusMu.Unlock()
@@ -289,6 +397,13 @@ func (j *CronJob) recordVideoPollResult(pendingCount int, err error) {
 }
 
 func (j *CronJob) Snapshot() StatusResponse {
+	activeWorkers, maxConcurrency := j.scheduler.Active()
+	queuePaused, pauseReason := j.resources.Paused()
+	var pipelineStages map[string][]workflow.StageStats
+	if j.processing != nil {
+		pipelineStages = j.processing.PipelineStats()
+	}
+
 	j.statusMu.RLock()
 	defer j.statusMu.RUnlock()
 
@@ -300,9 +415,12 @@ func (j *CronJob) Snapshot() StatusResponse {
 		LastVideoPollFinishedAt:  cloneTimePtr(j.lastVideoPollFinishedAt),
 		LastPendingCount:         j.lastPendingCount,
 		LastVideoPollError:       j.lastVideoPollError,
-		ActiveWorkers:            len(j.semaphore),
-		MaxConcurrency:           maxCronConcurrency,
+		ActiveWorkers:            activeWorkers,
+		MaxConcurrency:           maxConcurrency,
 		PendingRetryLimit:        maxCronRetryCount,
+		QueuePaused:              queuePaused,
+		QueuePauseReason:         pauseReason,
+		PipelineStages:           pipelineStages,
 	}
 }
 
@@ -323,18 +441,24 @@ func (j *CronJob) processVideo(ctx context.Context, video model.Video) {
 		zap.String("video_id", video.VideoID),
 		zap.Uint("id", video.ID),
 	)
+	if j.taskRuntime.Draining() {
+		// 服务正在停止，不再认领新视频
+		return
+	}
 
-	logger.Info("开始处理视频", zap.Int("retry_count", video.RetryCount))
-
-	updates := map[string]interface{}{
-		"status":      model.VideoStatusProcessing,
-		"retry_count": video.RetryCount + 1,
+	// 以租约认领：多个实例共享队列时只有一个能认领成功
+	claimed, err := j.leases.Claim(&video)
+	if err != nil {
+		logger.Error("认领视频失败", zap.Error(err))
+		return
 	}
-	if err := j.db.Model(&video).Updates(updates).Error; err != nil {
-		logger.Error("更新视频状态为002（处理中）失败", zap.Error(err))
+	if !claimed {
+		logger.Debug("视频已被其他实例认领，跳过")
 		return
 	}
 
+	logger.Info("开始处理视频", zap.Int("retry_count", video.RetryCount))
+
 	preferences := handler.ResolveVideoProcessingPreferences(
 		ctx,
 		j.userSettings,
@@ -345,15 +469,58 @@ func (j *CronJob) processVideo(ctx context.Context, video model.Video) {
 	)
 
 	initialCtx := &workflow.VideoContext{
+		Platform:              video.Platform,
 		VideoURL:              video.URL,
 		VideoID:               video.VideoID,
 		UserID:                video.UserID,
 		PreferredResolution:   preferences.PreferredResolution,
 		SpeechSynthesisConfig: preferences.SpeechSynthesisConfig,
 		TaskChainSettings:     preferences.TaskChainSettings,
+		WorkflowProfile:       video.WorkflowProfile,
+	}
+
+	// 登记为可取消任务，停止接口可中断正在执行的步骤
+	ctx, done := j.taskRuntime.Track(ctx, video.VideoID)
+	defer done()
+	// 处理期间持续续约；续约失败（被其他实例停止或租约被回收）时中断处理
+	ctx, cancelLease := context.WithCancelCause(ctx)
+	defer cancelLease(nil)
+	go j.leases.Keep(ctx, video.VideoID, cancelLease)
+
+	var vctx *workflow.VideoContext
+	if video.Platform == workflow.PlatformDouyin && j.douyinChain != nil {
+		vctx, err = j.douyinChain.ProcessContextWithTracking(ctx, initialCtx, video.VideoID, video.UserID)
+	} else {
+		vctx, err = j.youtubeChain.ProcessContextWithTracking(ctx, initialCtx, video.VideoID, video.UserID)
+	}
+	if err != nil && errors.Is(context.Cause(ctx), errLeaseLost) {
+		// 租约已由其他实例接管，结果不再写回
+		logger.Warn("视频租约已被回收，放弃本次处理结果", zap.Error(err))
+		return
+	}
+	if err != nil && workflow.IsShutdownInterrupted(ctx, err) {
+		// 服务停止：释放租约并重新排队，不计入重试次数，下次认领后从中断的步骤继续
+		logger.Warn("服务停止，视频处理已中断，重新排队", zap.Error(err))
+		workflow.MarkVideoInterrupted(j.db, logger, video.VideoID, j.youtubeChain.VideoDir(video.VideoID))
+		if _, err := j.leases.Finish(&video, map[string]interface{}{
+			"status":      model.VideoStatusPending,
+			"retry_count": video.RetryCount,
+		}); err != nil {
+			logger.Error("中断的视频重新排队失败", zap.Error(err))
+		}
+		j.taskRuntime.Interrupted(video.VideoID)
+		return
+	}
+	if err != nil && errors.Is(err, context.Canceled) {
+		// 用户主动停止：记为已取消，不计入自动重试
+		logger.Info("视频处理已取消")
+		workflow.MarkVideoCancelled(j.db, logger, video.VideoID, j.youtubeChain.VideoDir(video.VideoID))
+		if _, err := j.leases.Finish(&video, nil); err != nil {
+			logger.Error("释放视频租约失败", zap.Error(err))
+		}
+		j.events.PublishVideoStatus(j.db, video.VideoID, video.UserID, model.VideoStatusCancelled)
+		return
 	}
-
-	vctx, err := j.youtubeChain.ProcessContextWithTracking(ctx, initialCtx, video.VideoID, video.UserID)
 	if err != nil {
 		logger.Error("视频处理失败",
 			zap.Error(err),
@@ -361,15 +528,15 @@ func (j *CronJob) processVideo(ctx context.Context, video model.Video) {
 		)
 
 		if video.RetryCount+1 >= maxCronRetryCount {
-			if err := j.db.Model(&video).Update("status", model.VideoStatusFailed).Error; err != nil {
-				logger.Error("更新视频状态为004（失败）失败", zap.Error(err))
+			if _, err := j.leases.Finish(&video, map[string]interface{}{"status": model.VideoStatusFailed}); err != nil {
+				logger.Error("更新视频状态为 failed 失败", zap.Error(err))
 			}
 			logger.Warn("视频处理失败次数超过限制，标记为失败",
 				zap.Int("max_retry", maxCronRetryCount),
 			)
 		} else {
-			if err := j.db.Model(&video).Update("status", model.VideoStatusPending).Error; err != nil {
-				logger.Error("恢复视频状态为001（待处理）失败", zap.Error(err))
+			if _, err := j.leases.Finish(&video, map[string]interface{}{"status": model.VideoStatusPending}); err != nil {
+				logger.Error("恢复视频状态为 queued 失败", zap.Error(err))
 			}
 			logger.Info("视频将在下次检查时重试",
 				zap.Int("current_retry", video.RetryCount+1),
@@ -379,8 +546,9 @@ func (j *CronJob) processVideo(ctx context.Context, video model.Video) {
 		return
 	}
 
+	// 需要人工审核的视频停在 awaiting_review，审核通过后才会被自动投稿
 	videoUpdates := map[string]interface{}{
-		"status":          model.VideoStatusCompleted,
+		"status":          workflow.FinishedVideoStatus(vctx),
 		"video_path":      vctx.VideoPath,
 		"subtitle_path":   vctx.AudioPath,
 		"generated_title": vctx.Title,
@@ -390,9 +558,12 @@ func (j *CronJob) processVideo(ctx context.Context, video model.Video) {
 		"bili_aid":        vctx.BiliAID,
 	}
 
-	if err := j.db.Model(&video).Updates(videoUpdates).Error; err != nil {
+	if written, err := j.leases.Finish(&video, videoUpdates); err != nil {
 		logger.Error("更新视频处理结果失败", zap.Error(err))
 		return
+	} else if !written {
+		logger.Warn("视频租约已不属于本实例，处理结果未写回")
+		return
 	}
 
 	logger.Info("视频处理完成",
@@ -464,6 +635,7 @@ func (j *CronJob) runBilibiliAutoUploadForUser(now time.Time, settings model.Use
 	var videos []model.Video
 	err := j.db.
 		Where("user_id = ? AND status = ? AND video_path != '' AND (bili_bvid = '' OR bili_bvid IS NULL)", settings.UserID, model.VideoStatusCompleted).
+		Where("(review_status = '' OR review_status IS NULL OR review_status = ?)", model.ReviewStatusApproved).
 		Order("updated_at ASC").
 		Limit(maxAutoUploadVideosPerUser).
 		Find(&videos).Error
@@ -0,0 +1,156 @@
+package background
+
+import (
+	"errors"
+	"time"
+
+	"github.com/difyz9/ytb2bili/internal/events"
+	"github.com/difyz9/ytb2bili/internal/workflow"
+	"github.com/difyz9/ytb2bili/pkg/store/model"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// orphanScanInterval 周期检查遗留视频的间隔
+	orphanScanInterval = 5 * time.Minute
+	// orphanStaleAfter 未持有租约的执行中视频超过该时长没有任何进展（视频与步骤记录均未更新）才视为遗留
+	orphanStaleAfter = 30 * time.Minute
+)
+
+// orphanRecovery 找回进程崩溃或重启后遗留在执行中状态的视频：
+// 没有任何实例在处理（不在本进程的任务登记中，也没有其他实例的有效租约），状态却停在 downloading/processing/uploading。
+//   - 执行中的步骤、步骤尝试与运行记录标记为 interrupted；
+//   - 视频重新排队（重试次数用尽的标记为失败），认领后任务链从最后完成的步骤继续；
+//   - 投稿中断的视频回到 ready，由自动投稿或手动投稿重新上传。
+//
+// 启动时本进程还没有任务，租约属于本实例的执行中视频立即回收；
+// 未持有租约的视频可能是其他实例进程内的处理或手动投稿，启动与周期检查都只回收超过 orphanStaleAfter 没有进展的，
+// 持有其他实例租约的视频由 ReclaimExpired 按过期时间回收。
+type orphanRecovery struct {
+	db         *gorm.DB
+	owner      string
+	runtime    *workflow.TaskRuntimeRegistry
+	events     *events.Bus
+	logger     *zap.Logger
+	staleAfter time.Duration
+}
+
+func newOrphanRecovery(db *gorm.DB, owner string, runtime *workflow.TaskRuntimeRegistry, bus *events.Bus, logger *zap.Logger) *orphanRecovery {
+	return &orphanRecovery{
+		db:         db,
+		owner:      owner,
+		runtime:    runtime,
+		events:     bus,
+		logger:     logger,
+		staleAfter: orphanStaleAfter,
+	}
+}
+
+// Recover 回收遗留视频，返回回收数量
+func (r *orphanRecovery) Recover(startup bool) (int, error) {
+	now := time.Now()
+	cutoff := now.Add(-r.staleAfter)
+	stale := r.db.Where("(lease_owner = '' OR lease_owner IS NULL) AND updated_at < ?", cutoff).
+		Where("NOT EXISTS (SELECT 1 FROM tb_task_steps WHERE tb_task_steps.video_id = tb_videos.video_id AND tb_task_steps.updated_at >= ?)", cutoff)
+	query := r.db.Model(&model.Video{}).
+		Select("id", "video_id", "user_id", "status", "retry_count", "lease_owner")
+	if startup {
+		query = query.Where("status IN ?", model.VideoActiveStatuses).
+			Where(r.db.Where("lease_owner = ?", r.owner).Or(stale))
+	} else {
+		// 投稿不登记为任务，耗时也可能很长，只在启动时回收
+		query = query.Where("status IN ?", []string{model.VideoStatusDownloading, model.VideoStatusProcessing}).
+			Where(stale)
+	}
+	var orphans []model.Video
+	if err := query.Find(&orphans).Error; err != nil {
+		return 0, err
+	}
+
+	recovered := 0
+	for _, video := range orphans {
+		if r.runtime.Has(video.VideoID) {
+			continue
+		}
+		ok, err := r.recover(video, now)
+		if err != nil {
+			return recovered, err
+		}
+		if ok {
+			recovered++
+		}
+	}
+	return recovered, nil
+}
+
+func (r *orphanRecovery) recover(video model.Video, now time.Time) (bool, error) {
+	status := model.VideoStatusPending
+	switch {
+	case model.NormalizeVideoStatus(video.Status) == model.VideoStatusUploading:
+		status = model.VideoStatusReady
+	case video.RetryCount >= maxCronRetryCount:
+		status = model.VideoStatusFailed
+	}
+	owner := video.LeaseOwner
+	_, err := model.TransitionVideoStatus(r.db, video.VideoID, model.VideoTransition{
+		To:   status,
+		From: model.VideoActiveStatuses,
+		// 条件与查询一致：期间已被认领或已结束的视频不回收
+		Scope: func(db *gorm.DB) *gorm.DB {
+			return db.Where("id = ? AND COALESCE(lease_owner, '') = ?", video.ID, owner)
+		},
+		Updates: releasedLease(),
+		Reason:  "进程退出时仍在执行，重启后回收",
+		Actor:   r.owner,
+	})
+	if errors.Is(err, model.ErrVideoStatusConflict) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	r.events.PublishVideoStatus(r.db, video.VideoID, video.UserID, status)
+	r.markInterrupted(video.VideoID, now)
+	r.logger.Warn("回收遗留的执行中视频",
+		zap.String("video_id", video.VideoID),
+		zap.String("previous_status", video.Status),
+		zap.String("previous_owner", owner),
+		zap.String("status", status))
+	return true, nil
+}
+
+// markInterrupted 把视频执行中的步骤、步骤尝试和运行记录标记为 interrupted
+func (r *orphanRecovery) markInterrupted(videoID string, now time.Time) {
+	const msg = "进程退出时仍在执行，将从此步骤继续"
+	if err := r.db.Model(&model.TaskStep{}).
+		Where("video_id = ? AND status = ?", videoID, model.TaskStepStatusRunning).
+		Updates(map[string]interface{}{
+			"status":           model.TaskStepStatusInterrupted,
+			"end_time":         &now,
+			"progress_percent": 0,
+			"progress_text":    msg,
+			"error_msg":        msg,
+			"can_retry":        true,
+		}).Error; err != nil {
+		r.logger.Warn("标记中断的步骤失败", zap.String("video_id", videoID), zap.Error(err))
+	}
+	if err := r.db.Model(&model.TaskStepAttempt{}).
+		Where("video_id = ? AND status = ?", videoID, model.TaskStepStatusRunning).
+		Updates(map[string]interface{}{
+			"status":    model.TaskStepStatusInterrupted,
+			"end_time":  &now,
+			"error_msg": msg,
+		}).Error; err != nil {
+		r.logger.Warn("标记中断的步骤尝试失败", zap.String("video_id", videoID), zap.Error(err))
+	}
+	if err := r.db.Model(&model.TaskRun{}).
+		Where("video_id = ? AND status = ?", videoID, model.TaskRunStatusRunning).
+		Updates(map[string]interface{}{
+			"status":    model.TaskRunStatusInterrupted,
+			"end_time":  &now,
+			"error_msg": msg,
+		}).Error; err != nil {
+		r.logger.Warn("标记中断的运行记录失败", zap.String("video_id", videoID), zap.Error(err))
+	}
+}
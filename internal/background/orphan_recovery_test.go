@@ -0,0 +1,152 @@
+package background
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/difyz9/ytb2bili/internal/workflow"
+	"github.com/difyz9/ytb2bili/pkg/store/model"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+func openOrphanTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db := openSchedulerTestDB(t)
+	if err := db.AutoMigrate(&model.TaskStep{}, &model.TaskStepAttempt{}, &model.TaskRun{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func createActiveVideo(t *testing.T, db *gorm.DB, videoID, status, owner string, retryCount int) {
+	t.Helper()
+	video := &model.Video{UserID: "u1", VideoID: videoID, URL: "https://example.com/" + videoID,
+		Status: status, LeaseOwner: owner, RetryCount: retryCount}
+	if err := db.Create(video).Error; err != nil {
+		t.Fatalf("create video: %v", err)
+	}
+	db.Create(&model.TaskStep{VideoID: videoID, StepName: "DownloadVideo", StepOrder: 1, Status: model.TaskStepStatusCompleted})
+	db.Create(&model.TaskStep{VideoID: videoID, StepName: "Transcribe", StepOrder: 2, Status: model.TaskStepStatusRunning})
+	db.Create(&model.TaskStepAttempt{RunID: videoID + "-run", VideoID: videoID, StepName: "Transcribe", Attempt: 1, Status: model.TaskStepStatusRunning})
+	db.Create(&model.TaskRun{RunID: videoID + "-run", VideoID: videoID, Status: model.TaskRunStatusRunning})
+}
+
+func TestOrphanRecovery_StartupRequeuesInterruptedVideos(t *testing.T) {
+	db := openOrphanTestDB(t)
+	createActiveVideo(t, db, "local", model.VideoStatusProcessing, "", 0)
+	createActiveVideo(t, db, "own-lease", model.VideoStatusDownloading, "worker-a", 1)
+	createActiveVideo(t, db, "exhausted", model.VideoStatusProcessing, "", maxCronRetryCount)
+	createActiveVideo(t, db, "upload", model.VideoStatusUploading, "", 0)
+	createActiveVideo(t, db, "other-lease", model.VideoStatusProcessing, "worker-b", 1)
+	createActiveVideo(t, db, "running", model.VideoStatusProcessing, "", 0)
+	ageVideos(t, db, time.Now().Add(-time.Hour), "local", "exhausted", "upload", "running")
+
+	runtime := workflow.NewTaskRuntimeRegistry()
+	_, done := runtime.Track(context.Background(), "running")
+	defer done()
+
+	recovery := newOrphanRecovery(db, "worker-a", runtime, nil, zap.NewNop())
+	recovered, err := recovery.Recover(true)
+	if err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+	if recovered != 4 {
+		t.Fatalf("expected 4 recovered videos, got %d", recovered)
+	}
+
+	expect := map[string][2]string{
+		"local":       {model.VideoStatusQueued, model.TaskStepStatusInterrupted},
+		"own-lease":   {model.VideoStatusQueued, model.TaskStepStatusInterrupted},
+		"exhausted":   {model.VideoStatusFailed, model.TaskStepStatusInterrupted},
+		"upload":      {model.VideoStatusReady, model.TaskStepStatusInterrupted},
+		"other-lease": {model.VideoStatusProcessing, model.TaskStepStatusRunning}, // 由 ReclaimExpired 按租约处理
+		"running":     {model.VideoStatusProcessing, model.TaskStepStatusRunning}, // 本进程仍在执行
+	}
+	for videoID, want := range expect {
+		video := loadVideo(t, db, videoID)
+		var step model.TaskStep
+		db.Where("video_id = ? AND step_name = ?", videoID, "Transcribe").First(&step)
+		if video.Status != want[0] || step.Status != want[1] {
+			t.Fatalf("%s: expected video %s / step %s, got %s / %s", videoID, want[0], want[1], video.Status, step.Status)
+		}
+	}
+
+	if video := loadVideo(t, db, "own-lease"); video.LeaseOwner != "" || video.RetryCount != 1 {
+		t.Fatalf("expected released lease with unchanged retry count, got owner=%q retry=%d", video.LeaseOwner, video.RetryCount)
+	}
+	var download model.TaskStep
+	db.Where("video_id = ? AND step_name = ?", "local", "DownloadVideo").First(&download)
+	if download.Status != model.TaskStepStatusCompleted {
+		t.Fatalf("completed steps must be kept for resume, got %s", download.Status)
+	}
+	var attempt model.TaskStepAttempt
+	db.Where("video_id = ?", "local").First(&attempt)
+	var run model.TaskRun
+	db.Where("video_id = ?", "local").First(&run)
+	if attempt.Status != model.TaskStepStatusInterrupted || run.Status != model.TaskRunStatusInterrupted || run.EndTime == nil {
+		t.Fatalf("expected interrupted attempt/run, got %s / %s", attempt.Status, run.Status)
+	}
+}
+
+// ageVideos 把视频及其步骤记录的更新时间改为 at，模拟长时间没有进展
+func ageVideos(t *testing.T, db *gorm.DB, at time.Time, videoIDs ...string) {
+	t.Helper()
+	for _, videoID := range videoIDs {
+		db.Model(&model.Video{}).Where("video_id = ?", videoID).UpdateColumn("updated_at", at)
+		db.Model(&model.TaskStep{}).Where("video_id = ?", videoID).UpdateColumn("updated_at", at)
+	}
+}
+
+func TestOrphanRecovery_StartupKeepsFreshUnleasedVideos(t *testing.T) {
+	db := openOrphanTestDB(t)
+	// 另一个 all 模式实例在进程内处理的视频与手动投稿都不持有租约
+	createActiveVideo(t, db, "other-instance", model.VideoStatusProcessing, "", 0)
+	createActiveVideo(t, db, "manual-upload", model.VideoStatusUploading, "", 0)
+	createActiveVideo(t, db, "own-lease", model.VideoStatusProcessing, "worker-a", 0)
+
+	recovery := newOrphanRecovery(db, "worker-a", workflow.NewTaskRuntimeRegistry(), nil, zap.NewNop())
+	recovered, err := recovery.Recover(true)
+	if err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+	if recovered != 1 {
+		t.Fatalf("expected only the video leased by this instance to be recovered, got %d", recovered)
+	}
+	for videoID, want := range map[string]string{
+		"other-instance": model.VideoStatusProcessing,
+		"manual-upload":  model.VideoStatusUploading,
+		"own-lease":      model.VideoStatusQueued,
+	} {
+		if video := loadVideo(t, db, videoID); video.Status != want {
+			t.Fatalf("%s: expected %s, got %s", videoID, want, video.Status)
+		}
+	}
+}
+
+func TestOrphanRecovery_PeriodicOnlyRecoversStaleVideos(t *testing.T) {
+	db := openOrphanTestDB(t)
+	createActiveVideo(t, db, "fresh", model.VideoStatusProcessing, "", 0)
+	createActiveVideo(t, db, "stale", model.VideoStatusProcessing, "", 0)
+	createActiveVideo(t, db, "stale-upload", model.VideoStatusUploading, "", 0)
+	ageVideos(t, db, time.Now().Add(-time.Hour), "stale", "stale-upload")
+
+	recovery := newOrphanRecovery(db, "worker-a", nil, nil, zap.NewNop())
+	recovered, err := recovery.Recover(false)
+	if err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+	if recovered != 1 {
+		t.Fatalf("expected only the stale video to be recovered, got %d", recovered)
+	}
+	if video := loadVideo(t, db, "stale"); video.Status != model.VideoStatusQueued {
+		t.Fatalf("expected stale video to be requeued, got %s", video.Status)
+	}
+	if video := loadVideo(t, db, "fresh"); video.Status != model.VideoStatusProcessing {
+		t.Fatalf("video with recent progress must be left alone, got %s", video.Status)
+	}
+	if video := loadVideo(t, db, "stale-upload"); video.Status != model.VideoStatusUploading {
+		t.Fatalf("uploads are only recovered on startup, got %s", video.Status)
+	}
+}
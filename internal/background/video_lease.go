@@ -0,0 +1,221 @@
+package background
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/difyz9/ytb2bili/internal/config"
+	"github.com/difyz9/ytb2bili/internal/events"
+	"github.com/difyz9/ytb2bili/pkg/store/model"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// errLeaseLost 租约已被其他实例回收（本实例续约超时），当前处理必须放弃且不得再写回结果
+var errLeaseLost = errors.New("video lease lost")
+
+// videoLeases 多实例共享待处理队列时的认领协议：
+//   - Claim 用条件更新把 queued 改为 processing 并写入租约，只有一个实例能认领成功；
+//   - 处理期间 Keep 定期续约，续约失败说明视频已被停止或租约已被回收，立即中断处理；
+//   - ReclaimExpired 把崩溃实例留下的过期租约重新排队（重试次数用尽的标记为失败）。
+//
+// 写入成功的状态变化会发布到事件总线。
+type videoLeases struct {
+	db     *gorm.DB
+	owner  string
+	ttl    time.Duration
+	events *events.Bus
+	logger *zap.Logger
+}
+
+func newVideoLeases(db *gorm.DB, cfg config.WorkerConfig, bus *events.Bus, logger *zap.Logger) *videoLeases {
+	return &videoLeases{
+		db:     db,
+		owner:  cfg.InstanceID(),
+		ttl:    cfg.LeaseTTL(),
+		events: bus,
+		logger: logger,
+	}
+}
+
+func releasedLease() map[string]interface{} {
+	return map[string]interface{}{
+		"lease_owner":        "",
+		"lease_expires_at":   nil,
+		"lease_heartbeat_at": nil,
+	}
+}
+
+// Claim 认领待处理视频：仅当视频仍为待处理、重试次数未变时成功，同时计入一次重试
+func (l *videoLeases) Claim(video *model.Video) (bool, error) {
+	now := time.Now()
+	_, err := model.TransitionVideoStatus(l.db, video.VideoID, model.VideoTransition{
+		To:    model.VideoStatusProcessing,
+		From:  []string{model.VideoStatusQueued},
+		Scope: func(db *gorm.DB) *gorm.DB { return db.Where("id = ? AND retry_count = ?", video.ID, video.RetryCount) },
+		Updates: map[string]interface{}{
+			"retry_count":        video.RetryCount + 1,
+			"lease_owner":        l.owner,
+			"lease_expires_at":   now.Add(l.ttl),
+			"lease_heartbeat_at": now,
+		},
+		Reason: "认领处理", Actor: l.owner,
+	})
+	if errors.Is(err, model.ErrVideoStatusConflict) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	l.events.PublishVideoStatus(l.db, video.VideoID, video.UserID, model.VideoStatusProcessing)
+	return true, nil
+}
+
+// Renew 续约；返回 false 表示租约已不属于本实例，或视频已失败、被取消
+func (l *videoLeases) Renew(videoID string) (bool, error) {
+	now := time.Now()
+	result := l.db.Model(&model.Video{}).
+		Where("video_id = ? AND lease_owner = ? AND status NOT IN ?", videoID, l.owner, model.VideoStoppedStatuses).
+		Updates(map[string]interface{}{
+			"lease_expires_at":   now.Add(l.ttl),
+			"lease_heartbeat_at": now,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// Keep 在 ctx 结束前每 1/3 有效期续约一次。续约失败时以原因取消 ctx：
+// 视频被停止（API 实例把状态改为 cancelled）为 context.Canceled，其余情况为 errLeaseLost。
+func (l *videoLeases) Keep(ctx context.Context, videoID string, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		ok, err := l.Renew(videoID)
+		if err != nil {
+			// 数据库暂时不可用：继续尝试，真正过期后由回收方接管
+			l.logger.Warn("续约视频租约失败", zap.String("video_id", videoID), zap.Error(err))
+			continue
+		}
+		if !ok {
+			cancel(l.lostCause(videoID))
+			return
+		}
+	}
+}
+
+func (l *videoLeases) lostCause(videoID string) error {
+	var video model.Video
+	if err := l.db.Select("status", "lease_owner").Where("video_id = ?", videoID).First(&video).Error; err == nil &&
+		video.LeaseOwner == l.owner &&
+		model.NormalizeVideoStatus(video.Status) == model.VideoStatusCancelled {
+		return context.Canceled
+	}
+	return errLeaseLost
+}
+
+// Finish 写回处理结果并释放租约；租约已不属于本实例时不写入并返回 false。
+// updates 含 status 时经状态机变更
+func (l *videoLeases) Finish(video *model.Video, updates map[string]interface{}) (bool, error) {
+	values := releasedLease()
+	status, hasStatus := "", false
+	for key, value := range updates {
+		if key == "status" {
+			status, hasStatus = value.(string)
+			continue
+		}
+		values[key] = value
+	}
+	if hasStatus {
+		previous, err := model.TransitionVideoStatus(l.db, video.VideoID, model.VideoTransition{
+			To:      status,
+			Scope:   func(db *gorm.DB) *gorm.DB { return db.Where("id = ? AND lease_owner = ?", video.ID, l.owner) },
+			Updates: values,
+			Reason:  "处理结束", Actor: l.owner,
+		})
+		var invalid *model.InvalidVideoTransitionError
+		switch {
+		case err == nil:
+			if previous != model.NormalizeVideoStatus(status) {
+				l.events.PublishVideoStatus(l.db, video.VideoID, video.UserID, status)
+			}
+			return true, nil
+		case errors.Is(err, model.ErrVideoStatusConflict):
+			return false, nil
+		case errors.As(err, &invalid):
+			// 处理期间状态已被改变（如审核已通过），只写入其余字段
+			l.logger.Warn("忽略不合法的视频状态变更", zap.String("video_id", video.VideoID), zap.Error(err))
+		default:
+			return false, err
+		}
+	}
+	result := l.db.Model(&model.Video{}).
+		Where("id = ? AND lease_owner = ?", video.ID, l.owner).
+		Updates(values)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// ReclaimExpired 回收过期租约：视频重新排队，执行中的步骤复位为待处理；
+// 重试次数用尽的视频标记为失败。返回回收的视频数。
+func (l *videoLeases) ReclaimExpired() (int, error) {
+	now := time.Now()
+	var expired []model.Video
+	if err := l.db.Select("id", "video_id", "user_id", "retry_count", "lease_owner").
+		Where("status IN ? AND lease_owner <> '' AND lease_expires_at < ?", model.VideoActiveStatuses, now).
+		Find(&expired).Error; err != nil {
+		return 0, err
+	}
+
+	reclaimed := 0
+	for _, video := range expired {
+		status, stepStatus := model.VideoStatusPending, model.TaskStepStatusPending
+		if video.RetryCount >= maxCronRetryCount {
+			status, stepStatus = model.VideoStatusFailed, model.TaskStepStatusFailed
+		}
+		// 条件与查询一致：期间已被原 worker 续约或结束的视频不回收
+		owner := video.LeaseOwner
+		_, err := model.TransitionVideoStatus(l.db, video.VideoID, model.VideoTransition{
+			To:   status,
+			From: model.VideoActiveStatuses,
+			Scope: func(db *gorm.DB) *gorm.DB {
+				return db.Where("id = ? AND lease_owner = ? AND lease_expires_at < ?", video.ID, owner, now)
+			},
+			Updates: releasedLease(),
+			Reason:  "处理实例失联，租约已过期", Actor: l.owner,
+		})
+		if errors.Is(err, model.ErrVideoStatusConflict) {
+			continue
+		}
+		if err != nil {
+			return reclaimed, err
+		}
+		reclaimed++
+		l.events.PublishVideoStatus(l.db, video.VideoID, video.UserID, status)
+
+		stepUpdates := map[string]interface{}{"status": stepStatus}
+		if stepStatus == model.TaskStepStatusFailed {
+			stepUpdates["error_msg"] = "处理实例失联，租约已过期"
+			stepUpdates["can_retry"] = true
+		}
+		if err := l.db.Model(&model.TaskStep{}).
+			Where("video_id = ? AND status = ?", video.VideoID, model.TaskStepStatusRunning).
+			Updates(stepUpdates).Error; err != nil {
+			l.logger.Warn("复位过期租约的执行中步骤失败", zap.String("video_id", video.VideoID), zap.Error(err))
+		}
+		l.logger.Warn("回收过期的视频租约",
+			zap.String("video_id", video.VideoID),
+			zap.String("previous_owner", video.LeaseOwner),
+			zap.String("status", status))
+	}
+	return reclaimed, nil
+}
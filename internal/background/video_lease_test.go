@@ -0,0 +1,144 @@
+package background
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/difyz9/ytb2bili/internal/config"
+	"github.com/difyz9/ytb2bili/pkg/store/model"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+func newTestLeases(db *gorm.DB, owner string) *videoLeases {
+	return newVideoLeases(db, config.WorkerConfig{ID: owner, LeaseSeconds: 60}, nil, zap.NewNop())
+}
+
+func loadVideo(t *testing.T, db *gorm.DB, videoID string) model.Video {
+	t.Helper()
+	var video model.Video
+	if err := db.Where("video_id = ?", videoID).First(&video).Error; err != nil {
+		t.Fatalf("load video: %v", err)
+	}
+	return video
+}
+
+func TestVideoLeases_OnlyOneWorkerClaims(t *testing.T) {
+	db := openSchedulerTestDB(t)
+	createPendingVideo(t, db, "u1", "v1", model.VideoPriorityNormal, time.Now())
+	video := loadVideo(t, db, "v1")
+
+	a, b := newTestLeases(db, "worker-a"), newTestLeases(db, "worker-b")
+	if ok, err := a.Claim(&video); err != nil || !ok {
+		t.Fatalf("expected worker-a to claim, ok=%v err=%v", ok, err)
+	}
+	if ok, _ := b.Claim(&video); ok {
+		t.Fatal("expected second claim of the same row to fail")
+	}
+
+	claimed := loadVideo(t, db, "v1")
+	if claimed.Status != model.VideoStatusProcessing || claimed.LeaseOwner != "worker-a" || claimed.RetryCount != 1 {
+		t.Fatalf("unexpected claimed row: status=%s owner=%s retry=%d", claimed.Status, claimed.LeaseOwner, claimed.RetryCount)
+	}
+	if ok, _ := b.Renew("v1"); ok {
+		t.Fatal("expected renew by a non-owner to fail")
+	}
+	if ok, _ := b.Finish(&claimed, map[string]interface{}{"status": model.VideoStatusCompleted}); ok {
+		t.Fatal("expected finish by a non-owner to be ignored")
+	}
+	if ok, _ := a.Finish(&claimed, map[string]interface{}{"status": model.VideoStatusCompleted}); !ok {
+		t.Fatal("expected owner to write the result")
+	}
+	if done := loadVideo(t, db, "v1"); done.Status != model.VideoStatusCompleted || done.LeaseOwner != "" || done.LeaseExpiresAt != nil {
+		t.Fatalf("expected completed row with released lease, got status=%s owner=%q", done.Status, done.LeaseOwner)
+	}
+}
+
+func TestVideoLeases_ReclaimExpiredLeases(t *testing.T) {
+	db := openSchedulerTestDB(t)
+	if err := db.AutoMigrate(&model.TaskStep{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Minute)
+	rows := []model.Video{
+		{VideoID: "crashed", RetryCount: 1, LeaseExpiresAt: &past},
+		{VideoID: "exhausted", RetryCount: maxCronRetryCount, LeaseExpiresAt: &past},
+		{VideoID: "alive", RetryCount: 1, LeaseExpiresAt: &future},
+	}
+	for i := range rows {
+		rows[i].UserID, rows[i].URL = "u1", "https://example.com/"+rows[i].VideoID
+		rows[i].Status, rows[i].LeaseOwner = model.VideoStatusProcessing, "dead-worker"
+		if err := db.Create(&rows[i]).Error; err != nil {
+			t.Fatalf("create video: %v", err)
+		}
+		db.Create(&model.TaskStep{VideoID: rows[i].VideoID, StepName: "DownloadVideo", Status: model.TaskStepStatusRunning})
+	}
+
+	reclaimed, err := newTestLeases(db, "worker-a").ReclaimExpired()
+	if err != nil {
+		t.Fatalf("ReclaimExpired: %v", err)
+	}
+	if reclaimed != 2 {
+		t.Fatalf("expected 2 reclaimed leases, got %d", reclaimed)
+	}
+
+	expect := map[string][2]string{
+		"crashed":   {model.VideoStatusPending, model.TaskStepStatusPending},
+		"exhausted": {model.VideoStatusFailed, model.TaskStepStatusFailed},
+		"alive":     {model.VideoStatusProcessing, model.TaskStepStatusRunning},
+	}
+	for videoID, want := range expect {
+		video := loadVideo(t, db, videoID)
+		var step model.TaskStep
+		db.Where("video_id = ?", videoID).First(&step)
+		if video.Status != want[0] || step.Status != want[1] {
+			t.Fatalf("%s: expected video %s / step %s, got %s / %s", videoID, want[0], want[1], video.Status, step.Status)
+		}
+	}
+}
+
+func TestVideoLeases_KeepCancelsWhenStoppedOrLost(t *testing.T) {
+	db := openSchedulerTestDB(t)
+	for _, videoID := range []string{"stopped", "stolen"} {
+		createPendingVideo(t, db, "u1", videoID, model.VideoPriorityNormal, time.Now())
+	}
+	leases := newVideoLeases(db, config.WorkerConfig{ID: "worker-a"}, nil, zap.NewNop())
+	leases.ttl = 30 * time.Millisecond
+
+	run := func(videoID string, interfere func()) error {
+		video := loadVideo(t, db, videoID)
+		if ok, err := leases.Claim(&video); !ok || err != nil {
+			t.Fatalf("claim %s: ok=%v err=%v", videoID, ok, err)
+		}
+		interfere()
+		ctx, cancel := context.WithCancelCause(context.Background())
+		defer cancel(nil)
+		go leases.Keep(ctx, videoID, cancel)
+		select {
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		case <-time.After(time.Second):
+			t.Fatalf("%s: expected Keep to cancel the context", videoID)
+			return nil
+		}
+	}
+
+	// API 实例停止任务：状态改为 cancelled，租约仍属于本实例
+	cause := run("stopped", func() {
+		db.Model(&model.Video{}).Where("video_id = ?", "stopped").Update("status", model.VideoStatusCancelled)
+	})
+	if !errors.Is(cause, context.Canceled) {
+		t.Fatalf("expected stop to surface as context.Canceled, got %v", cause)
+	}
+
+	// 租约被其他实例回收并重新认领
+	cause = run("stolen", func() {
+		db.Model(&model.Video{}).Where("video_id = ?", "stolen").Update("lease_owner", "worker-b")
+	})
+	if !errors.Is(cause, errLeaseLost) {
+		t.Fatalf("expected errLeaseLost, got %v", cause)
+	}
+}
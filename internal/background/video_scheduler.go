@@ -0,0 +1,274 @@
+package background
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/difyz9/ytb2bili/internal/config"
+	"github.com/difyz9/ytb2bili/pkg/store/model"
+	"gorm.io/gorm"
+)
+
+const defaultCronConcurrency = 3
+
+// 未配置时各会员等级的单用户并发上限与轮转权重
+var (
+	defaultTierConcurrency = map[model.Tier]int{
+		model.TierFree:       1,
+		model.TierBasic:      1,
+		model.TierStandard:   2,
+		model.TierPro:        3,
+		model.TierEnterprise: 5,
+	}
+	defaultTierWeights = map[model.Tier]int{
+		model.TierFree:       1,
+		model.TierBasic:      2,
+		model.TierStandard:   3,
+		model.TierPro:        4,
+		model.TierEnterprise: 6,
+	}
+)
+
+// videoScheduler 决定每轮轮询从待处理视频中取出哪些：
+//   - 优先级高的先取（见 model.VideoPriority*）；
+//   - 同一优先级内按用户轮转，按会员等级加权（stride 调度），单个用户的大量积压不会饿死其他用户；
+//   - 单个用户的并发受会员等级上限约束，全局并发受 max_concurrent 约束。
+type videoScheduler struct {
+	maxConcurrent int
+	userLimits    map[model.Tier]int
+	weights       map[model.Tier]int
+
+	mu       sync.Mutex
+	total    int
+	running  map[string]int      // 用户 → 运行中的视频数
+	inFlight map[string]struct{} // 已派发、尚未结束的视频 ID
+	pass     map[string]float64  // 用户的 stride 虚拟时间，越小越先轮到
+	vtime    float64             // 最近一次派发时的虚拟时间，新加入的用户从这里开始
+}
+
+func newVideoScheduler(cfg config.SchedulerConfig) *videoScheduler {
+	s := &videoScheduler{
+		maxConcurrent: cfg.MaxConcurrent,
+		userLimits:    mergeTierValues(defaultTierConcurrency, cfg.UserConcurrency),
+		weights:       mergeTierValues(defaultTierWeights, cfg.TierWeights),
+		running:       make(map[string]int),
+		inFlight:      make(map[string]struct{}),
+		pass:          make(map[string]float64),
+	}
+	if s.maxConcurrent <= 0 {
+		s.maxConcurrent = defaultCronConcurrency
+	}
+	return s
+}
+
+func mergeTierValues(defaults map[model.Tier]int, overrides map[string]int) map[model.Tier]int {
+	merged := make(map[model.Tier]int, len(defaults))
+	for tier, value := range defaults {
+		merged[tier] = value
+	}
+	for key, value := range overrides {
+		if value > 0 {
+			merged[model.Tier(strings.ToLower(strings.TrimSpace(key)))] = value
+		}
+	}
+	return merged
+}
+
+func (s *videoScheduler) userLimit(tier model.Tier) int {
+	if limit, ok := s.userLimits[tier]; ok {
+		return limit
+	}
+	return s.userLimits[model.TierFree]
+}
+
+func (s *videoScheduler) weight(tier model.Tier) float64 {
+	if weight, ok := s.weights[tier]; ok {
+		return float64(weight)
+	}
+	return float64(s.weights[model.TierFree])
+}
+
+// Active 返回运行中的视频数与全局并发上限
+func (s *videoScheduler) Active() (int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.total, s.maxConcurrent
+}
+
+// Next 查询待处理视频并按调度规则取出本轮要派发的视频，取出的视频已计入运行数，
+// 处理结束后必须调用 Release。pending 为当前待处理视频总数。
+func (s *videoScheduler) Next(db *gorm.DB) (picked []model.Video, pending int, err error) {
+	var counts []struct {
+		UserID  string
+		Pending int
+	}
+	if err := db.Model(&model.Video{}).
+		Select("user_id, COUNT(*) AS pending").
+		Where("status = ? AND retry_count < ?", model.VideoStatusPending, maxCronRetryCount).
+		Group("user_id").
+		Scan(&counts).Error; err != nil {
+		return nil, 0, err
+	}
+	userIDs := make([]string, 0, len(counts))
+	for _, row := range counts {
+		pending += row.Pending
+		userIDs = append(userIDs, row.UserID)
+	}
+	if len(userIDs) == 0 {
+		return nil, 0, nil
+	}
+
+	tiers, err := loadUserTiers(db, userIDs)
+	if err != nil {
+		return nil, pending, err
+	}
+
+	// 每个用户最多取其剩余并发名额条，按优先级、提交时间排序
+	s.mu.Lock()
+	free := s.maxConcurrent - s.total
+	need := make(map[string]int, len(userIDs))
+	for _, userID := range userIDs {
+		n := s.userLimit(tiers[userID]) - s.running[userID]
+		if n > free {
+			n = free
+		}
+		if n > 0 {
+			// 已派发但状态尚未更新的视频可能仍在结果中，多取这部分再过滤
+			need[userID] = n + s.running[userID]
+		}
+	}
+	s.mu.Unlock()
+	if free <= 0 {
+		return nil, pending, nil
+	}
+
+	queues := make(map[string][]model.Video, len(need))
+	for userID, limit := range need {
+		var videos []model.Video
+		if err := db.Where("user_id = ? AND status = ? AND retry_count < ?", userID, model.VideoStatusPending, maxCronRetryCount).
+			Order("priority DESC, created_at ASC").
+			Limit(limit).
+			Find(&videos).Error; err != nil {
+			return nil, pending, err
+		}
+		queues[userID] = videos
+	}
+
+	return s.pick(queues, tiers), pending, nil
+}
+
+// pick 从各用户的队列（已按优先级、提交时间排序）中选出本轮派发的视频并计入运行数
+func (s *videoScheduler) pick(queues map[string][]model.Video, tiers map[string]model.Tier) []model.Video {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for userID, videos := range queues {
+		kept := videos[:0]
+		for _, video := range videos {
+			if _, ok := s.inFlight[video.VideoID]; !ok {
+				kept = append(kept, video)
+			}
+		}
+		queues[userID] = kept
+	}
+
+	var picked []model.Video
+	for s.total < s.maxConcurrent {
+		userID, ok := s.nextUser(queues, tiers)
+		if !ok {
+			break
+		}
+		video := queues[userID][0]
+		queues[userID] = queues[userID][1:]
+
+		start := s.pass[userID]
+		if start < s.vtime {
+			start = s.vtime
+		}
+		s.vtime = start
+		s.pass[userID] = start + 1/s.weight(tiers[userID])
+		s.running[userID]++
+		s.total++
+		s.inFlight[video.VideoID] = struct{}{}
+		picked = append(picked, video)
+	}
+	return picked
+}
+
+// nextUser 在队首优先级最高的用户中选虚拟时间最小的；并列时运行数少的优先，再先到先得，最后按用户 ID 保证确定性
+func (s *videoScheduler) nextUser(queues map[string][]model.Video, tiers map[string]model.Tier) (string, bool) {
+	userIDs := make([]string, 0, len(queues))
+	for userID, videos := range queues {
+		if len(videos) > 0 && s.running[userID] < s.userLimit(tiers[userID]) {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	if len(userIDs) == 0 {
+		return "", false
+	}
+
+	passOf := func(userID string) float64 {
+		if pass := s.pass[userID]; pass > s.vtime {
+			return pass
+		}
+		return s.vtime
+	}
+	sort.Slice(userIDs, func(i, j int) bool {
+		a, b := queues[userIDs[i]][0], queues[userIDs[j]][0]
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		if pa, pb := passOf(userIDs[i]), passOf(userIDs[j]); pa != pb {
+			return pa < pb
+		}
+		if ra, rb := s.running[userIDs[i]], s.running[userIDs[j]]; ra != rb {
+			return ra < rb
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return userIDs[i] < userIDs[j]
+	})
+	return userIDs[0], true
+}
+
+// Release 视频处理结束（无论成功与否）后释放其并发名额
+func (s *videoScheduler) Release(video model.Video) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.inFlight[video.VideoID]; !ok {
+		return
+	}
+	delete(s.inFlight, video.VideoID)
+	s.total--
+	if s.running[video.UserID]--; s.running[video.UserID] <= 0 {
+		delete(s.running, video.UserID)
+		// 空闲用户重新加入时从当前虚拟时间开始；超前（多占过名额）的虚拟时间保留，避免停一下就重新插队
+		if s.pass[video.UserID] <= s.vtime {
+			delete(s.pass, video.UserID)
+		}
+	}
+}
+
+// loadUserTiers 查询用户当前有效的会员等级，没有会员或已过期视为 free
+func loadUserTiers(db *gorm.DB, userIDs []string) (map[string]model.Tier, error) {
+	tiers := make(map[string]model.Tier, len(userIDs))
+	var memberships []model.UserMembership
+	if err := db.Where("user_id IN ?", userIDs).Find(&memberships).Error; err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, membership := range memberships {
+		if membership.ExpiresAt.After(now) {
+			tiers[membership.UserID] = membership.Tier
+		}
+	}
+	for _, userID := range userIDs {
+		if _, ok := tiers[userID]; !ok {
+			tiers[userID] = model.TierFree
+		}
+	}
+	return tiers, nil
+}
@@ -0,0 +1,129 @@
+package background
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/difyz9/ytb2bili/internal/config"
+	"github.com/difyz9/ytb2bili/pkg/store/model"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func openSchedulerTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&model.Video{}, &model.VideoStatusTransition{}, &model.UserMembership{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func createPendingVideo(t *testing.T, db *gorm.DB, userID, videoID string, priority int, createdAt time.Time) {
+	t.Helper()
+	video := &model.Video{
+		UserID:   userID,
+		VideoID:  videoID,
+		URL:      "https://example.com/" + videoID,
+		Status:   model.VideoStatusPending,
+		Priority: priority,
+	}
+	video.CreatedAt = createdAt
+	if err := db.Create(video).Error; err != nil {
+		t.Fatalf("create video: %v", err)
+	}
+}
+
+func countByUser(videos []model.Video) map[string]int {
+	counts := make(map[string]int)
+	for _, video := range videos {
+		counts[video.UserID]++
+	}
+	return counts
+}
+
+func TestVideoScheduler_BacklogDoesNotStarveOtherUsers(t *testing.T) {
+	db := openSchedulerTestDB(t)
+	base := time.Now().Add(-time.Hour)
+	for i := 0; i < 500; i++ {
+		createPendingVideo(t, db, "heavy", fmt.Sprintf("heavy-%03d", i), model.VideoPriorityNormal, base.Add(time.Duration(i)*time.Millisecond))
+	}
+	createPendingVideo(t, db, "light", "light-1", model.VideoPriorityNormal, base.Add(time.Minute))
+	db.Create(&model.UserMembership{UserID: "heavy", Tier: model.TierEnterprise, ExpiresAt: time.Now().Add(24 * time.Hour)})
+
+	s := newVideoScheduler(config.SchedulerConfig{MaxConcurrent: 3})
+	picked, pending, err := s.Next(db)
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	if pending != 501 {
+		t.Fatalf("expected 501 pending, got %d", pending)
+	}
+	counts := countByUser(picked)
+	if len(picked) != 3 || counts["light"] != 1 || counts["heavy"] != 2 {
+		t.Fatalf("expected light user to get a slot alongside the backlog, got %v", counts)
+	}
+
+	// 名额已满时不再派发，也不会重复派发已在运行的视频
+	if again, _, _ := s.Next(db); len(again) != 0 {
+		t.Fatalf("expected no dispatch while full, got %d", len(again))
+	}
+	s.Release(picked[0])
+	again, _, _ := s.Next(db)
+	if len(again) != 1 {
+		t.Fatalf("expected one slot after release, got %d", len(again))
+	}
+	for _, video := range picked[1:] {
+		if video.VideoID == again[0].VideoID {
+			t.Fatalf("video %s dispatched twice", video.VideoID)
+		}
+	}
+}
+
+func TestVideoScheduler_ManualBeforeAutoSyncAndUserCap(t *testing.T) {
+	db := openSchedulerTestDB(t)
+	base := time.Now().Add(-time.Hour)
+	for i := 0; i < 5; i++ {
+		createPendingVideo(t, db, "sync", fmt.Sprintf("sync-%d", i), model.VideoPriorityAutoSync, base)
+	}
+	createPendingVideo(t, db, "a", "a-manual", model.VideoPriorityManual, base.Add(time.Minute))
+	createPendingVideo(t, db, "a", "a-manual-2", model.VideoPriorityManual, base.Add(2*time.Minute))
+	createPendingVideo(t, db, "b", "b-manual", model.VideoPriorityManual, base.Add(3*time.Minute))
+
+	s := newVideoScheduler(config.SchedulerConfig{MaxConcurrent: 3})
+	picked, _, err := s.Next(db)
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	counts := countByUser(picked)
+	// free 等级单用户并发为 1：a 只能取一条，剩余名额给低优先级的同步视频
+	if counts["a"] != 1 || counts["b"] != 1 || counts["sync"] != 1 {
+		t.Fatalf("unexpected dispatch %v", counts)
+	}
+	if picked[0].Priority != model.VideoPriorityManual || picked[1].Priority != model.VideoPriorityManual {
+		t.Fatalf("expected manual submissions first, got %s, %s", picked[0].VideoID, picked[1].VideoID)
+	}
+}
+
+func TestVideoScheduler_WeightsShareSlotsByTier(t *testing.T) {
+	s := newVideoScheduler(config.SchedulerConfig{
+		MaxConcurrent:   100,
+		UserConcurrency: map[string]int{"free": 100, "pro": 100},
+		TierWeights:     map[string]int{"free": 1, "pro": 3},
+	})
+	queues := map[string][]model.Video{}
+	for _, userID := range []string{"f", "p"} {
+		for i := 0; i < 100; i++ {
+			queues[userID] = append(queues[userID], model.Video{UserID: userID, VideoID: fmt.Sprintf("%s-%d", userID, i)})
+		}
+	}
+	s.maxConcurrent = 40
+	counts := countByUser(s.pick(queues, map[string]model.Tier{"f": model.TierFree, "p": model.TierPro}))
+	if counts["p"] != 30 || counts["f"] != 10 {
+		t.Fatalf("expected 3:1 share, got %v", counts)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/difyz9/ytb2bili/internal/analytics"
 	"github.com/difyz9/ytb2bili/internal/background"
 	"github.com/difyz9/ytb2bili/internal/config"
+	"github.com/difyz9/ytb2bili/internal/events"
 	"github.com/difyz9/ytb2bili/internal/handler"
 	"github.com/difyz9/ytb2bili/internal/server"
 	"github.com/difyz9/ytb2bili/internal/service"
@@ -17,8 +18,8 @@ import (
 	"go.uber.org/zap"
 )
 
-// NewApp wires fx modules and runs the app.
-func NewApp() *fx.App {
+// NewApp wires fx modules and runs the app. extra options are appended (e.g. fx.Populate).
+func NewApp(extra ...fx.Option) *fx.App {
 	return fx.New(
 		fx.Provide(zap.NewDevelopment),
 		fx.Provide(config.LoadAppConfig),
@@ -30,6 +31,7 @@ func NewApp() *fx.App {
 		agent.Module, // 消耗 tools group，提供 *agent.NanoAgent
 
 		analytics.Module, // Analytics 数据统计模块
+		events.Module,    // 工作流事件总线与外发 Webhook
 		store.Module,
 		service.Module, // 业务服务模块
 		background.Module,
@@ -45,6 +47,7 @@ func NewApp() *fx.App {
 		server.Module,  // 后启动服务器
 
 		fx.Invoke(start),
+		fx.Options(extra...),
 	)
 }
 
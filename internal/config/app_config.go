@@ -73,6 +73,9 @@ type AppConfig struct {
 
 	Workflow     WorkflowConfig     `toml:"workflow"`
 	AgentOpenAPI AgentOpenAPIConfig `toml:"agent_open_api"`
+	Worker       WorkerConfig       `toml:"worker"`
+
+	Notifications NotificationsConfig `toml:"notifications"`
 }
 
 // LLMConfig holds the user-configurable LLM provider settings.
@@ -218,6 +221,173 @@ type WorkflowConfig struct {
 
 	// 并发控制
 	MaxConcurrent int `toml:"max_concurrent"`
+
+	// 步骤超时，键为步骤名（如 Transcribe），未配置的步骤使用内置默认值
+	StepTimeouts map[string]StepTimeoutConfig `toml:"step_timeouts"`
+
+	// 步骤结果缓存：相同输入（音频哈希、字幕文本 + 语言 + 模型、文本 + 音色）复用已有结果
+	StepCache StepCacheConfig `toml:"step_cache"`
+
+	// 声明式工作流：按名称选择的步骤组合，未指定时使用 default_profile，仍为空则使用内置完整流程
+	DefaultProfile string                  `toml:"default_profile"`
+	Profiles       []WorkflowProfileConfig `toml:"profiles"`
+
+	// 外部程序步骤：按名称注册到步骤表，可在 [[workflow.profiles.steps]] 中引用
+	Plugins []PluginStepConfig `toml:"plugins"`
+
+	// 定时任务取待处理视频的调度：全局并发、按会员等级的单用户并发上限与权重
+	Scheduler SchedulerConfig `toml:"scheduler"`
+
+	// 资源准入：下载前检查磁盘空间，限制 CPU 密集步骤与下载的并发，资源紧张时暂停取新任务
+	Resources ResourcesConfig `toml:"resources"`
+
+	// 发布前人工审核：处理完成的视频停在 awaiting_review，审核通过后才会被自动投稿
+	Review ReviewConfig `toml:"review"`
+
+	// 语音识别：转写引擎（bcut / whisper）与 whisper 的模型、语言、解码参数
+	ASR ASRConfig `toml:"asr"`
+}
+
+// WorkflowProfileConfig 一个命名的工作流（[[workflow.profiles]]）
+type WorkflowProfileConfig struct {
+	Name        string                      `toml:"name"`
+	Description string                      `toml:"description"`
+	Platform    string                      `toml:"platform"` // youtube / douyin，默认 youtube
+	Steps       []WorkflowProfileStepConfig `toml:"steps"`
+}
+
+// WorkflowProfileStepConfig 工作流中的单个步骤（[[workflow.profiles.steps]]）
+type WorkflowProfileStepConfig struct {
+	Name     string         `toml:"name"`     // 步骤注册名，如 DownloadVideo、AddWatermark
+	Order    int            `toml:"order"`    // 执行顺序，0 表示按列表位置
+	Required *bool          `toml:"required"` // 失败是否中止流程，未设置时沿用步骤默认值
+	Params   map[string]any `toml:"params"`   // 传给步骤的参数，步骤通过 StepParams 读取
+}
+
+// PluginStepConfig 外部程序步骤（[[workflow.plugins]]）。
+// 程序从 stdin 读取 JSON 形式的 VideoContext，在 stdout 逐行输出进度与最终的字段补丁，
+// 协议见 internal/workflow/plugin_step.go。
+type PluginStepConfig struct {
+	Name           string            `toml:"name"`            // 注册名，不能与内置步骤重名
+	Command        string            `toml:"command"`         // 可执行文件，绝对路径或 PATH 中的命令名
+	Args           []string          `toml:"args"`            // 命令行参数
+	Env            map[string]string `toml:"env"`             // 追加的环境变量
+	WorkDir        string            `toml:"work_dir"`        // 工作目录，默认为视频文件所在目录
+	Required       bool              `toml:"required"`        // 失败是否中止流程，默认 false
+	TimeoutSeconds int               `toml:"timeout_seconds"` // 单次执行超时（秒），0 时按 step_timeouts 规则
+	DependsOn      []string          `toml:"depends_on"`      // 依赖的步骤，未设置时等待所有顺序更靠前的步骤
+}
+
+// StepCacheConfig 步骤结果缓存配置（[workflow.step_cache]）
+type StepCacheConfig struct {
+	Disabled   bool   `toml:"disabled"`     // 关闭缓存
+	Dir        string `toml:"dir"`          // 缓存文件目录，默认 <download_dir>/.step_cache
+	MaxAgeDays int    `toml:"max_age_days"` // 超过天数的条目视为失效，0 表示不过期
+}
+
+// SchedulerConfig 待处理视频调度配置（[workflow.scheduler]）。
+// user_concurrency / tier_weights 的键为会员等级（free、basic、standard、pro、enterprise），未配置的等级使用内置默认值。
+type SchedulerConfig struct {
+	MaxConcurrent   int            `toml:"max_concurrent"`   // 全局同时处理的视频数，默认 3
+	UserConcurrency map[string]int `toml:"user_concurrency"` // 单个用户同时处理的视频数上限
+	TierWeights     map[string]int `toml:"tier_weights"`     // 轮转权重，权重越高分到的名额越多
+}
+
+// ResourcesConfig 资源准入配置（[workflow.resources]）。
+// 磁盘剩余低于 min_free_disk_mb 或 CPU 使用率超过 max_cpu_percent 时，后台暂停取新任务，
+// 已在处理的视频继续执行。
+type ResourcesConfig struct {
+	MinFreeDiskMB     int      `toml:"min_free_disk_mb"`    // download_dir 所在磁盘至少保留的空间（MB），默认 2048，<0 关闭磁盘检查
+	DiskSizeFactor    float64  `toml:"disk_size_factor"`    // 下载前按 探测大小 × 系数 预留空间（合并、抽音频、水印会产生副本），默认 2.5
+	DiskWaitMinutes   int      `toml:"disk_wait_minutes"`   // 空间不足时下载最多等待的分钟数，超过后步骤失败，默认 30
+	CPUHeavySlots     int      `toml:"cpu_heavy_slots"`     // 同时运行的 CPU 密集步骤数，默认 CPU 核数的一半（至少 1）
+	CPUHeavySteps     []string `toml:"cpu_heavy_steps"`     // CPU 密集步骤，默认 ExtractAudio、AddWatermark；本地 whisper 转写自动按片段占用名额，无需加入 Transcribe
+	MaxCPUPercent     float64  `toml:"max_cpu_percent"`     // CPU 使用率达到该值时暂停取新任务，0 表示不检查
+	DownloadSlots     int      `toml:"download_slots"`      // 同时进行的视频下载数，默认 2
+	DownloadRateLimit string   `toml:"download_rate_limit"` // 单个下载的限速，传给 yt-dlp --limit-rate（如 "5M"），为空不限速
+}
+
+// ReviewConfig 发布前人工审核（[workflow.review]）。
+// 用户设置 review_before_upload 优先于这里的默认值。
+type ReviewConfig struct {
+	Required bool `toml:"required"` // 处理完成后是否默认等待人工审核
+}
+
+// ASRConfig 语音识别配置（[workflow.asr]）。
+// 用户设置 asr_provider / whisper_options / transcript_source_order 可按用户覆盖。
+type ASRConfig struct {
+	Provider string            `toml:"provider"` // 首选引擎：bcut（默认，B 站必剪接口）/ whisper / whisper_api / whisper_local
+	Fallback []string          `toml:"fallback"` // 首选引擎失败或结果不合格时依次尝试的引擎，如 ["whisper_api", "whisper_local"]
+	// 字幕来源优先级：manual（作者上传的平台字幕）/ asr（上面的转写引擎）/ auto（平台自动字幕），
+	// 默认 ["manual", "asr", "auto"]；省略的来源不使用。平台字幕目前仅支持 YouTube
+	SourceOrder []string `toml:"source_order"`
+	Whisper  WhisperASRConfig  `toml:"whisper"`
+	Chunking ASRChunkingConfig `toml:"chunking"`
+	Quality  ASRQualityConfig  `toml:"quality"`
+	Resegment ASRResegmentConfig `toml:"resegment"`
+}
+
+// ASRQualityConfig 转写结果质量检查（[workflow.asr.quality]），不合格时换下一个引擎。
+// 0 使用默认值，负数关闭该项检查。
+type ASRQualityConfig struct {
+	MinSegmentsPerMinute float64 `toml:"min_segments_per_minute"` // 每分钟音频至少的字幕条数（2 分钟以上的音频才检查），默认 1
+	MaxRepeatRatio       float64 `toml:"max_repeat_ratio"`        // 出现最多的同一句文本占比上限（幻觉循环），默认 0.5
+	MaxRepeatRun         int     `toml:"max_repeat_run"`          // 相同文本连续出现的条数上限，默认 4
+	SkipLanguageCheck    bool    `toml:"skip_language_check"`     // 不检查识别语言与翻译源语言是否一致
+}
+
+// ASRChunkingConfig 长音频分段转写（[workflow.asr.chunking]）。
+// 超过 max_chunk_minutes 的音频按静音位置切成约 chunk_minutes 的片段并行转写，再按全局时间轴拼接。
+type ASRChunkingConfig struct {
+	Disabled        bool    `toml:"disabled"`          // 关闭分段，整段提交给转写引擎
+	ChunkMinutes    float64 `toml:"chunk_minutes"`     // 目标片段长度（分钟），默认 10
+	MaxChunkMinutes float64 `toml:"max_chunk_minutes"` // 片段最大长度（分钟），附近没有静音时在目标位置硬切，默认 chunk_minutes × 1.5
+	Concurrency     int     `toml:"concurrency"`       // 同时转写的片段数，默认 3
+	SilenceNoiseDB  float64 `toml:"silence_noise_db"`  // 静音判定阈值（dB），默认 -35
+	SilenceSeconds  float64 `toml:"silence_seconds"`   // 最短静音时长（秒），默认 0.4
+	OverlapSeconds  float64 `toml:"overlap_seconds"`   // 硬切处前后片段的重叠（秒），重复文本拼接时去除，默认 2
+}
+
+// ASRResegmentConfig 字幕重新分段（[workflow.asr.resegment]）。
+// 转写结果在翻译前按标点、停顿与词级时间戳重新切分为易读的字幕，中日韩文字使用单独的长度与语速上限。0 使用默认值。
+type ASRResegmentConfig struct {
+	Disabled             bool    `toml:"disabled"`                 // 关闭重新分段，保留引擎原始分段
+	MaxLineChars         int     `toml:"max_line_chars"`           // 每条字幕最多字符数（拉丁文字），默认 42
+	MaxLineCharsCJK      int     `toml:"max_line_chars_cjk"`       // 每条字幕最多字符数（中日韩），默认 18
+	MaxCueSeconds        float64 `toml:"max_cue_seconds"`          // 单条字幕最长时长（秒），默认 7
+	MinCueSeconds        float64 `toml:"min_cue_seconds"`          // 更短的字幕视为碎片，与相邻字幕合并或延长，默认 1
+	MinGapSeconds        float64 `toml:"min_gap_seconds"`          // 相邻字幕之间保留的间隔（秒），默认 0.08
+	MaxCharsPerSecond    float64 `toml:"max_chars_per_second"`     // 阅读速度上限（拉丁文字），不足时延长字幕，默认 20
+	MaxCharsPerSecondCJK float64 `toml:"max_chars_per_second_cjk"` // 阅读速度上限（中日韩），默认 9
+	MergeGapSeconds      float64 `toml:"merge_gap_seconds"`        // 碎片只与间隔不超过该值的字幕合并，默认 0.8
+}
+
+// WhisperASRConfig whisper 转写配置（[workflow.asr.whisper]）。
+// 配置 api_url 时调用 OpenAI 兼容接口，否则调用本地 whisper.cpp 的 whisper-cli。
+type WhisperASRConfig struct {
+	BinaryPath     string  `toml:"binary_path"`     // whisper-cli 路径，默认 <model_dir>/whisper-cli，不存在时从 PATH 查找
+	ModelDir       string  `toml:"model_dir"`       // 模型目录，模型文件名为 ggml-<model>.bin
+	Model          string  `toml:"model"`           // 模型名，如 base、small、large-v3，默认 base
+	ModelPath      string  `toml:"model_path"`      // 模型文件路径，优先于 model
+	Language       string  `toml:"language"`        // 源语言代码，为空或 auto 时自动检测
+	Threads        int     `toml:"threads"`         // 解码线程数，0 使用 whisper-cli 默认值
+	BeamSize       int     `toml:"beam_size"`       // beam search 宽度，0 使用默认值
+	Prompt         string  `toml:"prompt"`          // 初始提示词：专有名词、术语、标点风格
+	WordTimestamps bool    `toml:"word_timestamps"` // 输出词级时间戳
+	VAD            bool    `toml:"vad"`             // 开启语音活动检测，跳过静音与背景音乐
+	VADModel       string  `toml:"vad_model"`       // VAD 模型文件，开启 vad 时必填
+	VADThreshold   float64 `toml:"vad_threshold"`   // VAD 语音概率阈值（0-1），0 使用默认值
+	APIURL         string  `toml:"api_url"`         // OpenAI 兼容接口地址（如 WhisperX），配置后不再调用本地 whisper-cli
+	APIKey         string  `toml:"api_key"`
+	APIModel       string  `toml:"api_model"` // 接口使用的模型，默认 whisper-1
+}
+
+// StepTimeoutConfig 单个步骤的超时配置。
+// 实际超时 = base_seconds + 视频时长(分钟) × per_video_minute_seconds，且不超过 max_seconds。
+type StepTimeoutConfig struct {
+	BaseSeconds           int     `toml:"base_seconds"`             // 基础超时（秒），<0 表示不限制
+	PerVideoMinuteSeconds float64 `toml:"per_video_minute_seconds"` // 每分钟视频追加的秒数
+	MaxSeconds            int     `toml:"max_seconds"`              // 超时上限（秒），0 表示不设上限
 }
 
 // GetDSN returns driver DSN for the configured DB type.
@@ -453,6 +623,12 @@ func LoadAppConfig() (*AppConfig, error) {
 	if err := validateAuthConfig(cfg); err != nil {
 		return nil, err
 	}
+	if err := applyWorkerEnv(&cfg.Worker); err != nil {
+		return nil, err
+	}
+	if err := validateNotificationsConfig(&cfg.Notifications); err != nil {
+		return nil, err
+	}
 
 	propagateLLMToAgent(cfg)
 
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// NotificationsConfig 事件通知配置（[notifications]）
+type NotificationsConfig struct {
+	Webhooks []WebhookConfig `toml:"webhooks"`
+}
+
+// WebhookConfig 一个外发 Webhook（[[notifications.webhooks]]）。
+// 工作流事件以 JSON POST 到 URL；配置了 Secret 时附带 HMAC-SHA256 签名头。
+type WebhookConfig struct {
+	URL    string   `toml:"url"`
+	Events []string `toml:"events"`  // 事件类型，支持 "step.*" 前缀通配；为空时推送除 step.progress 外的所有事件
+	UserID string   `toml:"user_id"` // 只推送该用户的视频事件，为空表示全部
+	Secret string   `toml:"secret"`
+}
+
+func validateNotificationsConfig(cfg *NotificationsConfig) error {
+	for i, hook := range cfg.Webhooks {
+		parsed, err := url.Parse(strings.TrimSpace(hook.URL))
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("notifications.webhooks[%d]: invalid url %q", i, hook.URL)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// 运行模式
+const (
+	WorkerModeAll    = "all"    // HTTP API + 后台处理（单实例默认）
+	WorkerModeAPI    = "api"    // 只提供 HTTP API，提交的视频排队交给 worker 实例处理
+	WorkerModeWorker = "worker" // 只运行后台处理，不监听 HTTP 端口
+)
+
+const (
+	defaultLeaseSeconds         = 120
+	defaultShutdownGraceSeconds = 60
+	// shutdownSlack 停止应用时在排空宽限期之外，留给取消中的任务退出与 HTTP 服务器等其他组件关闭的时间
+	shutdownSlack = 30 * time.Second
+)
+
+// WorkerConfig 多实例部署配置（[worker]）。
+// 多个实例共享同一 MySQL/PostgreSQL 时，后台处理以租约认领待处理视频，
+// 实例崩溃后过期的租约由其他实例回收。环境变量 YTB2BILI_WORKER_MODE / YTB2BILI_WORKER_ID 可覆盖配置文件。
+type WorkerConfig struct {
+	Mode         string `toml:"mode"`          // all / api / worker，默认 all
+	ID           string `toml:"id"`            // 实例标识，写入租约；默认 主机名-进程号
+	LeaseSeconds int    `toml:"lease_seconds"` // 租约有效期（秒），默认 120，处理中每 1/3 有效期续约一次
+
+	// 停止时等待处理中的视频在步骤边界停下的时长（秒），默认 60；超时后取消仍在执行的步骤
+	ShutdownGraceSeconds int `toml:"shutdown_grace_seconds"`
+}
+
+// NormalizedMode 返回小写的运行模式，未配置时为 all
+func (c WorkerConfig) NormalizedMode() string {
+	mode := strings.ToLower(strings.TrimSpace(c.Mode))
+	if mode == "" {
+		return WorkerModeAll
+	}
+	return mode
+}
+
+// RunsAPI 本实例是否监听 HTTP API
+func (c WorkerConfig) RunsAPI() bool {
+	return c.NormalizedMode() != WorkerModeWorker
+}
+
+// RunsWorker 本实例是否运行后台处理（轮询待处理视频、订阅同步、自动上传等）
+func (c WorkerConfig) RunsWorker() bool {
+	return c.NormalizedMode() != WorkerModeAPI
+}
+
+// InstanceID 返回实例标识，未配置时使用 主机名-进程号
+func (c WorkerConfig) InstanceID() string {
+	if id := strings.TrimSpace(c.ID); id != "" {
+		return id
+	}
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "ytb2bili"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// LeaseTTL 返回租约有效期
+func (c WorkerConfig) LeaseTTL() time.Duration {
+	if c.LeaseSeconds <= 0 {
+		return defaultLeaseSeconds * time.Second
+	}
+	return time.Duration(c.LeaseSeconds) * time.Second
+}
+
+// ShutdownGrace 返回停止时排空处理中视频的宽限期
+func (c WorkerConfig) ShutdownGrace() time.Duration {
+	if c.ShutdownGraceSeconds <= 0 {
+		return defaultShutdownGraceSeconds * time.Second
+	}
+	return time.Duration(c.ShutdownGraceSeconds) * time.Second
+}
+
+// ShutdownTimeout 返回停止应用的总超时：排空宽限期加上其他组件的关闭时间
+func (c WorkerConfig) ShutdownTimeout() time.Duration {
+	return c.ShutdownGrace() + shutdownSlack
+}
+
+func applyWorkerEnv(cfg *WorkerConfig) error {
+	if mode := firstNonEmptyEnv("YTB2BILI_WORKER_MODE"); mode != "" {
+		cfg.Mode = mode
+	}
+	if id := firstNonEmptyEnv("YTB2BILI_WORKER_ID"); id != "" {
+		cfg.ID = id
+	}
+	switch cfg.NormalizedMode() {
+	case WorkerModeAll, WorkerModeAPI, WorkerModeWorker:
+		return nil
+	default:
+		return fmt.Errorf("invalid [worker] mode %q: expected all, api or worker", cfg.Mode)
+	}
+}
@@ -0,0 +1,182 @@
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/difyz9/ytb2bili/pkg/store/model"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Type 事件类型
+type Type string
+
+const (
+	StepStarted     Type = "step.started"
+	StepProgress    Type = "step.progress"
+	StepCompleted   Type = "step.completed"
+	StepSkipped     Type = "step.skipped"
+	StepFailed      Type = "step.failed" // 失败或超时，Status 区分 failed / timeout
+	StepCancelled   Type = "step.cancelled"
+	VideoStatus     Type = "video.status"
+	UploadCompleted Type = "upload.completed"
+	UploadFailed    Type = "upload.failed"
+)
+
+// Event 工作流事件。步骤事件的 Status 为 tb_task_steps 的步骤状态，
+// video.status 事件的 Status 为 tb_videos 的视频状态码。
+type Event struct {
+	Type      Type      `json:"type"`
+	VideoID   string    `json:"video_id"`
+	UserID    string    `json:"user_id,omitempty"`
+	Step      string    `json:"step,omitempty"`
+	Status    string    `json:"status,omitempty"`
+	Percent   int       `json:"progress_percent,omitempty"`
+	Message   string    `json:"message,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	BVID      string    `json:"bvid,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// StepEventType 返回步骤结束状态对应的事件类型
+func StepEventType(status string) Type {
+	switch status {
+	case model.TaskStepStatusRunning:
+		return StepStarted
+	case model.TaskStepStatusCompleted:
+		return StepCompleted
+	case model.TaskStepStatusSkipped:
+		return StepSkipped
+	case model.TaskStepStatusCancelled, model.TaskStepStatusInterrupted:
+		return StepCancelled
+	default:
+		return StepFailed
+	}
+}
+
+// Filter 订阅过滤条件，返回 true 的事件才会投递
+type Filter func(Event) bool
+
+// ForVideo 只接收指定视频的事件
+func ForVideo(videoID string) Filter {
+	return func(e Event) bool { return e.VideoID == videoID }
+}
+
+// OfTypes 只接收指定类型的事件
+func OfTypes(types ...Type) Filter {
+	set := make(map[Type]struct{}, len(types))
+	for _, t := range types {
+		set[t] = struct{}{}
+	}
+	return func(e Event) bool {
+		_, ok := set[e.Type]
+		return ok
+	}
+}
+
+// Bus 进程内事件总线。Publish 从不阻塞：订阅者的缓冲区满时丢弃该订阅者的事件，
+// 避免慢订阅者（SSE 客户端、Webhook）拖慢工作流。nil 的 *Bus 可以安全调用。
+type Bus struct {
+	mu     sync.RWMutex
+	subs   map[*Subscription]struct{}
+	logger *zap.Logger
+}
+
+// NewBus 创建事件总线
+func NewBus(logger *zap.Logger) *Bus {
+	return &Bus{subs: make(map[*Subscription]struct{}), logger: logger}
+}
+
+// Publish 向所有匹配的订阅者投递事件；未设置 Timestamp 时取当前时间
+func (b *Bus) Publish(event Event) {
+	if b == nil || event.VideoID == "" {
+		return
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for sub := range b.subs {
+		if sub.filter != nil && !sub.filter(event) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			if sub.dropped.Add(1) == 1 {
+				b.logger.Warn("事件订阅者处理过慢，开始丢弃事件",
+					zap.String("subscriber", sub.name),
+					zap.String("video_id", event.VideoID),
+					zap.String("type", string(event.Type)))
+			}
+		}
+	}
+}
+
+// PublishVideoStatus 发布视频状态变化事件；userID 为空且 db 不为 nil 时从 tb_videos 查询
+func (b *Bus) PublishVideoStatus(db *gorm.DB, videoID, userID, status string) {
+	if b == nil || videoID == "" || status == "" {
+		return
+	}
+	if userID == "" && db != nil {
+		var video model.Video
+		if err := db.Select("user_id").Where("video_id = ?", videoID).Limit(1).Find(&video).Error; err == nil {
+			userID = video.UserID
+		}
+	}
+	b.Publish(Event{
+		Type:    VideoStatus,
+		VideoID: videoID,
+		UserID:  userID,
+		Status:  status,
+		Message: model.VideoStatusText(status),
+	})
+}
+
+// Subscribe 订阅匹配 filter 的事件（filter 为 nil 时接收全部），buffer 为缓冲区大小。
+// 使用完毕必须调用 Close；总线为 nil 时返回的订阅永远不会收到事件。
+func (b *Bus) Subscribe(name string, filter Filter, buffer int) *Subscription {
+	if buffer <= 0 {
+		buffer = 64
+	}
+	sub := &Subscription{name: name, filter: filter, ch: make(chan Event, buffer), bus: b}
+	sub.C = sub.ch
+	if b == nil {
+		return sub
+	}
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+	return sub
+}
+
+// Subscription 一个订阅；事件从 C 读取，Close 后 C 被关闭
+type Subscription struct {
+	C       <-chan Event
+	name    string
+	filter  Filter
+	ch      chan Event
+	bus     *Bus
+	dropped atomic.Int64
+	once    sync.Once
+}
+
+// Dropped 因缓冲区已满被丢弃的事件数
+func (s *Subscription) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Close 取消订阅并关闭 C（可重复调用）
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		if s.bus != nil {
+			s.bus.mu.Lock()
+			delete(s.bus.subs, s)
+			s.bus.mu.Unlock()
+		}
+		close(s.ch)
+	})
+}
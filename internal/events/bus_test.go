@@ -0,0 +1,54 @@
+package events
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestBus_FiltersAndDropsForSlowSubscribers(t *testing.T) {
+	bus := NewBus(zap.NewNop())
+	video := bus.Subscribe("video", ForVideo("v1"), 1)
+	defer video.Close()
+	uploads := bus.Subscribe("uploads", OfTypes(UploadCompleted), 4)
+	defer uploads.Close()
+
+	bus.Publish(Event{Type: StepStarted, VideoID: "v1", Step: "DownloadVideo"})
+	bus.Publish(Event{Type: StepCompleted, VideoID: "v1", Step: "DownloadVideo"}) // 缓冲区已满，丢弃
+	bus.Publish(Event{Type: UploadCompleted, VideoID: "v2", BVID: "BV1xx"})
+
+	if got := <-video.C; got.Type != StepStarted || got.Timestamp.IsZero() {
+		t.Fatalf("expected first event with timestamp, got %+v", got)
+	}
+	if video.Dropped() != 1 {
+		t.Fatalf("expected one dropped event, got %d", video.Dropped())
+	}
+	if got := <-uploads.C; got.VideoID != "v2" || got.BVID != "BV1xx" {
+		t.Fatalf("unexpected upload event %+v", got)
+	}
+	select {
+	case extra := <-uploads.C:
+		t.Fatalf("expected filter to drop step events, got %+v", extra)
+	default:
+	}
+
+	video.Close()
+	video.Close()
+	if _, open := <-video.C; open {
+		t.Fatal("expected closed subscription channel")
+	}
+	bus.Publish(Event{Type: StepStarted, VideoID: "v1"}) // 已取消的订阅不再投递
+}
+
+func TestBus_NilIsNoop(t *testing.T) {
+	var bus *Bus
+	bus.Publish(Event{Type: StepStarted, VideoID: "v1"})
+	bus.PublishVideoStatus(nil, "v1", "", "003")
+	sub := bus.Subscribe("nil", nil, 0)
+	select {
+	case event := <-sub.C:
+		t.Fatalf("expected no events from a nil bus, got %+v", event)
+	default:
+	}
+	sub.Close()
+}
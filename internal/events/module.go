@@ -0,0 +1,29 @@
+package events
+
+import (
+	"context"
+
+	"github.com/difyz9/ytb2bili/internal/config"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+// Module 提供进程内事件总线，并按 [notifications] 启动外发 Webhook
+var Module = fx.Module("events",
+	fx.Provide(NewBus),
+	fx.Invoke(startWebhookNotifier),
+)
+
+func startWebhookNotifier(lc fx.Lifecycle, cfg *config.AppConfig, bus *Bus, logger *zap.Logger) {
+	notifier := NewWebhookNotifier(cfg.Notifications, bus, logger)
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			notifier.Start()
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			notifier.Stop()
+			return nil
+		},
+	})
+}
@@ -0,0 +1,187 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/difyz9/ytb2bili/internal/config"
+	"go.uber.org/zap"
+)
+
+// Webhook 请求头
+const (
+	HeaderEvent     = "X-Ytb2bili-Event"
+	HeaderSignature = "X-Ytb2bili-Signature" // sha256=<hex(HMAC-SHA256(secret, body))>
+)
+
+const (
+	webhookAttempts = 3
+	webhookTimeout  = 10 * time.Second
+	webhookBuffer   = 256
+)
+
+// webhookRetryDelay 第 n 次失败后的等待时间（测试中可缩短）
+var webhookRetryDelay = func(attempt int) time.Duration {
+	return time.Duration(attempt) * time.Second
+}
+
+// Sign 计算 Webhook 请求体签名
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Deliver 以 JSON POST 投递一次 Webhook；网络错误、5xx 与 429 最多重试 3 次，其余 4xx 不重试。
+// secret 为空时不签名。
+func Deliver(ctx context.Context, client *http.Client, url, secret, event string, payload any) error {
+	body, err := sonic.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("encode webhook payload: %w", err)
+	}
+	var lastErr error
+	for attempt := 1; attempt <= webhookAttempts; attempt++ {
+		retry, err := postWebhook(ctx, client, url, secret, event, body)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retry || attempt == webhookAttempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(webhookRetryDelay(attempt)):
+		}
+	}
+	return lastErr
+}
+
+func postWebhook(ctx context.Context, client *http.Client, url, secret, event string, body []byte) (retry bool, err error) {
+	reqCtx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, event)
+	if secret != "" {
+		req.Header.Set(HeaderSignature, Sign(secret, body))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry = resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+}
+
+// WebhookNotifier 把总线上的事件推送到 [[notifications.webhooks]] 配置的地址。
+// 每个 Webhook 独立订阅、按顺序投递，一个地址变慢不影响其他地址。
+type WebhookNotifier struct {
+	hooks  []config.WebhookConfig
+	bus    *Bus
+	client *http.Client
+	logger *zap.Logger
+
+	cancel context.CancelFunc
+	subs   []*Subscription
+	wg     sync.WaitGroup
+}
+
+// NewWebhookNotifier 创建 Webhook 推送器
+func NewWebhookNotifier(cfg config.NotificationsConfig, bus *Bus, logger *zap.Logger) *WebhookNotifier {
+	return &WebhookNotifier{
+		hooks:  cfg.Webhooks,
+		bus:    bus,
+		client: &http.Client{},
+		logger: logger,
+	}
+}
+
+// Start 为每个 Webhook 启动订阅与投递协程
+func (n *WebhookNotifier) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	n.cancel = cancel
+	for _, hook := range n.hooks {
+		sub := n.bus.Subscribe("webhook:"+hook.URL, webhookFilter(hook), webhookBuffer)
+		n.subs = append(n.subs, sub)
+		n.wg.Add(1)
+		go n.run(ctx, hook, sub)
+	}
+	if len(n.hooks) > 0 {
+		n.logger.Info("Webhook 通知已启用", zap.Int("webhooks", len(n.hooks)))
+	}
+}
+
+// Stop 取消订阅并放弃正在重试的投递
+func (n *WebhookNotifier) Stop() {
+	if n.cancel != nil {
+		n.cancel()
+	}
+	for _, sub := range n.subs {
+		sub.Close()
+	}
+	n.wg.Wait()
+}
+
+func (n *WebhookNotifier) run(ctx context.Context, hook config.WebhookConfig, sub *Subscription) {
+	defer n.wg.Done()
+	for event := range sub.C {
+		if err := Deliver(ctx, n.client, hook.URL, hook.Secret, string(event.Type), event); err != nil && ctx.Err() == nil {
+			n.logger.Warn("Webhook 推送失败",
+				zap.String("url", hook.URL),
+				zap.String("video_id", event.VideoID),
+				zap.String("type", string(event.Type)),
+				zap.Error(err))
+		}
+	}
+}
+
+// webhookFilter 按 events（支持 "step.*" 通配）与 user_id 过滤；未配置 events 时不推送高频的 step.progress
+func webhookFilter(hook config.WebhookConfig) Filter {
+	patterns := make([]string, 0, len(hook.Events))
+	for _, pattern := range hook.Events {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	userID := strings.TrimSpace(hook.UserID)
+	return func(e Event) bool {
+		if userID != "" && e.UserID != userID {
+			return false
+		}
+		if len(patterns) == 0 {
+			return e.Type != StepProgress
+		}
+		return MatchType(patterns, e.Type)
+	}
+}
+
+// MatchType 判断事件类型是否匹配任一模式；模式为完整类型、"*" 或以 ".*" 结尾的前缀
+func MatchType(patterns []string, t Type) bool {
+	for _, pattern := range patterns {
+		switch {
+		case pattern == "*" || pattern == string(t):
+			return true
+		case strings.HasSuffix(pattern, ".*") && strings.HasPrefix(string(t), strings.TrimSuffix(pattern, "*")):
+			return true
+		}
+	}
+	return false
+}
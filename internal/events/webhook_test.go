@@ -0,0 +1,81 @@
+package events
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/difyz9/ytb2bili/internal/config"
+	"go.uber.org/zap"
+)
+
+func TestDeliver_SignsAndRetriesServerErrors(t *testing.T) {
+	webhookRetryDelay = func(int) time.Duration { return time.Millisecond }
+	defer func() {
+		webhookRetryDelay = func(attempt int) time.Duration { return time.Duration(attempt) * time.Second }
+	}()
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get(HeaderEvent) != "step.failed" || r.Header.Get(HeaderSignature) != Sign("s3cret", body) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	err := Deliver(context.Background(), server.Client(), server.URL, "s3cret", "step.failed", Event{Type: StepFailed, VideoID: "v1"})
+	if err != nil || calls.Load() != 2 {
+		t.Fatalf("expected delivery on second attempt, err=%v calls=%d", err, calls.Load())
+	}
+
+	// 4xx 不重试
+	calls.Store(0)
+	if err := Deliver(context.Background(), server.Client(), server.URL, "wrong", "step.failed", nil); err == nil {
+		t.Fatal("expected a rejected signature to fail")
+	}
+}
+
+func TestWebhookNotifier_FiltersByPatternAndUser(t *testing.T) {
+	received := make(chan string, 8)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get(HeaderEvent)
+	}))
+	defer server.Close()
+
+	bus := NewBus(zap.NewNop())
+	notifier := NewWebhookNotifier(config.NotificationsConfig{Webhooks: []config.WebhookConfig{
+		{URL: server.URL, Events: []string{"upload.*", "video.status"}, UserID: "u1"},
+	}}, bus, zap.NewNop())
+	notifier.Start()
+
+	bus.Publish(Event{Type: StepCompleted, VideoID: "v1", UserID: "u1"})
+	bus.Publish(Event{Type: UploadCompleted, VideoID: "v2", UserID: "u2"})
+	bus.Publish(Event{Type: UploadFailed, VideoID: "v1", UserID: "u1"})
+	bus.Publish(Event{Type: VideoStatus, VideoID: "v1", UserID: "u1", Status: "003"})
+
+	for _, want := range []string{string(UploadFailed), string(VideoStatus)} {
+		select {
+		case got := <-received:
+			if got != want {
+				t.Fatalf("expected %s, got %s", want, got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for %s", want)
+		}
+	}
+	notifier.Stop()
+	if len(received) != 0 {
+		t.Fatalf("expected filtered events not to be delivered, got %s", <-received)
+	}
+}
@@ -33,8 +33,16 @@ func UploadVideoToBilibili(ctx context.Context, logger *zap.Logger, biliService
 		return nil, fmt.Errorf("缺少用户ID")
 	}
 
+	// 上传结果事件以数据库中的 video_id 发布
+	ctx = workflow.WithVideoID(ctx, video.VideoID)
+	previous, err := biliService.BeginUpload(video)
+	if err != nil {
+		// 状态不允许进入 uploading（如仍在处理中）时不阻止手动投稿，只是不记录投稿阶段
+		logger.Warn("标记视频投稿中失败", zap.String("video_id", video.VideoID), zap.Error(err))
+	}
 	result, err := biliChain.RunFromVideoPath(ctx, userID, video.VideoPath, video.URL, overrides)
 	if err != nil {
+		biliService.AbortUpload(video, previous, err)
 		return nil, err
 	}
 
@@ -45,6 +53,7 @@ func UploadVideoToBilibili(ctx context.Context, logger *zap.Logger, biliService
 		Description: result.Description,
 		Tags:        result.Tags,
 	}); err != nil {
+		biliService.AbortUpload(video, previous, err)
 		return nil, err
 	}
 
@@ -4,7 +4,7 @@
 //   - 飞书通过 HTTP 事件回调（POST /webhook/feishu）推送消息
 //   - 收到 im.message.receive_v1 事件后，解析 YouTube URL
 //   - 立即 Reply "⏳ 处理中..."，然后异步触发 YouTubeChain 工作流
-//   - 处理完成后通过飞书 API 发送结果通知
+//   - 处理完成后通过飞书 API 发送结果通知；之后上传B站的结果由事件总线推送
 //
 // 前置配置（config.toml）：
 //
@@ -34,9 +34,11 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/difyz9/ytb2bili/internal/config"
+	"github.com/difyz9/ytb2bili/internal/events"
 	"github.com/difyz9/ytb2bili/internal/service"
 	"github.com/difyz9/ytb2bili/internal/workflow"
 	"github.com/difyz9/ytb2bili/pkg/store/model"
+	"go.uber.org/fx"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
@@ -54,6 +56,7 @@ type FeishuHandler struct {
 	youtubeChain *workflow.YouTubeChain
 	userSettings *service.UserSettingsClient
 	videoService *service.VideoService
+	events       *events.Bus
 	logger       *zap.Logger
 
 	// tenant_access_token cache (thread-safe)
@@ -68,16 +71,18 @@ type FeishuHandlerParams struct {
 	Chain        *workflow.YouTubeChain
 	UserSettings *service.UserSettingsClient
 	VideoService *service.VideoService
+	Events       *events.Bus
 	Logger       *zap.Logger
 }
 
 // NewFeishuHandler creates a FeishuHandler.
-func NewFeishuHandler(appCfg *config.AppConfig, chain *workflow.YouTubeChain, userSettings *service.UserSettingsClient, videoService *service.VideoService, logger *zap.Logger) *FeishuHandler {
+func NewFeishuHandler(appCfg *config.AppConfig, chain *workflow.YouTubeChain, userSettings *service.UserSettingsClient, videoService *service.VideoService, bus *events.Bus, logger *zap.Logger) *FeishuHandler {
 	return &FeishuHandler{
 		cfg:          &appCfg.Feishu,
 		youtubeChain: chain,
 		userSettings: userSettings,
 		videoService: videoService,
+		events:       bus,
 		logger:       logger.With(zap.String("handler", "feishu")),
 	}
 }
@@ -220,20 +225,26 @@ func (h *FeishuHandler) handleIMMessage(event feishuEvent) {
 				Platform:      "youtube",
 				Status:        model.VideoStatusProcessing,
 				OperationType: "feishu",
+				Priority:      model.VideoPriorityManual,
 			}
 			if err := h.videoService.GetDB().Create(newVideo).Error; err != nil {
 				h.logger.Warn("创建视频记录失败", zap.Error(err))
 			}
-		} else {
-			h.videoService.GetDB().Model(&existing).Updates(map[string]interface{}{
-				"status":         model.VideoStatusProcessing,
+		} else if _, err := model.TransitionVideoStatus(h.videoService.GetDB(), videoID, model.VideoTransition{
+			To: model.VideoStatusProcessing,
+			Updates: map[string]interface{}{
 				"operation_type": "feishu",
-			})
+				"priority":       model.VideoPriorityManual,
+			},
+			Reason: "飞书提交", Actor: openID,
+		}); err != nil {
+			h.logger.Warn("更新视频状态失败", zap.Error(err))
 		}
 	}
 
 	// Run the full YouTube workflow pipeline (may take several minutes).
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	// 不设整体超时：各步骤按 [workflow.step_timeouts] 单独限时。
+	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	ctx = workflow.WithUserID(ctx, openID)
 	preferences := ResolveVideoProcessingPreferences(ctx, h.userSettings, openID, "", nil, nil)
@@ -271,9 +282,7 @@ func (h *FeishuHandler) handleIMMessage(event feishuEvent) {
 			zap.String("url", ytURL),
 			zap.Error(processErr))
 		if videoID != "" {
-			h.videoService.GetDB().Model(&model.Video{}).
-				Where("video_id = ?", videoID).
-				Update("status", model.VideoStatusFailed)
+			h.videoService.MarkStatus(context.Background(), videoID, model.VideoStatusFailed)
 		}
 		errMsg := fmt.Sprintf("❌ 视频处理失败\n🔗 %s\n\n原因：%v", ytURL, processErr)
 		if err := h.sendMessage(openID, errMsg); err != nil {
@@ -289,6 +298,68 @@ func (h *FeishuHandler) handleIMMessage(event feishuEvent) {
 	}
 }
 
+// ─────────────────────────────────────────────────────────────────────────────
+// Upload notifications
+// ─────────────────────────────────────────────────────────────────────────────
+
+// startFeishuUploadNotifications 飞书启用时订阅上传结果事件：
+// 通过飞书提交的视频（自动上传或手动上传）上传B站后通知提交者。
+func startFeishuUploadNotifications(lc fx.Lifecycle, h *FeishuHandler) {
+	if !h.cfg.Enabled {
+		return
+	}
+	var sub *events.Subscription
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			sub = h.events.Subscribe("feishu", events.OfTypes(events.UploadCompleted, events.UploadFailed), 64)
+			go h.notifyUploads(sub)
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			sub.Close()
+			return nil
+		},
+	})
+}
+
+func (h *FeishuHandler) notifyUploads(sub *events.Subscription) {
+	for event := range sub.C {
+		video, err := h.videoService.GetByVideoID(context.Background(), event.VideoID)
+		if err != nil || video.OperationType != "feishu" || video.UserID == "" {
+			continue
+		}
+		if err := h.sendMessage(video.UserID, buildUploadMessage(video, event)); err != nil {
+			h.logger.Warn("发送飞书上传通知失败", zap.String("video_id", event.VideoID), zap.Error(err))
+		}
+	}
+}
+
+func buildUploadMessage(video *model.Video, event events.Event) string {
+	var sb strings.Builder
+	title := event.Message
+	if title == "" {
+		title = video.Title
+	}
+	if event.Type == events.UploadFailed {
+		sb.WriteString("❌ 上传B站失败\n")
+	} else {
+		sb.WriteString("✅ 已上传到B站！\n")
+	}
+	if title != "" {
+		sb.WriteString(fmt.Sprintf("📌 标题：%s\n", title))
+	}
+	if event.BVID != "" {
+		sb.WriteString(fmt.Sprintf("🎬 B站视频：https://www.bilibili.com/video/%s\n", event.BVID))
+	}
+	if video.URL != "" {
+		sb.WriteString(fmt.Sprintf("🔗 %s\n", video.URL))
+	}
+	if event.Error != "" {
+		sb.WriteString(fmt.Sprintf("\n原因：%s", event.Error))
+	}
+	return sb.String()
+}
+
 func buildSuccessMessage(ytURL string, result *workflow.VideoContext) string {
 	var sb strings.Builder
 	sb.WriteString("✅ 视频处理完成！\n")
@@ -24,6 +24,7 @@ var Module = fx.Module("handler",
 	fx.Provide(NewLocalAuthHandler),
 	fx.Provide(NewUserHandler),
 	fx.Provide(NewSystemSettingsHandler),
+	fx.Provide(NewStepCacheHandler),
 	fx.Provide(NewUserSettingsHandler),
 	fx.Provide(NewAccountBindingHandler),
 	fx.Provide(NewCookiesHandler),
@@ -44,4 +45,7 @@ var Module = fx.Module("handler",
 
 	// ── Single route-wiring invocation ────────────────────────────────────
 	fx.Invoke(registerRoutes),
+
+	// ── Event subscribers ─────────────────────────────────────────────────
+	fx.Invoke(startFeishuUploadNotifications),
 )
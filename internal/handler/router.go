@@ -42,6 +42,7 @@ type RouterParams struct {
 	Updater          *UpdaterHandler
 	User             *UserHandler
 	SystemSettings   *SystemSettingsHandler
+	StepCache        *StepCacheHandler
 	UserSettings     *UserSettingsHandler
 	Video            *VideoHandler
 	VideoProcess     *VideoProcessHandler
@@ -92,6 +93,7 @@ func registerRoutes(p RouterParams) {
 	p.BiliAccount.RegisterRoutesWithAuth(r, authMid)
 	p.User.RegisterRoutes(r)
 	p.SystemSettings.RegisterRoutes(r)
+	p.StepCache.RegisterRoutes(r)
 	p.UserSettings.RegisterRoutes(r)
 	p.AccountBinding.RegisterRoutes(r)
 	p.Cookies.RegisterRoutes(r)
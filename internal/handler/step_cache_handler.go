@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/difyz9/ytb2bili/internal/config"
+	"github.com/difyz9/ytb2bili/internal/middleware"
+	"github.com/difyz9/ytb2bili/internal/workflow"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// StepCacheHandler 步骤结果缓存的管理接口：查看统计、清理条目
+type StepCacheHandler struct {
+	cache     *workflow.StepCache
+	logger    *zap.Logger
+	jwtSecret string
+}
+
+const stepCacheDBTimeout = 30 * time.Second
+
+func NewStepCacheHandler(cache *workflow.StepCache, logger *zap.Logger, cfg *config.AppConfig) *StepCacheHandler {
+	jwtSecret := ""
+	if cfg != nil {
+		jwtSecret = strings.TrimSpace(cfg.Auth.JWTSecret)
+	}
+	return &StepCacheHandler{cache: cache, logger: logger, jwtSecret: jwtSecret}
+}
+
+// GetStats 按步骤返回缓存条目数、占用大小与命中次数
+func (h *StepCacheHandler) GetStats(c *gin.Context) {
+	if h.cache == nil {
+		Success(c, gin.H{"enabled": false, "steps": []workflow.StepCacheStats{}})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), stepCacheDBTimeout)
+	defer cancel()
+
+	stats, err := h.cache.Stats(ctx)
+	if err != nil {
+		h.logger.Error("读取步骤缓存统计失败", zap.Error(err))
+		InternalServerError(c, "读取步骤缓存统计失败")
+		return
+	}
+	Success(c, gin.H{"enabled": true, "steps": stats})
+}
+
+// Purge 清理缓存条目。
+// 查询参数：step 只清理指定步骤；older_than_days 只清理早于该天数的条目。
+func (h *StepCacheHandler) Purge(c *gin.Context) {
+	if h.cache == nil {
+		BadRequest(c, "步骤缓存未启用")
+		return
+	}
+
+	var olderThan time.Duration
+	if raw := strings.TrimSpace(c.Query("older_than_days")); raw != "" {
+		days, err := strconv.Atoi(raw)
+		if err != nil || days < 0 {
+			BadRequest(c, "older_than_days 必须为非负整数")
+			return
+		}
+		olderThan = time.Duration(days) * 24 * time.Hour
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), stepCacheDBTimeout)
+	defer cancel()
+
+	deleted, err := h.cache.Purge(ctx, c.Query("step"), olderThan)
+	if err != nil {
+		h.logger.Error("清理步骤缓存失败", zap.Error(err))
+		InternalServerError(c, "清理步骤缓存失败")
+		return
+	}
+	h.logger.Info("已清理步骤缓存",
+		zap.String("step", c.Query("step")),
+		zap.Duration("older_than", olderThan),
+		zap.Int64("deleted", deleted))
+	Success(c, gin.H{"deleted": deleted})
+}
+
+func (h *StepCacheHandler) RegisterRoutes(r *gin.Engine) {
+	group := r.Group("/api/v1/system/step-cache")
+	group.Use(middleware.AnyAuthMiddleware(h.jwtSecret))
+	{
+		group.GET("", h.GetStats)
+		group.DELETE("", h.Purge)
+	}
+}
@@ -147,11 +147,12 @@ func (h *SubtitleHandler) saveVideoSubtitles(c *gin.Context) {
 		}
 	}
 		existingVideo.SavedAt = req.SavedAt
-		existingVideo.Status = model.VideoStatusPending // 重置状态为待处理
+		existingVideo.Priority = model.VideoPriorityFor(req.OperationType, req.PlaylistID)
 		existingVideo.DeletedAt = gorm.DeletedAt{} // 恢复记录（清除删除标记）
 
 		// 更新到数据库（使用 Unscoped 以便更新已删除的记录）
-		if err := h.DB.Unscoped().Save(&existingVideo).Error; err != nil {
+		// 状态经状态机重置为待处理，这里不直接覆盖
+		if err := h.DB.Unscoped().Omit("status").Save(&existingVideo).Error; err != nil {
 			fmt.Printf("更新视频失败，字幕数据长度: %d\n", len(subtitlesJSONStr))
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"success": false,
@@ -159,6 +160,13 @@ func (h *SubtitleHandler) saveVideoSubtitles(c *gin.Context) {
 			})
 			return
 		}
+		if _, err := model.TransitionVideoStatus(h.DB, videoID, model.VideoTransition{
+			To: model.VideoStatusQueued, Reason: "重新提交字幕", Actor: existingVideo.UserID,
+		}); err != nil {
+			fmt.Printf("⚠️ 重置视频状态失败: %v\n", err)
+		} else {
+			existingVideo.Status = model.VideoStatusQueued
+		}
 		savedVideo = &existingVideo
 		
 		if existingVideo.DeletedAt.Valid {
@@ -181,6 +189,7 @@ func (h *SubtitleHandler) saveVideoSubtitles(c *gin.Context) {
 			Status:        model.VideoStatusPending,
 			Description:   req.Description,
 			OperationType: req.OperationType,
+			Priority:      model.VideoPriorityFor(req.OperationType, req.PlaylistID),
 			Subtitles:     subtitlesJSONStr,
 			PlaylistID:    req.PlaylistID,
 			Timestamp:     timestamp,
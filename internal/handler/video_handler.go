@@ -14,6 +14,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/difyz9/ytb2bili/internal/analytics"
 	"github.com/difyz9/ytb2bili/internal/config"
+	"github.com/difyz9/ytb2bili/internal/events"
 	"github.com/difyz9/ytb2bili/internal/service"
 	"github.com/difyz9/ytb2bili/internal/workflow"
 	bili "github.com/difyz9/ytb2bili/pkg/bilibili"
@@ -33,6 +34,7 @@ type VideoHandler struct {
 	analytics        *analytics.Client
 	userSettings     *service.UserSettingsClient
 	cfg              *config.AppConfig
+	events           *events.Bus
 }
 
 func NewVideoHandler(
@@ -45,6 +47,7 @@ func NewVideoHandler(
 	analyticsClient *analytics.Client,
 	userSettings *service.UserSettingsClient,
 	cfg *config.AppConfig,
+	bus *events.Bus,
 ) *VideoHandler {
 	return &VideoHandler{
 		logger:        logger,
@@ -56,6 +59,7 @@ func NewVideoHandler(
 		analytics:     analyticsClient,
 		userSettings:  userSettings,
 		cfg:           cfg,
+		events:        bus,
 	}
 }
 
@@ -71,6 +75,7 @@ func (h *VideoHandler) RegisterRoutesWithAuth(r *gin.Engine, authMid gin.Handler
 		api.POST("", h.createVideo)
 		api.GET("", h.listVideos)
 		api.GET("counts", h.taskCounts)
+		api.GET("step-stats", h.stepStats)
 		api.GET(":id", h.getVideo)
 		api.GET(":id/events", h.StreamVideoEvents)
 		api.GET(":id/file", h.serveVideoFile)
@@ -85,6 +90,7 @@ func (h *VideoHandler) RegisterRoutesWithAuth(r *gin.Engine, authMid gin.Handler
 	authGroup.POST(":id/upload-bilibili", h.uploadToBilibili)
 	authGroup.POST(":id/resume", h.resumeVideo)
 	authGroup.POST(":id/stop", h.stopVideo)
+	h.registerReviewRoutes(authGroup)
 }
 
 // ── CRUD ─────────────────────────────────────────────────────────────────────
@@ -115,6 +121,22 @@ func (h *VideoHandler) taskCounts(c *gin.Context) {
 	Success(c, counts)
 }
 
+// stepStats 最近 days 天（默认 7）各步骤的失败率与平均耗时
+func (h *VideoHandler) stepStats(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "7"))
+	if err != nil || days <= 0 {
+		BadRequest(c, "无效的days")
+		return
+	}
+	stats, err := h.videoService.StepAttemptStats(c.Request.Context(), time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		h.logger.Error("查询步骤统计失败", zap.Error(err))
+		InternalServerError(c, "查询步骤统计失败")
+		return
+	}
+	Success(c, gin.H{"days": days, "steps": stats})
+}
+
 func (h *VideoHandler) listVideos(c *gin.Context) {
 	userID := c.Query("user_id")
 	sourceType := c.Query("source_type")
@@ -164,7 +186,16 @@ func (h *VideoHandler) getVideo(c *gin.Context) {
 		video.UserID = c.GetString("uid")
 	}
 
-	Success(c, service.VideoWithStepsWrapper{Video: *video, TaskSteps: steps})
+	runs, err := h.videoService.ListRuns(c.Request.Context(), video.VideoID, 20)
+	if err != nil {
+		h.logger.Warn("查询运行历史失败", zap.String("video_id", video.VideoID), zap.Error(err))
+	}
+	history, err := h.videoService.StatusHistory(c.Request.Context(), video.VideoID, 50)
+	if err != nil {
+		h.logger.Warn("查询状态历史失败", zap.String("video_id", video.VideoID), zap.Error(err))
+	}
+
+	Success(c, service.VideoWithStepsWrapper{Video: *video, TaskSteps: steps, Runs: runs, StatusHistory: history})
 }
 
 func (h *VideoHandler) updateVideo(c *gin.Context) {
@@ -287,6 +318,10 @@ func (h *VideoHandler) retryTaskStep(c *gin.Context) {
 		NotFound(c, "视频不存在")
 		return
 	}
+	if h.processingSvc.IsTaskRunning(video.VideoID) {
+		BadRequest(c, "任务正在运行中，请先停止")
+		return
+	}
 
 	// Reset steps from restart point
 	if err := h.videoService.ResetStepsFrom(c.Request.Context(), video.VideoID, req.RestartFromStep); err != nil {
@@ -299,24 +334,10 @@ func (h *VideoHandler) retryTaskStep(c *gin.Context) {
 	}
 
 	// Start async retry
-	resumeCtx := buildResumeVideoContext(req)
-	go func() {
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
-		defer cancel()
-
-		var err error
-		if resumeCtx != nil {
-			err = h.youtubeChain.ResumeProcessingWithContext(ctx, video, resumeCtx)
-		} else {
-			err = h.youtubeChain.ResumeProcessing(ctx, video)
-		}
-		if err != nil {
-			h.logger.Error("单步重试续跑失败",
-				zap.String("video_id", video.VideoID),
-				zap.String("step_name", req.RestartFromStep),
-				zap.Error(err))
-		}
-	}()
+	if err := h.processingSvc.ResumeVideo(video, buildResumeVideoContext(req)); err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
 
 	Success(c, gin.H{"message": "步骤重试已启动"})
 }
@@ -342,6 +363,10 @@ func (h *VideoHandler) resumeVideo(c *gin.Context) {
 	if video.UserID == "" {
 		video.UserID = c.GetString("uid")
 	}
+	if h.processingSvc.IsTaskRunning(video.VideoID) {
+		BadRequest(c, "任务正在运行中，请先停止")
+		return
+	}
 
 	if strings.TrimSpace(req.RestartFromStep) != "" {
 		if err := h.videoService.ResetStepsFrom(c.Request.Context(), video.VideoID, req.RestartFromStep); err != nil {
@@ -350,25 +375,12 @@ func (h *VideoHandler) resumeVideo(c *gin.Context) {
 		}
 	}
 
-	// Mark as processing
-	h.videoService.MarkStatus(c.Request.Context(), video.VideoID, model.VideoStatusProcessing)
+	// 已取消（cancelled / 旧数据 paused）或失败的任务从未完成的步骤继续，已完成步骤保留
+	if err := h.processingSvc.ResumeVideo(video, buildResumeVideoContext(req)); err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
 	Success(c, gin.H{"message": "重新处理已启动"})
-
-	resumeCtx := buildResumeVideoContext(req)
-	go func() {
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
-		defer cancel()
-
-		var err error
-		if resumeCtx != nil {
-			err = h.youtubeChain.ResumeProcessingWithContext(ctx, video, resumeCtx)
-		} else {
-			err = h.youtubeChain.ResumeProcessing(ctx, video)
-		}
-		if err != nil {
-			h.logger.Error("重新处理失败", zap.String("video_id", video.VideoID), zap.Error(err))
-		}
-	}()
 }
 
 func (h *VideoHandler) stopVideo(c *gin.Context) {
@@ -390,13 +402,19 @@ func (h *VideoHandler) stopVideo(c *gin.Context) {
 		return
 	}
 
-	h.videoService.MarkStatus(c.Request.Context(), video.VideoID, model.VideoStatusPaused)
+	// 任务退出时会把执行中的步骤记为 cancelled 并清理未完成文件，这里先展示停止中
+	h.videoService.MarkStatus(c.Request.Context(), video.VideoID, model.VideoStatusCancelled)
 	h.videoService.MarkStepsStopping(c.Request.Context(), video.VideoID)
 	Success(c, gin.H{"message": "停止请求已发送"})
 }
 
 // ── SSE streaming ────────────────────────────────────────────────────────────
 
+// sseReconcileInterval 事件流兜底对账间隔：事件被丢弃或来自其他实例时，按数据库补发
+const sseReconcileInterval = 10 * time.Second
+
+// StreamVideoEvents 以 SSE 推送视频的步骤进度，视频进入终态时发送 done 事件。
+// 进度来自事件总线；总线只在进程内，API 模式下步骤在 worker 实例执行，改为每秒按数据库对账。
 func (h *VideoHandler) StreamVideoEvents(c *gin.Context) {
 	videoID := c.Param("id")
 
@@ -407,100 +425,129 @@ func (h *VideoHandler) StreamVideoEvents(c *gin.Context) {
 		return
 	}
 
+	// 先订阅再读取快照，避免两者之间的事件丢失
+	sub := h.events.Subscribe("sse:"+videoID, events.ForVideo(videoID), 256)
+	defer sub.Close()
+
 	c.Header("Content-Type", "text/event-stream; charset=utf-8")
 	c.Header("Cache-Control", "no-cache")
 	c.Header("Connection", "keep-alive")
 	c.Header("X-Accel-Buffering", "no")
 
 	flusher, canFlush := c.Writer.(http.Flusher)
-
-	loadSteps := func() []model.TaskStep {
-		steps, _ := h.videoService.ListSteps(c.Request.Context(), videoID)
-		return steps
+	flush := func() {
+		if canFlush {
+			flusher.Flush()
+		}
 	}
 
-	reloadVideo := func() (status, bvid string) {
-		v, _ := h.videoService.GetByVideoID(context.Background(), videoID)
-		if v != nil {
-			return v.Status, v.BiliBVID
+	// 已发送的步骤状态，对账时只补发有变化的步骤
+	sent := make(map[string]model.TaskStep)
+	sendStep := func(s model.TaskStep) {
+		if prev, ok := sent[s.StepName]; ok && prev.Status == s.Status &&
+			prev.ProgressPercent == s.ProgressPercent && prev.ProgressText == s.ProgressText {
+			return
 		}
-		return "", ""
+		sent[s.StepName] = s
+		writeSSEEvent(c.Writer, sseStepPayload(s)) //nolint:errcheck
 	}
-
-	type stepKey struct{ name, status string }
-	sent := make(map[stepKey]bool)
-
-	sendSteps := func(steps []model.TaskStep) {
+	reconcile := func() {
+		steps, _ := h.videoService.ListSteps(c.Request.Context(), videoID)
 		for _, s := range steps {
-			k := stepKey{s.StepName, s.Status}
-			if sent[k] {
-				continue
-			}
-			sent[k] = true
-			payload := map[string]interface{}{
-				"step_name":  s.StepName,
-				"status":     s.Status,
-				"step_order": s.StepOrder,
-			}
-			if s.Duration > 0 {
-				payload["duration"] = s.Duration
-			}
-			if s.ProgressPercent > 0 {
-				payload["progress_percent"] = s.ProgressPercent
-			}
-			if s.ProgressText != "" {
-				payload["progress_text"] = s.ProgressText
-			}
-			if s.ErrorMsg != "" {
-				payload["error_msg"] = s.ErrorMsg
-			}
-			writeSSEEvent(c.Writer, payload) //nolint:errcheck
-		}
-		if canFlush {
-			flusher.Flush()
+			sendStep(s)
 		}
+		flush()
 	}
-
-	ctx := c.Request.Context()
-	ticker := time.NewTicker(1 * time.Second)
-	defer ticker.Stop()
-
-	sendSteps(loadSteps())
-	status, bvid := reloadVideo()
-	if sseTerminalStatus(status) {
+	// sendDone 视频已进入终态时发送 done 事件并返回 true
+	sendDone := func(status string) bool {
+		v, _ := h.videoService.GetByVideoID(context.Background(), videoID)
+		if v == nil {
+			return false
+		}
+		if status == "" {
+			status = v.Status
+		}
+		if !sseTerminalStatus(status) {
+			return false
+		}
+		reconcile()
 		writeSSEEvent(c.Writer, map[string]interface{}{ //nolint:errcheck
 			"type":         "done",
 			"video_status": status,
-			"bili_bvid":    bvid,
+			"bili_bvid":    v.BiliBVID,
 		})
-		if canFlush {
-			flusher.Flush()
-		}
+		flush()
+		return true
+	}
+
+	reconcile()
+	if sendDone("") {
 		return
 	}
 
+	interval := sseReconcileInterval
+	if h.cfg != nil && !h.cfg.Worker.RunsWorker() {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	ctx := c.Request.Context()
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			sendSteps(loadSteps())
-			status, bvid = reloadVideo()
-			if sseTerminalStatus(status) {
-				writeSSEEvent(c.Writer, map[string]interface{}{ //nolint:errcheck
-					"type":         "done",
-					"video_status": status,
-					"bili_bvid":    bvid,
-				})
-				if canFlush {
-					flusher.Flush()
-				}
+			reconcile()
+			if sendDone("") {
 				return
 			}
+		case event := <-sub.C:
+			switch {
+			case event.Type == events.VideoStatus:
+				if sendDone(event.Status) {
+					return
+				}
+			case event.Step == "":
+				// 上传结果等非步骤事件不进入步骤列表
+			case event.Type == events.StepProgress:
+				s, ok := sent[event.Step]
+				if !ok {
+					reconcile()
+					continue
+				}
+				s.Status, s.ProgressPercent, s.ProgressText = event.Status, event.Percent, event.Message
+				sendStep(s)
+				flush()
+			default:
+				// 状态变化时按数据库补全耗时、错误等字段
+				reconcile()
+			}
 		}
 	}
 }
 
+func sseStepPayload(s model.TaskStep) map[string]interface{} {
+	payload := map[string]interface{}{
+		"step_name":  s.StepName,
+		"status":     s.Status,
+		"step_order": s.StepOrder,
+	}
+	if s.Duration > 0 {
+		payload["duration"] = s.Duration
+	}
+	if s.ProgressPercent > 0 {
+		payload["progress_percent"] = s.ProgressPercent
+	}
+	if s.ProgressText != "" {
+		payload["progress_text"] = s.ProgressText
+	}
+	if s.ErrorMsg != "" {
+		payload["error_msg"] = s.ErrorMsg
+	}
+	return payload
+}
+
 // ── Bilibili upload ──────────────────────────────────────────────────────────
 
 func (h *VideoHandler) uploadToBilibili(c *gin.Context) {
@@ -583,11 +630,7 @@ func (h *VideoHandler) uploadToBilibili(c *gin.Context) {
 // ── Helpers ──────────────────────────────────────────────────────────────────
 
 func sseTerminalStatus(status string) bool {
-	switch status {
-	case "003", "completed", "004", "failed", model.VideoStatusPaused:
-		return true
-	}
-	return false
+	return model.IsVideoStatusSettled(status)
 }
 
 func writeSSEEvent(w io.Writer, payload interface{}) error {
@@ -16,6 +16,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/difyz9/ytb2bili/internal/config"
+	"github.com/difyz9/ytb2bili/internal/events"
 	"github.com/difyz9/ytb2bili/internal/service"
 	"github.com/difyz9/ytb2bili/internal/workflow"
 	"github.com/difyz9/ytb2bili/pkg/utils"
@@ -35,6 +36,8 @@ type VideoProcessHandler struct {
 	downloadVideoTool *tools.DownloadVideoTool
 	userSettings      *service.UserSettingsClient
 	cfg               *config.AppConfig
+	agentOpen         *service.AgentOpenService
+	events            *events.Bus
 }
 
 type VideoProcessHandlerParams struct {
@@ -45,6 +48,8 @@ type VideoProcessHandlerParams struct {
 	DownloadVideoTool *tools.DownloadVideoTool `optional:"true"`
 	UserSettings      *service.UserSettingsClient
 	Cfg               *config.AppConfig
+	AgentOpen         *service.AgentOpenService `optional:"true"`
+	Events            *events.Bus               `optional:"true"`
 }
 
 func NewVideoProcessHandler(params VideoProcessHandlerParams) *VideoProcessHandler {
@@ -55,6 +60,8 @@ func NewVideoProcessHandler(params VideoProcessHandlerParams) *VideoProcessHandl
 		downloadVideoTool: params.DownloadVideoTool,
 		userSettings:      params.UserSettings,
 		cfg:               params.Cfg,
+		agentOpen:         params.AgentOpen,
+		events:            params.Events,
 	}
 }
 
@@ -67,6 +74,13 @@ type SubmitLinkRequest struct {
 	TaskChainSettings     *workflow.TaskChainSettings     `json:"task_chain_settings"`
 	SpeechSynthesisConfig *workflow.SpeechSynthesisConfig `json:"speech_synthesis_config"`
 	PlaylistConfig        *PlaylistSubmitConfig           `json:"playlist_config"`
+	WorkflowProfile       string                          `json:"workflow_profile"`
+}
+
+// PlanLinkRequest 提交前预览处理计划：参数与 SubmitLinkRequest 相同，可额外指定续跑起点
+type PlanLinkRequest struct {
+	SubmitLinkRequest
+	RestartFromStep string `json:"restart_from_step"`
 }
 
 type PlaylistSubmitConfig struct {
@@ -82,6 +96,7 @@ type SubmitVideoRequest struct {
 	PreferredResolution   string                          `json:"preferred_resolution"`
 	TaskChainSettings     *workflow.TaskChainSettings     `json:"task_chain_settings"`
 	SpeechSynthesisConfig *workflow.SpeechSynthesisConfig `json:"speech_synthesis_config"`
+	WorkflowProfile       string                          `json:"workflow_profile"`
 }
 
 type VideoProcessResponse struct {
@@ -124,6 +139,74 @@ func (h *VideoProcessHandler) RegisterRoutesWithAuth(r *gin.Engine, jwtMid gin.H
 	api.POST("/submit-video", h.SubmitVideo)
 	api.POST("/upload", h.UploadVideo)
 	api.POST("/async-submit-link", h.AsyncSubmitLink)
+	api.GET("/workflow-profiles", h.ListWorkflowProfiles)
+	api.POST("/plan", h.PlanLink)
+}
+
+// ── Workflow profiles ────────────────────────────────────────────────────────
+
+// ListWorkflowProfiles 返回配置中可选择的工作流
+func (h *VideoProcessHandler) ListWorkflowProfiles(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0, "message": "success",
+		"data": gin.H{
+			"profiles":        h.processingSvc.WorkflowProfiles(),
+			"default_profile": h.processingSvc.DefaultWorkflowProfile(),
+		},
+	})
+}
+
+// ── Plan (dry run) ───────────────────────────────────────────────────────────
+
+// PlanLink 预览链接提交后将执行的步骤与预估用量（转写分钟数、翻译/元数据 token、配音字数），
+// 不创建视频记录、不执行任何步骤，便于用户提交前调整任务链设置
+func (h *VideoProcessHandler) PlanLink(c *gin.Context) {
+	var req PlanLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	if uid := c.GetString("uid"); uid != "" {
+		req.UserID = uid
+	}
+
+	ctx := c.Request.Context()
+	platform, normalizedURL, videoID, douyinInfo, err := h.processingSvc.ResolveRemoteVideoTarget(ctx, req.URL)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+		return
+	}
+	if err := h.processingSvc.ValidateWorkflowProfile(req.WorkflowProfile, platform); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+		return
+	}
+
+	initialCtx := &workflow.VideoContext{
+		Platform:              platform,
+		VideoURL:              normalizedURL,
+		UserID:                req.UserID,
+		VideoID:               videoID,
+		PreferredResolution:   req.PreferredResolution,
+		TranslationConfig:     h.resolveTranslationConfig(ctx, req.UserID),
+		SpeechSynthesisConfig: req.SpeechSynthesisConfig,
+		TaskChainSettings:     workflow.NormalizeTaskChainSettings(req.TaskChainSettings),
+		RestartFromStep:       strings.TrimSpace(req.RestartFromStep),
+		WorkflowProfile:       req.WorkflowProfile,
+	}
+
+	var plan *workflow.ChainPlan
+	if platform == "douyin" {
+		initialCtx.DouyinVideoInfo = douyinInfo
+		plan, err = h.processingSvc.DouyinChain().Plan(ctx, initialCtx, videoID, req.UserID)
+	} else {
+		plan, err = h.processingSvc.YouTubeChain().Plan(ctx, initialCtx, videoID, req.UserID)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "生成处理计划失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": plan})
 }
 
 // ── SubmitLink (sync) ────────────────────────────────────────────────────────
@@ -144,6 +227,10 @@ func (h *VideoProcessHandler) SubmitLink(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, VideoProcessResponse{Success: false, Message: resolveErr.Error()})
 		return
 	}
+	if err := h.processingSvc.ValidateWorkflowProfile(req.WorkflowProfile, platform); err != nil {
+		c.JSON(http.StatusBadRequest, VideoProcessResponse{Success: false, Message: err.Error()})
+		return
+	}
 
 	settings := workflow.NormalizeTaskChainSettings(req.TaskChainSettings)
 	speechCfg := req.SpeechSynthesisConfig
@@ -159,6 +246,7 @@ func (h *VideoProcessHandler) SubmitLink(c *gin.Context) {
 		TranslationConfig:     translationCfg,
 		SpeechSynthesisConfig: speechCfg,
 		TaskChainSettings:     settings,
+		WorkflowProfile:       req.WorkflowProfile,
 	}
 
 	var result *workflow.VideoContext
@@ -178,7 +266,7 @@ func (h *VideoProcessHandler) SubmitLink(c *gin.Context) {
 	// Save results to DB
 	updates := map[string]interface{}{
 		"platform": platform, "title": result.Title, "description": result.Description,
-		"status": model.VideoStatusCompleted, "video_path": result.VideoPath,
+		"status": workflow.FinishedVideoStatus(result), "video_path": result.VideoPath,
 	}
 	if result.Transcript != nil {
 		updates["subtitle_path"] = result.Transcript.SRTPath
@@ -224,6 +312,11 @@ func (h *VideoProcessHandler) SubmitVideo(c *gin.Context) {
 		return
 	}
 
+	if err := h.processingSvc.ValidateWorkflowProfile(req.WorkflowProfile, workflow.PlatformYouTube); err != nil {
+		c.JSON(http.StatusBadRequest, VideoProcessResponse{Success: false, Message: err.Error()})
+		return
+	}
+
 	videoID := extractVideoIDFromPath(req.VideoPath)
 	settings := workflow.NormalizeTaskChainSettings(req.TaskChainSettings)
 	speechCfg := req.SpeechSynthesisConfig
@@ -233,7 +326,7 @@ func (h *VideoProcessHandler) SubmitVideo(c *gin.Context) {
 	initialCtx := &workflow.VideoContext{
 		VideoID: videoID, VideoPath: req.VideoPath, Title: req.Title, UserID: req.UserID,
 		TranslationConfig: translationCfg, SpeechSynthesisConfig: speechCfg,
-		TaskChainSettings: settings,
+		TaskChainSettings: settings, WorkflowProfile: req.WorkflowProfile,
 	}
 	result, err := h.processingSvc.YouTubeChain().ProcessLocalContextWithTracking(c.Request.Context(), initialCtx, videoID, req.UserID)
 	if err != nil {
@@ -242,7 +335,7 @@ func (h *VideoProcessHandler) SubmitVideo(c *gin.Context) {
 		return
 	}
 
-	updates := map[string]interface{}{"status": model.VideoStatusCompleted, "video_path": result.VideoPath}
+	updates := map[string]interface{}{"status": workflow.FinishedVideoStatus(result), "video_path": result.VideoPath}
 	if result.Title != "" {
 		updates["title"] = result.Title
 	}
@@ -338,6 +431,10 @@ func (h *VideoProcessHandler) AsyncSubmitLink(c *gin.Context) {
 
 	// Handle playlist
 	if shouldExpandPlaylist(req.URL, req.PlaylistConfig) && h.downloadVideoTool != nil {
+		if err := h.processingSvc.ValidateWorkflowProfile(req.WorkflowProfile, workflow.PlatformYouTube); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+			return
+		}
 		playlistCfg := normalizePlaylistConfig(req.PlaylistConfig)
 		playlistResult, err := h.downloadVideoTool.ListPlaylistEntries(c.Request.Context(), req.URL, tools.PlaylistOptions{
 			Enabled: true, StartIndex: playlistCfg.StartIndex, MaxItems: playlistCfg.MaxItems,
@@ -357,7 +454,7 @@ func (h *VideoProcessHandler) AsyncSubmitLink(c *gin.Context) {
 				req.UserID, "youtube", req.PreferredResolution,
 				speechVoiceName, string(settingsJSON), playlistResult.PlaylistID)
 			h.processingSvc.EnqueueRemoteVideoProcessing("youtube", entry.URL, entry.VideoID,
-				req.UserID, req.PreferredResolution, nil, settings, speechCfg)
+				req.UserID, req.PreferredResolution, req.WorkflowProfile, nil, settings, speechCfg)
 		}
 
 		c.JSON(http.StatusOK, gin.H{
@@ -376,6 +473,10 @@ func (h *VideoProcessHandler) AsyncSubmitLink(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": resolveErr.Error()})
 		return
 	}
+	if err := h.processingSvc.ValidateWorkflowProfile(req.WorkflowProfile, platform); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+		return
+	}
 
 	speechVoiceName := ""
 	if speechCfg != nil {
@@ -385,7 +486,7 @@ func (h *VideoProcessHandler) AsyncSubmitLink(c *gin.Context) {
 	h.videoService.UpsertAsProcessing(videoID, normalizedURL, "", req.UserID, platform,
 		req.PreferredResolution, speechVoiceName, string(settingsJSON), "")
 	h.processingSvc.EnqueueRemoteVideoProcessing(platform, normalizedURL, videoID,
-		req.UserID, req.PreferredResolution, douyinInfo, settings, speechCfg)
+		req.UserID, req.PreferredResolution, req.WorkflowProfile, douyinInfo, settings, speechCfg)
 
 	c.JSON(http.StatusOK, gin.H{
 		"code": 0, "message": "已加入处理队列",
@@ -413,7 +514,8 @@ func (h *VideoProcessHandler) StartAgentOpenJob(job *model.AgentJob, req service
 
 func (h *VideoProcessHandler) startAgentOpenPipeline(job *model.AgentJob, req service.AgentOpenJobRequest) {
 	go func() {
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+		// 各步骤按 [workflow.step_timeouts] 单独限时，这里不再设整体超时
+		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
 
 		h.updateJob(job.JobID, map[string]any{"status": "running", "progress": 10, "stage": "starting"})
@@ -433,16 +535,30 @@ func (h *VideoProcessHandler) startAgentOpenPipeline(job *model.AgentJob, req se
 			return
 		}
 
+		workflowProfile := agentOpenStr(req.Input["workflow_profile"])
+		if err := h.processingSvc.ValidateWorkflowProfile(workflowProfile, platform); err != nil {
+			h.failJob(job.JobID, "invalid_request", err.Error())
+			return
+		}
+
 		h.updateJob(job.JobID, map[string]any{"progress": 20, "stage": "queued_video"})
 		h.videoService.UpsertAsProcessing(videoID, normalizedURL, "", job.OwnerUserID, platform,
 			resolvedResolution, "", "{}", "")
 
 		h.updateJob(job.JobID, map[string]any{"progress": 30, "stage": "processing_video"})
-		_, procErr := h.processingSvc.ProcessRemoteVideo(ctx, platform, normalizedURL, videoID,
-			job.OwnerUserID, resolvedResolution, douyinInfo, nil, nil)
+		sub := h.events.Subscribe("agent-job:"+job.JobID, events.ForVideo(videoID), 64)
+		forwarded := make(chan struct{})
+		go func() {
+			defer close(forwarded)
+			h.forwardAgentJobProgress(job.JobID, videoID, sub)
+		}()
+		vctx, procErr := h.processingSvc.ProcessRemoteVideo(ctx, platform, normalizedURL, videoID,
+			job.OwnerUserID, resolvedResolution, workflowProfile, douyinInfo, nil, nil)
+		// 先停止转发进度，保证终态是任务的最后一次更新
+		sub.Close()
+		<-forwarded
 		if procErr != nil {
 			if errors.Is(procErr, context.Canceled) {
-				h.videoService.MarkStatus(ctx, videoID, model.VideoStatusPaused)
 				h.updateJob(job.JobID, map[string]any{"status": "cancelled", "progress": 100, "stage": "cancelled"})
 				return
 			}
@@ -452,7 +568,7 @@ func (h *VideoProcessHandler) startAgentOpenPipeline(job *model.AgentJob, req se
 		}
 
 		resultJSON, _ := json.Marshal(gin.H{
-			"video_id": videoID, "platform": platform, "status": model.VideoStatusCompleted,
+			"video_id": videoID, "platform": platform, "status": workflow.FinishedVideoStatus(vctx),
 		})
 		h.updateJob(job.JobID, map[string]any{
 			"status": "completed", "progress": 100, "stage": "completed", "result_json": string(resultJSON),
@@ -466,9 +582,35 @@ func (h *VideoProcessHandler) resolveTranslationConfig(ctx context.Context, user
 	return ResolveVideoTranslationConfig(ctx, h.cfg, h.userSettings, userID, nil)
 }
 
+// forwardAgentJobProgress 把视频的步骤事件折算为任务进度：处理阶段占 30%–95%，按已结束步骤数推进
+func (h *VideoProcessHandler) forwardAgentJobProgress(jobID, videoID string, sub *events.Subscription) {
+	for event := range sub.C {
+		switch event.Type {
+		case events.StepStarted:
+			h.updateJob(jobID, map[string]any{"stage": event.Step})
+		case events.StepCompleted, events.StepSkipped:
+			steps, err := h.videoService.ListSteps(context.Background(), videoID)
+			if err != nil || len(steps) == 0 {
+				continue
+			}
+			finished := 0
+			for _, step := range steps {
+				if step.Status == model.TaskStepStatusCompleted || step.Status == model.TaskStepStatusSkipped {
+					finished++
+				}
+			}
+			h.updateJob(jobID, map[string]any{"progress": 30 + 65*finished/len(steps)})
+		}
+	}
+}
+
 func (h *VideoProcessHandler) updateJob(jobID string, updates map[string]any) {
-	// The handler has no direct DB access; this is a thin wrapper
-	// Agent Open job updates should be done through a dedicated service
+	if h.agentOpen == nil {
+		return
+	}
+	if _, err := h.agentOpen.UpdateJob(context.Background(), jobID, updates); err != nil {
+		h.logger.Warn("更新 Agent Open 任务失败", zap.String("job_id", jobID), zap.Error(err))
+	}
 }
 
 func (h *VideoProcessHandler) failJob(jobID, errorCode, errorMessage string) {
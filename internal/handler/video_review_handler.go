@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/difyz9/ytb2bili/internal/service"
+	"github.com/difyz9/ytb2bili/internal/workflow"
+	"github.com/difyz9/ytb2bili/pkg/store/model"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ── 发布前人工审核 ───────────────────────────────────────────────────────────
+// 审核关卡停住的视频（status = awaiting_review）在这里查看、修改、通过或退回。
+// 通过后视频转为已完成，由自动投稿接手；退回时所选步骤带着审核意见重跑，之后再次进入审核。
+
+// reviewDefaultRerunSteps 退回时未指定步骤则只重新生成标题/简介/标签
+var reviewDefaultRerunSteps = []string{workflow.StepNameGenerateMetadata}
+
+// reviewSubtitleExts 审核页展示的字幕文件类型
+var reviewSubtitleExts = map[string]bool{".srt": true, ".vtt": true, ".ass": true}
+
+type reviewSubtitleFile struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	URL  string `json:"url,omitempty"`
+}
+
+type reviewDetailResponse struct {
+	service.VideoWithStepsWrapper
+	SubtitleFiles []reviewSubtitleFile `json:"subtitle_files"`
+}
+
+type reviewRejectReq struct {
+	Feedback string   `json:"feedback"`
+	Steps    []string `json:"steps"`
+}
+
+func (h *VideoHandler) registerReviewRoutes(g *gin.RouterGroup) {
+	g.GET(":id/review", h.getReview)
+	g.PATCH(":id/review", h.updateReview)
+	g.POST(":id/review/approve", h.approveReview)
+	g.POST(":id/review/reject", h.rejectReview)
+}
+
+func (h *VideoHandler) getReview(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		BadRequest(c, "无效的id")
+		return
+	}
+	video, steps, err := h.videoService.GetWithSteps(c.Request.Context(), uint(id))
+	if err != nil {
+		NotFound(c, "视频不存在")
+		return
+	}
+	Success(c, reviewDetailResponse{
+		VideoWithStepsWrapper: service.VideoWithStepsWrapper{Video: *video, TaskSteps: steps},
+		SubtitleFiles:         h.reviewSubtitleFiles(video),
+	})
+}
+
+func (h *VideoHandler) updateReview(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		BadRequest(c, "无效的id")
+		return
+	}
+	var req service.ReviewMetadataPatch
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+	video, err := h.videoService.UpdateReviewMetadata(c.Request.Context(), uint(id), req)
+	if err != nil {
+		h.reviewError(c, "更新审核内容失败", err)
+		return
+	}
+	Success(c, video)
+}
+
+func (h *VideoHandler) approveReview(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		BadRequest(c, "无效的id")
+		return
+	}
+	// 允许通过时一并提交最后的修改
+	var req service.ReviewMetadataPatch
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+	video, err := h.videoService.ApproveReview(c.Request.Context(), uint(id), c.GetString("uid"), req)
+	if err != nil {
+		h.reviewError(c, "审核通过失败", err)
+		return
+	}
+	h.logger.Info("视频审核通过", zap.String("video_id", video.VideoID), zap.String("reviewer", video.ReviewedBy))
+	Success(c, video)
+}
+
+func (h *VideoHandler) rejectReview(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		BadRequest(c, "无效的id")
+		return
+	}
+	var req reviewRejectReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+	if strings.TrimSpace(req.Feedback) == "" {
+		BadRequest(c, "请填写审核意见")
+		return
+	}
+	steps := req.Steps
+	if len(steps) == 0 {
+		steps = reviewDefaultRerunSteps
+	}
+	// 审核关卡本身总是重跑，重跑结束后视频再次进入待审核
+	steps = append(append([]string{}, steps...), workflow.StepNameReviewGate)
+
+	video, err := h.videoService.GetByPrimaryKey(c.Request.Context(), uint(id))
+	if err != nil {
+		NotFound(c, "视频不存在")
+		return
+	}
+	if h.processingSvc.IsTaskRunning(video.VideoID) {
+		BadRequest(c, "任务正在运行中，请先停止")
+		return
+	}
+	video, err = h.videoService.RejectReview(c.Request.Context(), uint(id), c.GetString("uid"), req.Feedback, steps)
+	if err != nil {
+		h.reviewError(c, "审核退回失败", err)
+		return
+	}
+	if video.UserID == "" {
+		video.UserID = c.GetString("uid")
+	}
+
+	// 审核意见由任务链从 tb_videos.review_feedback 读取，worker 实例重跑时同样生效
+	if err := h.processingSvc.ResumeVideo(video, nil); err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+	h.logger.Info("视频审核退回",
+		zap.String("video_id", video.VideoID),
+		zap.Strings("steps", steps))
+	Success(c, gin.H{"message": "已退回并开始重新处理", "steps": steps})
+}
+
+func (h *VideoHandler) reviewError(c *gin.Context, msg string, err error) {
+	switch {
+	case errors.Is(err, service.ErrNotAwaitingReview):
+		BadRequest(c, err.Error())
+	case strings.Contains(err.Error(), "不存在"):
+		BadRequest(c, msg+": "+err.Error())
+	default:
+		h.logger.Error(msg, zap.Error(err))
+		InternalServerError(c, msg)
+	}
+}
+
+// reviewSubtitleFiles 列出视频目录下的字幕文件；位于静态目录内时附带访问地址
+func (h *VideoHandler) reviewSubtitleFiles(video *model.Video) []reviewSubtitleFile {
+	files := []reviewSubtitleFile{}
+	if video == nil || strings.TrimSpace(video.VideoPath) == "" {
+		return files
+	}
+	dir := filepath.Dir(video.VideoPath)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return files
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !reviewSubtitleExts[strings.ToLower(filepath.Ext(entry.Name()))] {
+			continue
+		}
+		full := filepath.Join(dir, entry.Name())
+		files = append(files, reviewSubtitleFile{Name: entry.Name(), Path: full, URL: h.staticURL(full)})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files
+}
+
+func (h *VideoHandler) staticURL(file string) string {
+	if h.cfg == nil || strings.TrimSpace(h.cfg.Server.StaticDir) == "" {
+		return ""
+	}
+	root, err := filepath.Abs(h.cfg.Server.StaticDir)
+	if err != nil {
+		return ""
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return ""
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
+	}
+	return path.Join("/", h.cfg.Server.StaticPath, filepath.ToSlash(rel))
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/difyz9/ytb2bili/internal/config"
 	internalservice "github.com/difyz9/ytb2bili/internal/service"
+	"github.com/difyz9/ytb2bili/internal/workflow"
 	"github.com/difyz9/ytb2bili/pkg/store"
 	"github.com/difyz9/ytb2bili/pkg/store/model"
 	"go.uber.org/zap"
@@ -78,19 +79,26 @@ type updateSubscriptionStatusRequest struct {
 	SyncEnabled *bool  `json:"sync_enabled"`
 }
 
+type updateSubscriptionWorkflowProfileRequest struct {
+	UserID          string `json:"user_id"`
+	WorkflowProfile string `json:"workflow_profile"`
+}
+
 type YouTubeHandler struct {
 	logger         *zap.Logger
 	youtubeClient  *internalservice.YouTubeClientFactory
 	systemSettings *internalservice.SystemSettingsClient
 	youtubeService *internalservice.YouTubeService
+	profiles       *workflow.WorkflowProfiles
 }
 
-func NewYouTubeHandler(logger *zap.Logger, _ *config.AppConfig, youtubeClient *internalservice.YouTubeClientFactory, systemSettings *internalservice.SystemSettingsClient, youtubeService *internalservice.YouTubeService) *YouTubeHandler {
+func NewYouTubeHandler(logger *zap.Logger, _ *config.AppConfig, youtubeClient *internalservice.YouTubeClientFactory, systemSettings *internalservice.SystemSettingsClient, youtubeService *internalservice.YouTubeService, profiles *workflow.WorkflowProfiles) *YouTubeHandler {
 	return &YouTubeHandler{
 		logger:         logger,
 		youtubeClient:  youtubeClient,
 		systemSettings: systemSettings,
 		youtubeService: youtubeService,
+		profiles:       profiles,
 	}
 }
 
@@ -652,7 +660,7 @@ func (h *YouTubeHandler) GetLatestVideos(c *gin.Context) {
 	// 构建查询
 	query := h.youtubeService.GetDB().Model(&model.Video{}).
 		Where("tb_videos.video_id != ? AND tb_videos.video_id IS NOT NULL AND tb_videos.title != ? AND tb_videos.title IS NOT NULL", "", "").
-		Where("tb_videos.status = ?", "synced")
+		Where("tb_videos.status = ?", model.VideoStatusSynced)
 	hasSubscriptionJoin := false
 
 	// 如果有用户ID，只返回该用户的视频
@@ -902,10 +910,11 @@ func (h *YouTubeHandler) processVideoEntry(entry YouTubeEntry, channelId string,
 			VideoID:       videoID,
 			ChannelId:     channelId,
 			Duration:      0,
-			Status:        "synced", // 自动同步的视频标记为 synced
+			Status:        model.VideoStatusSynced, // 自动同步的视频标记为 synced
 			Platform:      "youtube",
 			PublishedAt:   publishedAt,
 			OperationType: "auto_sync", // 频道订阅自动同步
+			Priority:      model.VideoPriorityAutoSync,
 		}
 
 		if err := h.youtubeService.GetDB().Create(&video).Error; err != nil {
@@ -1174,6 +1183,70 @@ func (h *YouTubeHandler) UpdateTbSubscriptionStatus(c *gin.Context) {
 	Success(c, gin.H{"subscription": subscription})
 }
 
+// UpdateTbSubscriptionWorkflowProfile godoc
+// @Summary      设置频道使用的工作流
+// @Description  为订阅频道同步的视频指定工作流配置，空字符串表示沿用用户设置或默认工作流
+// @Tags         youtube
+// @Accept       json
+// @Produce      json
+// @Param        id      path      int   true  "订阅ID"
+// @Param        request body      updateSubscriptionWorkflowProfileRequest true "工作流参数"
+// @Success      200     {object}  map[string]interface{}  "subscription: object"
+// @Failure      400     {object}  map[string]interface{}  "error: string"
+// @Failure      403     {object}  map[string]interface{}  "error: string"
+// @Failure      404     {object}  map[string]interface{}  "error: string"
+// @Failure      500     {object}  map[string]interface{}  "error: string"
+// @Router       /youtube/TbSubscriptions/{id}/workflow-profile [patch]
+func (h *YouTubeHandler) UpdateTbSubscriptionWorkflowProfile(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		BadRequest(c, "无效的订阅ID")
+		return
+	}
+
+	var req updateSubscriptionWorkflowProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	profileName := strings.TrimSpace(req.WorkflowProfile)
+	if err := h.profiles.Validate(profileName, workflow.PlatformYouTube); err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+
+	var subscription model.TbSubscription
+	if err := h.youtubeService.GetDB().First(&subscription, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			NotFound(c, "订阅频道不存在")
+			return
+		}
+		InternalServerError(c, "查询订阅频道失败")
+		return
+	}
+
+	requestUserID := strings.TrimSpace(req.UserID)
+	if requestUserID == "" {
+		if uid, exists := c.Get("uid"); exists {
+			if uidStr, ok := uid.(string); ok {
+				requestUserID = uidStr
+			}
+		}
+	}
+	if requestUserID != "" && requestUserID != subscription.UserID {
+		Forbidden(c, "无权操作该频道")
+		return
+	}
+
+	if err := h.youtubeService.GetDB().Model(&subscription).Update("workflow_profile", profileName).Error; err != nil {
+		InternalServerError(c, "更新频道工作流失败")
+		return
+	}
+	subscription.WorkflowProfile = profileName
+	Success(c, gin.H{"subscription": subscription})
+}
+
 // SyncUserTbSubscriptions godoc
 // @Summary      同步用户订阅
 // @Description  从YouTube API同步用户的订阅频道列表
@@ -1355,6 +1428,7 @@ func (h *YouTubeHandler) RegisterRoutes(r *gin.Engine, authMiddleware ...gin.Han
 		api.GET("/TbSubscriptions", h.GetUserTbSubscriptions)
 		api.POST("/TbSubscriptions/sync", h.SyncUserTbSubscriptions)
 		api.PATCH("/TbSubscriptions/:id/status", h.UpdateTbSubscriptionStatus)
+		api.PATCH("/TbSubscriptions/:id/workflow-profile", h.UpdateTbSubscriptionWorkflowProfile)
 	}
 
 	// 新路由格式 (与 web-app 一致)
@@ -1379,6 +1453,7 @@ func (h *YouTubeHandler) RegisterRoutes(r *gin.Engine, authMiddleware ...gin.Han
 			youtube.GET("/TbSubscriptions", h.GetUserTbSubscriptions)
 			youtube.POST("/TbSubscriptions/sync", h.SyncUserTbSubscriptions)
 			youtube.PATCH("/TbSubscriptions/:id/status", h.UpdateTbSubscriptionStatus)
+			youtube.PATCH("/TbSubscriptions/:id/workflow-profile", h.UpdateTbSubscriptionWorkflowProfile)
 		}
 	}
 }
@@ -47,7 +47,12 @@ func NewHTTPServer(cfg *config.AppConfig, r *gin.Engine) *http.Server {
 	}
 }
 
-func Start(lc fx.Lifecycle, srv *http.Server, logger *zap.Logger) {
+func Start(lc fx.Lifecycle, cfg *config.AppConfig, srv *http.Server, logger *zap.Logger) {
+	if !cfg.Worker.RunsAPI() {
+		// worker 模式只做后台处理，HTTP API 由单独的实例提供
+		logger.Info("worker mode: http server disabled")
+		return
+	}
 	lc.Append(fx.Hook{
 		OnStart: func(ctx context.Context) error {
 			logger.Info("http server starting", zap.String("addr", srv.Addr))
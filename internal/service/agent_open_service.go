@@ -7,10 +7,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"strings"
 	"time"
 
 	"github.com/difyz9/ytb2bili/internal/config"
+	"github.com/difyz9/ytb2bili/internal/events"
 	"github.com/difyz9/ytb2bili/pkg/store/model"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
@@ -81,18 +83,20 @@ type AgentOpenJobRequest struct {
 }
 
 type AgentOpenService struct {
-	cfg    config.AgentOpenAPIConfig
-	db     *gorm.DB
-	logger *zap.Logger
-	tools  []AgentOpenToolDefinition
+	cfg     config.AgentOpenAPIConfig
+	db      *gorm.DB
+	logger  *zap.Logger
+	tools   []AgentOpenToolDefinition
+	webhook *http.Client
 }
 
 func NewAgentOpenService(cfg *config.AppConfig, db *gorm.DB, logger *zap.Logger) *AgentOpenService {
 	return &AgentOpenService{
-		cfg:    cfg.AgentOpenAPI,
-		db:     db,
-		logger: logger,
-		tools:  defaultAgentOpenToolCatalog(),
+		cfg:     cfg.AgentOpenAPI,
+		db:      db,
+		logger:  logger,
+		tools:   defaultAgentOpenToolCatalog(),
+		webhook: &http.Client{},
 	}
 }
 
@@ -290,6 +294,79 @@ func (s *AgentOpenService) GetJob(ctx context.Context, principal *AgentOpenPrinc
 	return &job, nil
 }
 
+// UpdateJob 更新任务进度或状态，并按任务登记的 webhook 推送 job.<status> / job.progress 事件。
+// 推送同步进行（含重试），调用方应在任务自己的协程中调用以保证事件顺序。
+func (s *AgentOpenService) UpdateJob(ctx context.Context, jobID string, updates map[string]any) (*model.AgentJob, error) {
+	var job model.AgentJob
+	if err := s.db.WithContext(ctx).Where("job_id = ?", jobID).First(&job).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrJobNotFound
+		}
+		return nil, err
+	}
+	previousStatus := job.Status
+	if err := s.db.WithContext(ctx).Model(&job).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Where("job_id = ?", jobID).First(&job).Error; err != nil {
+		return nil, err
+	}
+
+	event := "job.progress"
+	if job.Status != previousStatus {
+		event = "job." + job.Status
+	}
+	if job.WebhookURL != "" && agentOpenWebhookWanted(job.WebhookEvents, event) {
+		status := "delivered"
+		if err := events.Deliver(ctx, s.webhook, job.WebhookURL, s.cfg.WebhookSigningSecret, event, agentOpenJobPayload(event, &job)); err != nil {
+			status = "failed"
+			s.logger.Warn("Agent Open 任务 webhook 推送失败",
+				zap.String("job_id", job.JobID), zap.String("event", event), zap.Error(err))
+		}
+		if job.WebhookStatus != status {
+			s.db.WithContext(ctx).Model(&job).Update("webhook_status", status)
+		}
+	}
+	return &job, nil
+}
+
+// agentOpenWebhookWanted 未指定事件时推送除 job.progress 外的状态变化；支持 "job.*"
+func agentOpenWebhookWanted(eventsCSV, event string) bool {
+	var patterns []string
+	for _, pattern := range strings.Split(eventsCSV, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	if len(patterns) == 0 {
+		return event != "job.progress"
+	}
+	return events.MatchType(patterns, events.Type(event))
+}
+
+func agentOpenJobPayload(event string, job *model.AgentJob) map[string]any {
+	payload := map[string]any{
+		"event":      event,
+		"job_id":     job.JobID,
+		"tool_name":  job.ToolName,
+		"status":     job.Status,
+		"progress":   job.Progress,
+		"stage":      job.Stage,
+		"updated_at": job.UpdatedAt,
+	}
+	if job.ErrorCode != "" || job.ErrorMessage != "" {
+		payload["error_code"] = job.ErrorCode
+		payload["error"] = job.ErrorMessage
+	}
+	if strings.TrimSpace(job.ResultJSON) != "" {
+		var result map[string]any
+		if json.Unmarshal([]byte(job.ResultJSON), &result) == nil {
+			payload["result"] = result
+		}
+	}
+	return payload
+}
+
 func defaultAgentOpenToolCatalog() []AgentOpenToolDefinition {
 	return []AgentOpenToolDefinition{
 		{
@@ -371,7 +448,7 @@ func (s *AgentOpenService) invokeQueryVideos(ctx context.Context, principal *Age
 
 	query := s.db.WithContext(ctx).Model(&model.Video{}).Where("user_id = ?", ownerUserID)
 	if status := asString(input["status"]); status != "" {
-		query = query.Where("status = ?", status)
+		query = query.Where("status = ?", model.NormalizeVideoStatus(status))
 	}
 	if platform := asString(input["platform"]); platform != "" {
 		query = query.Where("platform = ?", platform)
@@ -2,23 +2,28 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/difyz9/ytb2bili/internal/events"
 	"github.com/difyz9/ytb2bili/pkg/store/model"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
 // VideoService 封装视频相关的数据库操作，供 handler 层调用。
+// 状态变化同时发布到事件总线。
 type VideoService struct {
 	db     *gorm.DB
 	logger *zap.Logger
+	events *events.Bus
 }
 
-func NewVideoService(db *gorm.DB, logger *zap.Logger) *VideoService {
-	return &VideoService{db: db, logger: logger}
+func NewVideoService(db *gorm.DB, logger *zap.Logger, bus *events.Bus) *VideoService {
+	return &VideoService{db: db, logger: logger, events: bus}
 }
 
 func (s *VideoService) GetDB() *gorm.DB { return s.db }
@@ -28,6 +33,18 @@ func (s *VideoService) GetDB() *gorm.DB { return s.db }
 type VideoWithStepsWrapper struct {
 	model.Video
 	TaskSteps []model.TaskStep `json:"task_steps"`
+	Runs      []model.TaskRun  `json:"runs,omitempty"` // 最近的运行历史，仅详情接口返回
+
+	StatusHistory []model.VideoStatusTransition `json:"status_history,omitempty"` // 状态变更历史，仅详情接口返回
+}
+
+// StepAttemptStat 按步骤汇总的执行统计
+type StepAttemptStat struct {
+	StepName      string  `json:"step_name"`
+	Attempts      int64   `json:"attempts"`        // 已结束的执行次数
+	Failed        int64   `json:"failed"`          // 失败或超时次数
+	FailureRate   float64 `json:"failure_rate"`    // Failed / Attempts
+	AvgDurationMs float64 `json:"avg_duration_ms"` // 成功执行的平均耗时
 }
 
 type VideoListResponse struct {
@@ -39,11 +56,12 @@ type VideoListResponse struct {
 }
 
 type TabCounts struct {
-	All          int64 `json:"all"`
-	Processing   int64 `json:"processing"`
-	Completed    int64 `json:"completed"`
-	Failed       int64 `json:"failed"`
-	BiliUploaded int64 `json:"bili_uploaded"`
+	All            int64 `json:"all"`
+	Processing     int64 `json:"processing"`
+	Completed      int64 `json:"completed"`
+	Failed         int64 `json:"failed"`
+	AwaitingReview int64 `json:"awaiting_review"`
+	BiliUploaded   int64 `json:"bili_uploaded"`
 }
 
 // ── 查询构建 ─────────────────────────────────────────────────────────────────
@@ -62,11 +80,13 @@ func (s *VideoService) BuildBaseQuery(userID, sourceType, tab string) *gorm.DB {
 	}
 	switch tab {
 	case "processing":
-		q = q.Where("status IN (?)", []string{"001", "002", "pending", "processing"})
+		q = q.Where("status IN (?)", model.VideoInProgressStatuses)
 	case "completed":
-		q = q.Where("status IN (?)", []string{"003", "completed", "processed", "ready", "synced"})
+		q = q.Where("status IN (?)", model.VideoDoneStatuses)
 	case "failed":
-		q = q.Where("status IN (?)", []string{"004", "failed", model.VideoStatusPaused})
+		q = q.Where("status IN (?)", model.VideoStoppedStatuses)
+	case "review":
+		q = q.Where("status = ?", model.VideoStatusAwaitingReview)
 	}
 	return q
 }
@@ -147,6 +167,11 @@ func (s *VideoService) CountByTab(ctx context.Context, userID, sourceType string
 		return nil, fmt.Errorf("查询失败视频数失败: %w", err)
 	}
 	counts.Failed = failed
+	awaitingReview, err := countTab("review")
+	if err != nil {
+		return nil, fmt.Errorf("查询待审核视频数失败: %w", err)
+	}
+	counts.AwaitingReview = awaitingReview
 	return &counts, nil
 }
 
@@ -186,6 +211,10 @@ func (s *VideoService) Update(ctx context.Context, id uint, patch map[string]int
 }
 
 func (s *VideoService) Delete(ctx context.Context, id uint) error {
+	var video model.Video
+	if err := s.db.WithContext(ctx).Select("video_id").First(&video, id).Error; err == nil && video.VideoID != "" {
+		s.db.WithContext(ctx).Unscoped().Where("video_id = ?", video.VideoID).Delete(&model.VideoCheckpoint{})
+	}
 	return s.db.WithContext(ctx).Delete(&model.Video{}, id).Error
 }
 
@@ -208,34 +237,79 @@ func (s *VideoService) UpsertAsProcessing(videoID, url, title, userID, platform,
 			TaskChainSettings:   taskChainSettingsJSON,
 			PlaylistID:          strings.TrimSpace(playlistID),
 			OperationType:       "manual",
+			Priority:            model.VideoPriorityFor("manual", playlistID),
 		}
 		if err := s.db.Create(newVideo).Error; err != nil {
 			s.logger.Warn("创建视频记录失败", zap.String("video_id", videoID), zap.Error(err))
+			return
 		}
 	} else if err == nil {
 		updates := map[string]interface{}{
-			"platform": platform, "status": model.VideoStatusProcessing,
-			"operation_type": "manual", "preferred_resolution": normRes(preferredResolution),
+			"platform": platform,
+			"operation_type": "manual", "priority": model.VideoPriorityFor("manual", playlistID),
+			"preferred_resolution": normRes(preferredResolution),
 			"speech_voice_name": strings.TrimSpace(speechVoiceName),
 			"task_chain_settings": taskChainSettingsJSON, "playlist_id": strings.TrimSpace(playlistID),
 		}
 		if strings.TrimSpace(userID) != "" {
 			updates["user_id"] = userID
 		}
-		if err := s.db.Model(&existing).Updates(updates).Error; err != nil {
+		if _, err := model.TransitionVideoStatus(s.db, videoID, model.VideoTransition{
+			To: model.VideoStatusProcessing, Updates: updates, Reason: "提交处理", Actor: userID,
+		}); err != nil {
 			s.logger.Warn("更新视频状态失败", zap.String("video_id", videoID), zap.Error(err))
+			return
 		}
+	} else {
+		return
 	}
+	s.events.PublishVideoStatus(s.db, videoID, userID, model.VideoStatusProcessing)
 }
 
+// MarkStatus 按状态机变更视频状态，不合法的变更只记录日志
 func (s *VideoService) MarkStatus(ctx context.Context, videoID, status string) {
-	s.db.WithContext(ctx).Model(&model.Video{}).Where("video_id = ?", videoID).Update("status", status)
+	s.transition(ctx, videoID, model.VideoTransition{To: status, Actor: "system"})
 }
 
+// UpdateProcessingResult 写入处理结果；updates 含 status 时经状态机变更
 func (s *VideoService) UpdateProcessingResult(ctx context.Context, videoID string, updates map[string]interface{}) {
-	if err := s.db.WithContext(ctx).Model(&model.Video{}).Where("video_id = ?", videoID).Updates(updates).Error; err != nil {
-		s.logger.Warn("更新视频处理结果失败", zap.String("video_id", videoID), zap.Error(err))
+	status, ok := updates["status"].(string)
+	if !ok {
+		if err := s.db.WithContext(ctx).Model(&model.Video{}).Where("video_id = ?", videoID).Updates(updates).Error; err != nil {
+			s.logger.Warn("更新视频处理结果失败", zap.String("video_id", videoID), zap.Error(err))
+		}
+		return
+	}
+	rest := make(map[string]interface{}, len(updates))
+	for key, value := range updates {
+		if key != "status" {
+			rest[key] = value
+		}
 	}
+	s.transition(ctx, videoID, model.VideoTransition{To: status, Updates: rest, Reason: "处理完成", Actor: "system"})
+}
+
+func (s *VideoService) transition(ctx context.Context, videoID string, t model.VideoTransition) {
+	previous, err := model.TransitionVideoStatus(s.db.WithContext(ctx), videoID, t)
+	if err != nil {
+		s.logger.Warn("变更视频状态失败",
+			zap.String("video_id", videoID), zap.String("to", t.To), zap.Error(err))
+		return
+	}
+	if previous != model.NormalizeVideoStatus(t.To) {
+		s.events.PublishVideoStatus(s.db, videoID, "", t.To)
+	}
+}
+
+// StatusHistory 视频的状态变更历史，按时间倒序
+func (s *VideoService) StatusHistory(ctx context.Context, videoID string, limit int) ([]model.VideoStatusTransition, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	var history []model.VideoStatusTransition
+	err := s.db.WithContext(ctx).Where("video_id = ?", videoID).
+		Order("id DESC").Limit(limit).Find(&history).Error
+	return history, err
 }
 
 func (s *VideoService) PersistResumeOverrides(videoID, resolution, voiceName, chainSettingsJSON string) {
@@ -279,12 +353,210 @@ func (s *VideoService) ResetStepsFrom(ctx context.Context, videoID, stepName str
 		}).Error
 }
 
+// ListRuns 返回视频最近 limit 次运行及各自的步骤执行记录，按时间倒序
+func (s *VideoService) ListRuns(ctx context.Context, videoID string, limit int) ([]model.TaskRun, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	var runs []model.TaskRun
+	if err := s.db.WithContext(ctx).Where("video_id = ?", videoID).Order("id desc").Limit(limit).Find(&runs).Error; err != nil {
+		return nil, err
+	}
+	if len(runs) == 0 {
+		return runs, nil
+	}
+	runIDs := make([]string, len(runs))
+	for i, run := range runs {
+		runIDs[i] = run.RunID
+	}
+	var attempts []model.TaskStepAttempt
+	if err := s.db.WithContext(ctx).Where("run_id IN ?", runIDs).Order("id asc").Find(&attempts).Error; err != nil {
+		return nil, err
+	}
+	byRun := make(map[string][]model.TaskStepAttempt, len(runs))
+	for _, attempt := range attempts {
+		byRun[attempt.RunID] = append(byRun[attempt.RunID], attempt)
+	}
+	for i := range runs {
+		runs[i].Attempts = byRun[runs[i].RunID]
+	}
+	return runs, nil
+}
+
+// StepAttemptStats 统计 since 之后各步骤的失败率与平均耗时
+func (s *VideoService) StepAttemptStats(ctx context.Context, since time.Time) ([]StepAttemptStat, error) {
+	var stats []StepAttemptStat
+	err := s.db.WithContext(ctx).Model(&model.TaskStepAttempt{}).
+		Select(`step_name,
+			COUNT(*) AS attempts,
+			SUM(CASE WHEN status IN ? THEN 1 ELSE 0 END) AS failed,
+			COALESCE(AVG(CASE WHEN status = ? THEN duration END), 0) AS avg_duration_ms`,
+			[]string{model.TaskStepStatusFailed, model.TaskStepStatusTimeout}, model.TaskStepStatusCompleted).
+		Where("start_time >= ? AND status <> ?", since, model.TaskStepStatusRunning).
+		Group("step_name").
+		Order("step_name").
+		Scan(&stats).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range stats {
+		if stats[i].Attempts > 0 {
+			stats[i].FailureRate = float64(stats[i].Failed) / float64(stats[i].Attempts)
+		}
+	}
+	return stats, nil
+}
+
 func (s *VideoService) MarkStepsStopping(ctx context.Context, videoID string) {
 	s.db.WithContext(ctx).Model(&model.TaskStep{}).
 		Where("video_id = ? AND status = ?", videoID, model.TaskStepStatusRunning).
 		Updates(map[string]any{"progress_text": "停止中"})
 }
 
+// ── 发布前人工审核 ───────────────────────────────────────────────────────────
+
+// ErrNotAwaitingReview 视频不处于待审核状态（可能已被他人审核）
+var ErrNotAwaitingReview = errors.New("视频当前不在待审核状态")
+
+// ReviewMetadataPatch 审核时可修改的元数据，nil 表示不修改
+type ReviewMetadataPatch struct {
+	Title       *string `json:"title"`
+	Description *string `json:"description"`
+	Tags        *string `json:"tags"`
+}
+
+func (p ReviewMetadataPatch) updates() map[string]interface{} {
+	updates := map[string]interface{}{}
+	if p.Title != nil {
+		updates["generated_title"] = strings.TrimSpace(*p.Title)
+	}
+	if p.Description != nil {
+		updates["generated_desc"] = strings.TrimSpace(*p.Description)
+	}
+	if p.Tags != nil {
+		updates["generated_tags"] = strings.TrimSpace(*p.Tags)
+	}
+	return updates
+}
+
+// updateAwaitingReview 仅在视频仍待审核时更新，避免并发审核互相覆盖
+func (s *VideoService) updateAwaitingReview(tx *gorm.DB, id uint, updates map[string]interface{}) error {
+	res := tx.Model(&model.Video{}).
+		Where("id = ? AND status = ?", id, model.VideoStatusAwaitingReview).
+		Updates(updates)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotAwaitingReview
+	}
+	return nil
+}
+
+// UpdateReviewMetadata 审核前修改生成的标题、简介、标签
+func (s *VideoService) UpdateReviewMetadata(ctx context.Context, id uint, patch ReviewMetadataPatch) (*model.Video, error) {
+	updates := patch.updates()
+	if len(updates) == 0 {
+		return s.GetByPrimaryKey(ctx, id)
+	}
+	if err := s.updateAwaitingReview(s.db.WithContext(ctx), id, updates); err != nil {
+		return nil, err
+	}
+	return s.GetByPrimaryKey(ctx, id)
+}
+
+// ApproveReview 审核通过：可同时提交修改后的元数据，视频转为已完成，之后由自动投稿接手
+func (s *VideoService) ApproveReview(ctx context.Context, id uint, reviewer string, patch ReviewMetadataPatch) (*model.Video, error) {
+	video, err := s.GetByPrimaryKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	updates := patch.updates()
+	updates["review_status"] = model.ReviewStatusApproved
+	updates["reviewed_by"] = reviewer
+	updates["reviewed_at"] = &now
+	if _, err := model.TransitionVideoStatus(s.db.WithContext(ctx), video.VideoID, model.VideoTransition{
+		To:      model.VideoStatusCompleted,
+		From:    []string{model.VideoStatusAwaitingReview},
+		Scope:   func(db *gorm.DB) *gorm.DB { return db.Where("id = ?", id) },
+		Updates: updates,
+		Reason:  "审核通过",
+		Actor:   reviewer,
+	}); err != nil {
+		if errors.Is(err, model.ErrVideoStatusConflict) {
+			return nil, ErrNotAwaitingReview
+		}
+		return nil, err
+	}
+	if video, err = s.GetByPrimaryKey(ctx, id); err != nil {
+		return nil, err
+	}
+	s.events.PublishVideoStatus(s.db, video.VideoID, video.UserID, model.VideoStatusCompleted)
+	return video, nil
+}
+
+// RejectReview 审核退回：记录意见并把所选步骤重置为待执行，由调用方重新提交处理。
+// 退回意见在重跑时附加到翻译与元数据生成的提示词中。
+func (s *VideoService) RejectReview(ctx context.Context, id uint, reviewer, feedback string, steps []string) (*model.Video, error) {
+	video, err := s.GetByPrimaryKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := s.updateAwaitingReview(tx, id, map[string]interface{}{
+			"review_status":   model.ReviewStatusRejected,
+			"review_feedback": strings.TrimSpace(feedback),
+			"reviewed_by":     reviewer,
+			"reviewed_at":     &now,
+		}); err != nil {
+			return err
+		}
+		return resetSteps(tx, video.VideoID, steps)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetByPrimaryKey(ctx, id)
+}
+
+// ResetSteps 将指定步骤重置为待执行，其余步骤保持不变
+func (s *VideoService) ResetSteps(ctx context.Context, videoID string, stepNames []string) error {
+	return resetSteps(s.db.WithContext(ctx), videoID, stepNames)
+}
+
+func resetSteps(tx *gorm.DB, videoID string, stepNames []string) error {
+	names := make([]string, 0, len(stepNames))
+	for _, name := range stepNames {
+		if trimmed := strings.TrimSpace(name); trimmed != "" {
+			names = append(names, trimmed)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	var existing []string
+	if err := tx.Model(&model.TaskStep{}).Where("video_id = ? AND step_name IN ?", videoID, names).Pluck("step_name", &existing).Error; err != nil {
+		return err
+	}
+	found := make(map[string]bool, len(existing))
+	for _, name := range existing {
+		found[name] = true
+	}
+	for _, name := range names {
+		if !found[name] {
+			return fmt.Errorf("步骤 %q 不存在", name)
+		}
+	}
+	return tx.Model(&model.TaskStep{}).
+		Where("video_id = ? AND step_name IN ?", videoID, names).
+		Updates(map[string]any{
+			"status": model.TaskStepStatusPending, "start_time": nil, "end_time": nil,
+			"duration": 0, "error_msg": "", "progress_percent": 0, "progress_text": "",
+		}).Error
+}
+
 // ── 辅助 ─────────────────────────────────────────────────────────────────────
 
 func normRes(resolution string) string {
@@ -4,11 +4,11 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 
 	"github.com/difyz9/ytb2bili/internal/config"
+	"github.com/difyz9/ytb2bili/pkg/utils"
 	"go.uber.org/fx"
 	"go.uber.org/zap"
 )
@@ -17,6 +17,9 @@ import (
 // 步骤: 非会员添加水印
 // ============================================================================
 
+// defaultWatermarkText 未配置 text 参数时的水印文字
+const defaultWatermarkText = "Powered by ytb2bili"
+
 type AddWatermarkStep struct {
 	BaseStep
 	ffmpegPath     string
@@ -81,7 +84,8 @@ func (s *AddWatermarkStep) Execute(ctx context.Context, input any) (any, error)
 	// 	return vctx, nil
 	// }
 
-	filter := s.buildDrawtextFilter()
+	// 水印文字可通过工作流步骤参数 text 覆盖
+	filter := s.buildDrawtextFilter(StepParamString(ctx, "text", defaultWatermarkText))
 
 	outPath := watermarkedOutputPath(videoPath)
 	tmpPath := watermarkTempOutputPath(outPath)
@@ -111,14 +115,17 @@ func (s *AddWatermarkStep) Execute(ctx context.Context, input any) (any, error)
 	return vctx, nil
 }
 
-func (s *AddWatermarkStep) buildDrawtextFilter() string {
+func (s *AddWatermarkStep) buildDrawtextFilter(text string) string {
+	// 文本位于单引号内，单引号本身无法转义，直接去掉
+	text = escapeFFmpegDrawtextValue(strings.ReplaceAll(text, "'", ""))
+	options := "text='" + text + "':fontsize=100:fontcolor=green@0.7:x=(w-text_w)-10:y=(h-text_h)-10:box=1:boxcolor=yellow@0.5:boxborderw=10"
 	if font := s.resolveExistingFontFile(); font != "" {
 		font = escapeFFmpegDrawtextValue(font)
-		return "drawtext=fontfile=" + font + ":text='Powered by ytb2bili':fontsize=100:fontcolor=green@0.7:x=(w-text_w)-10:y=(h-text_h)-10:box=1:boxcolor=yellow@0.5:boxborderw=10"
+		return "drawtext=fontfile=" + font + ":" + options
 	}
 
 	// 未找到字体文件时，让 ffmpeg/fontconfig 自行选择默认字体。
-	return "drawtext=text='Powered by ytb2bili':fontsize=100:fontcolor=green@0.7:x=(w-text_w)-10:y=(h-text_h)-10:box=1:boxcolor=yellow@0.5:boxborderw=10"
+	return "drawtext=" + options
 }
 
 func (s *AddWatermarkStep) resolveExistingFontFile() string {
@@ -207,7 +214,7 @@ func (s *AddWatermarkStep) runFFmpegWatermark(ctx context.Context, inPath, outPa
 		outPath,
 	}
 
-	cmd := exec.CommandContext(ctx, s.ffmpegPath, args...)
+	cmd := utils.CommandContext(ctx, s.ffmpegPath, args...)
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("ffmpeg watermark failed: %w: %s", err, truncateOutput(string(out), 4000))
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/difyz9/ytb2bili/internal/config"
+	"github.com/difyz9/ytb2bili/internal/events"
 	"github.com/difyz9/ytb2bili/pkg/llm"
 	"go.uber.org/fx"
 	"go.uber.org/zap"
@@ -77,6 +78,7 @@ type BilibiliChain struct {
 	metadataStep *GenerateMetadataStep
 	uploadStep   *UploadToBilibiliStep
 	logger       *zap.Logger
+	events       *events.Bus
 }
 
 // BilibiliChainParams B站链的依赖参数
@@ -84,12 +86,14 @@ type BilibiliChainParams struct {
 	fx.In
 	Steps  []Step `group:"bilibili_steps"`
 	Logger *zap.Logger
+	Events *events.Bus `optional:"true"`
 }
 
 // NewBilibiliChain 创建B站上传任务链
 func NewBilibiliChain(params BilibiliChainParams) *BilibiliChain {
 	chain := &BilibiliChain{
 		logger: params.Logger,
+		events: params.Events,
 	}
 
 	// 从steps中找到对应的步骤
@@ -159,6 +163,7 @@ func (bc *BilibiliChain) Run(ctx context.Context, input *BilibiliContext) error
 	output, err := bc.uploadStep.Execute(ctx, &input.VideoContext)
 	if err != nil {
 		bc.logger.Error("❌ B站上传失败", zap.Error(err))
+		bc.publishUpload(ctx, input, err)
 		return err
 	}
 	// 更新context
@@ -166,6 +171,7 @@ func (bc *BilibiliChain) Run(ctx context.Context, input *BilibiliContext) error
 		input.BiliBVID = vctx.BiliBVID
 		input.BiliAID = vctx.BiliAID
 	}
+	bc.publishUpload(ctx, input, nil)
 
 	bc.logger.Info("=== B站上传工作流完成 ===",
 		zap.String("bvid", input.BiliBVID),
@@ -174,6 +180,23 @@ func (bc *BilibiliChain) Run(ctx context.Context, input *BilibiliContext) error
 	return nil
 }
 
+// publishUpload 发布上传结果事件；视频 ID 优先取 context 中的值（从路径推断的 ID 可能不是数据库中的 video_id）
+func (bc *BilibiliChain) publishUpload(ctx context.Context, input *BilibiliContext, err error) {
+	videoID := GetVideoID(ctx)
+	if videoID == "" {
+		videoID = input.VideoID
+	}
+	event := events.Event{
+		Type: events.UploadCompleted, VideoID: videoID, UserID: input.UserID,
+		BVID: input.BiliBVID, Message: input.VideoContext.Title,
+	}
+	if err != nil {
+		event.Type = events.UploadFailed
+		event.Error = err.Error()
+	}
+	bc.events.Publish(event)
+}
+
 // RunFromVideoPath 从视频路径直接执行B站上传
 // 简化接口，无需手动构建 BilibiliContext
 func (bc *BilibiliChain) RunFromVideoPath(ctx context.Context, userID string, videoPath string, videoURL string, overrides *BilibiliSubmissionOverrides) (*BilibiliContext, error) {
@@ -8,16 +8,23 @@ import (
 	"strings"
 	"time"
 
+	"github.com/difyz9/ytb2bili/internal/events"
+	"github.com/difyz9/ytb2bili/pkg/store/model"
 	"go.uber.org/fx"
 	"go.uber.org/zap"
 )
 
 // Chain 任务链执行器
 type Chain struct {
-	name    string
-	steps   []Step
-	logger  *zap.Logger
-	tracker *ProgressTracker // 可选的进度追踪器
+	name         string
+	steps        []Step
+	logger       *zap.Logger
+	tracker      *ProgressTracker  // 可选的进度追踪器
+	checkpoints  *CheckpointStore  // 可选的 VideoContext 检查点存储
+	timeouts     *StepTimeouts     // 可选的步骤超时规则，nil 表示不限制
+	events       *events.Bus       // 可选的事件总线，运行时交给 tracker 发布步骤事件
+	resources    *ResourceGuard    // 可选的资源准入控制，nil 表示不限制
+	interceptors []StepInterceptor // 可选的步骤拦截器（指标、计费、追踪、审计），按 Order 从外到内
 }
 
 // WithTracker 设置进度追踪器（链式调用）
@@ -26,12 +33,47 @@ func (c *Chain) WithTracker(tracker *ProgressTracker) *Chain {
 	return c
 }
 
+// WithCheckpoints 设置检查点存储（链式调用）
+func (c *Chain) WithCheckpoints(store *CheckpointStore) *Chain {
+	c.checkpoints = store
+	return c
+}
+
+// WithTimeouts 设置步骤超时规则（链式调用）
+func (c *Chain) WithTimeouts(timeouts *StepTimeouts) *Chain {
+	c.timeouts = timeouts
+	return c
+}
+
+// WithEvents 设置事件总线（链式调用）
+func (c *Chain) WithEvents(bus *events.Bus) *Chain {
+	c.events = bus
+	return c
+}
+
+// WithResources 设置资源准入控制（链式调用）
+func (c *Chain) WithResources(resources *ResourceGuard) *Chain {
+	c.resources = resources
+	return c
+}
+
+// WithInterceptors 设置步骤拦截器，按 Order 从外到内嵌套（链式调用）
+func (c *Chain) WithInterceptors(interceptors ...StepInterceptor) *Chain {
+	c.interceptors = sortInterceptors(interceptors)
+	return c
+}
+
 // ChainParams 任务链的依赖参数
 type ChainParams struct {
 	fx.In
-	Name   string `optional:"true"` // 可选的链名称
-	Steps  []Step `group:"steps"`   // 自动注入所有步骤
-	Logger *zap.Logger
+	Name      string `optional:"true"` // 可选的链名称
+	Steps     []Step `group:"steps"`   // 自动注入所有步骤
+	Logger    *zap.Logger
+	Timeouts  *StepTimeouts  `optional:"true"` // 步骤超时规则
+	Events    *events.Bus    `optional:"true"` // 事件总线
+	Resources *ResourceGuard `optional:"true"` // 资源准入控制
+
+	Interceptors []StepInterceptor `group:"step_interceptors"` // 步骤拦截器
 }
 
 // NewChain 创建新的任务链
@@ -49,9 +91,13 @@ func NewChain(params ChainParams) *Chain {
 	})
 
 	return &Chain{
-		name:   name,
-		steps:  steps,
-		logger: params.Logger,
+		name:         name,
+		steps:        steps,
+		logger:       params.Logger,
+		timeouts:     params.Timeouts,
+		events:       params.Events,
+		resources:    params.Resources,
+		interceptors: sortInterceptors(params.Interceptors),
 	}
 }
 
@@ -69,15 +115,26 @@ type Result struct {
 
 // StepDetail 单个步骤的执行详情
 type StepDetail struct {
-	Name     string
-	Success  bool
-	Skipped  bool
-	Error    error
-	Duration time.Duration
-	Output   any
+	Name      string
+	Success   bool
+	Skipped   bool
+	Error     error
+	Duration  time.Duration
+	Output    any
+	Attempts  int  // 实际执行次数（含重试）
+	TimedOut  bool // 是否因超时失败
+	Cancelled bool // 是否因任务取消而中断
+}
+
+// stepOutcome 并发执行的步骤完成后回传给调度循环的结果
+type stepOutcome struct {
+	index  int
+	detail *StepDetail
 }
 
-// Run 执行任务链
+// Run 执行任务链。
+// 步骤按依赖图调度：所有依赖已结束的步骤即为就绪，多个就绪步骤并发执行。
+// 未声明依赖的步骤等待所有 Order 更小的步骤，因此旧步骤仍按 Order 串行。
 func (c *Chain) Run(ctx context.Context, input any) *Result {
 	startTime := time.Now()
 
@@ -90,20 +147,102 @@ func (c *Chain) Run(ctx context.Context, input any) *Result {
 		zap.String("chain", c.name),
 		zap.Int("steps", len(c.steps)))
 
-	currentInput := input
+	graph := buildStepGraph(c.steps, c.logger)
 
+	// 续跑起点在调度前按 Order 一次性求值，避免并发步骤竞争 restartStepActivated
+	restartSkipped := make([]bool, len(c.steps))
 	for i, step := range c.steps {
-		// 每步开始前检查 context 是否已取消
-		select {
-		case <-ctx.Done():
-			result.Success = false
-			result.Error = ctx.Err()
-			return result
-		default:
+		restartSkipped[i] = shouldSkipForRestartStep(input, step)
+	}
+
+	remaining := make([]int, len(c.steps))
+	for i := range c.steps {
+		remaining[i] = len(graph.deps[i])
+	}
+	started := make([]bool, len(c.steps))
+	outcomes := make(chan stepOutcome, len(c.steps))
+
+	currentInput := input
+	outputIndex := -1
+	running := 0
+	finished := 0
+	aborted := false
+	cancelled := false
+	completedSteps := restoredStepNames(input)
+	lastCompleted := ""
+	// 检查点快照：没有步骤运行时整体刷新，否则只更新刚完成步骤声明写入的字段
+	var snapshot videoContextSnapshot
+	if vctx, ok := input.(*VideoContext); ok && vctx != nil {
+		snapshot = snapshotVideoContext(vctx)
+	}
+	// 视频时长只在没有步骤运行时读取，避免与并发步骤竞争 VideoContext
+	videoSeconds := videoDurationSeconds(input)
+
+	for finished < len(c.steps) {
+		if !aborted {
+			for i, step := range c.steps {
+				if started[i] || remaining[i] > 0 {
+					continue
+				}
+				// 每步开始前检查 context 是否已取消
+				if err := ctx.Err(); err != nil {
+					result.Success = false
+					result.Error = err
+					aborted = true
+					cancelled = true
+					break
+				}
+				// 服务正在停止：不再开始新步骤，等正在执行的步骤结束并写入检查点后退出
+				if shutdownRequested(ctx) {
+					c.logger.Info("Shutdown requested, stopping chain at step boundary",
+						zap.String("chain", c.name),
+						zap.String("next_step", step.Name()),
+						zap.Int("running", running))
+					result.Success = false
+					result.Error = ErrShutdown
+					aborted = true
+					cancelled = true
+					break
+				}
+				started[i] = true
+				running++
+				timeout := c.timeouts.For(step.Name(), videoSeconds)
+				go func(i int, step Step, stepInput any) {
+					outcomes <- stepOutcome{
+						index:  i,
+						detail: c.executeStep(ctx, step, i+1, stepInput, restartSkipped[i], timeout),
+					}
+				}(i, step, currentInput)
+			}
+		}
+
+		if running == 0 {
+			if !aborted {
+				result.Success = false
+				result.Error = fmt.Errorf("task chain '%s' has unsatisfiable step dependencies", c.name)
+			}
+			break
 		}
 
-		detail := c.executeStep(ctx, step, i+1, currentInput)
+		outcome := <-outcomes
+		running--
+		finished++
+		step := c.steps[outcome.index]
+		detail := outcome.detail
 		result.StepDetails[step.Name()] = detail
+		for _, dependent := range graph.dependents[outcome.index] {
+			remaining[dependent]--
+		}
+
+		if detail.Cancelled {
+			result.Success = false
+			if result.Error == nil {
+				result.Error = fmt.Errorf("step '%s' cancelled: %w", step.Name(), detail.Error)
+			}
+			aborted = true
+			cancelled = true
+			continue
+		}
 
 		if detail.Skipped {
 			result.SkippedSteps++
@@ -116,12 +255,15 @@ func (c *Chain) Run(ctx context.Context, input any) *Result {
 			result.FailedSteps++
 
 			if step.IsRequired() {
-				result.Success = false
-				result.Error = fmt.Errorf("required step '%s' failed: %w", step.Name(), detail.Error)
+				if result.Error == nil {
+					result.Success = false
+					result.Error = fmt.Errorf("required step '%s' failed: %w", step.Name(), detail.Error)
+				}
 				c.logger.Error("Required step failed, aborting",
 					zap.String("step", step.Name()),
 					zap.Error(detail.Error))
-				break
+				aborted = true
+				continue
 			}
 
 			c.logger.Warn("Optional step failed, continuing",
@@ -130,7 +272,31 @@ func (c *Chain) Run(ctx context.Context, input any) *Result {
 			continue
 		}
 
-		currentInput = detail.Output
+		// 并发完成时以 Order 最靠后的成功步骤输出作为后续输入
+		if outcome.index > outputIndex {
+			outputIndex = outcome.index
+			currentInput = detail.Output
+		}
+		completedSteps = append(completedSteps, step.Name())
+		lastCompleted = step.Name()
+
+		// 每个成功的步骤都立即写检查点，之后的步骤失败或进程退出都不会重做它。
+		// 仍有步骤在运行时不读取整个 VideoContext（可能正被写入），只复制该步骤声明写入的字段
+		if vctx, ok := detail.Output.(*VideoContext); ok && vctx != nil {
+			if running == 0 {
+				snapshot = snapshotVideoContext(vctx)
+			} else if access, ok := declaredContextAccess(step); ok {
+				snapshot.copyFields(vctx, access.Writes())
+			}
+			c.saveCheckpoint(ctx, lastCompleted, completedSteps, snapshot)
+		}
+		if running == 0 {
+			videoSeconds = videoDurationSeconds(currentInput)
+		}
+	}
+
+	if cancelled {
+		return result
 	}
 
 	result.FinalOutput = currentInput
@@ -147,8 +313,8 @@ func (c *Chain) Run(ctx context.Context, input any) *Result {
 	return result
 }
 
-// executeStep 执行单个步骤
-func (c *Chain) executeStep(ctx context.Context, step Step, stepNum int, input any) *StepDetail {
+// executeStep 执行单个步骤，timeout 为单次尝试的超时（0 表示不限制）
+func (c *Chain) executeStep(ctx context.Context, step Step, stepNum int, input any, restartSkipped bool, timeout time.Duration) *StepDetail {
 	startTime := time.Now()
 	videoID := GetVideoID(ctx)
 	if c.tracker != nil {
@@ -164,7 +330,7 @@ func (c *Chain) executeStep(ctx context.Context, step Step, stepNum int, input a
 		zap.Int("step_num", stepNum),
 		zap.String("step", step.Name()))
 
-	if shouldSkipForRestartStep(input, step) {
+	if restartSkipped {
 		detail.Skipped = true
 		detail.Duration = time.Since(startTime)
 		c.logger.Debug("Step skipped before requested restart step", zap.String("step", step.Name()))
@@ -174,6 +340,14 @@ func (c *Chain) executeStep(ctx context.Context, step Step, stepNum int, input a
 		return detail
 	}
 
+	if canRestoreStep(step, input) {
+		detail.Skipped = true
+		detail.Output = input
+		detail.Duration = time.Since(startTime)
+		c.logger.Info("Step restored from checkpoint", zap.String("step", step.Name()))
+		return detail
+	}
+
 	// 检查是否应该跳过
 	if skipStep, ok := step.(StepWithSkip); ok && skipStep.ShouldSkip(ctx, input) {
 		detail.Skipped = true
@@ -191,10 +365,69 @@ func (c *Chain) executeStep(ctx context.Context, step Step, stepNum int, input a
 		c.tracker.BeforeStep(videoID, step.Name())
 	}
 
-	// 执行步骤
-	output, err := step.Execute(ctx, input)
+	// 拦截器包裹整个步骤执行（含资源等待与重试），返回时按结果逆序回调
+	call := &StepCall{
+		Chain: c.name, Step: step.Name(), StepNum: stepNum,
+		VideoID: videoID, UserID: GetUserID(ctx), Input: input, StartTime: startTime,
+	}
+	ctx, entered, err := c.enterInterceptors(ctx, call)
+	defer c.exitInterceptors(ctx, call, entered, detail)
+
+	// 资源准入：等待 CPU / 下载名额与磁盘空间，随后按步骤的重试策略执行并重试临时错误
+	var output any
+	if err == nil {
+		var release func()
+		release, err = c.resources.Admit(ctx, step, input)
+		if err == nil {
+			output, err = c.executeWithRetry(ctx, step, input, videoID, timeout, detail)
+			release()
+		}
+	}
 	detail.Duration = time.Since(startTime)
 
+	// 任务被取消：步骤返回的错误多为取消的连带结果（进程被杀、请求中断），记为 cancelled 而非 failed；
+	// 服务停止时被取消的步骤记为 interrupted，重新排队后从该步骤继续
+	if err != nil && ctx.Err() != nil && !IsStepTimeout(err) {
+		detail.Success = false
+		detail.Cancelled = true
+		detail.Error = ctx.Err()
+		status := model.TaskStepStatusCancelled
+		if IsShutdownInterrupted(ctx, nil) {
+			detail.Error = ErrShutdown
+			status = model.TaskStepStatusInterrupted
+		}
+
+		c.logger.Warn("Step cancelled",
+			zap.String("step", step.Name()),
+			zap.String("status", status),
+			zap.NamedError("step_error", err))
+		if c.tracker != nil && videoID != "" {
+			c.tracker.AfterStep(videoID, step.Name(), status, "")
+		}
+		return detail
+	}
+
+	if err != nil && IsStepTimeout(err) {
+		detail.Success = false
+		detail.TimedOut = true
+		detail.Error = err
+
+		c.logger.Error("Step timed out",
+			zap.String("step", step.Name()),
+			zap.Duration("timeout", timeout))
+		if c.tracker != nil && videoID != "" {
+			c.tracker.AfterStep(videoID, step.Name(), model.TaskStepStatusTimeout, err.Error())
+		}
+		if hookStep, ok := step.(StepWithHooks); ok {
+			if hookErr := hookStep.OnError(ctx, err); hookErr != nil {
+				c.logger.Warn("Error hook failed",
+					zap.String("step", step.Name()),
+					zap.Error(hookErr))
+			}
+		}
+		return detail
+	}
+
 	if err != nil {
 		var skipErr *StepSkippedError
 		if errors.As(err, &skipErr) {
@@ -263,6 +496,82 @@ func (c *Chain) executeStep(ctx context.Context, step Step, stepNum int, input a
 	return detail
 }
 
+// executeWithRetry 执行步骤，遇到可重试错误时按 RetryPolicy 指数退避重试。
+// 每次尝试单独计时，超时直接返回 StepTimeoutError，不再重试。
+func (c *Chain) executeWithRetry(ctx context.Context, step Step, input any, videoID string, timeout time.Duration, detail *StepDetail) (any, error) {
+	policy := resolveRetryPolicy(step)
+	maxAttempts := policy.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		detail.Attempts = attempt
+		output, err := c.executeWithTimeout(ctx, step, input, timeout)
+		if err == nil || attempt >= maxAttempts || ctx.Err() != nil || !policy.retryable(err) {
+			return output, err
+		}
+
+		delay := policy.backoff(attempt)
+		c.logger.Warn("Step failed with transient error, retrying",
+			zap.String("step", step.Name()),
+			zap.Int("attempt", attempt),
+			zap.Int("max_attempts", maxAttempts),
+			zap.Duration("backoff", delay),
+			zap.Error(err))
+		if c.tracker != nil && videoID != "" {
+			c.tracker.UpdateStepProgress(videoID, step.Name(), 0,
+				fmt.Sprintf("临时错误，%s 后第 %d 次重试", delay.Round(time.Second), attempt+1))
+		}
+		if sleepErr := sleepWithContext(ctx, delay); sleepErr != nil {
+			return output, err
+		}
+	}
+}
+
+// executeWithTimeout 在超时限制下执行一次步骤；超时返回 StepTimeoutError。
+// 必需步骤超时后任务链终止，宽限期后可放弃等待；可选步骤超时后任务链继续，
+// 必须等它真正返回，否则它仍可能写入后续步骤共用的 VideoContext
+func (c *Chain) executeWithTimeout(ctx context.Context, step Step, input any, timeout time.Duration) (any, error) {
+	output, timedOut, err := runWithTimeout(ctx, timeout, step.IsRequired(), func(ctx context.Context) (any, error) {
+		return step.Execute(ctx, input)
+	})
+	if timedOut {
+		return nil, &StepTimeoutError{Step: step.Name(), Timeout: timeout}
+	}
+	return output, err
+}
+
+// saveCheckpoint 持久化 VideoContext 快照，失败仅记录日志
+func (c *Chain) saveCheckpoint(ctx context.Context, lastStep string, completedSteps []string, snapshot videoContextSnapshot) {
+	if c.checkpoints == nil {
+		return
+	}
+	videoID := GetVideoID(ctx)
+	if videoID == "" {
+		videoID = snapshot.VideoID
+	}
+	if err := c.checkpoints.SaveSnapshot(videoID, lastStep, completedSteps, snapshot); err != nil {
+		c.logger.Warn("Failed to save workflow checkpoint",
+			zap.String("video_id", videoID),
+			zap.String("step", lastStep),
+			zap.Error(err))
+	}
+}
+
+func restoredStepNames(input any) []string {
+	vctx, ok := input.(*VideoContext)
+	if !ok || vctx == nil {
+		return nil
+	}
+	names := make([]string, 0, len(vctx.restoredSteps))
+	for name := range vctx.restoredSteps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func shouldSkipForRestartStep(input any, step Step) bool {
 	vctx, ok := input.(*VideoContext)
 	if !ok || vctx == nil {
@@ -0,0 +1,132 @@
+package workflow
+
+import (
+	"go.uber.org/zap"
+)
+
+// ── 步骤依赖图 ───────────────────────────────────────────────────────────────
+// Chain 根据步骤声明构建 DAG：
+//   - 实现 StepWithDependencies 且 DependsOn 非 nil 的步骤，只依赖所列步骤；
+//   - 未声明依赖的步骤，依赖所有排在它前面（Order 更小）的步骤，保持原有串行语义；
+//   - 声明的依赖不在当前链中时（如抖音链没有 DownloadVideo），同样退回按 Order 串行；
+//   - 通过 StepWithContextAccess 声明的字段读写冲突，会在两个步骤之间补一条按 Order 的边；
+//   - Reads、Writes 均为 nil（或未实现 StepWithContextAccess）视为未声明，可能读写任意字段，
+//     与其他所有步骤冲突：只声明依赖、未声明读写字段的步骤不会与其他步骤并发。
+//     确实不读写上下文的步骤应返回空切片。
+// 全部步骤都未声明依赖时，该图退化为按 Order 的单链，与旧版行为完全一致。
+
+// stepGraph 任务链的依赖图，节点下标与 Chain.steps 一致
+type stepGraph struct {
+	deps       [][]int // deps[i]：步骤 i 依赖的步骤下标
+	dependents [][]int // dependents[i]：依赖步骤 i 的步骤下标
+}
+
+// buildStepGraph 由已按 Order 排序的步骤构建依赖图
+func buildStepGraph(steps []Step, logger *zap.Logger) *stepGraph {
+	index := make(map[string]int, len(steps))
+	for i, step := range steps {
+		index[step.Name()] = i
+	}
+
+	depSets := make([]map[int]struct{}, len(steps))
+	for i, step := range steps {
+		set := make(map[int]struct{})
+		declared := declaredDependencies(step)
+		sequential := declared == nil
+		for _, name := range declared {
+			j, ok := index[name]
+			if !ok {
+				logger.Debug("Dependency not in chain, falling back to order",
+					zap.String("step", step.Name()),
+					zap.String("depends_on", name))
+				sequential = true
+				break
+			}
+			if j != i {
+				set[j] = struct{}{}
+			}
+		}
+		if sequential {
+			for j := 0; j < i; j++ {
+				set[j] = struct{}{}
+			}
+		}
+
+		for j := 0; j < i; j++ {
+			if contextAccessConflicts(steps[j], step) {
+				set[j] = struct{}{}
+			}
+		}
+		depSets[i] = set
+	}
+
+	g := &stepGraph{
+		deps:       make([][]int, len(steps)),
+		dependents: make([][]int, len(steps)),
+	}
+	for i := range steps {
+		for j := 0; j < len(steps); j++ {
+			if _, ok := depSets[i][j]; ok {
+				g.deps[i] = append(g.deps[i], j)
+				g.dependents[j] = append(g.dependents[j], i)
+			}
+		}
+	}
+	return g
+}
+
+func declaredDependencies(step Step) []string {
+	depStep, ok := step.(StepWithDependencies)
+	if !ok {
+		return nil
+	}
+	return depStep.DependsOn()
+}
+
+// contextAccessConflicts 判断两个步骤是否存在写-读或写-写冲突，任一步骤未声明读写字段时视为冲突
+func contextAccessConflicts(a, b Step) bool {
+	aAccess, ok := declaredContextAccess(a)
+	if !ok {
+		return true
+	}
+	bAccess, ok := declaredContextAccess(b)
+	if !ok {
+		return true
+	}
+
+	aWrites := fieldSet(aAccess.Writes())
+	for _, f := range bAccess.Reads() {
+		if _, ok := aWrites[f]; ok {
+			return true
+		}
+	}
+	for _, f := range bAccess.Writes() {
+		if _, ok := aWrites[f]; ok {
+			return true
+		}
+	}
+	bWrites := fieldSet(bAccess.Writes())
+	for _, f := range aAccess.Reads() {
+		if _, ok := bWrites[f]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// declaredContextAccess 返回步骤声明的读写字段；Reads、Writes 均为 nil 时视为未声明
+func declaredContextAccess(step Step) (StepWithContextAccess, bool) {
+	access, ok := step.(StepWithContextAccess)
+	if !ok || (access.Reads() == nil && access.Writes() == nil) {
+		return nil, false
+	}
+	return access, true
+}
+
+func fieldSet(fields []ContextField) map[ContextField]struct{} {
+	set := make(map[ContextField]struct{}, len(fields))
+	for _, f := range fields {
+		set[f] = struct{}{}
+	}
+	return set
+}
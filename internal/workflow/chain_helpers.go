@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"sort"
 
+	"github.com/difyz9/ytb2bili/pkg/store/model"
 	"go.uber.org/fx"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
@@ -38,15 +39,20 @@ func RunChainWithTracking(
 	videoID string,
 	input any,
 ) (*VideoContext, error) {
-	tracker := NewProgressTracker(db, logger)
+	tracker := NewProgressTracker(db, logger).WithEvents(chain.events)
 	if err := tracker.InitSteps(videoID, chain.GetSteps()); err != nil {
 		logger.Warn("初始化任务步骤记录失败",
 			zap.String("video_id", videoID), zap.Error(err))
 	}
 
-	run := chain.clone().WithTracker(tracker)
+	fillVideoDuration(db, videoID, input)
+	fillReviewFeedback(db, videoID, input)
 
+	run := chain.clone().WithTracker(tracker).WithCheckpoints(NewCheckpointStore(db, logger))
+
+	tracker.StartRun(videoID, "")
 	result := run.Run(ctx, input)
+	tracker.FinishRun(runStatus(ctx, result.Error), errorText(result.Error))
 	if !result.Success {
 		return nil, result.Error
 	}
@@ -55,9 +61,58 @@ func RunChainWithTracking(
 	if !ok {
 		return nil, fmt.Errorf("unexpected output type: %T", result.FinalOutput)
 	}
+	// 仍在执行中的视频才写回完成状态，期间已被取消或审核通过时保持不变
+	transitionVideo(db, chain.events, logger, videoID, model.VideoTransition{
+		To:     FinishedVideoStatus(vctx),
+		From:   append([]string{model.VideoStatusAwaitingReview}, model.VideoActiveStatuses...),
+		Reason: "任务链完成", Actor: "system",
+	})
 	return vctx, nil
 }
 
+// runStatus 按运行结果得出 tb_task_runs 的状态
+func runStatus(ctx context.Context, err error) string {
+	switch {
+	case err == nil:
+		return model.TaskRunStatusCompleted
+	case IsShutdownInterrupted(ctx, err):
+		return model.TaskRunStatusInterrupted
+	case ctx.Err() != nil:
+		return model.TaskRunStatusCancelled
+	default:
+		return model.TaskRunStatusFailed
+	}
+}
+
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// fillVideoDuration 上下文未携带视频时长时，从 tb_videos 读取，供步骤超时按时长放宽
+func fillVideoDuration(db *gorm.DB, videoID string, input any) {
+	vctx, ok := input.(*VideoContext)
+	if !ok || vctx == nil || vctx.DurationSeconds > 0 || db == nil || videoID == "" {
+		return
+	}
+	var video model.Video
+	if err := db.Select("duration").Where("video_id = ?", videoID).Limit(1).Find(&video).Error; err == nil {
+		vctx.DurationSeconds = video.Duration
+	}
+}
+
+// saveVideoDuration 记录探测到的视频时长，只填补尚未记录的时长
+func saveVideoDuration(db *gorm.DB, videoID string, seconds float64) error {
+	if db == nil || videoID == "" || seconds <= 0 {
+		return nil
+	}
+	return db.Model(&model.Video{}).
+		Where("video_id = ? AND (duration IS NULL OR duration <= 0)", videoID).
+		Update("duration", seconds).Error
+}
+
 // AsStepForGroup 将步骤构造函数注册到指定 fx group。
 // 统一替代 AsStep（group:"steps"）、AsDouyinStep（group:"douyin_steps"）、AsBilibiliStep（group:"bilibili_steps"）。
 func AsStepForGroup(group string, constructor any) any {
@@ -0,0 +1,244 @@
+package workflow
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// 计划中步骤的处理方式
+const (
+	PlanActionRun           = "run"            // 将会执行
+	PlanActionSkip          = "skip"           // 按任务链开关或步骤条件跳过
+	PlanActionBeforeRestart = "before_restart" // 位于续跑起点之前，严格跳过
+	PlanActionRestore       = "restore"        // 检查点中已完成，直接恢复
+	PlanActionCached        = "cached"         // 会执行，但命中步骤结果缓存，不调用外部服务
+)
+
+// 视频时长来源
+const (
+	durationSourceContext    = "context"    // 提交参数或检查点
+	durationSourceRecord     = "record"     // 视频记录 tb_videos.duration
+	durationSourceProbe      = "probe"      // yt-dlp / 抖音解析探测
+	durationSourceTranscript = "transcript" // 已有转写的末尾时间
+	durationSourceUnknown    = "unknown"
+)
+
+// 用量预估参数（经验值，仅用于提交前参考）
+const (
+	planSpeechWordsPerSecond       = 2.5 // 英文口语语速（词/秒）
+	planSpeechHanPerSecond         = 4.5 // 中文口语语速（字/秒），用于估算配音字数
+	planTokensPerWord              = 1.3 // 非中文文本每词 token 数
+	planSecondsPerSegment          = 4.0 // 平均每条字幕时长（秒）
+	planTranslatePromptPerSegment  = 12  // 翻译时每条字幕的编号与格式开销
+	planTranslateOutputRatio       = 1.2 // 译文 token 相对原文的比例
+	planMetadataPromptTokens       = 500 // 元数据生成提示词
+	planMetadataSubtitleTokenLimit = 700 // 元数据生成截取的字幕文本上限（约 2000 字节）
+	planMetadataOutputTokens       = 300 // 标题、描述、标签
+)
+
+// planProbeTimeout 计划预览时探测视频时长的超时
+const planProbeTimeout = 30 * time.Second
+
+const planModeContextKey contextKey = "workflow_plan_mode"
+
+// withPlanMode 标记当前为计划预览：解析工作流等环节不得写数据库
+func withPlanMode(ctx context.Context) context.Context {
+	return context.WithValue(ctx, planModeContextKey, true)
+}
+
+func isPlanMode(ctx context.Context) bool {
+	planMode, _ := ctx.Value(planModeContextKey).(bool)
+	return planMode
+}
+
+// StepWithCachedResult 能在不执行的情况下判断结果缓存是否命中的步骤（用于计划预览）
+type StepWithCachedResult interface {
+	Step
+	HasCachedResult(ctx context.Context, input any) bool
+}
+
+// PlannedStep 计划中的单个步骤
+type PlannedStep struct {
+	Name           string   `json:"name"`
+	Order          int      `json:"order"`
+	Required       bool     `json:"required"`
+	DependsOn      []string `json:"depends_on,omitempty"`
+	Action         string   `json:"action"`
+	TimeoutSeconds int      `json:"timeout_seconds"` // 0 表示不限制
+}
+
+// PlanEstimate 按计划执行的步骤预估的外部服务用量
+type PlanEstimate struct {
+	VideoDurationSeconds    float64  `json:"video_duration_seconds"`
+	DurationSource          string   `json:"duration_source"`
+	ASRMinutes              float64  `json:"asr_minutes"`
+	TranslationInputTokens  int      `json:"translation_input_tokens"`
+	TranslationOutputTokens int      `json:"translation_output_tokens"`
+	MetadataTokens          int      `json:"metadata_tokens"`
+	TTSCharacters           int      `json:"tts_characters"`
+	Notes                   []string `json:"notes,omitempty"`
+}
+
+// ChainPlan 一次任务链运行的预览
+type ChainPlan struct {
+	Platform          string             `json:"platform"`
+	VideoID           string             `json:"video_id"`
+	WorkflowProfile   string             `json:"workflow_profile,omitempty"`
+	RestartFromStep   string             `json:"restart_from_step,omitempty"`
+	TaskChainSettings *TaskChainSettings `json:"task_chain_settings"`
+	Steps             []PlannedStep      `json:"steps"`
+	Estimate          PlanEstimate       `json:"estimate"`
+}
+
+// Plan 按 Run 的判定顺序（续跑起点 → 检查点恢复 → ShouldSkip → 结果缓存）求出每个步骤的处理方式，
+// 不执行任何步骤，也不修改 input 的运行状态。
+func (c *Chain) Plan(ctx context.Context, input any) []PlannedStep {
+	ctx = withPlanMode(ctx)
+	if vctx, ok := input.(*VideoContext); ok && vctx != nil {
+		activated := vctx.restartStepActivated
+		defer func() { vctx.restartStepActivated = activated }()
+	}
+
+	videoSeconds := videoDurationSeconds(input)
+	planned := make([]PlannedStep, 0, len(c.steps))
+	for _, step := range c.steps {
+		item := PlannedStep{
+			Name:           step.Name(),
+			Order:          step.Order(),
+			Required:       step.IsRequired(),
+			DependsOn:      declaredDependencies(step),
+			Action:         PlanActionRun,
+			TimeoutSeconds: int(c.timeouts.For(step.Name(), videoSeconds).Seconds()),
+		}
+		switch {
+		case shouldSkipForRestartStep(input, step):
+			item.Action = PlanActionBeforeRestart
+		case canRestoreStep(step, input):
+			item.Action = PlanActionRestore
+		default:
+			if skipStep, ok := step.(StepWithSkip); ok && skipStep.ShouldSkip(ctx, input) {
+				item.Action = PlanActionSkip
+			} else if cachedStep, ok := step.(StepWithCachedResult); ok && cachedStep.HasCachedResult(ctx, input) {
+				item.Action = PlanActionCached
+			}
+		}
+		planned = append(planned, item)
+	}
+	return planned
+}
+
+// buildChainPlan 生成计划并附上用量预估；durationSource 为调用方已确定的时长来源（可为空）
+func buildChainPlan(ctx context.Context, chain *Chain, platform, videoID string, vctx *VideoContext, durationSource string) *ChainPlan {
+	steps := chain.Plan(ctx, vctx)
+	plan := &ChainPlan{
+		Platform:          platform,
+		VideoID:           videoID,
+		WorkflowProfile:   vctx.WorkflowProfile,
+		RestartFromStep:   strings.TrimSpace(vctx.RestartFromStep),
+		TaskChainSettings: NormalizeTaskChainSettings(vctx.TaskChainSettings),
+		Steps:             steps,
+		Estimate:          estimatePlan(vctx, steps, durationSource),
+	}
+	if plan.RestartFromStep != "" && !planHasStep(steps, plan.RestartFromStep) {
+		plan.Estimate.Notes = append(plan.Estimate.Notes, "续跑起点 "+plan.RestartFromStep+" 不在任务链中，所有步骤都会被跳过")
+	}
+	return plan
+}
+
+func planHasStep(steps []PlannedStep, name string) bool {
+	for _, step := range steps {
+		if step.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// estimatePlan 只为实际会调用外部服务的步骤（action 为 run）计入用量。
+// 已有转写或译文时按实际文本计算，否则按视频时长和口语语速估算。
+func estimatePlan(vctx *VideoContext, steps []PlannedStep, durationSource string) PlanEstimate {
+	estimate := PlanEstimate{VideoDurationSeconds: videoDurationSeconds(vctx)}
+	switch {
+	case estimate.VideoDurationSeconds <= 0:
+		estimate.DurationSource = durationSourceUnknown
+	case durationSource != "":
+		estimate.DurationSource = durationSource
+	case vctx.DurationSeconds <= 0 && vctx.Transcript != nil && len(vctx.Transcript.Segments) > 0:
+		estimate.DurationSource = durationSourceTranscript
+	default:
+		estimate.DurationSource = durationSourceContext
+	}
+
+	runs := make(map[string]bool, len(steps))
+	for _, step := range steps {
+		runs[step.Name] = step.Action == PlanActionRun
+	}
+	seconds := estimate.VideoDurationSeconds
+
+	if runs[StepNameTranscribe] {
+		estimate.ASRMinutes = math.Ceil(seconds/60*10) / 10
+	}
+
+	// 原文 token 与字幕条数：已有转写按实际文本计算
+	sourceTokens := int(math.Ceil(seconds * planSpeechWordsPerSecond * planTokensPerWord))
+	segmentCount := int(math.Ceil(seconds / planSecondsPerSegment))
+	if texts := transcriptTexts(collectTranscriptTextSegments(vctx.Transcript)); len(texts) > 0 {
+		sourceTokens = 0
+		for _, text := range texts {
+			sourceTokens += estimateTextTokens(text)
+		}
+		segmentCount = len(texts)
+	}
+
+	if runs[StepNameLLMTranslate] || runs[StepNameDeepseekTranslate] {
+		estimate.TranslationInputTokens = sourceTokens + segmentCount*planTranslatePromptPerSegment
+		estimate.TranslationOutputTokens = int(math.Ceil(float64(sourceTokens) * planTranslateOutputRatio))
+	}
+
+	if runs[StepNameGenerateMetadata] {
+		subtitleTokens := sourceTokens
+		if subtitleTokens > planMetadataSubtitleTokenLimit {
+			subtitleTokens = planMetadataSubtitleTokenLimit
+		}
+		estimate.MetadataTokens = planMetadataPromptTokens + subtitleTokens + planMetadataOutputTokens
+	}
+
+	if runs[StepNameSynthesizeSubtitle] {
+		estimate.TTSCharacters = int(math.Ceil(seconds * planSpeechHanPerSecond))
+		if translated := translatedCharacters(vctx.SubtitleAudios); translated > 0 {
+			estimate.TTSCharacters = translated
+		}
+	}
+
+	if estimate.DurationSource == durationSourceUnknown {
+		estimate.Notes = append(estimate.Notes, "视频时长未知，无法预估转写、翻译与配音用量")
+	}
+	return estimate
+}
+
+// estimateTextTokens 粗略估算文本 token 数：中日韩字符按 1 个/字，其余按词计
+func estimateTextTokens(text string) int {
+	cjk := 0
+	var rest strings.Builder
+	for _, r := range text {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			cjk++
+			rest.WriteRune(' ')
+			continue
+		}
+		rest.WriteRune(r)
+	}
+	words := len(strings.Fields(rest.String()))
+	return cjk + int(math.Ceil(float64(words)*planTokensPerWord))
+}
+
+func translatedCharacters(audios []SubtitleAudio) int {
+	total := 0
+	for _, audio := range audios {
+		total += len([]rune(strings.TrimSpace(audio.TranslatedText)))
+	}
+	return total
+}
@@ -0,0 +1,162 @@
+package workflow
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/difyz9/ytb2bili/internal/config"
+	"github.com/difyz9/ytb2bili/pkg/store/model"
+	"github.com/difyz9/ytb2bili/pkg/tools"
+	"go.uber.org/zap"
+)
+
+func TestChain_PlanMirrorsRunDecisionsWithoutSideEffects(t *testing.T) {
+	cache, db := newTestStepCache(t)
+	logger := zap.NewNop()
+	ctx := context.Background()
+
+	executed := false
+	initialize := &testStep{BaseStep: NewBaseStepWithOrder(StepNameInitialize, true, 1), executed: &executed}
+	download := NewToolStep(NewBaseStepWithOrder(StepNameDownloadVideo, true, 2), &countingRunner{},
+		func(vctx *VideoContext) (string, error) { return `{}`, nil },
+		func(vctx *VideoContext, result string) error { return nil },
+		WithSkipFunc(func(ctx context.Context, vctx *VideoContext) bool { return vctx.VideoPath != "" }))
+	transcribeCalls := 0
+	transcribe := checkpointTestStep{
+		BaseStep: NewBaseStepWithOrder(StepNameTranscribe, false, 3).
+			WithContextAccess(nil, []ContextField{FieldTranscript}),
+		calls: &transcribeCalls,
+		apply: func(vctx *VideoContext) {},
+	}
+	translateRunner := &countingRunner{}
+	translate := NewToolStep(NewBaseStepWithOrder(StepNameLLMTranslate, false, 4), translateRunner,
+		func(vctx *VideoContext) (string, error) { return `{}`, nil },
+		func(vctx *VideoContext, result string) error { return nil },
+		WithResultCache(cache, func(vctx *VideoContext) (string, error) { return stepCacheKey("texts"), nil }))
+	metadata := &testStepWithSkip{BaseStep: NewBaseStepWithOrder(StepNameGenerateMetadata, false, 5), skip: true}
+	synthesizeExecuted := false
+	synthesize := &testStep{BaseStep: NewBaseStepWithOrder(StepNameSynthesizeSubtitle, false, 6), executed: &synthesizeExecuted}
+
+	cache.Put(ctx, StepNameLLMTranslate, stepCacheKey("texts"), "cached translation")
+	chain := NewChainFromSteps([]Step{initialize, download, transcribe, translate, metadata, synthesize}, logger, "plan").
+		WithTimeouts(NewStepTimeouts(config.WorkflowConfig{}))
+
+	vctx := &VideoContext{
+		VideoID:         "plan-1",
+		VideoPath:       "/tmp/plan-1.mp4",
+		DurationSeconds: 600,
+		RestartFromStep: StepNameDownloadVideo,
+		Transcript: &tools.TranscriptResult{Segments: []tools.TranscriptSegment{
+			{Start: 0, End: 2, Text: "hello world"},
+			{Start: 2, End: 4, Text: "good morning"},
+		}},
+		restoredSteps: map[string]struct{}{StepNameTranscribe: {}},
+	}
+	plan := buildChainPlan(WithVideoID(ctx, "plan-1"), chain, PlatformYouTube, "plan-1", vctx, durationSourceProbe)
+
+	want := map[string]string{
+		StepNameInitialize:         PlanActionBeforeRestart,
+		StepNameDownloadVideo:      PlanActionSkip,
+		StepNameTranscribe:         PlanActionRestore,
+		StepNameLLMTranslate:       PlanActionCached,
+		StepNameGenerateMetadata:   PlanActionSkip,
+		StepNameSynthesizeSubtitle: PlanActionRun,
+	}
+	if len(plan.Steps) != len(want) {
+		t.Fatalf("expected %d planned steps, got %d", len(want), len(plan.Steps))
+	}
+	for _, step := range plan.Steps {
+		if step.Action != want[step.Name] {
+			t.Fatalf("%s: expected action %s, got %s", step.Name, want[step.Name], step.Action)
+		}
+	}
+	// 超时按视频时长放宽：配音 900s + 10 分钟 × 60s
+	if got := plan.Steps[5].TimeoutSeconds; got != 1500 {
+		t.Fatalf("expected scaled timeout 1500s, got %d", got)
+	}
+
+	// 只有配音会调用外部服务：转写已恢复、翻译命中缓存、元数据被跳过
+	estimate := plan.Estimate
+	if estimate.DurationSource != durationSourceProbe || estimate.ASRMinutes != 0 ||
+		estimate.TranslationInputTokens != 0 || estimate.MetadataTokens != 0 {
+		t.Fatalf("unexpected estimate %+v", estimate)
+	}
+	if estimate.TTSCharacters != 2700 {
+		t.Fatalf("expected 600s × 4.5 TTS characters, got %d", estimate.TTSCharacters)
+	}
+
+	// 计划不执行步骤、不登记步骤记录，也不改变续跑状态
+	var steps int64
+	db.Model(&model.TaskStep{}).Count(&steps)
+	if executed || synthesizeExecuted || transcribeCalls != 0 || translateRunner.calls != 0 || steps != 0 {
+		t.Fatalf("expected no side effects, executed=%v synthesized=%v transcribe=%d translate=%d task_steps=%d",
+			executed, synthesizeExecuted, transcribeCalls, translateRunner.calls, steps)
+	}
+	if vctx.restartStepActivated {
+		t.Fatal("expected plan to leave restart state untouched")
+	}
+	if stats, _ := cache.Stats(ctx); len(stats) != 1 || stats[0].Hits != 0 {
+		t.Fatalf("expected cache lookup not to count as a hit, got %+v", stats)
+	}
+}
+
+func TestEstimatePlan_UsesDurationWhenNoTranscript(t *testing.T) {
+	steps := []PlannedStep{
+		{Name: StepNameTranscribe, Action: PlanActionRun},
+		{Name: StepNameLLMTranslate, Action: PlanActionRun},
+		{Name: StepNameGenerateMetadata, Action: PlanActionRun},
+	}
+	estimate := estimatePlan(&VideoContext{DurationSeconds: 90}, steps, durationSourceRecord)
+	if estimate.ASRMinutes != 1.5 {
+		t.Fatalf("expected 1.5 ASR minutes, got %v", estimate.ASRMinutes)
+	}
+	// 90s × 2.5 词/秒 × 1.3 token/词 ≈ 293 token，23 条字幕
+	if estimate.TranslationInputTokens != 293+23*planTranslatePromptPerSegment || estimate.TranslationOutputTokens != 352 {
+		t.Fatalf("unexpected translation estimate %+v", estimate)
+	}
+	if estimate.MetadataTokens != planMetadataPromptTokens+planMetadataOutputTokens+293 {
+		t.Fatalf("unexpected metadata estimate %d", estimate.MetadataTokens)
+	}
+
+	unknown := estimatePlan(&VideoContext{}, steps, "")
+	if unknown.DurationSource != durationSourceUnknown || len(unknown.Notes) == 0 {
+		t.Fatalf("expected unknown duration to be reported, got %+v", unknown)
+	}
+}
+
+func TestYouTubeChain_PlanSavesProbedDurationForRun(t *testing.T) {
+	db := openCheckpointTestDB(t)
+	videoID := "plan-long"
+	url := "https://www.youtube.com/watch?v=plan-long"
+	db.Create(&model.Video{VideoID: videoID, URL: url, Status: model.VideoStatusQueued})
+
+	download := &deadlineStep{BaseStep: NewBaseStepWithOrder(StepNameDownloadVideo, true, 1)}
+	chain := NewChainFromSteps([]Step{download}, zap.NewNop(), "youtube").
+		WithTimeouts(NewStepTimeouts(config.WorkflowConfig{}))
+	prober := &fakeVideoProber{seconds: 3 * 3600}
+	yc := &YouTubeChain{chain: chain, db: db, logger: zap.NewNop(), prober: prober}
+
+	vctx := yc.defaultVideoContext()
+	vctx.VideoURL = url
+	plan, err := yc.Plan(context.Background(), vctx, videoID, "")
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	if plan.Estimate.DurationSource != durationSourceProbe || plan.Steps[0].TimeoutSeconds != 12600 {
+		t.Fatalf("expected probed duration to scale the planned timeout, got %+v", plan)
+	}
+
+	// 运行时读取计划写入的时长，不再探测，超时与计划一致
+	run := yc.defaultVideoContext()
+	run.VideoURL = url
+	if _, err := yc.ProcessContextWithTracking(context.Background(), run, videoID, ""); err != nil {
+		t.Fatalf("run chain: %v", err)
+	}
+	if prober.calls != 1 {
+		t.Fatalf("expected run to reuse the planned duration, probed %d times", prober.calls)
+	}
+	if planned := time.Duration(plan.Steps[0].TimeoutSeconds) * time.Second; download.remaining < planned-time.Minute {
+		t.Fatalf("expected run timeout %s to match the plan, got %s", planned, download.remaining)
+	}
+}
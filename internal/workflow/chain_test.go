@@ -3,7 +3,9 @@ package workflow
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
+	"time"
 
 	"go.uber.org/fx"
 	"go.uber.org/fx/fxtest"
@@ -444,3 +446,225 @@ func TestFxModule(t *testing.T) {
 	app.RequireStop()
 }
 
+
+type testBlockingStep struct {
+	BaseStep
+	started chan<- string
+	release <-chan struct{}
+}
+
+func (s *testBlockingStep) Execute(ctx context.Context, input any) (any, error) {
+	s.started <- s.Name()
+	<-s.release
+	return input, nil
+}
+
+func TestChain_RunsIndependentStepsConcurrently(t *testing.T) {
+	started := make(chan string, 4)
+	release := make(chan struct{})
+	executedRoot := false
+	executedLast := false
+
+	root := &testStep{BaseStep: NewBaseStepWithOrder("root", true, 1), executed: &executedRoot}
+	left := &testBlockingStep{
+		BaseStep: NewBaseStepWithOrder("left", true, 2).WithDependsOn("root").
+			WithContextAccess([]ContextField{FieldVideoPath}, []ContextField{FieldAudioPath}),
+		started: started,
+		release: release,
+	}
+	right := &testBlockingStep{
+		BaseStep: NewBaseStepWithOrder("right", true, 3).WithDependsOn("root").
+			WithContextAccess([]ContextField{FieldVideoURL}, []ContextField{FieldThumbnailPath}),
+		started:  started,
+		release:  release,
+	}
+	last := &testStep{BaseStep: NewBaseStepWithOrder("last", true, 4), executed: &executedLast}
+
+	chain := NewChainFromSteps([]Step{last, right, left, root}, zaptest.NewLogger(t), "dag")
+
+	done := make(chan *Result, 1)
+	go func() { done <- chain.Run(context.Background(), "input") }()
+
+	seen := map[string]bool{}
+	for len(seen) < 2 {
+		select {
+		case name := <-started:
+			seen[name] = true
+		case <-time.After(2 * time.Second):
+			t.Fatalf("independent steps did not start concurrently, started: %v", seen)
+		}
+	}
+	if executedLast {
+		t.Fatal("undeclared step must wait for all lower-order steps")
+	}
+	close(release)
+
+	result := <-done
+	if !result.Success {
+		t.Fatalf("expected success, got error: %v", result.Error)
+	}
+	if !executedRoot || !executedLast || result.ExecutedSteps != 4 {
+		t.Fatalf("expected all 4 steps to execute, got %d", result.ExecutedSteps)
+	}
+}
+
+func TestChain_RequiredFailureStopsDependents(t *testing.T) {
+	executedRoot, executedChild, executedSibling := false, false, false
+
+	root := &testStep{BaseStep: NewBaseStepWithOrder("root", true, 1), executed: &executedRoot, shouldFail: true}
+	child := &testStep{BaseStep: NewBaseStepWithOrder("child", true, 2).WithDependsOn("root"), executed: &executedChild}
+	sibling := &testStep{BaseStep: NewBaseStepWithOrder("sibling", true, 3).WithDependsOn("child"), executed: &executedSibling}
+
+	chain := NewChainFromSteps([]Step{root, child, sibling}, zaptest.NewLogger(t), "dag")
+	result := chain.Run(context.Background(), "input")
+
+	if result.Success {
+		t.Fatal("expected chain to fail")
+	}
+	if executedChild || executedSibling {
+		t.Fatal("dependents of a failed required step must not run")
+	}
+}
+
+func TestBuildStepGraph_ContextAccessConflictAddsEdge(t *testing.T) {
+	writer := NewBaseStepWithOrder("writer", true, 1).
+		WithDependsOn().
+		WithContextAccess(nil, []ContextField{FieldAudioPath})
+	reader := NewBaseStepWithOrder("reader", true, 2).
+		WithDependsOn().
+		WithContextAccess([]ContextField{FieldAudioPath}, nil)
+	other := NewBaseStepWithOrder("other", true, 3).
+		WithDependsOn().
+		WithContextAccess([]ContextField{FieldVideoURL}, []ContextField{FieldThumbnailPath})
+
+	steps := []Step{
+		&testStepWithSkip{BaseStep: writer},
+		&testStepWithSkip{BaseStep: reader},
+		&testStepWithSkip{BaseStep: other},
+	}
+	graph := buildStepGraph(steps, zaptest.NewLogger(t))
+
+	if len(graph.deps[1]) != 1 || graph.deps[1][0] != 0 {
+		t.Fatalf("expected reader to depend on writer, got %v", graph.deps[1])
+	}
+	if len(graph.deps[2]) != 0 {
+		t.Fatalf("expected independent step to have no deps, got %v", graph.deps[2])
+	}
+}
+
+func TestBuildStepGraph_UndeclaredContextAccessConflictsWithAll(t *testing.T) {
+	steps := []Step{
+		&testStepWithSkip{BaseStep: NewBaseStepWithOrder("download", true, 1).
+			WithDependsOn().
+			WithContextAccess([]ContextField{FieldVideoURL}, []ContextField{FieldVideoPath})},
+		&testStepWithSkip{BaseStep: NewBaseStepWithOrder("metadata", true, 2)},
+		&testStepWithSkip{BaseStep: NewBaseStepWithOrder("plugin", true, 3).WithDependsOn()},
+		&testStepWithSkip{BaseStep: NewBaseStepWithOrder("thumbnail", true, 4).
+			WithDependsOn().
+			WithContextAccess([]ContextField{FieldVideoURL}, []ContextField{FieldThumbnailPath})},
+		&testStepWithSkip{BaseStep: NewBaseStepWithOrder("noop", true, 5).
+			WithDependsOn().
+			WithContextAccess([]ContextField{}, []ContextField{})},
+	}
+	graph := buildStepGraph(steps, zaptest.NewLogger(t))
+
+	if fmt.Sprint(graph.deps[2]) != "[0 1]" {
+		t.Fatalf("expected step declaring dependencies but not access to wait for all earlier steps, got %v", graph.deps[2])
+	}
+	if fmt.Sprint(graph.deps[3]) != "[1 2]" {
+		t.Fatalf("expected declared step to wait for undeclared earlier steps only, got %v", graph.deps[3])
+	}
+	if fmt.Sprint(graph.deps[4]) != "[1 2]" {
+		t.Fatalf("expected empty access to conflict only with undeclared steps, got %v", graph.deps[4])
+	}
+}
+
+func TestBuildStepGraph_MissingDependencyFallsBackToOrder(t *testing.T) {
+	steps := []Step{
+		&testStepWithSkip{BaseStep: NewBaseStepWithOrder("init", true, 1)},
+		&testStepWithSkip{BaseStep: NewBaseStepWithOrder("download_other", true, 2)},
+		&testStepWithSkip{BaseStep: NewBaseStepWithOrder("extract", true, 3).WithDependsOn("download")},
+	}
+	graph := buildStepGraph(steps, zaptest.NewLogger(t))
+
+	if len(graph.deps[2]) != 2 {
+		t.Fatalf("expected step with missing dependency to wait for all earlier steps, got %v", graph.deps[2])
+	}
+}
+
+type testContextBlockingStep struct {
+	BaseStep
+	started chan<- string
+}
+
+func (s *testContextBlockingStep) Execute(ctx context.Context, input any) (any, error) {
+	s.started <- s.Name()
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestChain_ShutdownStopsAtStepBoundary(t *testing.T) {
+	started := make(chan string, 1)
+	release := make(chan struct{})
+	executedNext := false
+
+	first := &testBlockingStep{BaseStep: NewBaseStepWithOrder("first", true, 1), started: started, release: release}
+	next := &testStep{BaseStep: NewBaseStepWithOrder("next", true, 2), executed: &executedNext}
+	chain := NewChainFromSteps([]Step{first, next}, zaptest.NewLogger(t), "shutdown")
+
+	registry := NewTaskRuntimeRegistry()
+	ctx, done := registry.Track(context.Background(), "v1")
+	results := make(chan *Result, 1)
+	go func() {
+		results <- chain.Run(ctx, "input")
+		done()
+	}()
+	<-started
+
+	stopped := make(chan []string, 1)
+	go func() { stopped <- registry.Shutdown(2*time.Second, time.Second) }()
+	for !registry.Draining() {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+
+	result := <-results
+	if result.Success || !errors.Is(result.Error, ErrShutdown) {
+		t.Fatalf("expected chain to stop with ErrShutdown, got success=%v err=%v", result.Success, result.Error)
+	}
+	if executedNext || result.ExecutedSteps != 1 {
+		t.Fatalf("running step must finish and the next one must not start, executed=%d", result.ExecutedSteps)
+	}
+	<-stopped
+}
+
+func TestTaskRuntimeRegistry_ShutdownCancelsAfterGrace(t *testing.T) {
+	started := make(chan string, 1)
+	step := &testContextBlockingStep{BaseStep: NewBaseStepWithOrder("long", true, 1), started: started}
+	chain := NewChainFromSteps([]Step{step}, zaptest.NewLogger(t), "shutdown")
+
+	registry := NewTaskRuntimeRegistry()
+	ctx, done := registry.Track(context.Background(), "v1")
+	results := make(chan *Result, 1)
+	go func() {
+		result := chain.Run(ctx, "input")
+		if IsShutdownInterrupted(ctx, result.Error) {
+			registry.Interrupted("v1")
+		}
+		done()
+		results <- result
+	}()
+	<-started
+
+	interrupted := registry.Shutdown(20*time.Millisecond, 2*time.Second)
+	if len(interrupted) != 1 || interrupted[0] != "v1" {
+		t.Fatalf("expected v1 to be reported as interrupted, got %v", interrupted)
+	}
+	result := <-results
+	if !errors.Is(result.Error, ErrShutdown) || !result.StepDetails["long"].Cancelled {
+		t.Fatalf("expected long step to be cancelled by shutdown, got %v", result.Error)
+	}
+	if registry.Has("v1") {
+		t.Fatal("interrupted task must be deregistered")
+	}
+}
@@ -0,0 +1,317 @@
+package workflow
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/difyz9/ytb2bili/pkg/store/model"
+	"github.com/difyz9/ytb2bili/pkg/tools"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ============================================================================
+// VideoContext 检查点
+// ============================================================================
+//
+// 任务链每完成一个步骤就把 VideoContext 的产出字段写入 tb_video_checkpoints。
+// 续跑 / 重试时先用检查点回填上下文，再跳过「数据库中已完成且产出仍可用」的步骤，
+// 这样进程重启或单步重试都不会重新执行 ASR 与翻译。
+
+// videoContextSnapshot 检查点中保存的 VideoContext 字段。
+// 只保存步骤产出；翻译、配音、任务链开关等配置以本次请求为准，不从检查点恢复。
+type videoContextSnapshot struct {
+	Platform           string                  `json:"platform,omitempty"`
+	VideoURL           string                  `json:"video_url,omitempty"`
+	VideoID            string                  `json:"video_id,omitempty"`
+	UserID             string                  `json:"user_id,omitempty"`
+	DurationSeconds    float64                 `json:"duration_seconds,omitempty"`
+	VideoPath          string                  `json:"video_path,omitempty"`
+	ThumbnailPath      string                  `json:"thumbnail_path,omitempty"`
+	AudioPath          string                  `json:"audio_path,omitempty"`
+	DouyinVideoInfo    *tools.DouyinVideoInfo  `json:"douyin_video_info,omitempty"`
+	Transcript         *tools.TranscriptResult `json:"transcript,omitempty"`
+	SubtitleAudios     []SubtitleAudio         `json:"subtitle_audios,omitempty"`
+	Title              string                  `json:"title,omitempty"`
+	Description        string                  `json:"description,omitempty"`
+	Tags               string                  `json:"tags,omitempty"`
+	BiliBVID           string                  `json:"bili_bvid,omitempty"`
+	BiliAID            int64                   `json:"bili_aid,omitempty"`
+	TranslationSkipped bool                    `json:"translation_skipped,omitempty"`
+}
+
+func snapshotVideoContext(vctx *VideoContext) videoContextSnapshot {
+	return videoContextSnapshot{
+		Platform:           vctx.Platform,
+		VideoURL:           vctx.VideoURL,
+		VideoID:            vctx.VideoID,
+		UserID:             vctx.UserID,
+		DurationSeconds:    vctx.DurationSeconds,
+		VideoPath:          vctx.VideoPath,
+		ThumbnailPath:      vctx.ThumbnailPath,
+		AudioPath:          vctx.AudioPath,
+		DouyinVideoInfo:    vctx.DouyinVideoInfo,
+		Transcript:         vctx.Transcript,
+		SubtitleAudios:     vctx.SubtitleAudios,
+		Title:              vctx.Title,
+		Description:        vctx.Description,
+		Tags:               vctx.Tags,
+		BiliBVID:           vctx.BiliBVID,
+		BiliAID:            vctx.BiliAID,
+		TranslationSkipped: vctx.TranslationSkipped,
+	}
+}
+
+// copyFields 只从 vctx 复制所列字段的当前值，其余字段保持不变。
+// 并发步骤仍在运行时使用：与刚完成的步骤读写冲突的步骤不会同时运行，它写入的字段此时不会被改动。
+func (s *videoContextSnapshot) copyFields(vctx *VideoContext, fields []ContextField) {
+	for _, field := range fields {
+		switch field {
+		case FieldVideoID:
+			s.VideoID = vctx.VideoID
+		case FieldVideoURL:
+			s.VideoURL = vctx.VideoURL
+		case FieldUserID:
+			s.UserID = vctx.UserID
+		case FieldDuration:
+			s.DurationSeconds = vctx.DurationSeconds
+		case FieldVideoPath:
+			s.VideoPath = vctx.VideoPath
+		case FieldThumbnailPath:
+			s.ThumbnailPath = vctx.ThumbnailPath
+		case FieldAudioPath:
+			s.AudioPath = vctx.AudioPath
+		case FieldDouyinVideoInfo:
+			s.DouyinVideoInfo = vctx.DouyinVideoInfo
+		case FieldTranscript:
+			s.Transcript = vctx.Transcript
+		case FieldSubtitleAudios:
+			s.SubtitleAudios = vctx.SubtitleAudios
+		case FieldMetadata:
+			s.Title, s.Description, s.Tags = vctx.Title, vctx.Description, vctx.Tags
+		case FieldBiliUpload:
+			s.BiliBVID, s.BiliAID = vctx.BiliBVID, vctx.BiliAID
+		case FieldTranslation:
+			s.TranslationSkipped = vctx.TranslationSkipped
+		}
+	}
+}
+
+// mergeInto 将快照回填到 vctx 中尚未设置的字段；本地文件已不存在的路径不回填
+func (s videoContextSnapshot) mergeInto(vctx *VideoContext) {
+	fillString := func(dst *string, src string) {
+		if strings.TrimSpace(*dst) == "" && strings.TrimSpace(src) != "" {
+			*dst = src
+		}
+	}
+	fillPath := func(dst *string, src string) {
+		if strings.TrimSpace(*dst) != "" || strings.TrimSpace(src) == "" {
+			return
+		}
+		if _, err := os.Stat(src); err == nil {
+			*dst = src
+		}
+	}
+
+	fillString(&vctx.Platform, s.Platform)
+	fillString(&vctx.VideoURL, s.VideoURL)
+	fillString(&vctx.VideoID, s.VideoID)
+	fillString(&vctx.UserID, s.UserID)
+	if vctx.DurationSeconds <= 0 {
+		vctx.DurationSeconds = s.DurationSeconds
+	}
+	fillPath(&vctx.VideoPath, s.VideoPath)
+	fillPath(&vctx.ThumbnailPath, s.ThumbnailPath)
+	fillPath(&vctx.AudioPath, s.AudioPath)
+	fillString(&vctx.Title, s.Title)
+	fillString(&vctx.Description, s.Description)
+	fillString(&vctx.Tags, s.Tags)
+	fillString(&vctx.BiliBVID, s.BiliBVID)
+	if vctx.BiliAID == 0 {
+		vctx.BiliAID = s.BiliAID
+	}
+	if vctx.DouyinVideoInfo == nil {
+		vctx.DouyinVideoInfo = s.DouyinVideoInfo
+	}
+	if vctx.Transcript == nil {
+		vctx.Transcript = s.Transcript
+	}
+	if len(vctx.SubtitleAudios) == 0 {
+		vctx.SubtitleAudios = s.SubtitleAudios
+	}
+	vctx.TranslationSkipped = vctx.TranslationSkipped || s.TranslationSkipped
+}
+
+// CheckpointStore 读写 VideoContext 检查点
+type CheckpointStore struct {
+	db     *gorm.DB
+	logger *zap.Logger
+}
+
+// NewCheckpointStore 创建检查点存储
+func NewCheckpointStore(db *gorm.DB, logger *zap.Logger) *CheckpointStore {
+	return &CheckpointStore{db: db, logger: logger}
+}
+
+// Save 覆盖写入视频的最新检查点
+func (s *CheckpointStore) Save(videoID, lastStep string, completedSteps []string, vctx *VideoContext) error {
+	if vctx == nil {
+		return nil
+	}
+	return s.SaveSnapshot(videoID, lastStep, completedSteps, snapshotVideoContext(vctx))
+}
+
+// SaveSnapshot 以已取好的快照覆盖写入视频的最新检查点
+func (s *CheckpointStore) SaveSnapshot(videoID, lastStep string, completedSteps []string, snapshot videoContextSnapshot) error {
+	if s == nil || s.db == nil || strings.TrimSpace(videoID) == "" {
+		return nil
+	}
+	payload, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	completed, err := json.Marshal(completedSteps)
+	if err != nil {
+		return err
+	}
+
+	checkpoint := &model.VideoCheckpoint{
+		VideoID:        videoID,
+		LastStep:       lastStep,
+		CompletedSteps: string(completed),
+		Payload:        string(payload),
+	}
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "video_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"last_step", "completed_steps", "payload", "updated_at", "deleted_at"}),
+	}).Create(checkpoint).Error
+}
+
+// Restore 用检查点回填 vctx，并标记可直接跳过的已完成步骤。
+// 只有检查点记录为完成、且 tb_task_steps 中仍为 completed 的步骤才会被跳过；
+// 指定了 RestartFromStep 时，起点及之后的步骤一律重新执行。
+// 返回是否找到并应用了检查点。
+func (s *CheckpointStore) Restore(videoID string, vctx *VideoContext) bool {
+	if s == nil || s.db == nil || strings.TrimSpace(videoID) == "" || vctx == nil {
+		return false
+	}
+
+	var checkpoint model.VideoCheckpoint
+	if err := s.db.Where("video_id = ?", videoID).First(&checkpoint).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logger.Warn("读取视频检查点失败", zap.String("video_id", videoID), zap.Error(err))
+		}
+		return false
+	}
+
+	var snapshot videoContextSnapshot
+	if err := json.Unmarshal([]byte(checkpoint.Payload), &snapshot); err != nil {
+		s.logger.Warn("解析视频检查点失败", zap.String("video_id", videoID), zap.Error(err))
+		return false
+	}
+	snapshot.mergeInto(vctx)
+
+	var completed []string
+	_ = json.Unmarshal([]byte(checkpoint.CompletedSteps), &completed)
+	vctx.restoredSteps = s.stillCompletedSteps(videoID, vctx.RestartFromStep, completed)
+
+	s.logger.Info("已从检查点恢复视频处理上下文",
+		zap.String("video_id", videoID),
+		zap.String("last_step", checkpoint.LastStep),
+		zap.Int("restored_steps", len(vctx.restoredSteps)))
+	return true
+}
+
+func (s *CheckpointStore) stillCompletedSteps(videoID, restartFromStep string, completed []string) map[string]struct{} {
+	if len(completed) == 0 {
+		return nil
+	}
+
+	var rows []model.TaskStep
+	if err := s.db.Where("video_id = ? AND step_name IN ?", videoID, completed).Find(&rows).Error; err != nil {
+		s.logger.Warn("读取任务步骤状态失败", zap.String("video_id", videoID), zap.Error(err))
+		return nil
+	}
+
+	restartOrder := -1
+	if restart := strings.TrimSpace(restartFromStep); restart != "" {
+		var target model.TaskStep
+		if err := s.db.Where("video_id = ? AND step_name = ?", videoID, restart).First(&target).Error; err == nil {
+			restartOrder = target.StepOrder
+		}
+	}
+
+	restored := make(map[string]struct{}, len(rows))
+	for _, row := range rows {
+		if row.Status != model.TaskStepStatusCompleted {
+			continue
+		}
+		if restartOrder >= 0 && row.StepOrder >= restartOrder {
+			continue
+		}
+		restored[row.StepName] = struct{}{}
+	}
+	return restored
+}
+
+// Delete 删除视频检查点
+func (s *CheckpointStore) Delete(videoID string) error {
+	if s == nil || s.db == nil || strings.TrimSpace(videoID) == "" {
+		return nil
+	}
+	return s.db.Unscoped().Where("video_id = ?", videoID).Delete(&model.VideoCheckpoint{}).Error
+}
+
+// canRestoreStep 判断步骤是否可直接沿用检查点结果：
+// 检查点标记为已完成，且步骤声明的所有写入字段在回填后的上下文中仍然可用。
+// 未声明写入字段的步骤（如初始化、保存数据库）始终重新执行。
+func canRestoreStep(step Step, input any) bool {
+	vctx, ok := input.(*VideoContext)
+	if !ok || vctx == nil || len(vctx.restoredSteps) == 0 {
+		return false
+	}
+	if _, ok := vctx.restoredSteps[step.Name()]; !ok {
+		return false
+	}
+	access, ok := step.(StepWithContextAccess)
+	if !ok || len(access.Writes()) == 0 {
+		return false
+	}
+	for _, field := range access.Writes() {
+		if !contextFieldPopulated(vctx, field) {
+			return false
+		}
+	}
+	return true
+}
+
+func contextFieldPopulated(vctx *VideoContext, field ContextField) bool {
+	switch field {
+	case FieldVideoID:
+		return strings.TrimSpace(vctx.VideoID) != ""
+	case FieldVideoURL:
+		return strings.TrimSpace(vctx.VideoURL) != ""
+	case FieldUserID:
+		return strings.TrimSpace(vctx.UserID) != ""
+	case FieldVideoPath:
+		return strings.TrimSpace(vctx.VideoPath) != ""
+	case FieldThumbnailPath:
+		return strings.TrimSpace(vctx.ThumbnailPath) != ""
+	case FieldAudioPath:
+		return strings.TrimSpace(vctx.AudioPath) != ""
+	case FieldTranscript:
+		return vctx.Transcript != nil && len(vctx.Transcript.Segments) > 0
+	case FieldSubtitleAudios:
+		return len(vctx.SubtitleAudios) > 0
+	case FieldMetadata:
+		return strings.TrimSpace(vctx.Title) != ""
+	case FieldBiliUpload:
+		return strings.TrimSpace(vctx.BiliBVID) != ""
+	default:
+		// 配置类字段随请求提供，不影响是否可恢复
+		return true
+	}
+}
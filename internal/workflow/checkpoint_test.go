@@ -0,0 +1,191 @@
+package workflow
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/difyz9/ytb2bili/pkg/store/model"
+	"github.com/difyz9/ytb2bili/pkg/tools"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type checkpointTestStep struct {
+	BaseStep
+	calls *int
+	apply func(vctx *VideoContext)
+}
+
+func (s checkpointTestStep) Execute(ctx context.Context, input any) (any, error) {
+	vctx, err := mustVideoContext(input)
+	if err != nil {
+		return nil, err
+	}
+	*s.calls++
+	s.apply(vctx)
+	return vctx, nil
+}
+
+func openCheckpointTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&model.TaskStep{}, &model.VideoCheckpoint{}, &model.Video{}, &model.TaskRun{}, &model.TaskStepAttempt{}, &model.VideoStatusTransition{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func TestCheckpoint_ResumeSkipsCompletedTranscription(t *testing.T) {
+	db := openCheckpointTestDB(t)
+	logger := zap.NewNop()
+	videoID := "ckpt-1"
+
+	transcribeCalls := 0
+	transcribe := checkpointTestStep{
+		BaseStep: NewBaseStepWithOrder(StepNameTranscribe, false, 5).
+			WithContextAccess(nil, []ContextField{FieldTranscript}),
+		calls: &transcribeCalls,
+		apply: func(vctx *VideoContext) {
+			vctx.Transcript = &tools.TranscriptResult{
+				Language: "en",
+				Segments: []tools.TranscriptSegment{{Start: 0, End: 1.5, Text: "hello"}},
+			}
+		},
+	}
+	translate := &testStep{BaseStep: NewBaseStepWithOrder(StepNameLLMTranslate, true, 6)}
+	translateExecuted := false
+	translate.executed = &translateExecuted
+
+	chain := NewChainFromSteps([]Step{transcribe, translate}, logger, "ckpt")
+
+	// 第一次运行：转写成功、翻译失败
+	translate.shouldFail = true
+	if _, err := RunChainWithTracking(WithVideoID(context.Background(), videoID), chain, db, logger, videoID, &VideoContext{VideoID: videoID}); err == nil {
+		t.Fatal("expected first run to fail at translation")
+	}
+	if transcribeCalls != 1 {
+		t.Fatalf("expected transcribe to run once, got %d", transcribeCalls)
+	}
+
+	var checkpoint model.VideoCheckpoint
+	if err := db.Where("video_id = ?", videoID).First(&checkpoint).Error; err != nil {
+		t.Fatalf("expected checkpoint row: %v", err)
+	}
+	if checkpoint.LastStep != StepNameTranscribe {
+		t.Fatalf("expected last step %s, got %s", StepNameTranscribe, checkpoint.LastStep)
+	}
+
+	// 第二次运行：从检查点恢复，不应重新转写
+	translate.shouldFail = false
+	resumed := &VideoContext{VideoID: videoID}
+	if !NewCheckpointStore(db, logger).Restore(videoID, resumed) {
+		t.Fatal("expected checkpoint to be restored")
+	}
+	if resumed.Transcript == nil || len(resumed.Transcript.Segments) != 1 {
+		t.Fatalf("expected transcript to be rehydrated, got %+v", resumed.Transcript)
+	}
+
+	out, err := RunChainWithTracking(WithVideoID(context.Background(), videoID), chain, db, logger, videoID, resumed)
+	if err != nil {
+		t.Fatalf("resume run failed: %v", err)
+	}
+	if transcribeCalls != 1 {
+		t.Fatalf("expected transcribe not to rerun, got %d calls", transcribeCalls)
+	}
+	if !translateExecuted || out.Transcript == nil {
+		t.Fatal("expected translation to run with restored transcript")
+	}
+}
+
+func TestCheckpoint_RestartFromStepRerunsTarget(t *testing.T) {
+	db := openCheckpointTestDB(t)
+	logger := zap.NewNop()
+	videoID := "ckpt-2"
+
+	store := NewCheckpointStore(db, logger)
+	seed := &VideoContext{Transcript: &tools.TranscriptResult{Segments: []tools.TranscriptSegment{{Text: "hi"}}}}
+	if err := store.Save(videoID, StepNameTranscribe, []string{StepNameTranscribe}, seed); err != nil {
+		t.Fatalf("save checkpoint: %v", err)
+	}
+	db.Create(&model.TaskStep{VideoID: videoID, StepName: StepNameTranscribe, StepOrder: 5, Status: model.TaskStepStatusCompleted})
+
+	restarted := &VideoContext{RestartFromStep: StepNameTranscribe}
+	store.Restore(videoID, restarted)
+	if _, ok := restarted.restoredSteps[StepNameTranscribe]; ok {
+		t.Fatal("restart target must not be restored from checkpoint")
+	}
+
+	plain := &VideoContext{}
+	store.Restore(videoID, plain)
+	if _, ok := plain.restoredSteps[StepNameTranscribe]; !ok {
+		t.Fatal("expected completed step to be restorable")
+	}
+}
+
+func TestCheckpoint_WrittenWhileSiblingStillRuns(t *testing.T) {
+	db := openCheckpointTestDB(t)
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sql db: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1) // 内存 SQLite 每个连接是独立的库
+	logger := zap.NewNop()
+	videoID := "ckpt-3"
+
+	thumbnailCalls, audioCalls := 0, 0
+	release := make(chan struct{})
+	thumbnail := checkpointTestStep{
+		BaseStep: NewBaseStepWithOrder(StepNameDownloadThumbnail, false, 2).
+			WithDependsOn().
+			WithContextAccess([]ContextField{FieldVideoURL}, []ContextField{FieldThumbnailPath}),
+		calls: &thumbnailCalls,
+		apply: func(vctx *VideoContext) { vctx.ThumbnailPath = "/data/ckpt-3/cover.jpg" },
+	}
+	audio := checkpointTestStep{
+		BaseStep: NewBaseStepWithOrder(StepNameExtractAudio, true, 3).
+			WithDependsOn().
+			WithContextAccess([]ContextField{FieldVideoPath}, []ContextField{FieldAudioPath}),
+		calls: &audioCalls,
+		apply: func(vctx *VideoContext) {
+			<-release
+			vctx.AudioPath = "/data/ckpt-3/audio.mp3"
+		},
+	}
+	chain := NewChainFromSteps([]Step{thumbnail, audio}, logger, "ckpt")
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := RunChainWithTracking(WithVideoID(context.Background(), videoID), chain, db, logger, videoID, &VideoContext{VideoID: videoID})
+		done <- err
+	}()
+
+	// 缩略图完成后音频仍在运行：检查点必须已经记录缩略图
+	var checkpoint model.VideoCheckpoint
+	deadline := time.Now().Add(2 * time.Second)
+	for db.Where("video_id = ?", videoID).First(&checkpoint).Error != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("expected checkpoint to be written while a sibling step is still running")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if checkpoint.LastStep != StepNameDownloadThumbnail || !strings.Contains(checkpoint.CompletedSteps, StepNameDownloadThumbnail) ||
+		!strings.Contains(checkpoint.Payload, "cover.jpg") || strings.Contains(checkpoint.Payload, "audio.mp3") {
+		t.Fatalf("unexpected checkpoint while audio runs: %+v", checkpoint)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("chain failed: %v", err)
+	}
+	db.Where("video_id = ?", videoID).First(&checkpoint)
+	if !strings.Contains(checkpoint.CompletedSteps, StepNameExtractAudio) || !strings.Contains(checkpoint.Payload, "cover.jpg") ||
+		!strings.Contains(checkpoint.Payload, "audio.mp3") {
+		t.Fatalf("expected final checkpoint with both steps, got %+v", checkpoint)
+	}
+}
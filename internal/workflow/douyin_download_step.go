@@ -59,6 +59,7 @@ func NewDownloadDouyinVideoStep(params DownloadDouyinVideoStepParams) *DownloadD
 			WithSkipFunc(func(ctx context.Context, vctx *VideoContext) bool {
 				return vctx.VideoPath != ""
 			}),
+			WithRetryPolicy(DefaultRetryPolicy()),
 			WithOnSuccess(func(ctx context.Context, output any) error {
 				vctx, ok := output.(*VideoContext)
 				if !ok {
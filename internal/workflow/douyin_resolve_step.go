@@ -51,6 +51,7 @@ func NewResolveDouyinShareStep(params ResolveDouyinShareStepParams) *ResolveDouy
 			WithSkipFunc(func(ctx context.Context, vctx *VideoContext) bool {
 				return vctx.DouyinVideoInfo != nil && strings.TrimSpace(vctx.DouyinVideoInfo.Data.AwemeID) != ""
 			}),
+			WithRetryPolicy(DefaultRetryPolicy()),
 			WithOnSuccess(func(ctx context.Context, output any) error {
 				vctx, ok := output.(*VideoContext)
 				if !ok {
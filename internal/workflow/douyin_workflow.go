@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/difyz9/ytb2bili/internal/config"
+	"github.com/difyz9/ytb2bili/internal/events"
 	"github.com/difyz9/ytb2bili/internal/service"
 	"github.com/difyz9/ytb2bili/pkg/llm"
 	"github.com/difyz9/ytb2bili/pkg/tikhub"
@@ -28,6 +29,7 @@ var DouyinWorkflowModule = fx.Module("douyin_workflow",
 		NewSynthesizeSubtitleAudioStep,
 		// NewAddWatermarkStep,
 		NewSaveDatabaseStep,
+		NewReviewGateStep,
 	)...),
 	fx.Provide(NewDouyinChain),
 )
@@ -39,6 +41,7 @@ type DouyinChain struct {
 	logger       *zap.Logger
 	resolver     *tools.FetchVideoByShareURLTool
 	workflowCfg  config.WorkflowConfig
+	profiles     *WorkflowProfiles
 }
 
 type DouyinChainParams struct {
@@ -49,10 +52,19 @@ type DouyinChainParams struct {
 	Logger       *zap.Logger
 	Resolver     *tools.FetchVideoByShareURLTool
 	Cfg          config.WorkflowConfig
+	Timeouts     *StepTimeouts     `optional:"true"`
+	Profiles     *WorkflowProfiles `optional:"true"`
+	Events       *events.Bus       `optional:"true"`
+	Resources    *ResourceGuard    `optional:"true"`
+	Interceptors []StepInterceptor `group:"step_interceptors"`
 }
 
 func NewDouyinChain(params DouyinChainParams) *DouyinChain {
-	chain := NewChainFromSteps(params.Steps, params.Logger, "DouyinTaskChain")
+	chain := NewChainFromSteps(params.Steps, params.Logger, "DouyinTaskChain").
+		WithTimeouts(params.Timeouts).
+		WithEvents(params.Events).
+		WithResources(params.Resources).
+		WithInterceptors(params.Interceptors...)
 
 	return &DouyinChain{
 		chain:        chain,
@@ -61,6 +73,7 @@ func NewDouyinChain(params DouyinChainParams) *DouyinChain {
 		logger:       params.Logger,
 		resolver:     params.Resolver,
 		workflowCfg:  params.Cfg,
+		profiles:     params.Profiles,
 	}
 }
 
@@ -111,8 +124,15 @@ func (dc *DouyinChain) ProcessContextWithTracking(ctx context.Context, initialCt
 	}
 	applyLatestUserSettingsToVideoContext(ctx, dc.userSettings, dc.logger, initialCtx)
 	ctx = withPreferencesApplied(ctx)
+	NewCheckpointStore(dc.db, dc.logger).Restore(videoID, initialCtx)
 
-	output, err := RunChainWithTracking(ctx, dc.chain, dc.db, dc.logger, videoID, initialCtx)
+	chain := dc.chain
+	if profile := dc.profiles.Resolve(ctx, PlatformDouyin, videoID, initialCtx.UserID, initialCtx.WorkflowProfile); profile != nil {
+		initialCtx.WorkflowProfile = profile.Name
+		chain = profile.Chain()
+	}
+
+	output, err := RunChainWithTracking(ctx, chain, dc.db, dc.logger, videoID, initialCtx)
 	if err != nil {
 		return nil, err
 	}
@@ -120,6 +140,62 @@ func (dc *DouyinChain) ProcessContextWithTracking(ctx context.Context, initialCt
 	return output, nil
 }
 
+// Plan 预览处理该抖音视频将执行的步骤与预估用量，不执行步骤、不写数据库；
+// 视频时长未知时解析分享链接读取（不下载）。
+func (dc *DouyinChain) Plan(ctx context.Context, initialCtx *VideoContext, videoID, userID string) (*ChainPlan, error) {
+	if initialCtx == nil {
+		initialCtx = dc.defaultVideoContext()
+	}
+	initialCtx.Platform = "douyin"
+	initialCtx.TaskChainSettings = NormalizeTaskChainSettings(initialCtx.TaskChainSettings)
+	ctx = withPlanMode(ctx)
+	resolvedUserID := strings.TrimSpace(userID)
+	if resolvedUserID == "" && initialCtx.UserID != "" {
+		resolvedUserID = initialCtx.UserID
+	}
+	ctx = WithVideoID(ctx, videoID)
+	if resolvedUserID != "" {
+		ctx = WithUserID(ctx, resolvedUserID)
+		initialCtx.UserID = resolvedUserID
+	}
+	applyLatestUserSettingsToVideoContext(ctx, dc.userSettings, dc.logger, initialCtx)
+	ctx = withPreferencesApplied(ctx)
+	NewCheckpointStore(dc.db, dc.logger).Restore(videoID, initialCtx)
+
+	durationSource := ""
+	if videoDurationSeconds(initialCtx) <= 0 {
+		fillVideoDuration(dc.db, videoID, initialCtx)
+		if initialCtx.DurationSeconds > 0 {
+			durationSource = durationSourceRecord
+		}
+	}
+	var notes []string
+	if videoDurationSeconds(initialCtx) <= 0 && strings.TrimSpace(initialCtx.VideoURL) != "" && dc.resolver != nil {
+		probeCtx, cancel := context.WithTimeout(ctx, planProbeTimeout)
+		// 只取时长，不写入 DouyinVideoInfo，以免计划中把 ResolveDouyinShare 判为跳过
+		info, err := dc.resolver.Fetch(probeCtx, initialCtx.VideoURL)
+		cancel()
+		if err != nil {
+			dc.logger.Warn("Failed to resolve douyin share for plan",
+				zap.String("video_id", videoID),
+				zap.Error(err))
+			notes = append(notes, "解析抖音分享链接失败："+err.Error())
+		} else if info != nil && info.Data.Video.Duration > 0 {
+			initialCtx.DurationSeconds = float64(info.Data.Video.Duration) / 1000
+			durationSource = durationSourceProbe
+		}
+	}
+
+	chain := dc.chain
+	if profile := dc.profiles.Resolve(ctx, PlatformDouyin, videoID, initialCtx.UserID, initialCtx.WorkflowProfile); profile != nil {
+		initialCtx.WorkflowProfile = profile.Name
+		chain = profile.Chain()
+	}
+	plan := buildChainPlan(ctx, chain, PlatformDouyin, videoID, initialCtx, durationSource)
+	plan.Estimate.Notes = append(notes, plan.Estimate.Notes...)
+	return plan, nil
+}
+
 func provideDouyinFetchVideoTool(logger *zap.Logger) *tools.FetchVideoByShareURLTool {
 	return tools.NewFetchVideoByShareURLTool(tikhub.NewDirectResolver(logger), logger)
 }
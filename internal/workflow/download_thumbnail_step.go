@@ -26,7 +26,9 @@ type DownloadThumbnailStepParams struct {
 func NewDownloadThumbnailStep(params DownloadThumbnailStepParams) *DownloadThumbnailStep {
 	return &DownloadThumbnailStep{
 		ToolStep: NewToolStep(
-			NewBaseStepWithOrder(StepNameDownloadThumbnail, false, 3),
+			NewBaseStepWithOrder(StepNameDownloadThumbnail, false, 3).
+				WithDependsOn(StepNameDownloadVideo).
+				WithContextAccess([]ContextField{FieldVideoURL, FieldChainSettings}, []ContextField{FieldThumbnailPath}),
 			params.Tool,
 			func(vctx *VideoContext) (string, error) {
 				return fmt.Sprintf(`{"video_url":%q}`, vctx.VideoURL), nil
@@ -39,6 +41,7 @@ func NewDownloadThumbnailStep(params DownloadThumbnailStepParams) *DownloadThumb
 				settings := NormalizeTaskChainSettings(vctx.TaskChainSettings)
 				return vctx.VideoURL == "" || !settings.DownloadThumbnail
 			}),
+			WithRetryPolicy(DefaultRetryPolicy()),
 			WithOnSuccess(func(ctx context.Context, output any) error {
 				vctx, ok := output.(*VideoContext)
 				if !ok {
@@ -16,6 +16,8 @@ import (
 
 type DownloadVideoStep struct {
 	*ToolStep
+	tool   *tools.DownloadVideoTool
+	logger *zap.Logger
 }
 
 type DownloadVideoStepParams struct {
@@ -26,8 +28,11 @@ type DownloadVideoStepParams struct {
 
 func NewDownloadVideoStep(params DownloadVideoStepParams) *DownloadVideoStep {
 	return &DownloadVideoStep{
+		tool:   params.Tool,
+		logger: params.Logger,
 		ToolStep: NewToolStep(
-			NewBaseStepWithOrder(StepNameDownloadVideo, true, 2),
+			NewBaseStepWithOrder(StepNameDownloadVideo, true, 2).
+				WithContextAccess([]ContextField{FieldVideoURL}, []ContextField{FieldVideoPath}),
 			params.Tool,
 			func(vctx *VideoContext) (string, error) {
 				return fmt.Sprintf(`{"video_url":%q,"format":%q}`, vctx.VideoURL, vctx.PreferredResolution), nil
@@ -52,6 +57,7 @@ func NewDownloadVideoStep(params DownloadVideoStepParams) *DownloadVideoStep {
 					tracker.UpdateStepProgress(workflowVideoID, StepNameDownloadVideo, update.Percent, update.Message)
 				}), nil
 			}),
+			WithRetryPolicy(DefaultRetryPolicy()),
 			WithOnSuccess(func(ctx context.Context, output any) error {
 				vctx, ok := output.(*VideoContext)
 				if !ok {
@@ -66,6 +72,25 @@ func NewDownloadVideoStep(params DownloadVideoStepParams) *DownloadVideoStep {
 	}
 }
 
+// EstimateDiskBytes 实现 StepWithDiskEstimate：用 yt-dlp 探测视频大小，供资源准入检查磁盘空间。
+// 已有本地文件或探测失败时返回 0。
+func (s *DownloadVideoStep) EstimateDiskBytes(ctx context.Context, input any) int64 {
+	vctx, ok := input.(*VideoContext)
+	if !ok || vctx == nil || vctx.VideoPath != "" || vctx.VideoURL == "" || s.tool == nil {
+		return 0
+	}
+	probeCtx, cancel := context.WithTimeout(ctx, planProbeTimeout)
+	defer cancel()
+	probe, err := s.tool.Probe(probeCtx, vctx.VideoURL)
+	if err != nil {
+		s.logger.Warn("Failed to probe video size for disk admission",
+			zap.String("url", vctx.VideoURL),
+			zap.Error(err))
+		return 0
+	}
+	return probe.SizeBytes
+}
+
 // extractVideoID 从视频路径提取视频 ID
 func extractVideoID(videoPath string) string {
 	dir := filepath.Dir(videoPath)
@@ -26,7 +26,9 @@ type ExtractAudioStepParams struct {
 func NewExtractAudioStep(params ExtractAudioStepParams) *ExtractAudioStep {
 	return &ExtractAudioStep{
 		ToolStep: NewToolStep(
-			NewBaseStepWithOrder(StepNameExtractAudio, true, 4),
+			NewBaseStepWithOrder(StepNameExtractAudio, true, 4).
+				WithDependsOn(StepNameDownloadVideo).
+				WithContextAccess([]ContextField{FieldVideoPath, FieldChainSettings}, []ContextField{FieldAudioPath}),
 			params.Tool,
 			func(vctx *VideoContext) (string, error) {
 				return fmt.Sprintf(`{"video_path":%q}`, vctx.VideoPath), nil
@@ -192,7 +192,8 @@ func (s *GenerateMetadataStep) Execute(ctx context.Context, input any) (any, err
 	s.logger.Info("resolved metadata model",
 		zap.String("model", resolvedModel),
 		zap.String("video_path", vctx.VideoPath))
-	metadata, err := s.generateMetadataFromLLM(ctx, vctx.UserID, resolvedModel, originalTitle, subtitleText, promoEnabled)
+	reportStepProvider(ctx, s.Name(), "llm", resolvedModel)
+	metadata, err := s.generateMetadataFromLLM(ctx, vctx.UserID, resolvedModel, originalTitle, subtitleText, promoEnabled, vctx.ReviewFeedback)
 	if err != nil {
 		s.logger.Error("❌ LLM生成元数据失败", zap.Error(err))
 		return persistFallback("LLM生成失败")
@@ -369,7 +370,7 @@ func (s *GenerateMetadataStep) isNumber(str string) bool {
 }
 
 // generateMetadataFromLLM 使用LLM生成元数据
-func (s *GenerateMetadataStep) generateMetadataFromLLM(ctx context.Context, userID, modelName, originalTitle, subtitleText string, promoEnabled bool) (*VideoMetadata, error) {
+func (s *GenerateMetadataStep) generateMetadataFromLLM(ctx context.Context, userID, modelName, originalTitle, subtitleText string, promoEnabled bool, reviewFeedback string) (*VideoMetadata, error) {
 	originalTitle = strings.TrimSpace(originalTitle)
 	titleHint := ""
 	if originalTitle != "" {
@@ -400,6 +401,9 @@ func (s *GenerateMetadataStep) generateMetadataFromLLM(ctx context.Context, user
 
 %s请直接返回JSON格式的结果，不要包含任何其他说明文字。`, titleHint, subtitleText, promoRequirement)
 	prompt = strings.ReplaceAll(prompt, "\t", "")
+	if feedback := reviewFeedbackPrompt(reviewFeedback); feedback != "" {
+		prompt += "\n\n" + feedback
+	}
 
 	// 构建消息
 	messages := []llm.Message{
@@ -22,6 +22,7 @@ import (
 type LLMTranslateStep struct {
 	BaseStep
 	translator  *tools.BatchTranslator
+	cache       *StepCache
 	logger      *zap.Logger
 	downloadDir string
 }
@@ -29,6 +30,7 @@ type LLMTranslateStep struct {
 type LLMTranslateStepParams struct {
 	fx.In
 	Translator *tools.BatchTranslator `optional:"true"`
+	Cache      *StepCache             `optional:"true"`
 	Logger     *zap.Logger
 	AppConfig  *config.AppConfig `optional:"true"`
 }
@@ -60,8 +62,14 @@ func NewLLMTranslateStep(params LLMTranslateStepParams) *LLMTranslateStep {
 	}
 
 	return &LLMTranslateStep{
-		BaseStep:    NewBaseStepWithOrder(StepNameLLMTranslate, false, 6),
+		BaseStep: NewBaseStepWithOrder(StepNameLLMTranslate, false, 6).
+			WithDependsOn(StepNameTranscribe).
+			WithContextAccess(
+				[]ContextField{FieldTranscript, FieldTranslation},
+				[]ContextField{FieldSubtitleAudios, FieldTranslation},
+			),
 		translator:  translator,
+		cache:       params.Cache,
 		logger:      params.Logger,
 		downloadDir: downloadDir,
 	}
@@ -98,17 +106,39 @@ func (s *LLMTranslateStep) Execute(ctx context.Context, input any) (any, error)
 		return vctx, nil
 	}
 
+	reportStepProvider(ctx, s.Name(), "llm", s.translator.ModelName())
+
 	runConfig := tools.TranslationRunConfig{
-		SourceLang: resolveSourceLang(vctx),
-		TargetLang: resolveTargetLang(vctx),
-		UserID:     strings.TrimSpace(vctx.UserID),
+		SourceLang:   resolveSourceLang(vctx),
+		TargetLang:   resolveTargetLang(vctx),
+		UserID:       strings.TrimSpace(vctx.UserID),
+		Instructions: reviewFeedbackPrompt(vctx.ReviewFeedback),
 	}
 
-	result, err := s.translator.TranslateTextsWithConfig(ctx, texts, runConfig)
-	if err != nil {
-		s.logger.Error("LLM subtitle translation failed", zap.Error(err))
-		return vctx, &StepSkippedError{
-			Step: s.Name(), Cause: err, Output: vctx,
+	cacheKey := ""
+	if s.cache != nil {
+		cacheKey = s.cacheKey(vctx, texts)
+	}
+	var result *tools.TranslationResult
+	var cached translationCacheEntry
+	if s.cache.Get(ctx, s.Name(), cacheKey, &cached) && len(cached.TranslatedTexts) == len(texts) {
+		s.logger.Info("LLM subtitle translation served from cache",
+			zap.String("video_id", vctx.VideoID),
+			zap.Int("total_segments", len(texts)))
+		result = &tools.TranslationResult{TranslatedTexts: cached.TranslatedTexts, SkippedTranslation: cached.SkippedTranslation}
+		reportCacheResult(ctx, s.Name(), 1, 1)
+	} else {
+		result, err = s.translator.TranslateTextsWithConfig(ctx, texts, runConfig)
+		if err != nil {
+			s.logger.Error("LLM subtitle translation failed", zap.Error(err))
+			return vctx, &StepSkippedError{
+				Step: s.Name(), Cause: err, Output: vctx,
+			}
+		}
+		// 部分批次失败时不缓存，避免把降级结果固定下来
+		if cacheKey != "" && len(result.Errors) == 0 {
+			s.cache.Put(ctx, s.Name(), cacheKey, translationCacheEntry{TranslatedTexts: result.TranslatedTexts, SkippedTranslation: result.SkippedTranslation})
+			reportCacheResult(ctx, s.Name(), 0, 1)
 		}
 	}
 	vctx.TranslationSkipped = result.SkippedTranslation
@@ -134,8 +164,42 @@ func (s *LLMTranslateStep) ShouldSkip(ctx context.Context, input any) bool {
 	return false
 }
 
+// HasCachedResult 实现 StepWithCachedResult：已有转写且相同原文、语言与模型的译文已缓存
+func (s *LLMTranslateStep) HasCachedResult(ctx context.Context, input any) bool {
+	vctx, ok := input.(*VideoContext)
+	if !ok || s.cache == nil || s.translator == nil {
+		return false
+	}
+	texts := transcriptTexts(collectTranscriptTextSegments(vctx.Transcript))
+	if len(texts) == 0 {
+		return false
+	}
+	_, ok = s.cache.lookup(ctx, s.Name(), s.cacheKey(vctx, texts))
+	return ok
+}
+
+// cacheKey 翻译缓存键；带审核退回意见时意见也计入，避免重跑命中被退回的译文
+func (s *LLMTranslateStep) cacheKey(vctx *VideoContext, texts []string) string {
+	parts := []string{resolveSourceLang(vctx), resolveTargetLang(vctx), s.translator.ModelName()}
+	if feedback := strings.TrimSpace(vctx.ReviewFeedback); feedback != "" {
+		parts = append(parts, "review:"+feedback)
+	}
+	return stepCacheKey(append(parts, texts...)...)
+}
+
+// RetryPolicy 实现 StepWithRetryPolicy：LLM 限流 / 超时等临时错误自动退避重试
+func (s *LLMTranslateStep) RetryPolicy() RetryPolicy {
+	return DefaultRetryPolicy()
+}
+
 // ── Helpers ──────────────────────────────────────────────────────────────────
 
+// translationCacheEntry 翻译结果缓存，键为 源语言 + 目标语言 + 模型 + 全部字幕文本
+type translationCacheEntry struct {
+	TranslatedTexts    []string `json:"translated_texts"`
+	SkippedTranslation bool     `json:"skipped_translation"`
+}
+
 func resolveSourceLang(vctx *VideoContext) string {
 	if vctx != nil && vctx.TranslationConfig != nil && vctx.TranslationConfig.SourceLanguage != "" {
 		return vctx.TranslationConfig.SourceLanguage
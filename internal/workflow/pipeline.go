@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/difyz9/ytb2bili/internal/events"
 	"go.uber.org/zap"
 )
 
@@ -42,28 +44,80 @@ type StageHandler func(ctx context.Context, task *PipelineTask) error
 
 // Stage 流水线阶段定义
 type Stage struct {
-	Name    StageName
-	Handler StageHandler
-	Workers int // 该阶段的并发工作数
+	Name      StageName
+	Handler   StageHandler
+	Workers   int // 该阶段的并发工作数
+	QueueSize int // 阶段输入队列容量，<=0 时默认为 Workers*2
+
+	Timeout     time.Duration                          // 阶段超时，0 表示不限制
+	TimeoutFunc func(task *PipelineTask) time.Duration // 按任务计算超时（如随视频时长放宽），优先于 Timeout
+
+	// Admit 可选的资源准入，在阶段超时开始前执行，等待名额与磁盘空间的时间不计入超时；返回释放函数
+	Admit func(ctx context.Context, task *PipelineTask) (func(), error)
+}
+
+// timeoutFor 返回任务在该阶段的超时
+func (s Stage) timeoutFor(task *PipelineTask) time.Duration {
+	if s.TimeoutFunc != nil {
+		return s.TimeoutFunc(task)
+	}
+	return s.Timeout
+}
+
+// admit 执行资源准入，未配置时直接通过
+func (s Stage) admit(ctx context.Context, task *PipelineTask) (func(), error) {
+	if s.Admit == nil {
+		return func() {}, nil
+	}
+	return s.Admit(ctx, task)
+}
+
+// StageStats 单个阶段的运行时统计（用于观测队列积压）
+type StageStats struct {
+	Name          StageName `json:"name"`
+	Workers       int       `json:"workers"`
+	QueueDepth    int       `json:"queue_depth"`
+	QueueCapacity int       `json:"queue_capacity"`
+	Active        int       `json:"active"`
+}
+
+// pipelineJob 在阶段队列之间流转的任务单元
+type pipelineJob struct {
+	ctx     context.Context
+	cancel  context.CancelFunc // 取消任务级 context，中断正在执行的阶段
+	task    *PipelineTask
+	eventCh chan PipelineEvent
+}
+
+// stageRuntime 阶段运行时：有界输入队列 + 固定数量的 worker
+type stageRuntime struct {
+	stage  Stage
+	queue  chan *pipelineJob
+	active atomic.Int32
+	wg     sync.WaitGroup
 }
 
 // Pipeline 多阶段队列式流水线
-// 类似 pyvideotrans 的 producer-consumer 模式，但用 Go channel + goroutine 实现
+// 类似 pyvideotrans 的 producer-consumer 模式，但用 Go channel + goroutine 实现。
+// 每个 Stage 拥有一个有界队列和 Workers 个 worker，下游队列满时上游 worker 阻塞，
+// 从而形成逐级背压。
 type Pipeline struct {
-	stages  []Stage
-	logger  *zap.Logger
-	wg      sync.WaitGroup
-	cancel  context.CancelFunc
-	tasks   map[string]*PipelineTask
-	mu      sync.Mutex
-	running bool
+	stages   []Stage
+	runtimes []*stageRuntime
+	logger   *zap.Logger
+	jobs     sync.WaitGroup // 已提交但尚未结束的任务
+	tasks    map[string]*PipelineTask
+	cancels  map[string]context.CancelFunc // 未结束任务的取消函数
+	mu       sync.Mutex
+	running  bool
+	events   *events.Bus // 可选，阶段事件同时发布为步骤事件
 }
 
 // PipelineEvent 流水线事件（用于进度通知）
 type PipelineEvent struct {
 	TaskID    string    `json:"task_id"`
 	Stage     StageName `json:"stage"`
-	Status    string    `json:"status"` // running / completed / failed / skipped
+	Status    string    `json:"status"` // running / completed / failed / timeout / skipped / cancelled
 	Error     string    `json:"error,omitempty"`
 	Timestamp time.Time `json:"timestamp"`
 }
@@ -71,131 +125,295 @@ type PipelineEvent struct {
 // NewPipeline 创建流水线
 func NewPipeline(stages []Stage, logger *zap.Logger) *Pipeline {
 	return &Pipeline{
-		stages: stages,
-		logger: logger,
-		tasks:  make(map[string]*PipelineTask),
+		stages:  stages,
+		logger:  logger,
+		tasks:   make(map[string]*PipelineTask),
+		cancels: make(map[string]context.CancelFunc),
 	}
 }
 
-// Submit 提交任务到流水线（异步，立即返回）
+// WithEvents 设置事件总线（链式调用）
+func (p *Pipeline) WithEvents(bus *events.Bus) *Pipeline {
+	p.events = bus
+	return p
+}
+
+// emit 发送任务事件，并把阶段事件发布到事件总线（结束事件 done 不是步骤，不发布）
+func (p *Pipeline) emit(job *pipelineJob, event PipelineEvent) {
+	job.eventCh <- event
+	if p.events == nil || event.Stage == "done" {
+		return
+	}
+	p.events.Publish(events.Event{
+		Type:      events.StepEventType(event.Status),
+		VideoID:   job.task.ID,
+		UserID:    job.task.UserID,
+		Step:      string(event.Stage),
+		Status:    event.Status,
+		Error:     event.Error,
+		Timestamp: event.Timestamp,
+	})
+}
+
+// Submit 提交任务到流水线。
+// 首个阶段队列已满时会阻塞（背压），直到有空位、ctx 取消或流水线停止。
+// 任务在从 ctx 派生的独立 context 中执行，CancelTask 只取消该任务。
+// 同一 ID 的任务结束后可以再次提交。
 func (p *Pipeline) Submit(ctx context.Context, task *PipelineTask) (<-chan PipelineEvent, error) {
 	p.mu.Lock()
 	if !p.running {
 		p.mu.Unlock()
 		return nil, fmt.Errorf("pipeline not started")
 	}
-	if _, exists := p.tasks[task.ID]; exists {
+	if _, active := p.cancels[task.ID]; active {
 		p.mu.Unlock()
 		return nil, fmt.Errorf("task %s already exists in pipeline", task.ID)
 	}
+	jobCtx, cancel := context.WithCancel(ctx)
 	task.CreatedAt = time.Now()
 	task.UpdatedAt = time.Now()
+	task.Status = "queued"
 	p.tasks[task.ID] = task
+	p.cancels[task.ID] = cancel
+	p.jobs.Add(1)
 	p.mu.Unlock()
 
 	eventCh := make(chan PipelineEvent, 100)
+	job := &pipelineJob{ctx: jobCtx, cancel: cancel, task: task, eventCh: eventCh}
 
-	p.wg.Add(1)
-	go func() {
-		defer p.wg.Done()
-		defer close(eventCh)
-		p.runTask(ctx, task, eventCh)
-	}()
-
-	return eventCh, nil
-}
-
-// runTask 运行单个任务通过所有阶段
-func (p *Pipeline) runTask(ctx context.Context, task *PipelineTask, eventCh chan<- PipelineEvent) {
-	currentCtx := ctx
-
-	for _, stage := range p.stages {
-		// Check cancellation
-		select {
-		case <-currentCtx.Done():
-			task.Status = "cancelled"
-			task.Error = currentCtx.Err()
-			eventCh <- PipelineEvent{
-				TaskID: task.ID, Stage: stage.Name,
-				Status: "cancelled", Error: currentCtx.Err().Error(),
-				Timestamp: time.Now(),
-			}
-			return
-		default:
-		}
+	if len(p.runtimes) == 0 {
+		p.finishJob(job, "completed")
+		return eventCh, nil
+	}
 
-		// Emit running event
-		task.Status = fmt.Sprintf("stage_%s", stage.Name)
-		task.UpdatedAt = time.Now()
-		eventCh <- PipelineEvent{
-			TaskID: task.ID, Stage: stage.Name, Status: "running",
-			Timestamp: time.Now(),
-		}
+	select {
+	case p.runtimes[0].queue <- job:
+		return eventCh, nil
+	case <-ctx.Done():
+		cancel()
+		p.mu.Lock()
+		delete(p.tasks, task.ID)
+		delete(p.cancels, task.ID)
+		p.mu.Unlock()
+		close(eventCh)
+		p.jobs.Done()
+		return nil, ctx.Err()
+	}
+}
 
-		// Execute stage with timeout
-		stageCtx, stageCancel := context.WithTimeout(currentCtx, 30*time.Minute)
-		stageErr := stage.Handler(stageCtx, task)
-		stageCancel()
-
-		if stageErr != nil {
-			task.Status = "failed"
-			task.Error = stageErr
-			task.UpdatedAt = time.Now()
-			eventCh <- PipelineEvent{
-				TaskID: task.ID, Stage: stage.Name, Status: "failed",
-				Error: stageErr.Error(), Timestamp: time.Now(),
-			}
-			return
-		}
+// runStage 阶段 worker 主循环：从本阶段队列取任务，执行后投递到下一阶段
+func (p *Pipeline) runStage(index int) {
+	rt := p.runtimes[index]
+	defer rt.wg.Done()
 
-		// Emit completed event
-		task.UpdatedAt = time.Now()
-		eventCh <- PipelineEvent{
-			TaskID: task.ID, Stage: stage.Name, Status: "completed",
-			Timestamp: time.Now(),
+	for job := range rt.queue {
+		rt.active.Add(1)
+		ok := p.executeStage(rt.stage, job)
+		rt.active.Add(-1)
+		if !ok {
+			continue
+		}
+		if index+1 < len(p.runtimes) {
+			// 下游队列满时在此阻塞，形成背压
+			p.runtimes[index+1].queue <- job
+			continue
 		}
+		p.finishJob(job, "completed")
 	}
+}
 
-	// All stages completed
-	task.Status = "completed"
-	task.UpdatedAt = time.Now()
-	eventCh <- PipelineEvent{
-		TaskID: task.ID, Stage: "done", Status: "completed",
+// executeStage 执行单个阶段，返回 false 表示任务已终止（失败或取消）
+func (p *Pipeline) executeStage(stage Stage, job *pipelineJob) bool {
+	task := job.task
+
+	if err := job.ctx.Err(); err != nil {
+		p.cancelJob(stage, job, err)
+		return false
+	}
+
+	p.setTaskStatus(task, fmt.Sprintf("stage_%s", stage.Name))
+	p.emit(job, PipelineEvent{
+		TaskID: task.ID, Stage: stage.Name, Status: "running",
 		Timestamp: time.Now(),
+	})
+
+	// 先通过资源准入再开始计时（与 Chain 一致）；按阶段配置的超时执行，处理函数不响应取消时，宽限期后放弃等待以释放 worker
+	timeout := stage.timeoutFor(task)
+	timedOut := false
+	release, stageErr := stage.admit(job.ctx, task)
+	if stageErr != nil {
+		stageErr = fmt.Errorf("stage %s admission failed: %w", stage.Name, stageErr)
+	} else {
+		_, timedOut, stageErr = runWithTimeout(job.ctx, timeout, true, func(ctx context.Context) (struct{}, error) {
+			return struct{}{}, stage.Handler(ctx, task)
+		})
+		release()
+	}
+
+	if timedOut {
+		stageErr = &StepTimeoutError{Step: string(stage.Name), Timeout: timeout}
+		task.Error = stageErr
+		p.logger.Warn("Pipeline stage timed out",
+			zap.String("task", task.ID),
+			zap.String("stage", string(stage.Name)),
+			zap.Duration("timeout", timeout))
+		p.emit(job, PipelineEvent{
+			TaskID: task.ID, Stage: stage.Name, Status: "timeout",
+			Error: stageErr.Error(), Timestamp: time.Now(),
+		})
+		p.finishJob(job, "timeout")
+		return false
 	}
+
+	// 任务被取消：阶段返回的错误是取消的连带结果，按 cancelled 结束
+	if stageErr != nil && job.ctx.Err() != nil {
+		p.cancelJob(stage, job, job.ctx.Err())
+		return false
+	}
+
+	if stageErr != nil {
+		task.Error = stageErr
+		p.emit(job, PipelineEvent{
+			TaskID: task.ID, Stage: stage.Name, Status: "failed",
+			Error: stageErr.Error(), Timestamp: time.Now(),
+		})
+		p.finishJob(job, "failed")
+		return false
+	}
+
+	p.emit(job, PipelineEvent{
+		TaskID: task.ID, Stage: stage.Name, Status: "completed",
+		Timestamp: time.Now(),
+	})
+	return true
+}
+
+// cancelJob 以 cancelled 结束任务
+func (p *Pipeline) cancelJob(stage Stage, job *pipelineJob, err error) {
+	job.task.Error = err
+	p.emit(job, PipelineEvent{
+		TaskID: job.task.ID, Stage: stage.Name,
+		Status: "cancelled", Error: err.Error(),
+		Timestamp: time.Now(),
+	})
+	p.finishJob(job, "cancelled")
+}
+
+// finishJob 结束任务：写入最终状态、释放任务 context、发送结束事件并关闭事件通道
+func (p *Pipeline) finishJob(job *pipelineJob, status string) {
+	p.mu.Lock()
+	job.task.Status = status
+	job.task.UpdatedAt = time.Now()
+	delete(p.cancels, job.task.ID)
+	p.mu.Unlock()
+	job.cancel()
+
+	if status == "completed" {
+		p.emit(job, PipelineEvent{
+			TaskID: job.task.ID, Stage: "done", Status: "completed",
+			Timestamp: time.Now(),
+		})
+	}
+	close(job.eventCh)
+	p.jobs.Done()
 }
 
-// Start 启动流水线（初始化资源）
+func (p *Pipeline) setTaskStatus(task *PipelineTask, status string) {
+	p.mu.Lock()
+	task.Status = status
+	task.UpdatedAt = time.Now()
+	p.mu.Unlock()
+}
+
+// Start 启动流水线：为每个阶段创建有界队列并拉起 Workers 个 worker
 func (p *Pipeline) Start() {
-	ctx, cancel := context.WithCancel(context.Background())
-	p.cancel = cancel
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.running {
+		return
+	}
+
+	p.runtimes = make([]*stageRuntime, len(p.stages))
+	for i, stage := range p.stages {
+		if stage.Workers <= 0 {
+			stage.Workers = 1
+		}
+		if stage.QueueSize <= 0 {
+			stage.QueueSize = stage.Workers * 2
+		}
+		p.runtimes[i] = &stageRuntime{
+			stage: stage,
+			queue: make(chan *pipelineJob, stage.QueueSize),
+		}
+	}
+	for i, rt := range p.runtimes {
+		rt.wg.Add(rt.stage.Workers)
+		for w := 0; w < rt.stage.Workers; w++ {
+			go p.runStage(i)
+		}
+	}
 	p.running = true
-	_ = ctx
 	p.logger.Info("Pipeline started", zap.Int("stages", len(p.stages)))
 }
 
-// Stop 停止流水线（取消所有进行中的任务）
+// Stop 停止流水线：拒绝新任务，等待已提交任务全部跑完后按顺序关闭各阶段队列
 func (p *Pipeline) Stop() {
-	if p.cancel != nil {
-		p.cancel()
+	p.mu.Lock()
+	if !p.running {
+		p.mu.Unlock()
+		return
 	}
-	p.wg.Wait()
 	p.running = false
+	p.mu.Unlock()
+
+	p.jobs.Wait()
+	for _, rt := range p.runtimes {
+		close(rt.queue)
+		rt.wg.Wait()
+	}
 	p.logger.Info("Pipeline stopped")
 }
 
-// CancelTask 取消指定任务
+// Stats 返回各阶段的队列深度与活跃 worker 数
+func (p *Pipeline) Stats() []StageStats {
+	p.mu.Lock()
+	runtimes := p.runtimes
+	p.mu.Unlock()
+
+	stats := make([]StageStats, 0, len(runtimes))
+	for _, rt := range runtimes {
+		stats = append(stats, StageStats{
+			Name:          rt.stage.Name,
+			Workers:       rt.stage.Workers,
+			QueueDepth:    len(rt.queue),
+			QueueCapacity: cap(rt.queue),
+			Active:        int(rt.active.Load()),
+		})
+	}
+	return stats
+}
+
+// CancelTask 取消指定任务：正在执行的阶段通过 context 立即中断（子进程、HTTP 请求随之终止），
+// 排队中的任务在出队时直接结束。任务不存在或已结束时返回 false。
 func (p *Pipeline) CancelTask(taskID string) bool {
 	p.mu.Lock()
-	task, exists := p.tasks[taskID]
+	cancel, active := p.cancels[taskID]
 	p.mu.Unlock()
-	if !exists {
+	if !active {
 		return false
 	}
-	task.Status = "cancelled"
+	cancel()
 	return true
 }
 
+// IsActive 判断任务是否已提交且尚未结束
+func (p *Pipeline) IsActive(taskID string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, active := p.cancels[taskID]
+	return active
+}
+
 // TaskStatus 查询任务状态
 func (p *Pipeline) TaskStatus(taskID string) *PipelineTask {
 	p.mu.Lock()
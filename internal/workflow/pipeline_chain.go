@@ -29,11 +29,21 @@ func NewChainPipeline(chain *Chain, logger *zap.Logger) *ChainPipeline {
 	steps := chain.GetSteps()
 	stages := make([]Stage, 0, len(steps))
 
-	for _, step := range steps {
+	for i, step := range steps {
 		step := step // capture
+		stepNum := i + 1
 		stage := Stage{
 			Name:    StageName(step.Name()),
 			Workers: defaultStageWorkers(step.Name()),
+			TimeoutFunc: func(task *PipelineTask) time.Duration {
+				return chain.timeouts.For(step.Name(), videoDurationSeconds(task.Context))
+			},
+			Admit: func(ctx context.Context, task *PipelineTask) (func(), error) {
+				if task.ID != "" {
+					ctx = WithVideoID(ctx, task.ID)
+				}
+				return chain.resources.Admit(ctx, step, task.Context)
+			},
 			Handler: func(ctx context.Context, task *PipelineTask) error {
 				if task.Context == nil {
 					return fmt.Errorf("pipeline task %s has nil VideoContext", task.ID)
@@ -47,10 +57,29 @@ func NewChainPipeline(chain *Chain, logger *zap.Logger) *ChainPipeline {
 					stepCtx = WithUserID(stepCtx, task.UserID)
 				}
 
+				// 与 Chain.executeStep 一样经过步骤拦截器
+				call := &StepCall{
+					Chain: chain.name, Step: step.Name(), StepNum: stepNum,
+					VideoID: task.ID, UserID: task.UserID, Input: task.Context, StartTime: time.Now(),
+				}
+				detail := &StepDetail{Name: step.Name(), Success: true, Attempts: 1}
+				stepCtx, entered, err := chain.enterInterceptors(stepCtx, call)
+				defer func() {
+					detail.Duration = time.Since(call.StartTime)
+					chain.exitInterceptors(stepCtx, call, entered, detail)
+				}()
+				if err != nil {
+					detail.Success, detail.Error = false, err
+					return fmt.Errorf("stage %s interceptor failed: %w", step.Name(), err)
+				}
+
 				output, err := step.Execute(stepCtx, task.Context)
 				if err != nil {
+					detail.Success, detail.Error = false, err
+					detail.Cancelled = stepCtx.Err() != nil
 					return fmt.Errorf("stage %s failed: %w", step.Name(), err)
 				}
+				detail.Output = output
 
 				if vctx, ok := output.(*VideoContext); ok {
 					task.Context = vctx
@@ -61,7 +90,7 @@ func NewChainPipeline(chain *Chain, logger *zap.Logger) *ChainPipeline {
 		stages = append(stages, stage)
 	}
 
-	p := NewPipeline(stages, logger)
+	p := NewPipeline(stages, logger).WithEvents(chain.events)
 	return &ChainPipeline{
 		chain:    chain,
 		pipeline: p,
@@ -85,6 +114,11 @@ func (cp *ChainPipeline) Stop() {
 	cp.pipeline.Stop()
 }
 
+// CancelTask 取消流水线中的任务，正在执行的步骤会立即中断
+func (cp *ChainPipeline) CancelTask(taskID string) bool {
+	return cp.pipeline.CancelTask(taskID)
+}
+
 // Pipeline 返回内部的 Pipeline 实例
 func (cp *ChainPipeline) Pipeline() *Pipeline {
 	return cp.pipeline
@@ -0,0 +1,293 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+func drainEvents(ch <-chan PipelineEvent) []PipelineEvent {
+	var events []PipelineEvent
+	for ev := range ch {
+		events = append(events, ev)
+	}
+	return events
+}
+
+func TestPipeline_StageWorkersBoundConcurrency(t *testing.T) {
+	var current, peak atomic.Int32
+	release := make(chan struct{})
+
+	stages := []Stage{{
+		Name:    StageDownload,
+		Workers: 2,
+		Handler: func(ctx context.Context, task *PipelineTask) error {
+			n := current.Add(1)
+			for {
+				old := peak.Load()
+				if n <= old || peak.CompareAndSwap(old, n) {
+					break
+				}
+			}
+			<-release
+			current.Add(-1)
+			return nil
+		},
+	}}
+
+	p := NewPipeline(stages, zaptest.NewLogger(t))
+	p.Start()
+
+	var chans []<-chan PipelineEvent
+	for _, id := range []string{"a", "b", "c", "d"} {
+		ch, err := p.Submit(context.Background(), &PipelineTask{ID: id})
+		if err != nil {
+			t.Fatalf("submit %s: %v", id, err)
+		}
+		chans = append(chans, ch)
+	}
+
+	deadline := time.After(2 * time.Second)
+	for {
+		stats := p.Stats()
+		if stats[0].Active == 2 && stats[0].QueueDepth == 2 {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("unexpected stage stats: %+v", stats)
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+
+	close(release)
+	for _, ch := range chans {
+		events := drainEvents(ch)
+		if last := events[len(events)-1]; last.Status != "completed" || last.Stage != "done" {
+			t.Fatalf("unexpected final event: %+v", last)
+		}
+	}
+	p.Stop()
+
+	if got := peak.Load(); got != 2 {
+		t.Fatalf("expected peak concurrency 2, got %d", got)
+	}
+}
+
+func TestPipeline_FailedStageStopsTask(t *testing.T) {
+	var finalizeCalls atomic.Int32
+	stages := []Stage{
+		{Name: StageTranscribe, Workers: 1, Handler: func(ctx context.Context, task *PipelineTask) error {
+			return errors.New("asr failed")
+		}},
+		{Name: StageFinalize, Workers: 1, Handler: func(ctx context.Context, task *PipelineTask) error {
+			finalizeCalls.Add(1)
+			return nil
+		}},
+	}
+
+	p := NewPipeline(stages, zaptest.NewLogger(t))
+	p.Start()
+	defer p.Stop()
+
+	task := &PipelineTask{ID: "v1"}
+	ch, err := p.Submit(context.Background(), task)
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	events := drainEvents(ch)
+
+	if last := events[len(events)-1]; last.Status != "failed" || last.Stage != StageTranscribe {
+		t.Fatalf("unexpected final event: %+v", last)
+	}
+	if finalizeCalls.Load() != 0 {
+		t.Fatal("finalize stage should not run after a failure")
+	}
+	if got := p.TaskStatus("v1").Status; got != "failed" {
+		t.Fatalf("expected failed status, got %s", got)
+	}
+}
+
+func TestPipeline_StopDrainsInFlightTasks(t *testing.T) {
+	var done atomic.Int32
+	stages := []Stage{
+		{Name: StagePrepare, Workers: 1, Handler: func(ctx context.Context, task *PipelineTask) error {
+			time.Sleep(10 * time.Millisecond)
+			return nil
+		}},
+		{Name: StageFinalize, Workers: 1, Handler: func(ctx context.Context, task *PipelineTask) error {
+			done.Add(1)
+			return nil
+		}},
+	}
+
+	p := NewPipeline(stages, zaptest.NewLogger(t))
+	p.Start()
+
+	for _, id := range []string{"a", "b", "c"} {
+		ch, err := p.Submit(context.Background(), &PipelineTask{ID: id})
+		if err != nil {
+			t.Fatalf("submit %s: %v", id, err)
+		}
+		go drainEvents(ch)
+	}
+	p.Stop()
+
+	if got := done.Load(); got != 3 {
+		t.Fatalf("expected all 3 tasks to finish before Stop returned, got %d", got)
+	}
+	if _, err := p.Submit(context.Background(), &PipelineTask{ID: "late"}); err == nil {
+		t.Fatal("expected submit after Stop to fail")
+	}
+}
+
+func TestPipeline_StageTimeoutEmitsTimeoutEvent(t *testing.T) {
+	origGrace := stepTimeoutGrace
+	stepTimeoutGrace = 10 * time.Millisecond
+	defer func() { stepTimeoutGrace = origGrace }()
+
+	release := make(chan struct{})
+	defer close(release)
+
+	stages := []Stage{{
+		Name:    StageTranscribe,
+		Workers: 1,
+		TimeoutFunc: func(task *PipelineTask) time.Duration {
+			return 20 * time.Millisecond
+		},
+		Handler: func(ctx context.Context, task *PipelineTask) error {
+			<-release // 不响应 ctx 的处理函数
+			return nil
+		},
+	}}
+
+	p := NewPipeline(stages, zaptest.NewLogger(t))
+	p.Start()
+	defer p.Stop()
+
+	ch, err := p.Submit(context.Background(), &PipelineTask{ID: "slow"})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+
+	done := make(chan []PipelineEvent, 1)
+	go func() { done <- drainEvents(ch) }()
+
+	select {
+	case events := <-done:
+		last := events[len(events)-1]
+		if last.Status != "timeout" {
+			t.Fatalf("expected timeout event, got %+v", events)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("stage was not abandoned after timeout")
+	}
+	if status := p.TaskStatus("slow"); status != nil && status.Status != "timeout" {
+		t.Fatalf("expected task status timeout, got %s", status.Status)
+	}
+}
+
+func TestPipeline_AdmissionWaitNotCountedAgainstStageTimeout(t *testing.T) {
+	admitted := make(chan struct{})
+	stages := []Stage{{
+		Name:    StageDownload,
+		Workers: 1,
+		Timeout: 50 * time.Millisecond,
+		Admit: func(ctx context.Context, task *PipelineTask) (func(), error) {
+			// 等待名额的时间超过阶段超时
+			time.Sleep(100 * time.Millisecond)
+			return func() { close(admitted) }, nil
+		},
+		Handler: func(ctx context.Context, task *PipelineTask) error {
+			return ctx.Err()
+		},
+	}}
+
+	p := NewPipeline(stages, zaptest.NewLogger(t))
+	p.Start()
+	defer p.Stop()
+
+	ch, err := p.Submit(context.Background(), &PipelineTask{ID: "queued"})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	events := drainEvents(ch)
+	if last := events[len(events)-1]; last.Status != "completed" {
+		t.Fatalf("expected stage to complete after admission, got %+v", events)
+	}
+	select {
+	case <-admitted:
+	default:
+		t.Fatal("expected admission to be released after the stage")
+	}
+}
+
+func TestPipeline_CancelTaskInterruptsRunningStage(t *testing.T) {
+	started := make(chan struct{})
+	var blocked, nextRan atomic.Bool
+	stages := []Stage{
+		{Name: StageDownload, Workers: 1, Handler: func(ctx context.Context, task *PipelineTask) error {
+			// 只有第一次提交阻塞到取消
+			if blocked.CompareAndSwap(false, true) {
+				close(started)
+				<-ctx.Done()
+				return ctx.Err()
+			}
+			return nil
+		}},
+		{Name: StageTranscribe, Workers: 1, Handler: func(ctx context.Context, task *PipelineTask) error {
+			nextRan.Store(true)
+			return nil
+		}},
+	}
+
+	p := NewPipeline(stages, zaptest.NewLogger(t))
+	p.Start()
+	defer p.Stop()
+
+	ch, err := p.Submit(context.Background(), &PipelineTask{ID: "v1"})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	<-started
+	if !p.CancelTask("v1") {
+		t.Fatal("expected running task to be cancellable")
+	}
+
+	done := make(chan []PipelineEvent)
+	go func() { done <- drainEvents(ch) }()
+	var events []PipelineEvent
+	select {
+	case events = <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("cancel did not interrupt the running stage")
+	}
+
+	last := events[len(events)-1]
+	if last.Status != "cancelled" || last.Stage != StageDownload {
+		t.Fatalf("expected cancelled event from download stage, got %+v", last)
+	}
+	if nextRan.Load() {
+		t.Fatal("later stages must not run after cancel")
+	}
+	if got := p.TaskStatus("v1").Status; got != "cancelled" {
+		t.Fatalf("expected cancelled status, got %q", got)
+	}
+	if p.CancelTask("v1") {
+		t.Fatal("finished task must not report as cancellable")
+	}
+
+	// 结束的任务可以重新提交
+	ch, err = p.Submit(context.Background(), &PipelineTask{ID: "v1"})
+	if err != nil {
+		t.Fatalf("resubmit after cancel: %v", err)
+	}
+	drainEvents(ch)
+	if got := p.TaskStatus("v1").Status; got != "completed" {
+		t.Fatalf("expected resubmitted task to complete, got %q", got)
+	}
+}
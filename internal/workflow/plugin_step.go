@@ -0,0 +1,278 @@
+package workflow
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/difyz9/ytb2bili/internal/config"
+	"github.com/difyz9/ytb2bili/pkg/utils"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+// ── 外部程序步骤 ─────────────────────────────────────────────────────────────
+// [[workflow.plugins]] 声明的程序作为步骤运行，无需修改 Go 代码即可扩展流程。
+//
+// 协议（版本 1）：
+//   - stdin 写入一个 JSON 对象后关闭：
+//     {"protocol":1,"step":"<名称>","video_id":"...","params":{...},"context":{...}}
+//     context 为 VideoContext 的产出字段，与检查点格式相同（video_path、audio_path、transcript、title 等）；
+//     params 为 [[workflow.profiles.steps]] 中该步骤的参数。
+//   - stdout 每行一个 JSON 对象，按 type 区分：
+//     {"type":"progress","percent":40,"message":"..."}  更新步骤进度
+//     {"type":"result","patch":{"title":"..."}}           合并到 VideoContext 的字段，只改出现的键
+//     {"type":"skip","reason":"..."}                      本次视为跳过（不影响后续步骤）
+//     {"type":"log","message":"..."}                      写入服务日志
+//     非 JSON 行按日志处理。
+//   - 退出码非 0 表示失败，stderr 末尾会附在错误信息中。
+// 环境变量 YTB2BILI_STEP、YTB2BILI_VIDEO_ID 标明当前步骤与视频。
+
+const (
+	pluginProtocolVersion = 1
+	pluginDefaultOrder    = 100
+	pluginMaxLineBytes    = 16 << 20 // 单行上限，字幕补丁可能较大
+	pluginStderrTailBytes = 4000
+)
+
+// 插件收到整个上下文快照，补丁可改写其中任意产出字段：声明全部读写，避免与其他步骤并发
+var (
+	pluginContextWrites = []ContextField{
+		FieldDuration, FieldVideoPath, FieldThumbnailPath, FieldAudioPath, FieldDouyinVideoInfo,
+		FieldTranscript, FieldSubtitleAudios, FieldMetadata, FieldBiliUpload, FieldTranslation,
+	}
+	pluginContextReads = append([]ContextField{FieldVideoID, FieldVideoURL, FieldUserID}, pluginContextWrites...)
+)
+
+// PluginStep 运行外部程序的步骤
+type PluginStep struct {
+	BaseStep
+	command string
+	args    []string
+	env     []string
+	workDir string
+	logger  *zap.Logger
+}
+
+type pluginRequest struct {
+	Protocol int                  `json:"protocol"`
+	Step     string               `json:"step"`
+	VideoID  string               `json:"video_id"`
+	Params   map[string]any       `json:"params,omitempty"`
+	Context  videoContextSnapshot `json:"context"`
+}
+
+type pluginMessage struct {
+	Type    string          `json:"type"`
+	Percent int             `json:"percent"`
+	Message string          `json:"message"`
+	Reason  string          `json:"reason"`
+	Patch   json.RawMessage `json:"patch"`
+}
+
+// NewPluginStep 按配置创建外部程序步骤；命令不存在时返回错误
+func NewPluginStep(cfg config.PluginStepConfig, logger *zap.Logger) (*PluginStep, error) {
+	name := strings.TrimSpace(cfg.Name)
+	if name == "" {
+		return nil, fmt.Errorf("plugin name is required")
+	}
+	command := strings.TrimSpace(cfg.Command)
+	if command == "" {
+		return nil, fmt.Errorf("plugin %q: command is required", name)
+	}
+	resolved, err := exec.LookPath(command)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %q: command %q not found: %w", name, command, err)
+	}
+
+	base := NewBaseStepWithOrder(name, cfg.Required, pluginDefaultOrder).
+		WithContextAccess(pluginContextReads, pluginContextWrites)
+	if len(cfg.DependsOn) > 0 {
+		base = base.WithDependsOn(cfg.DependsOn...)
+	}
+	env := make([]string, 0, len(cfg.Env))
+	for key, value := range cfg.Env {
+		env = append(env, key+"="+value)
+	}
+	return &PluginStep{
+		BaseStep: base,
+		command:  resolved,
+		args:     append([]string{}, cfg.Args...),
+		env:      env,
+		workDir:  strings.TrimSpace(cfg.WorkDir),
+		logger:   logger,
+	}, nil
+}
+
+// PluginStepsParams 外部程序步骤的依赖参数
+type PluginStepsParams struct {
+	fx.In
+	Cfg    config.WorkflowConfig
+	Logger *zap.Logger
+}
+
+// NewPluginSteps 创建 [[workflow.plugins]] 中的所有步骤，供步骤注册表收集
+func NewPluginSteps(params PluginStepsParams) ([]Step, error) {
+	steps := make([]Step, 0, len(params.Cfg.Plugins))
+	for i, pluginCfg := range params.Cfg.Plugins {
+		step, err := NewPluginStep(pluginCfg, params.Logger)
+		if err != nil {
+			return nil, fmt.Errorf("workflow.plugins[%d]: %w", i, err)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+func (s *PluginStep) Execute(ctx context.Context, input any) (any, error) {
+	vctx, err := mustVideoContext(input)
+	if err != nil {
+		return nil, err
+	}
+	videoID := GetVideoID(ctx)
+	if videoID == "" {
+		videoID = vctx.VideoID
+	}
+
+	request, err := json.Marshal(pluginRequest{
+		Protocol: pluginProtocolVersion,
+		Step:     s.Name(),
+		VideoID:  videoID,
+		Params:   StepParams(ctx),
+		Context:  snapshotVideoContext(vctx),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encode plugin request: %w", err)
+	}
+
+	cmd := utils.CommandContext(ctx, s.command, s.args...)
+	cmd.Dir = s.workDir
+	if cmd.Dir == "" && vctx.VideoPath != "" {
+		cmd.Dir = filepath.Dir(vctx.VideoPath)
+	}
+	cmd.Env = append(os.Environ(), s.env...)
+	cmd.Env = append(cmd.Env, "YTB2BILI_STEP="+s.Name(), "YTB2BILI_VIDEO_ID="+videoID)
+	cmd.Stdin = bytes.NewReader(request)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start plugin %s: %w", s.Name(), err)
+	}
+
+	var patch json.RawMessage
+	skipReason := ""
+	skipped := false
+	tracker := GetProgressTracker(ctx)
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), pluginMaxLineBytes)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var msg pluginMessage
+		if !strings.HasPrefix(line, "{") || json.Unmarshal([]byte(line), &msg) != nil {
+			s.logger.Debug("Plugin output", zap.String("step", s.Name()), zap.String("line", line))
+			continue
+		}
+		switch msg.Type {
+		case "progress":
+			if tracker != nil && videoID != "" {
+				tracker.UpdateStepProgress(videoID, s.Name(), msg.Percent, msg.Message)
+			}
+		case "result":
+			patch = msg.Patch
+		case "skip":
+			skipped, skipReason = true, msg.Reason
+		default:
+			s.logger.Info("Plugin message",
+				zap.String("step", s.Name()),
+				zap.String("video_id", videoID),
+				zap.String("message", msg.Message))
+		}
+	}
+	scanErr := scanner.Err()
+	if scanErr != nil {
+		// 输出无法继续解析时丢弃剩余输出，避免插件阻塞在写 stdout 上
+		_, _ = io.Copy(io.Discard, stdout)
+	}
+
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("plugin %s failed: %w: %s", s.Name(), err, tailOutput(stderr.String(), pluginStderrTailBytes))
+	}
+	if scanErr != nil {
+		return nil, fmt.Errorf("read plugin %s output: %w", s.Name(), scanErr)
+	}
+
+	if skipped {
+		if skipReason == "" {
+			skipReason = "skipped by plugin"
+		}
+		return nil, &StepSkippedError{Step: s.Name(), Cause: errors.New(skipReason), Output: vctx}
+	}
+	if err := applyContextPatch(vctx, patch); err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", s.Name(), err)
+	}
+	return vctx, nil
+}
+
+// applyContextPatch 将插件返回的字段补丁合并到 vctx。
+// 补丁键与检查点格式一致，只覆盖出现的键；platform、video_url、video_id、user_id 等标识字段会被忽略。
+func applyContextPatch(vctx *VideoContext, patch json.RawMessage) error {
+	if len(bytes.TrimSpace(patch)) == 0 || bytes.Equal(bytes.TrimSpace(patch), []byte("null")) {
+		return nil
+	}
+	// 先深拷贝当前字段，解码失败时不会改动 vctx
+	current, err := json.Marshal(snapshotVideoContext(vctx))
+	if err != nil {
+		return err
+	}
+	var merged videoContextSnapshot
+	if err := json.Unmarshal(current, &merged); err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(patch))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&merged); err != nil {
+		return fmt.Errorf("invalid context patch: %w", err)
+	}
+
+	vctx.DurationSeconds = merged.DurationSeconds
+	vctx.VideoPath = merged.VideoPath
+	vctx.ThumbnailPath = merged.ThumbnailPath
+	vctx.AudioPath = merged.AudioPath
+	vctx.DouyinVideoInfo = merged.DouyinVideoInfo
+	vctx.Transcript = merged.Transcript
+	vctx.SubtitleAudios = merged.SubtitleAudios
+	vctx.Title = merged.Title
+	vctx.Description = merged.Description
+	vctx.Tags = merged.Tags
+	vctx.BiliBVID = merged.BiliBVID
+	vctx.BiliAID = merged.BiliAID
+	vctx.TranslationSkipped = merged.TranslationSkipped
+	return nil
+}
+
+// tailOutput 保留输出末尾（错误信息通常在最后）
+func tailOutput(s string, max int) string {
+	s = strings.TrimSpace(s)
+	if max <= 0 || len(s) <= max {
+		return s
+	}
+	return "..." + s[len(s)-max:]
+}
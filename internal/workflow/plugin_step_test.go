@@ -0,0 +1,226 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/difyz9/ytb2bili/internal/config"
+	"github.com/difyz9/ytb2bili/pkg/store/model"
+	"go.uber.org/zap"
+)
+
+// writePluginScript 在临时目录写入可执行的 sh 脚本并返回其路径
+func writePluginScript(t *testing.T, dir, body string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("plugin scripts require a POSIX shell")
+	}
+	path := filepath.Join(dir, "plugin.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0o755); err != nil {
+		t.Fatalf("write plugin script: %v", err)
+	}
+	return path
+}
+
+func TestPluginStep_AppliesPatchAndReportsProgress(t *testing.T) {
+	dir := t.TempDir()
+	script := writePluginScript(t, dir, `cat > request.json
+echo "warming up"
+echo '{"type":"progress","percent":40,"message":"halfway"}'
+echo '{"type":"result","patch":{"title":"plugin title","tags":"a,b","video_id":"ignored"}}'
+`)
+	step, err := NewPluginStep(config.PluginStepConfig{Name: "Annotate", Command: script, WorkDir: dir}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewPluginStep: %v", err)
+	}
+
+	db := openCheckpointTestDB(t)
+	tracker := NewProgressTracker(db, zap.NewNop())
+	if err := tracker.InitSteps("plugin-1", []Step{step}); err != nil {
+		t.Fatalf("init steps: %v", err)
+	}
+	ctx := WithProgressTracker(WithVideoID(context.Background(), "plugin-1"), tracker)
+	ctx = WithStepParams(ctx, map[string]any{"lang": "ja"})
+
+	vctx := &VideoContext{VideoID: "plugin-1", VideoPath: "/data/plugin-1/video.mp4", Title: "original"}
+	if _, err := step.Execute(ctx, vctx); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if vctx.Title != "plugin title" || vctx.Tags != "a,b" {
+		t.Fatalf("expected patch to be applied, got title=%q tags=%q", vctx.Title, vctx.Tags)
+	}
+	if vctx.VideoID != "plugin-1" || vctx.VideoPath != "/data/plugin-1/video.mp4" {
+		t.Fatalf("patch must not change identity or untouched fields: %+v", vctx)
+	}
+
+	request, err := os.ReadFile(filepath.Join(dir, "request.json"))
+	if err != nil {
+		t.Fatalf("read request: %v", err)
+	}
+	for _, want := range []string{`"protocol":1`, `"video_path":"/data/plugin-1/video.mp4"`, `"params":{"lang":"ja"}`} {
+		if !strings.Contains(string(request), want) {
+			t.Fatalf("expected request to contain %s, got %s", want, request)
+		}
+	}
+
+	var row model.TaskStep
+	if err := db.Where("video_id = ? AND step_name = ?", "plugin-1", "Annotate").First(&row).Error; err != nil {
+		t.Fatalf("load step row: %v", err)
+	}
+	if row.ProgressPercent != 40 || row.ProgressText != "halfway" {
+		t.Fatalf("expected progress 40%% halfway, got %d %q", row.ProgressPercent, row.ProgressText)
+	}
+}
+
+func TestPluginStep_FailureSkipAndBadPatch(t *testing.T) {
+	cases := []struct {
+		name  string
+		body  string
+		check func(t *testing.T, err error)
+	}{
+		{
+			name: "non-zero exit includes stderr",
+			body: "echo 'model missing' >&2\nexit 3\n",
+			check: func(t *testing.T, err error) {
+				if err == nil || !strings.Contains(err.Error(), "model missing") {
+					t.Fatalf("expected failure with stderr, got %v", err)
+				}
+			},
+		},
+		{
+			name: "skip message",
+			body: `echo '{"type":"skip","reason":"no subtitles"}'` + "\n",
+			check: func(t *testing.T, err error) {
+				if !IsStepSkippedError(err) || !strings.Contains(err.Error(), "no subtitles") {
+					t.Fatalf("expected skipped error, got %v", err)
+				}
+			},
+		},
+		{
+			name: "unknown patch field",
+			body: `echo '{"type":"result","patch":{"no_such_field":1}}'` + "\n",
+			check: func(t *testing.T, err error) {
+				if err == nil || !strings.Contains(err.Error(), "invalid context patch") {
+					t.Fatalf("expected invalid patch error, got %v", err)
+				}
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			step, err := NewPluginStep(config.PluginStepConfig{Name: "Check", Command: writePluginScript(t, dir, tc.body)}, zap.NewNop())
+			if err != nil {
+				t.Fatalf("NewPluginStep: %v", err)
+			}
+			vctx := &VideoContext{Title: "keep"}
+			_, err = step.Execute(context.Background(), vctx)
+			tc.check(t, err)
+			if vctx.Title != "keep" {
+				t.Fatalf("failed plugin must not modify context, title=%q", vctx.Title)
+			}
+		})
+	}
+}
+
+func TestPluginStep_OptionalTimeoutDoesNotStopChain(t *testing.T) {
+	prevGrace := stepTimeoutGrace
+	stepTimeoutGrace = 100 * time.Millisecond
+	t.Cleanup(func() { stepTimeoutGrace = prevGrace })
+
+	dir := t.TempDir()
+	cfg := config.WorkflowConfig{Plugins: []config.PluginStepConfig{{
+		Name:           "SlowPlugin",
+		Command:        writePluginScript(t, dir, "sleep 30\n"),
+		TimeoutSeconds: 1,
+	}}}
+	plugins, err := NewPluginSteps(PluginStepsParams{Cfg: cfg, Logger: zap.NewNop()})
+	if err != nil {
+		t.Fatalf("NewPluginSteps: %v", err)
+	}
+	timeouts := NewStepTimeouts(cfg)
+	if got := timeouts.For("SlowPlugin", 3600); got != time.Second {
+		t.Fatalf("expected plugin timeout_seconds to be used, got %s", got)
+	}
+
+	executed := false
+	after := &testStep{BaseStep: NewBaseStepWithOrder("After", true, 200), executed: &executed}
+	chain := NewChainFromSteps([]Step{plugins[0], after}, zap.NewNop(), "plugin-test").WithTimeouts(timeouts)
+
+	started := time.Now()
+	result := chain.Run(context.Background(), &VideoContext{})
+	if elapsed := time.Since(started); elapsed > 10*time.Second {
+		t.Fatalf("plugin was not killed on timeout, chain took %s", elapsed)
+	}
+	if !result.Success || !executed {
+		t.Fatalf("optional plugin timeout must not stop the chain: success=%v executed=%v", result.Success, executed)
+	}
+	if detail := result.StepDetails["SlowPlugin"]; detail == nil || !detail.TimedOut {
+		t.Fatalf("expected plugin step to be marked timed out, got %+v", detail)
+	}
+}
+
+func TestPluginStep_DependsOnDoesNotRunAlongsideContextWriters(t *testing.T) {
+	dir := t.TempDir()
+	script := writePluginScript(t, dir, "exit 0\n")
+	plugin, err := NewPluginStep(config.PluginStepConfig{Name: "Annotate", Command: script, DependsOn: []string{StepNameTranscribe}}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewPluginStep: %v", err)
+	}
+	if _, declared := declaredContextAccess(plugin); !declared {
+		t.Fatal("expected plugin to declare the context fields it reads and patches")
+	}
+	steps := []Step{
+		&testStepWithSkip{BaseStep: NewBaseStepWithOrder(StepNameTranscribe, false, 5).
+			WithDependsOn().
+			WithContextAccess([]ContextField{FieldAudioPath}, []ContextField{FieldTranscript})},
+		&testStepWithSkip{BaseStep: NewBaseStepWithOrder(StepNameLLMTranslate, false, 6).
+			WithDependsOn(StepNameTranscribe).
+			WithContextAccess([]ContextField{FieldTranscript}, []ContextField{FieldSubtitleAudios, FieldTranslation})},
+		plugin,
+	}
+	graph := buildStepGraph(steps, zap.NewNop())
+	if fmt.Sprint(graph.deps[2]) != "[0 1]" {
+		t.Fatalf("expected plugin to wait for steps writing fields it may patch, got %v", graph.deps[2])
+	}
+}
+
+func TestPluginStep_PatchedFieldsKeptInPartialCheckpoint(t *testing.T) {
+	vctx := &VideoContext{VideoID: "v1", VideoURL: "https://example.com/v1"}
+	patch := []byte(`{"duration_seconds": 5400, "douyin_video_info": {"code": 0}, "title": "patched"}`)
+	if err := applyContextPatch(vctx, patch); err != nil {
+		t.Fatalf("applyContextPatch: %v", err)
+	}
+
+	// 仍有其他步骤运行时，检查点只复制插件声明写入的字段
+	var snapshot videoContextSnapshot
+	snapshot.copyFields(vctx, pluginContextWrites)
+	if snapshot.DurationSeconds != 5400 || snapshot.DouyinVideoInfo == nil || snapshot.Title != "patched" {
+		t.Fatalf("expected every patchable field to be declared as written, got %+v", snapshot)
+	}
+}
+
+func TestStepRegistry_RejectsPluginNameConflict(t *testing.T) {
+	dir := t.TempDir()
+	plugin, err := NewPluginStep(config.PluginStepConfig{Name: StepNameTranscribe, Command: writePluginScript(t, dir, "exit 0\n")}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewPluginStep: %v", err)
+	}
+	_, err = NewStepRegistry(StepRegistryParams{
+		Steps:   []Step{&paramRecordingStep{BaseStep: NewBaseStepWithOrder(StepNameTranscribe, false, 5)}},
+		Plugins: []Step{plugin},
+	})
+	if err == nil || !strings.Contains(err.Error(), "conflicts") {
+		t.Fatalf("expected name conflict error, got %v", err)
+	}
+
+	if _, err := NewPluginStep(config.PluginStepConfig{Name: "Missing", Command: filepath.Join(dir, "nope")}, zap.NewNop()); err == nil {
+		t.Fatal("expected missing command to be rejected at startup")
+	}
+}
@@ -4,11 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/difyz9/ytb2bili/internal/config"
+	"github.com/difyz9/ytb2bili/internal/events"
 	"github.com/difyz9/ytb2bili/internal/service"
 	"github.com/difyz9/ytb2bili/pkg/llm"
 	"github.com/difyz9/ytb2bili/pkg/store/model"
@@ -28,7 +31,10 @@ type ProcessingService struct {
 	cfg          *config.AppConfig
 	userSettings *service.UserSettingsClient
 	workerSem    chan struct{}
-	pipeline     *Pipeline
+	pipelineMu   sync.Mutex
+	pipelines    map[string]*ChainPipeline // 按平台懒加载、各任务共享的流水线
+	profiles     *WorkflowProfiles
+	events       *events.Bus
 }
 
 type ProcessingServiceParams struct {
@@ -41,19 +47,221 @@ type ProcessingServiceParams struct {
 	Logger       *zap.Logger
 	Cfg          *config.AppConfig           `optional:"true"`
 	UserSettings *service.UserSettingsClient `optional:"true"`
+	Profiles     *WorkflowProfiles           `optional:"true"`
+	Events       *events.Bus                 `optional:"true"`
 }
 
 func (s *ProcessingService) BiliChain() *BilibiliChain   { return s.biliChain }
 func (s *ProcessingService) YouTubeChain() *YouTubeChain   { return s.youtubeChain }
 func (s *ProcessingService) DouyinChain() *DouyinChain     { return s.douyinChain }
 
+// PipelineStats 按平台返回流水线各阶段的队列深度与活跃 worker 数
+func (s *ProcessingService) PipelineStats() map[string][]StageStats {
+	s.pipelineMu.Lock()
+	defer s.pipelineMu.Unlock()
+	stats := make(map[string][]StageStats, len(s.pipelines))
+	for platform, cp := range s.pipelines {
+		stats[platform] = cp.Pipeline().Stats()
+	}
+	return stats
+}
+
+// WorkflowProfiles 返回配置中的所有工作流
+func (s *ProcessingService) WorkflowProfiles() []*WorkflowProfile {
+	return s.profiles.List()
+}
+
+// DefaultWorkflowProfile 返回 default_profile，未配置时为空
+func (s *ProcessingService) DefaultWorkflowProfile() string {
+	return s.profiles.DefaultName()
+}
+
+// ValidateWorkflowProfile 校验提交时指定的工作流名称；platform 为空时不校验平台
+func (s *ProcessingService) ValidateWorkflowProfile(name, platform string) error {
+	return s.profiles.Validate(name, platform)
+}
+
+// CancelTask 取消正在运行的任务：后台处理、续跑与流水线任务的 context 都会被取消，
+// 子进程随之被终止，状态由任务退出时持久化为 cancelled。
 func (s *ProcessingService) CancelTask(videoID string) error {
-	if s.taskRuntime == nil || !s.taskRuntime.Cancel(videoID) {
+	cancelled := s.taskRuntime.Cancel(videoID)
+	s.pipelineMu.Lock()
+	for _, cp := range s.pipelines {
+		if cp.CancelTask(videoID) {
+			cancelled = true
+		}
+	}
+	s.pipelineMu.Unlock()
+	if !cancelled && s.leasedElsewhere(videoID) {
+		// 由其他 worker 处理：状态改为 cancelled 后，worker 续约失败即中断任务并持久化取消状态
+		cancelled = true
+	}
+	if !cancelled {
 		return fmt.Errorf("当前任务未在后台运行，暂时无法停止")
 	}
 	return nil
 }
 
+// leasedElsewhere 视频是否正由某个 worker 以未过期租约处理
+func (s *ProcessingService) leasedElsewhere(videoID string) bool {
+	if s.db == nil || videoID == "" {
+		return false
+	}
+	var video model.Video
+	if err := s.db.Select("status", "lease_owner", "lease_expires_at").
+		Where("video_id = ?", videoID).First(&video).Error; err != nil {
+		return false
+	}
+	return video.HasActiveLease(time.Now())
+}
+
+// queueOnly API 模式下本实例不处理视频，提交的任务交给 worker 实例
+func (s *ProcessingService) queueOnly() bool {
+	return s.cfg != nil && !s.cfg.Worker.RunsWorker()
+}
+
+// queueForWorkers 把视频置为待处理并重置重试次数，由 worker 实例按租约认领
+func (s *ProcessingService) queueForWorkers(videoID, workflowProfile string) error {
+	if s.db == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+	updates := map[string]interface{}{
+		"retry_count":        0,
+		"lease_owner":        "",
+		"lease_expires_at":   nil,
+		"lease_heartbeat_at": nil,
+	}
+	if profile := strings.TrimSpace(workflowProfile); profile != "" {
+		updates["workflow_profile"] = profile
+	}
+	if _, err := model.TransitionVideoStatus(s.db, videoID, model.VideoTransition{
+		To: model.VideoStatusQueued, Updates: updates, Reason: "提交给 worker 处理", Actor: "system",
+	}); err != nil {
+		return err
+	}
+	s.events.PublishVideoStatus(s.db, videoID, "", model.VideoStatusQueued)
+	return nil
+}
+
+// waitForWorker 等待 worker 处理完成排队的视频
+func (s *ProcessingService) waitForWorker(ctx context.Context, videoID string) error {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		var video model.Video
+		if err := s.db.WithContext(ctx).Select("status").Where("video_id = ?", videoID).First(&video).Error; err != nil {
+			return err
+		}
+		switch model.NormalizeVideoStatus(video.Status) {
+		case model.VideoStatusReady, model.VideoStatusPublished, model.VideoStatusAwaitingReview:
+			return nil
+		case model.VideoStatusFailed:
+			return fmt.Errorf("worker 处理视频失败")
+		case model.VideoStatusCancelled:
+			return context.Canceled
+		}
+	}
+}
+
+// IsTaskRunning 判断视频是否有正在运行的任务（本实例，或其他 worker 持有未过期租约）
+func (s *ProcessingService) IsTaskRunning(videoID string) bool {
+	if s.taskRuntime.Has(videoID) {
+		return true
+	}
+	if s.leasedElsewhere(videoID) {
+		return true
+	}
+	s.pipelineMu.Lock()
+	defer s.pipelineMu.Unlock()
+	for _, cp := range s.pipelines {
+		if cp.Pipeline().IsActive(videoID) {
+			return true
+		}
+	}
+	return false
+}
+
+// trackTask 为视频任务派生可取消的 context 并登记，返回的 finish 在任务结束时调用：
+// 任务被取消时把状态持久化为 cancelled 并清理未完成文件；因服务停止而中断时重新排队，
+// 由后台处理从中断的步骤继续。返回值为传入的 err。
+func (s *ProcessingService) trackTask(parent context.Context, videoID string) (context.Context, func(err error) error) {
+	ctx, done := s.taskRuntime.Track(parent, videoID)
+	return ctx, func(err error) error {
+		if err != nil && IsShutdownInterrupted(ctx, err) {
+			s.logger.Warn("服务停止，视频任务已中断，重新排队", zap.String("video_id", videoID))
+			MarkVideoInterrupted(s.db, s.logger, videoID, s.videoDir(videoID))
+			transitionVideo(s.db, s.events, s.logger, videoID, model.VideoTransition{
+				To: model.VideoStatusQueued, From: model.VideoActiveStatuses,
+				Reason: "服务停止时中断，重新排队", Actor: "system",
+			})
+			s.taskRuntime.Interrupted(videoID)
+			done()
+			return err
+		}
+		done()
+		if err != nil && errors.Is(err, context.Canceled) {
+			s.logger.Info("视频任务已取消", zap.String("video_id", videoID))
+			MarkVideoCancelled(s.db, s.logger, videoID, s.videoDir(videoID))
+			s.events.PublishVideoStatus(s.db, videoID, "", model.VideoStatusCancelled)
+		}
+		return err
+	}
+}
+
+// videoDir 返回视频的工作目录 <download_dir>/<videoID>
+func (s *ProcessingService) videoDir(videoID string) string {
+	if s.cfg == nil || strings.TrimSpace(s.cfg.Workflow.DownloadDir) == "" || videoID == "" {
+		return ""
+	}
+	return filepath.Join(s.cfg.Workflow.DownloadDir, videoID)
+}
+
+// ResumeVideo 在后台续跑视频：继续处理已取消/失败的任务，或配合 RestartFromStep 从指定步骤重跑。
+// 任务登记后可通过 CancelTask 停止；同一视频已有任务运行时返回错误。
+func (s *ProcessingService) ResumeVideo(video *model.Video, resumeCtx *VideoContext) error {
+	if s.youtubeChain == nil {
+		return fmt.Errorf("YouTube 处理链未注册")
+	}
+	if s.IsTaskRunning(video.VideoID) {
+		return fmt.Errorf("任务正在运行中，请先停止")
+	}
+	if s.taskRuntime.Draining() {
+		return fmt.Errorf("服务正在停止，请稍后再试")
+	}
+	if s.queueOnly() {
+		// 续跑起点已由调用方写入步骤状态，worker 认领后从未完成的步骤继续
+		return s.queueForWorkers(video.VideoID, "")
+	}
+	if s.db != nil {
+		if _, err := model.TransitionVideoStatus(s.db, video.VideoID, model.VideoTransition{
+			To: model.VideoStatusProcessing, Reason: "继续处理", Actor: video.UserID,
+		}); err != nil {
+			return err
+		}
+		s.events.PublishVideoStatus(s.db, video.VideoID, video.UserID, model.VideoStatusProcessing)
+	}
+
+	// 各步骤按 [workflow.step_timeouts] 单独限时，这里不再设整体超时
+	ctx, finish := s.trackTask(context.Background(), video.VideoID)
+	go func() {
+		var err error
+		if resumeCtx != nil {
+			err = s.youtubeChain.ResumeProcessingWithContext(ctx, video, resumeCtx)
+		} else {
+			err = s.youtubeChain.ResumeProcessing(ctx, video)
+		}
+		if err = finish(err); err != nil && !errors.Is(err, context.Canceled) {
+			s.logger.Error("续跑视频处理失败", zap.String("video_id", video.VideoID), zap.Error(err))
+		}
+	}()
+	return nil
+}
+
 func NewProcessingService(params ProcessingServiceParams) *ProcessingService {
 	maxConcurrent := params.Cfg.Workflow.MaxConcurrent
 	if maxConcurrent <= 0 {
@@ -69,36 +277,44 @@ func NewProcessingService(params ProcessingServiceParams) *ProcessingService {
 		cfg:          params.Cfg,
 		userSettings: params.UserSettings,
 		workerSem:    make(chan struct{}, maxConcurrent),
+		pipelines:    make(map[string]*ChainPipeline),
+		profiles:     params.Profiles,
+		events:       params.Events,
 	}
-	stages := NewStandardStages(
-		func(ctx context.Context, task *PipelineTask) error { return nil },
-		func(ctx context.Context, task *PipelineTask) error { return nil },
-		func(ctx context.Context, task *PipelineTask) error { return nil },
-		func(ctx context.Context, task *PipelineTask) error { return nil },
-		func(ctx context.Context, task *PipelineTask) error { return nil },
-		func(ctx context.Context, task *PipelineTask) error { return nil },
-		func(ctx context.Context, task *PipelineTask) error { return nil },
-	)
-	svc.pipeline = NewPipeline(stages, params.Logger)
-	svc.pipeline.Start()
 	return svc
 }
 
-func (s *ProcessingService) SubmitToPipeline(ctx context.Context, platform string, task *PipelineTask) (<-chan PipelineEvent, error) {
+// pipelineFor 返回平台对应的共享流水线，首次使用时创建并启动
+func (s *ProcessingService) pipelineFor(platform string) (*ChainPipeline, error) {
+	s.pipelineMu.Lock()
+	defer s.pipelineMu.Unlock()
+	if cp, ok := s.pipelines[platform]; ok {
+		return cp, nil
+	}
 	var chain *Chain
 	switch platform {
-	case "douyin":
+	case PlatformDouyin:
 		if s.douyinChain == nil {
 			return nil, fmt.Errorf("douyin pipeline not available")
 		}
-		chain = s.youtubeChain.getChain()
-	case "youtube":
+		chain = s.douyinChain.chain
+	case PlatformYouTube:
+		if s.youtubeChain == nil {
+			return nil, fmt.Errorf("youtube pipeline not available")
+		}
 		chain = s.youtubeChain.getChain()
 	default:
 		return nil, fmt.Errorf("unknown platform: %s", platform)
 	}
 	cp := NewChainPipeline(chain, s.logger)
 	cp.Start()
+	s.pipelines[platform] = cp
+	return cp, nil
+}
+
+// SubmitToPipeline 把任务提交到平台共享的流水线，可通过 CancelTask 取消。
+// 任务被取消时状态持久化为 cancelled；调用方需要读完返回的事件通道。
+func (s *ProcessingService) SubmitToPipeline(ctx context.Context, platform string, task *PipelineTask) (<-chan PipelineEvent, error) {
 	if task.ID == "" && task.VideoURL != "" {
 		p, _, vid, _, err := s.ResolveRemoteVideoTarget(ctx, task.VideoURL)
 		if err == nil {
@@ -106,7 +322,27 @@ func (s *ProcessingService) SubmitToPipeline(ctx context.Context, platform strin
 			task.ID = vid
 		}
 	}
-	return cp.Submit(ctx, task)
+	cp, err := s.pipelineFor(platform)
+	if err != nil {
+		return nil, err
+	}
+	events, err := cp.Submit(ctx, task)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan PipelineEvent, cap(events))
+	go func() {
+		defer close(out)
+		for event := range events {
+			if event.Status == "cancelled" {
+				MarkVideoCancelled(s.db, s.logger, task.ID, s.videoDir(task.ID))
+				s.events.PublishVideoStatus(s.db, task.ID, task.UserID, model.VideoStatusCancelled)
+			}
+			out <- event
+		}
+	}()
+	return out, nil
 }
 
 var (
@@ -163,42 +399,59 @@ func (s *ProcessingService) ResolveRemoteVideoTarget(ctx context.Context, raw st
 	}
 }
 
-func (s *ProcessingService) ProcessRemoteVideo(ctx context.Context, platform, normalizedURL, videoID, userID, preferredResolution string,
+func (s *ProcessingService) ProcessRemoteVideo(ctx context.Context, platform, normalizedURL, videoID, userID, preferredResolution, workflowProfile string,
 	douyinInfo *tools.DouyinVideoInfo, taskChainSettings *TaskChainSettings, speechConfig *SpeechSynthesisConfig) (*VideoContext, error) {
+	if s.queueOnly() {
+		// API 模式：排队交给 worker 并等待结束，结果以数据库记录为准，不返回上下文
+		if err := s.queueForWorkers(videoID, workflowProfile); err != nil {
+			return nil, err
+		}
+		return nil, s.waitForWorker(ctx, videoID)
+	}
+	// 登记为可取消任务；取消后的状态在这里统一持久化
+	ctx, finish := s.trackTask(ctx, videoID)
 	translationConfig := s.resolveTranslationConfig(ctx, userID)
 	initialCtx := &VideoContext{
 		Platform: platform, VideoURL: normalizedURL, VideoID: videoID, UserID: userID,
 		DouyinVideoInfo: douyinInfo, TranslationConfig: translationConfig,
 		SpeechSynthesisConfig: speechConfig, TaskChainSettings: taskChainSettings,
+		WorkflowProfile: workflowProfile,
 	}
+	var (
+		vctx *VideoContext
+		err  error
+	)
 	if platform == "douyin" {
-		return s.douyinChain.ProcessContextWithTracking(ctx, initialCtx, videoID, userID)
+		vctx, err = s.douyinChain.ProcessContextWithTracking(ctx, initialCtx, videoID, userID)
+	} else {
+		vctx, err = s.youtubeChain.ProcessContextWithTracking(ctx, initialCtx, videoID, userID)
 	}
-	return s.youtubeChain.ProcessContextWithTracking(ctx, initialCtx, videoID, userID)
+	return vctx, finish(err)
 }
 
-func (s *ProcessingService) EnqueueRemoteVideoProcessing(platform, normalizedURL, videoID, userID, preferredResolution string,
+func (s *ProcessingService) EnqueueRemoteVideoProcessing(platform, normalizedURL, videoID, userID, preferredResolution, workflowProfile string,
 	douyinInfo *tools.DouyinVideoInfo, taskChainSettings *TaskChainSettings, speechConfig *SpeechSynthesisConfig) {
+	if s.queueOnly() {
+		if err := s.queueForWorkers(videoID, workflowProfile); err != nil {
+			s.logger.Error("视频排队失败", zap.String("video_id", videoID), zap.Error(err))
+		}
+		return
+	}
 	s.workerSem <- struct{}{}
 	go func() {
 		defer func() { <-s.workerSem }()
-		baseCtx := context.Background()
-		ctx, cancel := context.WithTimeout(baseCtx, 30*time.Minute)
-		if s.taskRuntime != nil {
-			s.taskRuntime.Register(videoID, cancel)
-			defer s.taskRuntime.Finish(videoID)
-		}
-		defer cancel()
-		_, procErr := s.ProcessRemoteVideo(ctx, platform, normalizedURL, videoID, userID, preferredResolution,
+		// 各步骤按 [workflow.step_timeouts] 单独限时，这里不再设整体超时；
+		// ProcessRemoteVideo 登记可取消任务并在取消时持久化 cancelled 状态
+		_, procErr := s.ProcessRemoteVideo(context.Background(), platform, normalizedURL, videoID, userID, preferredResolution, workflowProfile,
 			douyinInfo, taskChainSettings, speechConfig)
 		if procErr != nil {
-			if errors.Is(procErr, context.Canceled) {
-				MarkVideoStopped(s.db, s.logger, videoID)
-				s.db.Model(&model.Video{}).Where("video_id = ?", videoID).Update("status", model.VideoStatusPaused)
+			if errors.Is(procErr, context.Canceled) || errors.Is(procErr, ErrShutdown) {
 				return
 			}
 			s.logger.Error("AsyncSubmitLink: 视频处理失败", zap.String("platform", platform), zap.String("video_id", videoID), zap.Error(procErr))
-			s.db.Model(&model.Video{}).Where("video_id = ?", videoID).Update("status", model.VideoStatusFailed)
+			transitionVideo(s.db, s.events, s.logger, videoID, model.VideoTransition{
+				To: model.VideoStatusFailed, Reason: procErr.Error(), Actor: "system",
+			})
 			return
 		}
 		s.logger.Info("AsyncSubmitLink: 视频处理完成", zap.String("platform", platform), zap.String("video_id", videoID))
@@ -6,7 +6,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/difyz9/ytb2bili/internal/events"
 	"github.com/difyz9/ytb2bili/pkg/store/model"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
@@ -62,11 +64,26 @@ func GetProgressTracker(ctx context.Context) *ProgressTracker {
 	return nil
 }
 
-// ProgressTracker 负责将任务步骤进度持久化到数据库
+// ProgressTracker 负责将任务步骤进度持久化到数据库，并把每次变化发布到事件总线
 type ProgressTracker struct {
 	db             *gorm.DB
 	logger         *zap.Logger
-	stepStartTimes sync.Map // key: "videoID:stepName" -> time.Time
+	events         *events.Bus // 可选，nil 时只写数据库
+	stepStartTimes sync.Map    // key: "videoID:stepName" -> time.Time
+	stepNotes      sync.Map    // key: "videoID:stepName" -> 完成后保留的 progress_text
+	videoUsers     sync.Map    // key: videoID -> user_id，事件按用户过滤时使用
+	stepAttempts   sync.Map    // key: "videoID:stepName" -> 执行中的 tb_task_step_attempts.id
+	stepProviders  sync.Map    // key: "videoID:stepName" -> stepProvider
+
+	// 当前运行（StartRun 设置），步骤执行记录归属该运行写入 tb_task_step_attempts
+	runID    string
+	runStart time.Time
+}
+
+// stepProvider 步骤实际使用的服务商与模型，随执行记录保存
+type stepProvider struct {
+	provider string
+	model    string
 }
 
 // NewProgressTracker 创建 ProgressTracker 实例
@@ -74,6 +91,88 @@ func NewProgressTracker(db *gorm.DB, logger *zap.Logger) *ProgressTracker {
 	return &ProgressTracker{db: db, logger: logger}
 }
 
+// WithEvents 设置事件总线（链式调用）
+func (t *ProgressTracker) WithEvents(bus *events.Bus) *ProgressTracker {
+	t.events = bus
+	return t
+}
+
+// publish 发布步骤事件；user_id 按视频查询一次后缓存
+func (t *ProgressTracker) publish(event events.Event) {
+	if t.events == nil {
+		return
+	}
+	if v, ok := t.videoUsers.Load(event.VideoID); ok {
+		event.UserID = v.(string)
+	} else {
+		var video model.Video
+		if err := t.db.Select("user_id").Where("video_id = ?", event.VideoID).Limit(1).Find(&video).Error; err == nil {
+			event.UserID = video.UserID
+			t.videoUsers.Store(event.VideoID, video.UserID)
+		}
+	}
+	t.events.Publish(event)
+}
+
+// StartRun 记录一次任务链运行，之后执行的步骤都归属该运行。
+// trigger 为空时按是否已有运行记录或已完成步骤判断首次处理还是续跑。
+func (t *ProgressTracker) StartRun(videoID, trigger string) string {
+	if videoID == "" {
+		return ""
+	}
+	if trigger == "" {
+		trigger = model.TaskRunTriggerProcess
+		var previous int64
+		t.db.Model(&model.TaskRun{}).Where("video_id = ?", videoID).Count(&previous)
+		if previous == 0 {
+			t.db.Model(&model.TaskStep{}).
+				Where("video_id = ? AND status IN ?", videoID, []string{model.TaskStepStatusCompleted, model.TaskStepStatusSkipped}).
+				Count(&previous)
+		}
+		if previous > 0 {
+			trigger = model.TaskRunTriggerResume
+		}
+	}
+	now := time.Now()
+	run := &model.TaskRun{
+		RunID:     uuid.NewString(),
+		VideoID:   videoID,
+		Trigger:   trigger,
+		Status:    model.TaskRunStatusRunning,
+		StartTime: &now,
+	}
+	if err := t.db.Create(run).Error; err != nil {
+		t.logger.Warn("创建运行记录失败", zap.String("video_id", videoID), zap.Error(err))
+		return ""
+	}
+	t.runID = run.RunID
+	t.runStart = now
+	return run.RunID
+}
+
+// FinishRun 结束当前运行，status 取 model.TaskRunStatus*
+func (t *ProgressTracker) FinishRun(status, errMsg string) {
+	if t.runID == "" {
+		return
+	}
+	now := time.Now()
+	if err := t.db.Model(&model.TaskRun{}).
+		Where("run_id = ?", t.runID).
+		Updates(map[string]interface{}{
+			"status":    status,
+			"end_time":  &now,
+			"duration":  now.Sub(t.runStart).Milliseconds(),
+			"error_msg": errMsg,
+		}).Error; err != nil {
+		t.logger.Warn("更新运行记录失败", zap.String("run_id", t.runID), zap.Error(err))
+	}
+}
+
+// RunID 当前运行 ID，未调用 StartRun 时为空
+func (t *ProgressTracker) RunID() string {
+	return t.runID
+}
+
 // InitSteps 为指定视频初始化所有任务步骤记录（幂等）
 func (t *ProgressTracker) InitSteps(videoID string, steps []Step) error {
 	if videoID == "" {
@@ -120,6 +219,12 @@ func (t *ProgressTracker) InitSteps(videoID string, steps []Step) error {
 	return nil
 }
 
+// videoPhaseStatuses 执行期间视频处于单独阶段的步骤，步骤结束后回到 processing
+var videoPhaseStatuses = map[string]string{
+	StepNameDownloadVideo:       model.VideoStatusDownloading,
+	StepNameDownloadDouyinVideo: model.VideoStatusDownloading,
+}
+
 // BeforeStep 将步骤状态标记为 running
 func (t *ProgressTracker) BeforeStep(videoID, stepName string) {
 	if videoID == "" {
@@ -140,9 +245,19 @@ func (t *ProgressTracker) BeforeStep(videoID, stepName string) {
 			zap.String("step", stepName),
 			zap.Error(err))
 	}
+	t.startAttempt(videoID, stepName, now)
+	if phase, ok := videoPhaseStatuses[stepName]; ok {
+		transitionVideo(t.db, t.events, t.logger, videoID, model.VideoTransition{
+			To: phase, From: []string{model.VideoStatusProcessing}, Reason: stepName, Actor: "system",
+		})
+	}
+	t.publish(events.Event{
+		Type: events.StepStarted, VideoID: videoID, Step: stepName,
+		Status: model.TaskStepStatusRunning, Timestamp: now,
+	})
 }
 
-// AfterStep 将步骤状态标记为 completed / failed / skipped
+// AfterStep 将步骤状态标记为 completed / failed / skipped / timeout / cancelled / interrupted
 func (t *ProgressTracker) AfterStep(videoID, stepName, status, errMsg string) {
 	if videoID == "" {
 		return
@@ -158,17 +273,29 @@ func (t *ProgressTracker) AfterStep(videoID, stepName, status, errMsg string) {
 		"end_time": &now,
 		"duration": durationMs,
 	}
+	note := ""
+	if v, ok := t.stepNotes.LoadAndDelete(videoID + ":" + stepName); ok {
+		note = v.(string)
+	}
 	if status == model.TaskStepStatusCompleted || status == model.TaskStepStatusSkipped {
 		updates["progress_percent"] = 100
-		updates["progress_text"] = ""
+		updates["progress_text"] = compactProgressText(note)
 	}
-	if status == model.TaskStepStatusFailed {
+	failed := status == model.TaskStepStatusFailed || status == model.TaskStepStatusTimeout
+	if failed {
 		updates["progress_text"] = compactProgressText(errMsg)
 	}
+	if status == model.TaskStepStatusCancelled {
+		updates["progress_text"] = "已取消，可继续处理"
+	}
+	if status == model.TaskStepStatusInterrupted {
+		updates["progress_percent"] = 0
+		updates["progress_text"] = "服务停止时中断，将从此步骤继续"
+	}
 	if errMsg != "" {
 		updates["error_msg"] = errMsg
 	}
-	if status == model.TaskStepStatusFailed {
+	if failed || status == model.TaskStepStatusCancelled || status == model.TaskStepStatusInterrupted {
 		updates["can_retry"] = true
 	}
 
@@ -180,6 +307,21 @@ func (t *ProgressTracker) AfterStep(videoID, stepName, status, errMsg string) {
 			zap.String("step", stepName),
 			zap.Error(err))
 	}
+	t.finishAttempt(videoID, stepName, status, errMsg, note, now, durationMs)
+	if phase, ok := videoPhaseStatuses[stepName]; ok {
+		transitionVideo(t.db, t.events, t.logger, videoID, model.VideoTransition{
+			To: model.VideoStatusProcessing, From: []string{phase}, Reason: stepName + " " + status, Actor: "system",
+		})
+	}
+	event := events.Event{
+		Type: events.StepEventType(status), VideoID: videoID, Step: stepName,
+		Status: status, Error: errMsg, Timestamp: now,
+	}
+	if status == model.TaskStepStatusCompleted || status == model.TaskStepStatusSkipped {
+		event.Percent = 100
+		event.Message = compactProgressText(note)
+	}
+	t.publish(event)
 }
 
 // UpdateStepProgress 持久化步骤的中间进度信息。
@@ -204,6 +346,80 @@ func (t *ProgressTracker) UpdateStepProgress(videoID, stepName string, percent i
 			zap.String("step", stepName),
 			zap.Error(err))
 	}
+	t.publish(events.Event{
+		Type: events.StepProgress, VideoID: videoID, Step: stepName,
+		Status: model.TaskStepStatusRunning, Percent: percent, Message: compactProgressText(message),
+	})
+}
+
+// SetCompletionNote 设置步骤完成后显示在 progress_text 中的说明（如缓存命中情况）
+func (t *ProgressTracker) SetCompletionNote(videoID, stepName, note string) {
+	if videoID == "" {
+		return
+	}
+	t.stepNotes.Store(videoID+":"+stepName, note)
+}
+
+// SetStepProvider 记录步骤本次实际使用的服务商与模型（如转写引擎、LLM 模型、TTS 音色）
+func (t *ProgressTracker) SetStepProvider(videoID, stepName, provider, modelName string) {
+	if videoID == "" {
+		return
+	}
+	t.stepProviders.Store(videoID+":"+stepName, stepProvider{provider: provider, model: modelName})
+}
+
+// reportStepProvider 供步骤通过 context 上报实际使用的服务商与模型
+func reportStepProvider(ctx context.Context, stepName, provider, modelName string) {
+	if tracker := GetProgressTracker(ctx); tracker != nil {
+		tracker.SetStepProvider(GetVideoID(ctx), stepName, provider, modelName)
+	}
+}
+
+// startAttempt 新增一条步骤执行记录，序号按该视频该步骤的历史累加
+func (t *ProgressTracker) startAttempt(videoID, stepName string, now time.Time) {
+	var last int
+	if err := t.db.Model(&model.TaskStepAttempt{}).
+		Where("video_id = ? AND step_name = ?", videoID, stepName).
+		Select("COALESCE(MAX(attempt), 0)").Scan(&last).Error; err != nil {
+		t.logger.Warn("查询步骤执行次数失败", zap.String("video_id", videoID), zap.String("step", stepName), zap.Error(err))
+	}
+	attempt := &model.TaskStepAttempt{
+		RunID:     t.runID,
+		VideoID:   videoID,
+		StepName:  stepName,
+		Attempt:   last + 1,
+		Status:    model.TaskStepStatusRunning,
+		StartTime: &now,
+	}
+	if err := t.db.Create(attempt).Error; err != nil {
+		t.logger.Warn("创建步骤执行记录失败", zap.String("video_id", videoID), zap.String("step", stepName), zap.Error(err))
+		return
+	}
+	t.stepAttempts.Store(videoID+":"+stepName, attempt.ID)
+}
+
+// finishAttempt 写回步骤执行结果；未经 BeforeStep 直接跳过的步骤没有执行记录
+func (t *ProgressTracker) finishAttempt(videoID, stepName, status, errMsg, note string, now time.Time, durationMs int64) {
+	key := videoID + ":" + stepName
+	v, ok := t.stepAttempts.LoadAndDelete(key)
+	if !ok {
+		t.stepProviders.Delete(key)
+		return
+	}
+	updates := map[string]interface{}{
+		"status":        status,
+		"end_time":      &now,
+		"duration":      durationMs,
+		"error_msg":     errMsg,
+		"progress_text": compactProgressText(note),
+	}
+	if p, ok := t.stepProviders.LoadAndDelete(key); ok {
+		updates["provider"] = p.(stepProvider).provider
+		updates["model"] = p.(stepProvider).model
+	}
+	if err := t.db.Model(&model.TaskStepAttempt{}).Where("id = ?", v.(uint)).Updates(updates).Error; err != nil {
+		t.logger.Warn("更新步骤执行记录失败", zap.String("video_id", videoID), zap.String("step", stepName), zap.Error(err))
+	}
 }
 
 func compactProgressText(message string) string {
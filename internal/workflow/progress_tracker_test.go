@@ -2,8 +2,13 @@ package workflow
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/difyz9/ytb2bili/internal/events"
 	storemodel "github.com/difyz9/ytb2bili/pkg/store/model"
 	"go.uber.org/zap"
 	"gorm.io/driver/sqlite"
@@ -64,4 +69,97 @@ func TestProgressTrackerInitStepsPreservesSkippedStatus(t *testing.T) {
 	if synthStep.ErrorMsg != "" {
 		t.Fatalf("expected synth step error to be cleared, got %q", synthStep.ErrorMsg)
 	}
-}
\ No newline at end of file
+}
+
+func TestProgressTrackerPublishesStepEvents(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&storemodel.TaskStep{}, &storemodel.Video{}, &storemodel.VideoStatusTransition{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	db.Create(&storemodel.Video{VideoID: "video-1", UserID: "u1", URL: "https://example.com/v"})
+
+	bus := events.NewBus(zap.NewNop())
+	sub := bus.Subscribe("test", events.ForVideo("video-1"), 8)
+	defer sub.Close()
+
+	tracker := NewProgressTracker(db, zap.NewNop()).WithEvents(bus)
+	tracker.BeforeStep("video-1", StepNameTranscribe)
+	tracker.UpdateStepProgress("video-1", StepNameTranscribe, 40, "转写中")
+	tracker.AfterStep("video-1", StepNameTranscribe, storemodel.TaskStepStatusTimeout, "step timed out")
+
+	want := []events.Event{
+		{Type: events.StepStarted, Status: storemodel.TaskStepStatusRunning},
+		{Type: events.StepProgress, Status: storemodel.TaskStepStatusRunning, Percent: 40, Message: "转写中"},
+		{Type: events.StepFailed, Status: storemodel.TaskStepStatusTimeout, Error: "step timed out"},
+	}
+	for _, w := range want {
+		var got events.Event
+		select {
+		case got = <-sub.C:
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %s event", w.Type)
+		}
+		if got.Type != w.Type || got.Status != w.Status || got.Percent != w.Percent || got.Message != w.Message ||
+			got.Error != w.Error || got.Step != StepNameTranscribe || got.UserID != "u1" {
+			t.Fatalf("expected %+v, got %+v", w, got)
+		}
+	}
+}
+
+// flakyProviderStep 第一次执行失败，之后成功，并上报使用的模型
+type flakyProviderStep struct {
+	BaseStep
+	calls int
+}
+
+func (s *flakyProviderStep) Execute(ctx context.Context, input any) (any, error) {
+	s.calls++
+	reportStepProvider(ctx, s.Name(), "llm", fmt.Sprintf("model-%d", s.calls))
+	if s.calls == 1 {
+		return nil, errors.New("rate limited")
+	}
+	return input, nil
+}
+
+func TestRunChainWithTracking_KeepsAttemptHistory(t *testing.T) {
+	db := openCheckpointTestDB(t)
+	videoID := "history-1"
+	flaky := &flakyProviderStep{BaseStep: NewBaseStepWithOrder(StepNameLLMTranslate, true, 6)}
+	chain := NewChainFromSteps([]Step{flaky}, zap.NewNop(), "history-test")
+	ctx := WithVideoID(context.Background(), videoID)
+
+	if _, err := RunChainWithTracking(ctx, chain, db, zap.NewNop(), videoID, &VideoContext{VideoID: videoID}); err == nil {
+		t.Fatal("expected first run to fail")
+	}
+	if _, err := RunChainWithTracking(ctx, chain, db, zap.NewNop(), videoID, &VideoContext{VideoID: videoID}); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+
+	var runs []storemodel.TaskRun
+	db.Order("id").Find(&runs)
+	if len(runs) != 2 {
+		t.Fatalf("expected 2 runs, got %d", len(runs))
+	}
+	if runs[0].Status != storemodel.TaskRunStatusFailed || runs[0].Trigger != storemodel.TaskRunTriggerProcess ||
+		runs[1].Status != storemodel.TaskRunStatusCompleted || runs[1].Trigger != storemodel.TaskRunTriggerResume {
+		t.Fatalf("unexpected runs: %+v", runs)
+	}
+
+	var attempts []storemodel.TaskStepAttempt
+	db.Where("video_id = ? AND step_name = ?", videoID, StepNameLLMTranslate).Order("attempt").Find(&attempts)
+	if len(attempts) != 2 {
+		t.Fatalf("expected 2 attempts, got %d", len(attempts))
+	}
+	first, second := attempts[0], attempts[1]
+	if first.Attempt != 1 || first.RunID != runs[0].RunID || first.Status != storemodel.TaskStepStatusFailed ||
+		!strings.Contains(first.ErrorMsg, "rate limited") || first.Model != "model-1" || first.Provider != "llm" {
+		t.Fatalf("first attempt should keep its failure details, got %+v", first)
+	}
+	if second.Attempt != 2 || second.RunID != runs[1].RunID || second.Status != storemodel.TaskStepStatusCompleted ||
+		second.ErrorMsg != "" || second.Model != "model-2" || second.EndTime == nil {
+		t.Fatalf("unexpected second attempt: %+v", second)
+	}
+}
@@ -0,0 +1,371 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/difyz9/ytb2bili/internal/config"
+	"github.com/difyz9/ytb2bili/pkg/tools"
+	"github.com/shirou/gopsutil/v4/cpu"
+	"github.com/shirou/gopsutil/v4/disk"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+// ── 资源准入 ─────────────────────────────────────────────────────────────────
+// 下载前检查 download_dir 所在磁盘能否放下探测到的视频（× disk_size_factor，并保留 min_free_disk_mb），
+// CPU 密集步骤（ffmpeg 抽音频、加水印）与下载各自只有有限的名额，名额用完时步骤排队等待；
+// 本地 whisper 在转写步骤内部按次占用 CPU 名额，分段转写时每个片段各占一个（见 AcquireCPU）。
+// 后台定时采样磁盘与 CPU，超过阈值时暂停取新任务，原因可在 /api/v1/background/status 查看。
+// Chain 与 Pipeline 共用同一个 ResourceGuard，均在步骤超时开始计时前准入，排队等待不计入超时。
+
+const (
+	defaultMinFreeDiskMB   = 2048
+	defaultDiskSizeFactor  = 2.5
+	defaultDiskWaitMinutes = 30
+	defaultDownloadSlots   = 2
+)
+
+// builtinCPUHeavySteps 未配置 cpu_heavy_steps 时视为 CPU 密集的步骤
+var builtinCPUHeavySteps = []string{StepNameExtractAudio, StepNameAddWatermark}
+
+// downloadStepNames 占用下载名额并在执行前检查磁盘空间的步骤
+var downloadStepNames = []string{StepNameDownloadVideo, StepNameDownloadDouyinVideo}
+
+// 采样与等待间隔（测试中可缩短）
+var (
+	resourceSampleInterval = 15 * time.Second
+	diskWaitInterval       = 30 * time.Second
+)
+
+type resourceClass int
+
+const (
+	resourceNone resourceClass = iota
+	resourceCPU
+	resourceDownload
+)
+
+// StepWithDiskEstimate 能在执行前估算所需磁盘空间（字节）的步骤，返回 0 表示未知
+type StepWithDiskEstimate interface {
+	Step
+	EstimateDiskBytes(ctx context.Context, input any) int64
+}
+
+// InsufficientDiskError 等待超时后磁盘空间仍不足
+type InsufficientDiskError struct {
+	Step      string
+	Required  int64
+	Available int64
+}
+
+func (e *InsufficientDiskError) Error() string {
+	return fmt.Sprintf("step '%s': insufficient disk space: need %s, available %s",
+		e.Step, tools.HumanizeBytes(e.Required), tools.HumanizeBytes(e.Available))
+}
+
+// ResourceGuard 资源准入控制，nil 表示不限制（所有方法均可在 nil 上调用）
+type ResourceGuard struct {
+	downloadDir string
+	diskCheck   bool
+	minFree     int64
+	sizeFactor  float64
+	diskWait    time.Duration
+	maxCPU      float64
+	classes     map[string]resourceClass
+	cpuSlots    chan struct{}
+	dlSlots     chan struct{}
+	logger      *zap.Logger
+
+	freeBytes  func(path string) (int64, error)
+	cpuPercent func() (float64, error)
+
+	mu          sync.Mutex
+	reserved    int64  // 已准入、尚未结束的下载预留的字节数
+	pauseReason string // 非空表示暂停取新任务
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// ResourceGuardParams 资源准入的依赖参数
+type ResourceGuardParams struct {
+	fx.In
+	Cfg       config.WorkflowConfig
+	Lifecycle fx.Lifecycle
+	Logger    *zap.Logger
+}
+
+// NewResourceGuard 按 [workflow.resources] 创建资源准入控制，并随应用启动后台采样
+func NewResourceGuard(params ResourceGuardParams) *ResourceGuard {
+	g := newResourceGuard(params.Cfg, params.Logger)
+	params.Lifecycle.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			g.Start()
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			g.Stop()
+			return nil
+		},
+	})
+	return g
+}
+
+func newResourceGuard(cfg config.WorkflowConfig, logger *zap.Logger) *ResourceGuard {
+	rc := cfg.Resources
+	downloadDir := strings.TrimSpace(cfg.DownloadDir)
+	if downloadDir == "" {
+		downloadDir = "./downloads"
+	}
+	minFreeMB := rc.MinFreeDiskMB
+	if minFreeMB == 0 {
+		minFreeMB = defaultMinFreeDiskMB
+	}
+	sizeFactor := rc.DiskSizeFactor
+	if sizeFactor <= 0 {
+		sizeFactor = defaultDiskSizeFactor
+	}
+	waitMinutes := rc.DiskWaitMinutes
+	if waitMinutes <= 0 {
+		waitMinutes = defaultDiskWaitMinutes
+	}
+	cpuSlots := rc.CPUHeavySlots
+	if cpuSlots <= 0 {
+		cpuSlots = max(1, runtime.NumCPU()/2)
+	}
+	dlSlots := rc.DownloadSlots
+	if dlSlots <= 0 {
+		dlSlots = defaultDownloadSlots
+	}
+
+	cpuSteps := builtinCPUHeavySteps
+	if len(rc.CPUHeavySteps) > 0 {
+		cpuSteps = rc.CPUHeavySteps
+	}
+	classes := make(map[string]resourceClass, len(cpuSteps)+len(downloadStepNames))
+	for _, name := range downloadStepNames {
+		classes[name] = resourceDownload
+	}
+	for _, name := range cpuSteps {
+		if name = strings.TrimSpace(name); name != "" {
+			classes[name] = resourceCPU
+		}
+	}
+
+	return &ResourceGuard{
+		downloadDir: downloadDir,
+		diskCheck:   minFreeMB > 0,
+		minFree:     int64(minFreeMB) << 20,
+		sizeFactor:  sizeFactor,
+		diskWait:    time.Duration(waitMinutes) * time.Minute,
+		maxCPU:      rc.MaxCPUPercent,
+		classes:     classes,
+		cpuSlots:    make(chan struct{}, cpuSlots),
+		dlSlots:     make(chan struct{}, dlSlots),
+		logger:      logger,
+		freeBytes:   diskFreeBytes,
+		cpuPercent:  cpuUsagePercent,
+	}
+}
+
+func diskFreeBytes(path string) (int64, error) {
+	usage, err := disk.Usage(path)
+	if err != nil {
+		return 0, err
+	}
+	return int64(usage.Free), nil
+}
+
+// cpuUsagePercent 返回距上次调用以来的整机 CPU 使用率
+func cpuUsagePercent() (float64, error) {
+	percentages, err := cpu.Percent(0, false)
+	if err != nil || len(percentages) == 0 {
+		return 0, err
+	}
+	return percentages[0], nil
+}
+
+// Admit 等待步骤的准入条件满足，返回步骤结束后必须调用的释放函数。
+// 等待期间在步骤进度中显示原因；ctx 取消或磁盘空间等待超时时返回错误。
+func (g *ResourceGuard) Admit(ctx context.Context, step Step, input any) (func(), error) {
+	if g == nil {
+		return func() {}, nil
+	}
+	switch g.classes[step.Name()] {
+	case resourceCPU:
+		return g.acquire(ctx, step.Name(), g.cpuSlots, "等待 CPU 资源")
+	case resourceDownload:
+		releaseSlot, err := g.acquire(ctx, step.Name(), g.dlSlots, "等待下载名额")
+		if err != nil {
+			return nil, err
+		}
+		reserved, err := g.reserveDisk(ctx, step, input)
+		if err != nil {
+			releaseSlot()
+			return nil, err
+		}
+		return func() {
+			g.mu.Lock()
+			g.reserved -= reserved
+			g.mu.Unlock()
+			releaseSlot()
+		}, nil
+	default:
+		return func() {}, nil
+	}
+}
+
+// AcquireCPU 在步骤内部占用一个 CPU 密集名额，返回释放函数；用于只有部分执行路径是 CPU 密集的步骤，
+// 如转写步骤使用本地 whisper 时每次（分段时每个片段）调用 whisper-cli。
+// 步骤本身已配置为 CPU 密集时整个步骤已持有名额，不再重复占用，避免名额不足时等待自己。
+func (g *ResourceGuard) AcquireCPU(ctx context.Context, stepName string) (func(), error) {
+	if g == nil || g.classes[stepName] == resourceCPU {
+		return func() {}, nil
+	}
+	return g.acquire(ctx, stepName, g.cpuSlots, "等待 CPU 资源")
+}
+
+// acquire 占用一个名额，名额已满时等待
+func (g *ResourceGuard) acquire(ctx context.Context, stepName string, slots chan struct{}, waitMessage string) (func(), error) {
+	select {
+	case slots <- struct{}{}:
+	default:
+		reportAdmission(ctx, stepName, fmt.Sprintf("%s（%d 个名额已占满）", waitMessage, cap(slots)))
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() { <-slots })
+	}, nil
+}
+
+// reserveDisk 确认磁盘能放下步骤预计写入的数据并预留这部分空间，返回预留的字节数。
+// 空间不足时每隔 diskWaitInterval 重新检查，超过 disk_wait_minutes 返回 InsufficientDiskError。
+func (g *ResourceGuard) reserveDisk(ctx context.Context, step Step, input any) (int64, error) {
+	if !g.diskCheck {
+		return 0, nil
+	}
+	var need int64
+	if estimator, ok := step.(StepWithDiskEstimate); ok {
+		need = int64(float64(estimator.EstimateDiskBytes(ctx, input)) * g.sizeFactor)
+	}
+
+	deadline := time.Now().Add(g.diskWait)
+	for {
+		free, err := g.freeBytes(g.downloadDir)
+		if err != nil {
+			// 读不到磁盘信息时不阻塞处理
+			g.logger.Warn("Failed to read disk usage for admission",
+				zap.String("path", g.downloadDir), zap.Error(err))
+			return 0, nil
+		}
+
+		g.mu.Lock()
+		available := free - g.reserved
+		if available-need >= g.minFree {
+			g.reserved += need
+			g.mu.Unlock()
+			return need, nil
+		}
+		g.mu.Unlock()
+
+		if time.Now().After(deadline) {
+			return 0, &InsufficientDiskError{Step: step.Name(), Required: need + g.minFree, Available: available}
+		}
+		reportAdmission(ctx, step.Name(), fmt.Sprintf("磁盘空间不足，等待释放：需要 %s，可用 %s",
+			tools.HumanizeBytes(need+g.minFree), tools.HumanizeBytes(available)))
+		if err := sleepWithContext(ctx, diskWaitInterval); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// reportAdmission 在步骤进度中显示等待原因
+func reportAdmission(ctx context.Context, stepName, message string) {
+	tracker := GetProgressTracker(ctx)
+	videoID := GetVideoID(ctx)
+	if tracker == nil || videoID == "" {
+		return
+	}
+	tracker.UpdateStepProgress(videoID, stepName, 0, message)
+}
+
+// Paused 返回是否应暂停取新任务及原因
+func (g *ResourceGuard) Paused() (bool, string) {
+	if g == nil {
+		return false, ""
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.pauseReason != "", g.pauseReason
+}
+
+// Start 启动后台采样（重复调用无效）
+func (g *ResourceGuard) Start() {
+	if g == nil || g.stop != nil {
+		return
+	}
+	g.stop = make(chan struct{})
+	g.sample()
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		ticker := time.NewTicker(resourceSampleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				g.sample()
+			case <-g.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止后台采样
+func (g *ResourceGuard) Stop() {
+	if g == nil || g.stop == nil {
+		return
+	}
+	close(g.stop)
+	g.wg.Wait()
+}
+
+// sample 采样磁盘与 CPU，更新暂停原因；状态变化时记录日志
+func (g *ResourceGuard) sample() {
+	var reasons []string
+	if g.diskCheck {
+		if free, err := g.freeBytes(g.downloadDir); err == nil && free < g.minFree {
+			reasons = append(reasons, fmt.Sprintf("磁盘空间不足：%s 剩余 %s，低于 %s",
+				g.downloadDir, tools.HumanizeBytes(free), tools.HumanizeBytes(g.minFree)))
+		}
+	}
+	if g.maxCPU > 0 {
+		if percent, err := g.cpuPercent(); err == nil && percent >= g.maxCPU {
+			reasons = append(reasons, fmt.Sprintf("CPU 使用率 %.0f%% 超过阈值 %.0f%%", percent, g.maxCPU))
+		}
+	}
+	reason := strings.Join(reasons, "；")
+
+	g.mu.Lock()
+	previous := g.pauseReason
+	g.pauseReason = reason
+	g.mu.Unlock()
+
+	switch {
+	case reason != "" && previous == "":
+		g.logger.Warn("资源紧张，暂停取新任务", zap.String("reason", reason))
+	case reason == "" && previous != "":
+		g.logger.Info("资源恢复，继续取新任务")
+	}
+}
@@ -0,0 +1,193 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/difyz9/ytb2bili/internal/config"
+	"go.uber.org/zap/zaptest"
+)
+
+// diskEstimateStep 预估固定磁盘占用的测试步骤
+type diskEstimateStep struct {
+	BaseStep
+	bytes int64
+}
+
+func (s *diskEstimateStep) Execute(ctx context.Context, input any) (any, error) {
+	return input, nil
+}
+
+func (s *diskEstimateStep) EstimateDiskBytes(ctx context.Context, input any) int64 {
+	return s.bytes
+}
+
+func newTestResourceGuard(t *testing.T, rc config.ResourcesConfig, freeBytes int64) *ResourceGuard {
+	t.Helper()
+	guard := newResourceGuard(config.WorkflowConfig{DownloadDir: t.TempDir(), Resources: rc}, zaptest.NewLogger(t))
+	guard.freeBytes = func(string) (int64, error) { return freeBytes, nil }
+	guard.cpuPercent = func() (float64, error) { return 0, nil }
+	return guard
+}
+
+func TestResourceGuard_LimitsCPUHeavySteps(t *testing.T) {
+	guard := newTestResourceGuard(t, config.ResourcesConfig{CPUHeavySlots: 1}, 100<<30)
+	extract := &diskEstimateStep{BaseStep: NewBaseStep(StepNameExtractAudio, true)}
+
+	release, err := guard.Admit(context.Background(), extract, nil)
+	if err != nil {
+		t.Fatalf("first CPU-heavy step should be admitted: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := guard.Admit(ctx, extract, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected second CPU-heavy step to wait for a slot, got %v", err)
+	}
+
+	// 不占名额的步骤不受影响
+	translate := &diskEstimateStep{BaseStep: NewBaseStep(StepNameLLMTranslate, true)}
+	if _, err := guard.Admit(ctx, translate, nil); err != nil {
+		t.Fatalf("non-heavy step should not wait: %v", err)
+	}
+
+	release()
+	release() // 重复释放不应多还名额
+	release2, err := guard.Admit(context.Background(), extract, nil)
+	if err != nil {
+		t.Fatalf("slot should be free after release: %v", err)
+	}
+	release2()
+	if len(guard.cpuSlots) != 0 {
+		t.Fatalf("expected all CPU slots released, %d still held", len(guard.cpuSlots))
+	}
+}
+
+func TestResourceGuard_ReservesDiskForDownloads(t *testing.T) {
+	previous := diskWaitInterval
+	diskWaitInterval = 5 * time.Millisecond
+	t.Cleanup(func() { diskWaitInterval = previous })
+
+	// 剩余 2.5GB，保留 1GB，每个下载预估 512MB × 2 = 1GB
+	guard := newTestResourceGuard(t, config.ResourcesConfig{
+		MinFreeDiskMB:  1024,
+		DiskSizeFactor: 2,
+	}, 2560<<20)
+	guard.diskWait = 20 * time.Millisecond
+	download := &diskEstimateStep{BaseStep: NewBaseStep(StepNameDownloadVideo, true), bytes: 512 << 20}
+
+	release, err := guard.Admit(context.Background(), download, nil)
+	if err != nil {
+		t.Fatalf("first download should fit: %v", err)
+	}
+
+	_, err = guard.Admit(context.Background(), download, nil)
+	var diskErr *InsufficientDiskError
+	if !errors.As(err, &diskErr) {
+		t.Fatalf("expected InsufficientDiskError while space is reserved, got %v", err)
+	}
+	if diskErr.Required != 2048<<20 || diskErr.Available != 1536<<20 {
+		t.Fatalf("unexpected disk error numbers: %+v", diskErr)
+	}
+	if len(guard.dlSlots) != 1 {
+		t.Fatalf("rejected download must give back its slot, %d held", len(guard.dlSlots))
+	}
+
+	release()
+	release2, err := guard.Admit(context.Background(), download, nil)
+	if err != nil {
+		t.Fatalf("download should fit after reservation released: %v", err)
+	}
+	release2()
+	if guard.reserved != 0 {
+		t.Fatalf("expected no reserved bytes, got %d", guard.reserved)
+	}
+}
+
+func TestResourceGuard_PausesQueueOnThresholds(t *testing.T) {
+	guard := newTestResourceGuard(t, config.ResourcesConfig{MinFreeDiskMB: 1024, MaxCPUPercent: 90}, 512<<20)
+	cpuPercent := 97.0
+	guard.cpuPercent = func() (float64, error) { return cpuPercent, nil }
+
+	guard.sample()
+	paused, reason := guard.Paused()
+	if !paused || !strings.Contains(reason, "磁盘空间不足") || !strings.Contains(reason, "CPU 使用率 97%") {
+		t.Fatalf("expected disk and CPU pause reasons, got paused=%v reason=%q", paused, reason)
+	}
+
+	guard.freeBytes = func(string) (int64, error) { return 10 << 30, nil }
+	cpuPercent = 20
+	guard.sample()
+	if paused, reason := guard.Paused(); paused {
+		t.Fatalf("expected queue to resume, still paused: %q", reason)
+	}
+
+	if paused, _ := (*ResourceGuard)(nil).Paused(); paused {
+		t.Fatal("nil guard must never pause the queue")
+	}
+}
+
+func TestResourceGuard_DisabledDiskCheck(t *testing.T) {
+	guard := newTestResourceGuard(t, config.ResourcesConfig{MinFreeDiskMB: -1}, 0)
+	download := &diskEstimateStep{BaseStep: NewBaseStep(StepNameDownloadVideo, true), bytes: 1 << 30}
+
+	release, err := guard.Admit(context.Background(), download, nil)
+	if err != nil {
+		t.Fatalf("disk check disabled, download should be admitted: %v", err)
+	}
+	release()
+	guard.sample()
+	if paused, reason := guard.Paused(); paused {
+		t.Fatalf("disabled disk check must not pause the queue: %q", reason)
+	}
+}
+
+func TestResourceGuard_AcquireCPUInsideStep(t *testing.T) {
+	guard := newTestResourceGuard(t, config.ResourcesConfig{CPUHeavySlots: 1}, 100<<30)
+
+	release, err := guard.AcquireCPU(context.Background(), StepNameTranscribe)
+	if err != nil {
+		t.Fatalf("first whisper run should get a CPU slot: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := guard.AcquireCPU(ctx, StepNameTranscribe); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected second whisper run to wait for a slot, got %v", err)
+	}
+	extract := &diskEstimateStep{BaseStep: NewBaseStep(StepNameExtractAudio, true)}
+	if _, err := guard.Admit(ctx, extract, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected CPU-heavy step to share slots with whisper runs, got %v", err)
+	}
+
+	release()
+	if len(guard.cpuSlots) != 0 {
+		t.Fatalf("expected all CPU slots released, %d still held", len(guard.cpuSlots))
+	}
+}
+
+func TestResourceGuard_AcquireCPUSkippedForCPUHeavyStep(t *testing.T) {
+	// 转写步骤整体已配置为 CPU 密集时，步骤内部不再重复占用名额
+	guard := newTestResourceGuard(t, config.ResourcesConfig{
+		CPUHeavySlots: 1,
+		CPUHeavySteps: []string{StepNameTranscribe},
+	}, 100<<30)
+	transcribe := &diskEstimateStep{BaseStep: NewBaseStep(StepNameTranscribe, true)}
+
+	release, err := guard.Admit(context.Background(), transcribe, nil)
+	if err != nil {
+		t.Fatalf("transcribe step should be admitted: %v", err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	inner, err := guard.AcquireCPU(ctx, StepNameTranscribe)
+	if err != nil {
+		t.Fatalf("whisper run inside a CPU-heavy step should not wait: %v", err)
+	}
+	inner()
+}
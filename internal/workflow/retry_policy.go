@@ -0,0 +1,102 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/difyz9/ytb2bili/pkg/tools"
+)
+
+// RetryPolicy 单个步骤的重试策略
+type RetryPolicy struct {
+	MaxAttempts    int              // 最大尝试次数（含首次），<=1 表示不重试
+	InitialBackoff time.Duration    // 首次重试前的等待时间
+	MaxBackoff     time.Duration    // 单次等待上限
+	Multiplier     float64          // 退避倍数，<=1 时按 2 处理
+	Jitter         float64          // 抖动比例（0~1），避免多个任务同时重试
+	Retryable      func(error) bool // 错误是否值得重试，nil 时使用 IsRetryableStepError
+}
+
+// StepWithRetryPolicy 支持步骤级重试的步骤。
+// 任务链在步骤返回可重试错误时按策略退避重试，永久错误立即失败。
+type StepWithRetryPolicy interface {
+	Step
+	RetryPolicy() RetryPolicy
+}
+
+// DefaultRetryPolicy 默认的网络类步骤重试策略：最多 3 次，2s 起指数退避
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 2 * time.Second,
+		MaxBackoff:     30 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// IsRetryableStepError 默认的可重试判断：仅临时错误（限流、超时、网络、5xx）重试。
+// StepSkippedError 按其 Cause 判断，使可容忍的步骤也能先重试再降级为 skipped。
+func IsRetryableStepError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var skipErr *StepSkippedError
+	if errors.As(err, &skipErr) && skipErr.Cause != nil {
+		err = skipErr.Cause
+	}
+	return tools.IsTransientError(err)
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsRetryableStepError(err)
+}
+
+// backoff 返回第 attempt 次失败后（从 1 开始）的等待时间
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	if p.InitialBackoff <= 0 {
+		return 0
+	}
+	multiplier := p.Multiplier
+	if multiplier <= 1 {
+		multiplier = 2
+	}
+	delay := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && delay > float64(p.MaxBackoff) {
+		delay = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		jitter := math.Min(p.Jitter, 1)
+		delay *= 1 - jitter + rand.Float64()*2*jitter
+	}
+	return time.Duration(delay)
+}
+
+// resolveRetryPolicy 返回步骤声明的重试策略，未声明时只执行一次
+func resolveRetryPolicy(step Step) RetryPolicy {
+	if retryStep, ok := step.(StepWithRetryPolicy); ok {
+		return retryStep.RetryPolicy()
+	}
+	return RetryPolicy{MaxAttempts: 1}
+}
+
+// sleepWithContext 等待 d，context 取消时提前返回错误
+func sleepWithContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
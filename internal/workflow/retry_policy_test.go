@@ -0,0 +1,74 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/difyz9/ytb2bili/pkg/tools"
+	"go.uber.org/zap/zaptest"
+)
+
+type retryTestStep struct {
+	BaseStep
+	errs  []error
+	calls int
+}
+
+func (s *retryTestStep) Execute(ctx context.Context, input any) (any, error) {
+	s.calls++
+	if s.calls <= len(s.errs) {
+		return nil, s.errs[s.calls-1]
+	}
+	return input, nil
+}
+
+func (s *retryTestStep) RetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, Multiplier: 2, Jitter: 0.2}
+}
+
+func TestChain_RetriesTransientStepErrors(t *testing.T) {
+	step := &retryTestStep{
+		BaseStep: NewBaseStepWithOrder("flaky", true, 1),
+		errs: []error{
+			errors.New("azure-tts returned status 429: too many requests"),
+			tools.TransientError(errors.New("fragment 3 not found")),
+		},
+	}
+	chain := NewChainFromSteps([]Step{step}, zaptest.NewLogger(t), "retry")
+
+	result := chain.Run(context.Background(), "input")
+	if !result.Success {
+		t.Fatalf("expected success after retries, got %v", result.Error)
+	}
+	if step.calls != 3 || result.StepDetails["flaky"].Attempts != 3 {
+		t.Fatalf("expected 3 attempts, got calls=%d attempts=%d", step.calls, result.StepDetails["flaky"].Attempts)
+	}
+}
+
+func TestChain_PermanentStepErrorStopsImmediately(t *testing.T) {
+	step := &retryTestStep{
+		BaseStep: NewBaseStepWithOrder("broken", true, 1),
+		errs:     []error{tools.PermanentError(errors.New("video unavailable or deleted"))},
+	}
+	chain := NewChainFromSteps([]Step{step}, zaptest.NewLogger(t), "retry")
+
+	result := chain.Run(context.Background(), "input")
+	if result.Success {
+		t.Fatal("expected chain to fail")
+	}
+	if step.calls != 1 {
+		t.Fatalf("expected a single attempt for permanent error, got %d", step.calls)
+	}
+}
+
+func TestRetryPolicy_BackoffIsBounded(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second, Multiplier: 2, Jitter: 0.2}
+	for attempt := 1; attempt <= 6; attempt++ {
+		delay := policy.backoff(attempt)
+		if delay <= 0 || delay > 6*time.Second {
+			t.Fatalf("attempt %d: unexpected backoff %s", attempt, delay)
+		}
+	}
+}
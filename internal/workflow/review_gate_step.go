@@ -0,0 +1,163 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/difyz9/ytb2bili/internal/config"
+	"github.com/difyz9/ytb2bili/internal/events"
+	"github.com/difyz9/ytb2bili/internal/service"
+	"github.com/difyz9/ytb2bili/pkg/store/model"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ============================================================================
+// 发布前人工审核关卡
+// ============================================================================
+//
+// 需要审核时（用户设置 review_before_upload，未设置时取 [workflow.review] required），
+// 处理完成的视频停在 awaiting_review，生成的标题/简介/标签与字幕文件等待人工确认；
+// 自动投稿只会取已完成（审核通过或无需审核）的视频。
+// 审核退回时所选步骤重跑，退回意见通过 VideoContext.ReviewFeedback 附加到翻译与元数据提示词。
+
+// ReviewGateStep 审核关卡步骤，位于元数据生成之后、投稿之前
+type ReviewGateStep struct {
+	BaseStep
+	db              *gorm.DB
+	userSettings    *service.UserSettingsClient
+	events          *events.Bus
+	logger          *zap.Logger
+	requiredDefault bool
+}
+
+type ReviewGateStepParams struct {
+	fx.In
+	DB           *gorm.DB
+	UserSettings *service.UserSettingsClient `optional:"true"`
+	Events       *events.Bus                 `optional:"true"`
+	Cfg          config.WorkflowConfig
+	Logger       *zap.Logger
+}
+
+func NewReviewGateStep(params ReviewGateStepParams) *ReviewGateStep {
+	return &ReviewGateStep{
+		BaseStep:        NewBaseStepWithOrder(StepNameReviewGate, true, 40), // 在 GenerateMetadata(30) 之后
+		db:              params.DB,
+		userSettings:    params.UserSettings,
+		events:          params.Events,
+		logger:          params.Logger,
+		requiredDefault: params.Cfg.Review.Required,
+	}
+}
+
+// ShouldSkip 视频所属用户无需审核时跳过
+func (s *ReviewGateStep) ShouldSkip(ctx context.Context, input any) bool {
+	vctx, ok := input.(*VideoContext)
+	if !ok || vctx == nil {
+		return true
+	}
+	return !s.reviewRequired(ctx, vctx.UserID)
+}
+
+func (s *ReviewGateStep) reviewRequired(ctx context.Context, userID string) bool {
+	if s.userSettings == nil || !s.userSettings.IsEnabled() || strings.TrimSpace(userID) == "" {
+		return s.requiredDefault
+	}
+	settings, err := s.userSettings.GetSettings(ctx, userID)
+	if err != nil {
+		s.logger.Warn("加载审核设置失败，使用默认值", zap.String("user_id", userID), zap.Error(err))
+		return s.requiredDefault
+	}
+	switch strings.TrimSpace(settings[model.UserSettingKeyReviewBeforeUpload]) {
+	case "1":
+		return true
+	case "0":
+		return false
+	default:
+		return s.requiredDefault
+	}
+}
+
+func (s *ReviewGateStep) Execute(ctx context.Context, input any) (any, error) {
+	vctx, err := mustVideoContext(input)
+	if err != nil {
+		return nil, err
+	}
+	videoID := GetVideoID(ctx)
+	if videoID == "" {
+		videoID = vctx.VideoID
+	}
+	if videoID == "" {
+		return nil, fmt.Errorf("review gate: missing video id")
+	}
+
+	// 以本次生成的元数据为准，审核时可再编辑；退回意见保留，便于对照
+	updates := map[string]interface{}{
+		"review_status": model.ReviewStatusPending,
+		"reviewed_by":   "",
+		"reviewed_at":   nil,
+	}
+	if title := strings.TrimSpace(vctx.Title); title != "" {
+		updates["generated_title"] = title
+	}
+	if desc := strings.TrimSpace(vctx.Description); desc != "" {
+		updates["generated_desc"] = desc
+	}
+	if tags := strings.TrimSpace(vctx.Tags); tags != "" {
+		updates["generated_tags"] = tags
+	}
+	if _, err := model.TransitionVideoStatus(s.db.WithContext(ctx), videoID, model.VideoTransition{
+		To: model.VideoStatusAwaitingReview, Updates: updates, Reason: "等待人工审核", Actor: "system",
+	}); err != nil {
+		return nil, fmt.Errorf("mark video awaiting review: %w", err)
+	}
+	vctx.AwaitingReview = true
+
+	if tracker := GetProgressTracker(ctx); tracker != nil {
+		tracker.SetCompletionNote(videoID, s.Name(), "等待人工审核")
+	}
+	s.events.PublishVideoStatus(s.db, videoID, vctx.UserID, model.VideoStatusAwaitingReview)
+	s.logger.Info("视频等待人工审核",
+		zap.String("video_id", videoID),
+		zap.String("title", vctx.Title))
+	return vctx, nil
+}
+
+// FinishedVideoStatus 任务链成功结束后应写回 tb_videos 的状态：
+// 审核关卡已停住视频时保持 awaiting_review，链内已投稿为 published，否则为已完成
+func FinishedVideoStatus(vctx *VideoContext) string {
+	if vctx != nil && vctx.AwaitingReview {
+		return model.VideoStatusAwaitingReview
+	}
+	if vctx != nil && strings.TrimSpace(vctx.BiliBVID) != "" {
+		return model.VideoStatusPublished
+	}
+	return model.VideoStatusCompleted
+}
+
+// fillReviewFeedback 视频是被审核退回后重跑时，把退回意见带入上下文
+func fillReviewFeedback(db *gorm.DB, videoID string, input any) {
+	vctx, ok := input.(*VideoContext)
+	if !ok || vctx == nil || strings.TrimSpace(vctx.ReviewFeedback) != "" || db == nil || videoID == "" {
+		return
+	}
+	var video model.Video
+	if err := db.Select("review_status", "review_feedback").Where("video_id = ?", videoID).Limit(1).Find(&video).Error; err != nil {
+		return
+	}
+	if video.ReviewStatus == model.ReviewStatusRejected {
+		vctx.ReviewFeedback = strings.TrimSpace(video.ReviewFeedback)
+	}
+}
+
+// reviewFeedbackPrompt 把审核退回意见格式化为追加到 LLM 提示词的要求，无意见时为空
+func reviewFeedbackPrompt(feedback string) string {
+	feedback = strings.TrimSpace(feedback)
+	if feedback == "" {
+		return ""
+	}
+	return "上一版结果未通过人工审核，审核意见如下，请据此修改：\n" + feedback
+}
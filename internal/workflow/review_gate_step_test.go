@@ -0,0 +1,84 @@
+package workflow
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/difyz9/ytb2bili/internal/config"
+	"github.com/difyz9/ytb2bili/pkg/store/model"
+	"github.com/difyz9/ytb2bili/pkg/tools"
+	"go.uber.org/zap"
+)
+
+func TestReviewGate_ParksVideoAwaitingReview(t *testing.T) {
+	db := openCheckpointTestDB(t)
+	if err := db.Create(&model.Video{VideoID: "review-1", UserID: "u1", Status: model.VideoStatusProcessing, GeneratedTitle: "old"}).Error; err != nil {
+		t.Fatalf("create video: %v", err)
+	}
+	gate := NewReviewGateStep(ReviewGateStepParams{DB: db, Cfg: config.WorkflowConfig{Review: config.ReviewConfig{Required: true}}, Logger: zap.NewNop()})
+
+	vctx := &VideoContext{VideoID: "review-1", UserID: "u1", Title: "新标题", Description: "简介", Tags: "a,b"}
+	if gate.ShouldSkip(context.Background(), vctx) {
+		t.Fatal("gate must run when review is required")
+	}
+	if _, err := gate.Execute(WithVideoID(context.Background(), "review-1"), vctx); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if !vctx.AwaitingReview || FinishedVideoStatus(vctx) != model.VideoStatusAwaitingReview {
+		t.Fatalf("expected context to be marked awaiting review, got %+v", vctx)
+	}
+
+	var video model.Video
+	if err := db.Where("video_id = ?", "review-1").First(&video).Error; err != nil {
+		t.Fatalf("load video: %v", err)
+	}
+	if video.Status != model.VideoStatusAwaitingReview || video.ReviewStatus != model.ReviewStatusPending {
+		t.Fatalf("expected awaiting_review/pending, got %q/%q", video.Status, video.ReviewStatus)
+	}
+	if video.GeneratedTitle != "新标题" || video.GeneratedDesc != "简介" || video.GeneratedTags != "a,b" {
+		t.Fatalf("expected generated metadata to be stored for review, got %+v", video)
+	}
+}
+
+func TestReviewGate_SkippedWhenNotRequired(t *testing.T) {
+	gate := NewReviewGateStep(ReviewGateStepParams{DB: openCheckpointTestDB(t), Logger: zap.NewNop()})
+	vctx := &VideoContext{VideoID: "review-2", UserID: "u1"}
+	if !gate.ShouldSkip(context.Background(), vctx) {
+		t.Fatal("gate must be skipped when review is not required")
+	}
+	if FinishedVideoStatus(vctx) != model.VideoStatusCompleted || FinishedVideoStatus(nil) != model.VideoStatusCompleted {
+		t.Fatal("videos that skip review should finish as completed")
+	}
+}
+
+func TestReviewFeedback_OnlyInjectedAfterRejection(t *testing.T) {
+	db := openCheckpointTestDB(t)
+	db.Create(&model.Video{VideoID: "rejected", ReviewStatus: model.ReviewStatusRejected, ReviewFeedback: " 标题太长 "})
+	db.Create(&model.Video{VideoID: "approved", ReviewStatus: model.ReviewStatusApproved, ReviewFeedback: "旧意见"})
+
+	rejected := &VideoContext{}
+	fillReviewFeedback(db, "rejected", rejected)
+	if rejected.ReviewFeedback != "标题太长" {
+		t.Fatalf("expected rejection feedback, got %q", rejected.ReviewFeedback)
+	}
+	approved := &VideoContext{}
+	fillReviewFeedback(db, "approved", approved)
+	if approved.ReviewFeedback != "" {
+		t.Fatalf("feedback of an approved video must not be reused, got %q", approved.ReviewFeedback)
+	}
+
+	if prompt := reviewFeedbackPrompt(rejected.ReviewFeedback); !strings.Contains(prompt, "标题太长") {
+		t.Fatalf("expected feedback in prompt, got %q", prompt)
+	}
+	if reviewFeedbackPrompt("  ") != "" {
+		t.Fatal("empty feedback should not add prompt text")
+	}
+
+	// 带审核意见的重译不能命中之前的翻译缓存
+	step := &LLMTranslateStep{translator: &tools.BatchTranslator{}}
+	texts := []string{"hello"}
+	if step.cacheKey(&VideoContext{}, texts) == step.cacheKey(rejected, texts) {
+		t.Fatal("review feedback must change the translation cache key")
+	}
+}
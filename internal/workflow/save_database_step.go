@@ -54,7 +54,6 @@ func (s *SaveDatabaseStep) Execute(ctx context.Context, input any) (any, error)
 	updates := map[string]interface{}{
 		"video_path": vctx.VideoPath,
 		"thumbnail":  vctx.ThumbnailPath,
-		"status":     "ready",
 	}
 	if strings.TrimSpace(vctx.VideoPath) != "" {
 		info, statErr := os.Stat(vctx.VideoPath)
@@ -70,6 +69,9 @@ func (s *SaveDatabaseStep) Execute(ctx context.Context, input any) (any, error)
 	if vctx.Transcript != nil {
 		srtPath := filepath.Join(filepath.Dir(vctx.VideoPath), vctx.VideoID+".srt")
 		updates["subtitle_path"] = srtPath
+		if vctx.Transcript.Engine != "" {
+			updates["transcript_source"] = vctx.Transcript.Engine
+		}
 	}
 
 	if video.ID == 0 {
@@ -82,11 +84,14 @@ func (s *SaveDatabaseStep) Execute(ctx context.Context, input any) (any, error)
 			VideoPath:      vctx.VideoPath,
 			VideoSizeBytes: updates["video_size_bytes"].(int64),
 			Thumbnail:      vctx.ThumbnailPath,
-			Status:         "ready",
+			Status:         model.VideoStatusProcessing, // 任务链结束后写回完成状态
 		}
 		if srt, ok := updates["subtitle_path"].(string); ok {
 			video.SubtitlePath = srt
 		}
+		if source, ok := updates["transcript_source"].(string); ok {
+			video.TranscriptSource = source
+		}
 		return vctx, s.db.WithContext(ctx).Create(&video).Error
 	}
 
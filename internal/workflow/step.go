@@ -39,6 +39,43 @@ type StepWithHooks interface {
 	OnError(ctx context.Context, err error) error
 }
 
+// StepWithDependencies 声明显式依赖的步骤。
+// DependsOn 返回 nil 表示未声明，任务链沿用「等待所有 Order 更小的步骤」的串行语义；
+// 返回非 nil（可为空切片）时，步骤只在所列步骤结束后即可与其他就绪步骤并发执行。
+type StepWithDependencies interface {
+	Step
+	DependsOn() []string
+}
+
+// StepWithContextAccess 声明步骤读写的 VideoContext 字段。
+// 任务链据此为可能并发的步骤补充依赖，避免同时读写同一字段。
+// Reads、Writes 均返回 nil 表示未声明，按可能读写任意字段处理；不读写上下文的步骤应返回空切片。
+type StepWithContextAccess interface {
+	Step
+	Reads() []ContextField
+	Writes() []ContextField
+}
+
+// ContextField VideoContext 字段标识
+type ContextField string
+
+const (
+	FieldVideoID         ContextField = "VideoID"
+	FieldVideoURL        ContextField = "VideoURL"
+	FieldUserID          ContextField = "UserID"
+	FieldDuration        ContextField = "DurationSeconds"
+	FieldVideoPath       ContextField = "VideoPath"
+	FieldThumbnailPath   ContextField = "ThumbnailPath"
+	FieldAudioPath       ContextField = "AudioPath"
+	FieldDouyinVideoInfo ContextField = "DouyinVideoInfo"
+	FieldTranscript      ContextField = "Transcript"
+	FieldSubtitleAudios  ContextField = "SubtitleAudios"
+	FieldMetadata        ContextField = "Metadata" // Title / Description / Tags
+	FieldBiliUpload      ContextField = "BiliUpload"
+	FieldTranslation     ContextField = "Translation" // TranslationConfig / TranslationSkipped
+	FieldChainSettings   ContextField = "TaskChainSettings"
+)
+
 // StepSkippedError 表示步骤在执行后因可容忍错误被视为跳过。
 // 任务链会继续执行后续步骤，并将该步骤持久化为 skipped。
 type StepSkippedError struct {
@@ -77,9 +114,12 @@ func IsStepSkippedError(err error) bool {
 
 // BaseStep 步骤的基础实现
 type BaseStep struct {
-	name     string
-	required bool
-	order    int
+	name      string
+	required  bool
+	order     int
+	dependsOn []string
+	reads     []ContextField
+	writes    []ContextField
 }
 
 func NewBaseStep(name string, required bool) BaseStep {
@@ -110,6 +150,31 @@ func (s BaseStep) Order() int {
 	return s.order
 }
 
+// WithDependsOn 返回声明了依赖步骤的副本（不传参数表示无依赖，可立即执行）
+func (s BaseStep) WithDependsOn(steps ...string) BaseStep {
+	s.dependsOn = append([]string{}, steps...)
+	return s
+}
+
+// WithContextAccess 返回声明了 VideoContext 读写字段的副本
+func (s BaseStep) WithContextAccess(reads, writes []ContextField) BaseStep {
+	s.reads = reads
+	s.writes = writes
+	return s
+}
+
+func (s BaseStep) DependsOn() []string {
+	return s.dependsOn
+}
+
+func (s BaseStep) Reads() []ContextField {
+	return s.reads
+}
+
+func (s BaseStep) Writes() []ContextField {
+	return s.writes
+}
+
 // mustVideoContext 从 input 安全提取 *VideoContext，类型不符时返回明确错误（而非 panic）
 func mustVideoContext(input any) (*VideoContext, error) {
 	vctx, ok := input.(*VideoContext)
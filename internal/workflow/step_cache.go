@@ -0,0 +1,305 @@
+package workflow
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/difyz9/ytb2bili/internal/config"
+	"github.com/difyz9/ytb2bili/pkg/store/model"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ============================================================================
+// 步骤结果缓存
+// ============================================================================
+//
+// 以步骤输入的内容哈希为键保存结果：Transcribe 用音频文件哈希，LLMTranslate 用
+// 字幕文本 + 语言对 + 模型，SynthesizeSubtitleAudio 用文本 + 音色 + 语速等参数。
+// 同一视频重复提交、或重新编码后音频不变时，可直接复用结果。
+// 结果 JSON 存在 tb_step_cache，音频等文件存在缓存目录。
+
+// StepCache 步骤结果缓存，nil 表示未启用（所有方法均可在 nil 上调用）
+type StepCache struct {
+	db     *gorm.DB
+	dir    string
+	maxAge time.Duration
+	logger *zap.Logger
+}
+
+// StepCacheParams 步骤缓存的依赖参数
+type StepCacheParams struct {
+	fx.In
+	Cfg    config.WorkflowConfig
+	DB     *gorm.DB `optional:"true"`
+	Logger *zap.Logger
+}
+
+// NewStepCache 创建步骤缓存；配置关闭或没有数据库时返回 nil
+func NewStepCache(params StepCacheParams) *StepCache {
+	cfg := params.Cfg.StepCache
+	if cfg.Disabled || params.DB == nil {
+		return nil
+	}
+	dir := strings.TrimSpace(cfg.Dir)
+	if dir == "" {
+		downloadDir := strings.TrimSpace(params.Cfg.DownloadDir)
+		if downloadDir == "" {
+			downloadDir = "./downloads"
+		}
+		dir = filepath.Join(downloadDir, ".step_cache")
+	}
+	return &StepCache{
+		db:     params.DB,
+		dir:    dir,
+		maxAge: time.Duration(cfg.MaxAgeDays) * 24 * time.Hour,
+		logger: params.Logger,
+	}
+}
+
+// stepCacheKey 将多个输入部分拼接后取 SHA-256；各部分以 \x00 分隔，避免拼接歧义
+func stepCacheKey(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// hashFile 计算文件内容的 SHA-256
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Get 读取缓存并解码到 out，返回是否命中
+func (c *StepCache) Get(ctx context.Context, stepName, key string, out any) bool {
+	entry, ok := c.lookup(ctx, stepName, key)
+	if !ok {
+		return false
+	}
+	if err := json.Unmarshal([]byte(entry.Payload), out); err != nil {
+		c.logger.Warn("解析步骤缓存失败，忽略该条目",
+			zap.String("step", stepName), zap.String("key", key), zap.Error(err))
+		return false
+	}
+	c.touch(entry)
+	return true
+}
+
+// Put 写入缓存，失败只记录日志，不影响步骤结果
+func (c *StepCache) Put(ctx context.Context, stepName, key string, value any) {
+	if c == nil || key == "" {
+		return
+	}
+	payload, err := json.Marshal(value)
+	if err != nil {
+		c.logger.Warn("序列化步骤缓存失败", zap.String("step", stepName), zap.Error(err))
+		return
+	}
+	c.save(ctx, &model.StepCacheEntry{
+		StepName:  stepName,
+		CacheKey:  key,
+		Payload:   string(payload),
+		SizeBytes: int64(len(payload)),
+	})
+}
+
+// GetFile 命中时把缓存文件复制到 destPath 并返回 true
+func (c *StepCache) GetFile(ctx context.Context, stepName, key, destPath string) bool {
+	entry, ok := c.lookup(ctx, stepName, key)
+	if !ok || entry.FilePath == "" {
+		return false
+	}
+	if err := copyFile(entry.FilePath, destPath); err != nil {
+		c.logger.Warn("复制缓存文件失败，忽略该条目",
+			zap.String("step", stepName), zap.String("file", entry.FilePath), zap.Error(err))
+		return false
+	}
+	c.touch(entry)
+	return true
+}
+
+// PutFile 把 srcPath 复制进缓存目录并登记
+func (c *StepCache) PutFile(ctx context.Context, stepName, key, srcPath string) {
+	if c == nil || key == "" {
+		return
+	}
+	cachedPath := filepath.Join(c.dir, stepName, key+filepath.Ext(srcPath))
+	if err := copyFile(srcPath, cachedPath); err != nil {
+		c.logger.Warn("写入缓存文件失败", zap.String("step", stepName), zap.String("src", srcPath), zap.Error(err))
+		return
+	}
+	var size int64
+	if info, err := os.Stat(cachedPath); err == nil {
+		size = info.Size()
+	}
+	c.save(ctx, &model.StepCacheEntry{
+		StepName:  stepName,
+		CacheKey:  key,
+		FilePath:  cachedPath,
+		SizeBytes: size,
+	})
+}
+
+func (c *StepCache) lookup(ctx context.Context, stepName, key string) (*model.StepCacheEntry, bool) {
+	if c == nil || key == "" {
+		return nil, false
+	}
+	var entry model.StepCacheEntry
+	if err := c.db.WithContext(ctx).Where("step_name = ? AND cache_key = ?", stepName, key).First(&entry).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			c.logger.Warn("读取步骤缓存失败", zap.String("step", stepName), zap.Error(err))
+		}
+		return nil, false
+	}
+	if c.maxAge > 0 && time.Since(entry.CreatedAt) > c.maxAge {
+		return nil, false
+	}
+	if entry.FilePath != "" {
+		if _, err := os.Stat(entry.FilePath); err != nil {
+			return nil, false
+		}
+	}
+	return &entry, true
+}
+
+func (c *StepCache) touch(entry *model.StepCacheEntry) {
+	now := time.Now()
+	c.db.Model(&model.StepCacheEntry{}).Where("id = ?", entry.ID).Updates(map[string]interface{}{
+		"hit_count":   gorm.Expr("hit_count + 1"),
+		"last_hit_at": &now,
+	})
+}
+
+func (c *StepCache) save(ctx context.Context, entry *model.StepCacheEntry) {
+	err := c.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "step_name"}, {Name: "cache_key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"payload", "file_path", "size_bytes", "created_at", "updated_at", "deleted_at"}),
+	}).Create(entry).Error
+	if err != nil {
+		c.logger.Warn("写入步骤缓存失败", zap.String("step", entry.StepName), zap.Error(err))
+	}
+}
+
+// StepCacheStats 单个步骤的缓存统计
+type StepCacheStats struct {
+	StepName  string `json:"step_name"`
+	Entries   int64  `json:"entries"`
+	SizeBytes int64  `json:"size_bytes"`
+	Hits      int64  `json:"hits"`
+}
+
+// Stats 按步骤汇总缓存条目数、大小与命中次数
+func (c *StepCache) Stats(ctx context.Context) ([]StepCacheStats, error) {
+	if c == nil {
+		return nil, nil
+	}
+	var stats []StepCacheStats
+	err := c.db.WithContext(ctx).Model(&model.StepCacheEntry{}).
+		Select("step_name, COUNT(*) AS entries, COALESCE(SUM(size_bytes), 0) AS size_bytes, COALESCE(SUM(hit_count), 0) AS hits").
+		Group("step_name").Order("step_name").Scan(&stats).Error
+	return stats, err
+}
+
+// Purge 删除缓存条目及其文件。stepName 为空表示所有步骤；olderThan > 0 时只删除更早创建的条目。
+// 返回删除的条目数。
+func (c *StepCache) Purge(ctx context.Context, stepName string, olderThan time.Duration) (int64, error) {
+	if c == nil {
+		return 0, fmt.Errorf("step cache is disabled")
+	}
+	query := c.db.WithContext(ctx).Unscoped().Model(&model.StepCacheEntry{})
+	if stepName = strings.TrimSpace(stepName); stepName != "" {
+		query = query.Where("step_name = ?", stepName)
+	}
+	if olderThan > 0 {
+		query = query.Where("created_at < ?", time.Now().Add(-olderThan))
+	}
+
+	var entries []model.StepCacheEntry
+	if err := query.Session(&gorm.Session{}).Select("id", "file_path").Find(&entries).Error; err != nil {
+		return 0, err
+	}
+	if len(entries) == 0 {
+		return 0, nil
+	}
+
+	ids := make([]uint, 0, len(entries))
+	for _, entry := range entries {
+		ids = append(ids, entry.ID)
+		if entry.FilePath != "" {
+			if err := os.Remove(entry.FilePath); err != nil && !os.IsNotExist(err) {
+				c.logger.Warn("删除缓存文件失败", zap.String("file", entry.FilePath), zap.Error(err))
+			}
+		}
+	}
+	result := c.db.WithContext(ctx).Unscoped().Where("id IN ?", ids).Delete(&model.StepCacheEntry{})
+	return result.RowsAffected, result.Error
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	tmp := dst + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+// reportCacheResult 把缓存命中情况写入步骤完成后的 ProgressText
+func reportCacheResult(ctx context.Context, stepName string, hits, total int) {
+	if total <= 0 {
+		return
+	}
+	tracker := GetProgressTracker(ctx)
+	if tracker == nil {
+		return
+	}
+	var note string
+	switch hits {
+	case total:
+		note = "缓存命中"
+	case 0:
+		note = "缓存未命中"
+	default:
+		note = fmt.Sprintf("缓存命中 %d/%d", hits, total)
+	}
+	tracker.SetCompletionNote(GetVideoID(ctx), stepName, note)
+}
@@ -0,0 +1,145 @@
+package workflow
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/difyz9/ytb2bili/internal/config"
+	"github.com/difyz9/ytb2bili/pkg/store/model"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// countingRunner 记录调用次数并返回固定输出
+type countingRunner struct {
+	calls  int
+	output string
+}
+
+func (r *countingRunner) InvokableRun(ctx context.Context, args string, opts ...tool.Option) (string, error) {
+	r.calls++
+	return r.output, nil
+}
+
+func newTestStepCache(t *testing.T) (*StepCache, *gorm.DB) {
+	t.Helper()
+	db := openCheckpointTestDB(t)
+	if err := db.AutoMigrate(&model.StepCacheEntry{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	cache := NewStepCache(StepCacheParams{
+		Cfg:    config.WorkflowConfig{StepCache: config.StepCacheConfig{Dir: t.TempDir()}},
+		DB:     db,
+		Logger: zap.NewNop(),
+	})
+	return cache, db
+}
+
+func TestStepCache_ToolStepHitSkipsRunner(t *testing.T) {
+	cache, db := newTestStepCache(t)
+	logger := zap.NewNop()
+
+	runner := &countingRunner{output: "hello"}
+	step := NewToolStep(
+		NewBaseStepWithOrder(StepNameTranscribe, true, 5),
+		runner,
+		func(vctx *VideoContext) (string, error) { return `{}`, nil },
+		func(vctx *VideoContext, result string) error {
+			vctx.Title = result
+			return nil
+		},
+		WithResultCache(cache, func(vctx *VideoContext) (string, error) {
+			return stepCacheKey("audio-hash"), nil
+		}),
+	)
+	chain := NewChainFromSteps([]Step{step}, logger, "cache")
+
+	run := func(videoID string) *VideoContext {
+		t.Helper()
+		out, err := RunChainWithTracking(WithVideoID(context.Background(), videoID), chain, db, logger, videoID, &VideoContext{VideoID: videoID})
+		if err != nil {
+			t.Fatalf("run %s: %v", videoID, err)
+		}
+		return out
+	}
+	progressText := func(videoID string) string {
+		var ts model.TaskStep
+		db.Where("video_id = ? AND step_name = ?", videoID, StepNameTranscribe).First(&ts)
+		return ts.ProgressText
+	}
+
+	run("cache-1")
+	if runner.calls != 1 {
+		t.Fatalf("expected first run to invoke the tool, got %d calls", runner.calls)
+	}
+	if got := progressText("cache-1"); got != "缓存未命中" {
+		t.Fatalf("expected miss note, got %q", got)
+	}
+
+	out := run("cache-2")
+	if runner.calls != 1 {
+		t.Fatalf("expected cache hit to skip the tool, got %d calls", runner.calls)
+	}
+	if out.Title != "hello" {
+		t.Fatalf("expected cached result to be applied, got %q", out.Title)
+	}
+	if got := progressText("cache-2"); got != "缓存命中" {
+		t.Fatalf("expected hit note, got %q", got)
+	}
+
+	stats, err := cache.Stats(context.Background())
+	if err != nil || len(stats) != 1 || stats[0].Entries != 1 || stats[0].Hits != 1 {
+		t.Fatalf("unexpected stats %+v (err %v)", stats, err)
+	}
+}
+
+func TestStepCache_FileRoundTripAndPurge(t *testing.T) {
+	cache, db := newTestStepCache(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	src := filepath.Join(dir, "src.mp3")
+	if err := os.WriteFile(src, []byte("audio"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	key := stepCacheKey("text", "voice")
+	dest := filepath.Join(dir, "out", "index_0001.mp3")
+	if cache.GetFile(ctx, StepNameSynthesizeSubtitle, key, dest) {
+		t.Fatal("expected miss before PutFile")
+	}
+	cache.PutFile(ctx, StepNameSynthesizeSubtitle, key, src)
+	if !cache.GetFile(ctx, StepNameSynthesizeSubtitle, key, dest) {
+		t.Fatal("expected hit after PutFile")
+	}
+	if data, _ := os.ReadFile(dest); string(data) != "audio" {
+		t.Fatalf("unexpected cached content %q", data)
+	}
+
+	cache.Put(ctx, StepNameLLMTranslate, stepCacheKey("a"), []string{"x"})
+
+	var entry model.StepCacheEntry
+	db.Where("step_name = ?", StepNameSynthesizeSubtitle).First(&entry)
+
+	deleted, err := cache.Purge(ctx, StepNameSynthesizeSubtitle, 0)
+	if err != nil || deleted != 1 {
+		t.Fatalf("expected one purged entry, got %d (err %v)", deleted, err)
+	}
+	if _, err := os.Stat(entry.FilePath); !os.IsNotExist(err) {
+		t.Fatalf("expected cached file to be removed, stat err %v", err)
+	}
+	var out []string
+	if !cache.Get(ctx, StepNameLLMTranslate, stepCacheKey("a"), &out) || len(out) != 1 {
+		t.Fatal("purging one step must keep other steps' entries")
+	}
+
+	var nilCache *StepCache
+	if nilCache.Get(ctx, StepNameLLMTranslate, "k", &out) {
+		t.Fatal("nil cache must always miss")
+	}
+	if _, err := nilCache.Purge(ctx, "", 0); err == nil {
+		t.Fatal("expected purge on disabled cache to fail")
+	}
+}
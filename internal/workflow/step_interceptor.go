@@ -0,0 +1,107 @@
+package workflow
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"go.uber.org/fx"
+)
+
+// StepCall 一次步骤执行的信息，供拦截器读取
+type StepCall struct {
+	Chain     string // 任务链名称
+	Step      string // 步骤名称
+	StepNum   int    // 步骤序号（从 1 开始）
+	VideoID   string
+	UserID    string
+	Input     any
+	StartTime time.Time
+}
+
+// StepInterceptor 包裹每次步骤执行的横切逻辑：指标、按步骤计费、链路追踪、审计日志等。
+// 只有真正执行的步骤会经过拦截器，续跑恢复、ShouldSkip 跳过的步骤不会。
+// 多个拦截器按 Order 从外到内嵌套：BeforeStep 依次调用，AfterStep / OnStepError 逆序调用。
+type StepInterceptor interface {
+	// BeforeStep 在步骤执行前调用，返回的 context 传给内层拦截器与步骤（如附加追踪 span）。
+	// 返回错误时步骤不执行，按步骤失败处理（如余额不足）；已进入的外层拦截器仍会收到 OnStepError。
+	BeforeStep(ctx context.Context, call *StepCall) (context.Context, error)
+	// AfterStep 在步骤成功后调用，detail.Output 为步骤输出
+	AfterStep(ctx context.Context, call *StepCall, detail *StepDetail)
+	// OnStepError 在步骤失败、超时、被取消或因非致命错误跳过后调用，detail.Error 为原因
+	OnStepError(ctx context.Context, call *StepCall, detail *StepDetail)
+}
+
+// StepInterceptorWithOrder 声明嵌套顺序的拦截器，Order 越小越靠外；未声明时为 0
+type StepInterceptorWithOrder interface {
+	StepInterceptor
+	Order() int
+}
+
+// BaseStepInterceptor 空实现，嵌入后只需实现关心的回调
+type BaseStepInterceptor struct{}
+
+func (BaseStepInterceptor) BeforeStep(ctx context.Context, _ *StepCall) (context.Context, error) {
+	return ctx, nil
+}
+
+func (BaseStepInterceptor) AfterStep(context.Context, *StepCall, *StepDetail) {}
+
+func (BaseStepInterceptor) OnStepError(context.Context, *StepCall, *StepDetail) {}
+
+// AsStepInterceptor 将拦截器构造函数注册到 group:"step_interceptors"，
+// 所有任务链（默认链、抖音链与声明式工作流）都会使用该组中的拦截器
+func AsStepInterceptor(constructor any) any {
+	return fx.Annotate(
+		constructor,
+		fx.As(new(StepInterceptor)),
+		fx.ResultTags(`group:"step_interceptors"`),
+	)
+}
+
+// sortInterceptors 按 Order 稳定排序，返回新切片
+func sortInterceptors(interceptors []StepInterceptor) []StepInterceptor {
+	sorted := make([]StepInterceptor, 0, len(interceptors))
+	for _, interceptor := range interceptors {
+		if interceptor != nil {
+			sorted = append(sorted, interceptor)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return interceptorOrder(sorted[i]) < interceptorOrder(sorted[j])
+	})
+	return sorted
+}
+
+func interceptorOrder(interceptor StepInterceptor) int {
+	if ordered, ok := interceptor.(StepInterceptorWithOrder); ok {
+		return ordered.Order()
+	}
+	return 0
+}
+
+// enterInterceptors 依次调用 BeforeStep，返回最终的 context 与成功进入的拦截器数；
+// 某个拦截器返回错误时停止，内层拦截器与步骤都不执行
+func (c *Chain) enterInterceptors(ctx context.Context, call *StepCall) (context.Context, int, error) {
+	for i, interceptor := range c.interceptors {
+		next, err := interceptor.BeforeStep(ctx, call)
+		if err != nil {
+			return ctx, i, err
+		}
+		if next != nil {
+			ctx = next
+		}
+	}
+	return ctx, len(c.interceptors), nil
+}
+
+// exitInterceptors 逆序通知已进入的拦截器步骤结果
+func (c *Chain) exitInterceptors(ctx context.Context, call *StepCall, entered int, detail *StepDetail) {
+	for i := entered - 1; i >= 0; i-- {
+		if detail.Success && !detail.Skipped {
+			c.interceptors[i].AfterStep(ctx, call, detail)
+		} else {
+			c.interceptors[i].OnStepError(ctx, call, detail)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxtest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+type interceptorCtxKey struct{}
+
+type recordingInterceptor struct {
+	BaseStepInterceptor
+	name   string
+	order  int
+	deny   string // 拒绝执行的步骤名
+	mu     *sync.Mutex
+	events *[]string
+}
+
+func (r *recordingInterceptor) Order() int { return r.order }
+
+func (r *recordingInterceptor) record(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	*r.events = append(*r.events, event)
+}
+
+func (r *recordingInterceptor) BeforeStep(ctx context.Context, call *StepCall) (context.Context, error) {
+	r.record(fmt.Sprintf("%s:before:%s", r.name, call.Step))
+	if call.Step == r.deny {
+		return ctx, errors.New("insufficient credits")
+	}
+	trail, _ := ctx.Value(interceptorCtxKey{}).(string)
+	return context.WithValue(ctx, interceptorCtxKey{}, trail+r.name), nil
+}
+
+func (r *recordingInterceptor) AfterStep(ctx context.Context, call *StepCall, detail *StepDetail) {
+	r.record(fmt.Sprintf("%s:after:%s", r.name, call.Step))
+}
+
+func (r *recordingInterceptor) OnStepError(ctx context.Context, call *StepCall, detail *StepDetail) {
+	r.record(fmt.Sprintf("%s:error:%s:%v", r.name, call.Step, detail.Error))
+}
+
+// ctxTrailStep 把拦截器写入 context 的标记作为输出
+type ctxTrailStep struct {
+	BaseStep
+	fail bool
+}
+
+func (s *ctxTrailStep) Execute(ctx context.Context, input any) (any, error) {
+	if s.fail {
+		return nil, errors.New("boom")
+	}
+	trail, _ := ctx.Value(interceptorCtxKey{}).(string)
+	return trail, nil
+}
+
+func TestChain_StepInterceptorsWrapExecution(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+	inner := &recordingInterceptor{name: "inner", order: 10, mu: &mu, events: &events}
+	outer := &recordingInterceptor{name: "outer", order: 1, mu: &mu, events: &events}
+
+	chain := NewChainFromSteps([]Step{
+		&ctxTrailStep{BaseStep: NewBaseStepWithOrder("enrich", true, 1)},
+		&ctxTrailStep{BaseStep: NewBaseStepWithOrder("flaky", false, 2), fail: true},
+		&testStepWithSkip{BaseStep: NewBaseStepWithOrder("skipped", false, 3), skip: true},
+	}, zaptest.NewLogger(t), "intercepted").WithInterceptors(inner, outer)
+
+	result := chain.Run(context.Background(), "input")
+	if !result.Success {
+		t.Fatalf("optional failure must not fail the chain: %v", result.Error)
+	}
+	if out := result.StepDetails["enrich"].Output; out != "outerinner" {
+		t.Fatalf("expected step to see context enriched outer→inner, got %v", out)
+	}
+
+	want := []string{
+		"outer:before:enrich", "inner:before:enrich", "inner:after:enrich", "outer:after:enrich",
+		"outer:before:flaky", "inner:before:flaky", "inner:error:flaky:boom", "outer:error:flaky:boom",
+	}
+	if strings.Join(events, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected interceptor calls (skipped steps must not be intercepted):\n got %v\nwant %v", events, want)
+	}
+}
+
+func TestChain_StepInterceptorCanRejectStep(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+	executed := false
+	outer := &recordingInterceptor{name: "outer", order: 1, mu: &mu, events: &events}
+	billing := &recordingInterceptor{name: "billing", order: 2, deny: "paid", mu: &mu, events: &events}
+
+	chain := NewChainFromSteps([]Step{
+		&testStep{BaseStep: NewBaseStepWithOrder("paid", true, 1), executed: &executed},
+	}, zaptest.NewLogger(t), "intercepted").WithInterceptors(outer, billing)
+
+	result := chain.Run(context.Background(), "input")
+	if result.Success || executed {
+		t.Fatalf("rejected step must not run and must fail the chain, executed=%v", executed)
+	}
+	if !strings.Contains(result.Error.Error(), "insufficient credits") {
+		t.Fatalf("expected interceptor error to surface, got %v", result.Error)
+	}
+	want := []string{"outer:before:paid", "billing:before:paid", "outer:error:paid:insufficient credits"}
+	if strings.Join(events, ",") != strings.Join(want, ",") {
+		t.Fatalf("only entered interceptors are notified:\n got %v\nwant %v", events, want)
+	}
+}
+
+func TestStepInterceptorsFromFxGroup(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+	executed := false
+
+	app := fxtest.New(t,
+		fx.Provide(func() *zap.Logger { return zaptest.NewLogger(t) }),
+		fx.Provide(AsStep(func() *testStep {
+			return &testStep{BaseStep: NewBaseStep("step1", true), executed: &executed}
+		})),
+		fx.Provide(AsStepInterceptor(func() *recordingInterceptor {
+			return &recordingInterceptor{name: "audit", mu: &mu, events: &events}
+		})),
+		fx.Provide(NewChain),
+		fx.Invoke(func(chain *Chain) {
+			if result := chain.Run(context.Background(), "input"); !result.Success {
+				t.Errorf("chain failed: %v", result.Error)
+			}
+		}),
+	)
+	app.RequireStart()
+	app.RequireStop()
+
+	if !executed || strings.Join(events, ",") != "audit:before:step1,audit:after:step1" {
+		t.Fatalf("expected fx-provided interceptor to wrap step1, got %v", events)
+	}
+}
@@ -18,4 +18,5 @@ const (
 	StepNameAddWatermark        = "AddWatermark"
 	StepNameSaveDatabase        = "SaveDatabase"
 	StepNameUploadToBilibili    = "UploadToBilibili"
+	StepNameReviewGate          = "ReviewGate"
 )
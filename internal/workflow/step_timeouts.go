@@ -0,0 +1,175 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/difyz9/ytb2bili/internal/config"
+)
+
+// ── 步骤超时 ─────────────────────────────────────────────────────────────────
+// 每个步骤的超时 = 基础时长 + 视频分钟数 × 每分钟追加时长，并受上限约束。
+// 内置默认值按步骤特点设定，可通过 [workflow.step_timeouts.<步骤名>] 覆盖；
+// 未知步骤使用 default 规则。Chain 与 Pipeline 共用同一套规则。
+
+// defaultStepTimeoutKey 未单独配置的步骤使用的规则名
+const defaultStepTimeoutKey = "default"
+
+// stepTimeoutGrace 超时后等待步骤自行退出的时间，超过后放弃等待（仅限结果不再被使用的场景），释放 worker
+var stepTimeoutGrace = 10 * time.Second
+
+var builtinStepTimeouts = map[string]config.StepTimeoutConfig{
+	StepNameInitialize:          {BaseSeconds: 120},
+	StepNameResolveDouyinShare:  {BaseSeconds: 120},
+	StepNameDownloadVideo:       {BaseSeconds: 1800, PerVideoMinuteSeconds: 60, MaxSeconds: 6 * 3600},
+	StepNameDownloadDouyinVideo: {BaseSeconds: 600, PerVideoMinuteSeconds: 60, MaxSeconds: 2 * 3600},
+	StepNameDownloadThumbnail:   {BaseSeconds: 120},
+	StepNameExtractAudio:        {BaseSeconds: 300, PerVideoMinuteSeconds: 10, MaxSeconds: 2 * 3600},
+	StepNameTranscribe:          {BaseSeconds: 900, PerVideoMinuteSeconds: 60, MaxSeconds: 6 * 3600},
+	StepNameLLMTranslate:        {BaseSeconds: 900, PerVideoMinuteSeconds: 30, MaxSeconds: 4 * 3600},
+	StepNameSynthesizeSubtitle:  {BaseSeconds: 900, PerVideoMinuteSeconds: 60, MaxSeconds: 6 * 3600},
+	StepNameGenerateMetadata:    {BaseSeconds: 600},
+	StepNameAddWatermark:        {BaseSeconds: 600, PerVideoMinuteSeconds: 30, MaxSeconds: 4 * 3600},
+	StepNameSaveDatabase:        {BaseSeconds: 120},
+	StepNameUploadToBilibili:    {BaseSeconds: 1800, PerVideoMinuteSeconds: 30, MaxSeconds: 6 * 3600},
+	defaultStepTimeoutKey:       {BaseSeconds: 1800, PerVideoMinuteSeconds: 30, MaxSeconds: 6 * 3600},
+}
+
+// StepTimeouts 按步骤名计算超时时长
+type StepTimeouts struct {
+	rules map[string]config.StepTimeoutConfig
+}
+
+// NewStepTimeouts 以内置默认值为基础，合并 [workflow.step_timeouts] 中的配置。
+// 配置项整体覆盖同名步骤的默认规则；外部程序步骤的 timeout_seconds 作为其固定超时，
+// 同样可被 step_timeouts 覆盖。
+func NewStepTimeouts(cfg config.WorkflowConfig) *StepTimeouts {
+	rules := make(map[string]config.StepTimeoutConfig, len(builtinStepTimeouts)+len(cfg.StepTimeouts))
+	for name, rule := range builtinStepTimeouts {
+		rules[name] = rule
+	}
+	for _, plugin := range cfg.Plugins {
+		if plugin.TimeoutSeconds > 0 {
+			rules[strings.TrimSpace(plugin.Name)] = config.StepTimeoutConfig{BaseSeconds: plugin.TimeoutSeconds}
+		}
+	}
+	for name, rule := range cfg.StepTimeouts {
+		rules[name] = rule
+	}
+	return &StepTimeouts{rules: rules}
+}
+
+// For 返回步骤在给定视频时长（秒）下的超时；返回 0 表示不限制
+func (t *StepTimeouts) For(stepName string, videoSeconds float64) time.Duration {
+	if t == nil {
+		return 0
+	}
+	rule, ok := t.rules[stepName]
+	if !ok {
+		rule = t.rules[defaultStepTimeoutKey]
+	}
+	if rule.BaseSeconds < 0 {
+		return 0
+	}
+
+	seconds := float64(rule.BaseSeconds)
+	if videoSeconds > 0 && rule.PerVideoMinuteSeconds > 0 {
+		seconds += videoSeconds / 60 * rule.PerVideoMinuteSeconds
+	}
+	if rule.MaxSeconds > 0 && seconds > float64(rule.MaxSeconds) {
+		seconds = float64(rule.MaxSeconds)
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// StepTimeoutError 步骤执行超过了配置的超时时长
+type StepTimeoutError struct {
+	Step    string
+	Timeout time.Duration
+}
+
+func (e *StepTimeoutError) Error() string {
+	return fmt.Sprintf("step '%s' timed out after %s", e.Step, e.Timeout)
+}
+
+// Unwrap 使 errors.Is(err, context.DeadlineExceeded) 成立，超时不会被重试
+func (e *StepTimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// IsStepTimeout 判断错误是否由步骤超时引起
+func IsStepTimeout(err error) bool {
+	var timeoutErr *StepTimeoutError
+	return errors.As(err, &timeoutErr)
+}
+
+// runWithTimeout 在超时 context 中执行 fn。
+// abandon 为 true 时，超时后最多再等待 stepTimeoutGrace 让 fn 退出，fn 不响应 context 时放弃等待，
+// 避免卡死的处理函数一直占用 worker；调用方超时后仍会继续使用 fn 可能写入的共享状态时（可选步骤），
+// 应传 false 一直等到 fn 返回——外部进程（whisper-cli、ffmpeg、插件）会随 context 按进程组终止。
+// 第二个返回值表示是否因本次超时而结束（父 context 取消不算超时）。
+func runWithTimeout[T any](ctx context.Context, timeout time.Duration, abandon bool, fn func(context.Context) (T, error)) (T, bool, error) {
+	if timeout <= 0 {
+		out, err := fn(ctx)
+		return out, false, err
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		out T
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		out, err := fn(runCtx)
+		done <- result{out: out, err: err}
+	}()
+
+	timedOut := func() bool {
+		return errors.Is(runCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil
+	}
+
+	select {
+	case r := <-done:
+		return r.out, timedOut(), r.err
+	case <-runCtx.Done():
+	}
+	if !abandon {
+		r := <-done
+		return r.out, timedOut(), r.err
+	}
+
+	grace := time.NewTimer(stepTimeoutGrace)
+	defer grace.Stop()
+	select {
+	case r := <-done:
+		return r.out, timedOut(), r.err
+	case <-grace.C:
+		var zero T
+		return zero, timedOut(), runCtx.Err()
+	}
+}
+
+// videoDurationSeconds 尽力估算当前视频时长（秒），未知时返回 0。
+// 依次使用：上下文中记录的时长、抖音元数据（毫秒）、已有转写的末尾时间。
+func videoDurationSeconds(input any) float64 {
+	vctx, ok := input.(*VideoContext)
+	if !ok || vctx == nil {
+		return 0
+	}
+	if vctx.DurationSeconds > 0 {
+		return vctx.DurationSeconds
+	}
+	if vctx.DouyinVideoInfo != nil && vctx.DouyinVideoInfo.Data.Video.Duration > 0 {
+		return float64(vctx.DouyinVideoInfo.Data.Video.Duration) / 1000
+	}
+	if vctx.Transcript != nil && len(vctx.Transcript.Segments) > 0 {
+		return vctx.Transcript.Segments[len(vctx.Transcript.Segments)-1].End
+	}
+	return 0
+}
@@ -0,0 +1,234 @@
+package workflow
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/difyz9/ytb2bili/internal/config"
+	"github.com/difyz9/ytb2bili/pkg/store/model"
+	"github.com/difyz9/ytb2bili/pkg/tools"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestStepTimeouts_ScaleWithVideoDuration(t *testing.T) {
+	timeouts := NewStepTimeouts(config.WorkflowConfig{})
+
+	short := timeouts.For(StepNameTranscribe, 0)
+	long := timeouts.For(StepNameTranscribe, 3*3600)
+	if short != 15*time.Minute {
+		t.Fatalf("expected base transcribe timeout 15m, got %s", short)
+	}
+	if long != 3*time.Hour+15*time.Minute {
+		t.Fatalf("expected 3h video to get 3h15m, got %s", long)
+	}
+	if capped := timeouts.For(StepNameTranscribe, 24*3600); capped != 6*time.Hour {
+		t.Fatalf("expected timeout to be capped at 6h, got %s", capped)
+	}
+	if unknown := timeouts.For("SomeCustomStep", 0); unknown != 30*time.Minute {
+		t.Fatalf("expected default rule for unknown step, got %s", unknown)
+	}
+}
+
+func TestStepTimeouts_ConfigOverrides(t *testing.T) {
+	timeouts := NewStepTimeouts(config.WorkflowConfig{
+		StepTimeouts: map[string]config.StepTimeoutConfig{
+			StepNameExtractAudio: {BaseSeconds: 60},
+			StepNameTranscribe:   {BaseSeconds: -1},
+		},
+	})
+
+	if got := timeouts.For(StepNameExtractAudio, 600); got != time.Minute {
+		t.Fatalf("expected configured 1m timeout, got %s", got)
+	}
+	if got := timeouts.For(StepNameTranscribe, 600); got != 0 {
+		t.Fatalf("expected negative base to disable timeout, got %s", got)
+	}
+	if got := (*StepTimeouts)(nil).For(StepNameTranscribe, 600); got != 0 {
+		t.Fatalf("expected nil timeouts to disable timeout, got %s", got)
+	}
+}
+
+// hangingStep 模拟不响应 context 的外部进程
+type hangingStep struct {
+	BaseStep
+	release chan struct{}
+}
+
+func (s *hangingStep) Execute(ctx context.Context, input any) (any, error) {
+	<-s.release
+	return input, nil
+}
+
+func TestChain_StepTimeoutRecordedAsTimeout(t *testing.T) {
+	origGrace := stepTimeoutGrace
+	stepTimeoutGrace = 10 * time.Millisecond
+	defer func() { stepTimeoutGrace = origGrace }()
+
+	db := openCheckpointTestDB(t)
+	videoID := "timeout-1"
+	release := make(chan struct{})
+	defer close(release)
+
+	hang := &hangingStep{BaseStep: NewBaseStepWithOrder(StepNameTranscribe, true, 1), release: release}
+	afterExecuted := false
+	after := &testStep{BaseStep: NewBaseStepWithOrder(StepNameLLMTranslate, true, 2), executed: &afterExecuted}
+
+	chain := NewChainFromSteps([]Step{hang, after}, zap.NewNop(), "timeout").
+		WithTimeouts(NewStepTimeouts(config.WorkflowConfig{
+			StepTimeouts: map[string]config.StepTimeoutConfig{
+				StepNameTranscribe: {BaseSeconds: 0, PerVideoMinuteSeconds: 0.0001},
+			},
+		}))
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := RunChainWithTracking(WithVideoID(context.Background(), videoID), chain, db, zaptest.NewLogger(t), videoID,
+			&VideoContext{VideoID: videoID, DurationSeconds: 60})
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if !IsStepTimeout(err) {
+			t.Fatalf("expected step timeout error, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("hung step was not abandoned after its timeout")
+	}
+	if afterExecuted {
+		t.Fatal("dependent step must not run after a required step timed out")
+	}
+
+	var step model.TaskStep
+	if err := db.Where("video_id = ? AND step_name = ?", videoID, StepNameTranscribe).First(&step).Error; err != nil {
+		t.Fatalf("load task step: %v", err)
+	}
+	if step.Status != model.TaskStepStatusTimeout {
+		t.Fatalf("expected task step status %q, got %q", model.TaskStepStatusTimeout, step.Status)
+	}
+}
+
+// lateWritingStep 超时后才返回并写入 VideoContext
+type lateWritingStep struct {
+	BaseStep
+	release chan struct{}
+}
+
+func (s *lateWritingStep) Execute(ctx context.Context, input any) (any, error) {
+	<-ctx.Done()
+	<-s.release
+	vctx := input.(*VideoContext)
+	vctx.Title = "late write"
+	return vctx, nil
+}
+
+// titleCheckStep 记录执行时看到的标题
+type titleCheckStep struct {
+	BaseStep
+	seen string
+}
+
+func (s *titleCheckStep) Execute(ctx context.Context, input any) (any, error) {
+	s.seen = input.(*VideoContext).Title
+	return input, nil
+}
+
+func TestChain_OptionalStepTimeoutWaitsForStepToReturn(t *testing.T) {
+	origGrace := stepTimeoutGrace
+	stepTimeoutGrace = 10 * time.Millisecond
+	defer func() { stepTimeoutGrace = origGrace }()
+
+	release := make(chan struct{})
+	slow := &lateWritingStep{BaseStep: NewBaseStepWithOrder(StepNameGenerateMetadata, false, 1), release: release}
+	next := &titleCheckStep{BaseStep: NewBaseStepWithOrder(StepNameSaveDatabase, true, 2)}
+	chain := NewChainFromSteps([]Step{slow, next}, zap.NewNop(), "timeout").
+		WithTimeouts(NewStepTimeouts(config.WorkflowConfig{
+			StepTimeouts: map[string]config.StepTimeoutConfig{
+				StepNameGenerateMetadata: {BaseSeconds: 0, PerVideoMinuteSeconds: 0.0001},
+			},
+		}))
+
+	done := make(chan *Result, 1)
+	go func() { done <- chain.Run(context.Background(), &VideoContext{DurationSeconds: 60}) }()
+
+	select {
+	case <-done:
+		t.Fatal("chain continued while the timed-out optional step could still write the context")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+
+	result := <-done
+	if !result.Success || !result.StepDetails[StepNameGenerateMetadata].TimedOut {
+		t.Fatalf("expected optional timeout to be tolerated, got %+v", result)
+	}
+	if next.seen != "late write" {
+		t.Fatalf("expected next step to start after the timed-out step returned, saw title %q", next.seen)
+	}
+}
+
+// fakeVideoProber 返回固定时长的探测结果
+type fakeVideoProber struct {
+	seconds float64
+	calls   int
+}
+
+func (p *fakeVideoProber) Probe(ctx context.Context, url string) (*tools.VideoProbe, error) {
+	p.calls++
+	return &tools.VideoProbe{DurationSeconds: p.seconds}, nil
+}
+
+// deadlineStep 记录执行时 context 剩余的超时时长
+type deadlineStep struct {
+	BaseStep
+	remaining time.Duration
+}
+
+func (s *deadlineStep) Execute(ctx context.Context, input any) (any, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		s.remaining = time.Until(deadline)
+	}
+	return input, nil
+}
+
+func TestYouTubeChain_DownloadTimeoutScalesWithProbedDuration(t *testing.T) {
+	db := openCheckpointTestDB(t)
+	videoID := "long-1"
+	db.Create(&model.Video{VideoID: videoID, URL: "https://www.youtube.com/watch?v=long-1", Status: model.VideoStatusProcessing})
+
+	download := &deadlineStep{BaseStep: NewBaseStepWithOrder(StepNameDownloadVideo, true, 1)}
+	chain := NewChainFromSteps([]Step{download}, zap.NewNop(), "youtube").
+		WithTimeouts(NewStepTimeouts(config.WorkflowConfig{}))
+	prober := &fakeVideoProber{seconds: 3 * 3600}
+	yc := &YouTubeChain{chain: chain, db: db, logger: zaptest.NewLogger(t), prober: prober}
+
+	vctx := yc.defaultVideoContext()
+	vctx.VideoURL = "https://www.youtube.com/watch?v=long-1"
+	if _, err := yc.ProcessContextWithTracking(context.Background(), vctx, videoID, ""); err != nil {
+		t.Fatalf("run chain: %v", err)
+	}
+
+	// 3 小时视频：1800s + 180 分钟 × 60s = 3.5 小时
+	if download.remaining < 3*time.Hour+29*time.Minute {
+		t.Fatalf("expected DownloadVideo timeout scaled to 3h30m, got %s", download.remaining)
+	}
+	var video model.Video
+	if err := db.Where("video_id = ?", videoID).First(&video).Error; err != nil {
+		t.Fatalf("load video: %v", err)
+	}
+	if video.Duration != 3*3600 {
+		t.Fatalf("expected probed duration to be saved, got %v", video.Duration)
+	}
+
+	// 再次运行直接读取记录的时长，不再探测
+	rerun := yc.defaultVideoContext()
+	rerun.VideoURL = vctx.VideoURL
+	if _, err := yc.ProcessContextWithTracking(context.Background(), rerun, videoID, ""); err != nil {
+		t.Fatalf("rerun chain: %v", err)
+	}
+	if prober.calls != 1 {
+		t.Fatalf("expected saved duration to be reused, probed %d times", prober.calls)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/difyz9/ytb2bili/internal/service"
@@ -17,15 +18,22 @@ type SynthesizeSubtitleAudioStep struct {
 	BaseStep
 	ttsClient    *tools.TTSClient
 	userSettings *service.UserSettingsClient
+	cache        *StepCache
 	logger       *zap.Logger
 }
 
 // NewSynthesizeSubtitleAudioStep 创建合成字幕音频步骤
-func NewSynthesizeSubtitleAudioStep(ttsClient *tools.TTSClient, userSettings *service.UserSettingsClient, logger *zap.Logger) *SynthesizeSubtitleAudioStep {
+func NewSynthesizeSubtitleAudioStep(ttsClient *tools.TTSClient, userSettings *service.UserSettingsClient, cache *StepCache, logger *zap.Logger) *SynthesizeSubtitleAudioStep {
 	return &SynthesizeSubtitleAudioStep{
-		BaseStep:     NewBaseStepWithOrder(StepNameSynthesizeSubtitle, false, 7),
+		BaseStep: NewBaseStepWithOrder(StepNameSynthesizeSubtitle, false, 7).
+			WithDependsOn(StepNameLLMTranslate).
+			WithContextAccess(
+				[]ContextField{FieldSubtitleAudios, FieldUserID},
+				[]ContextField{FieldSubtitleAudios, FieldUserID, FieldChainSettings},
+			),
 		ttsClient:    ttsClient,
 		userSettings: userSettings,
+		cache:        cache,
 		logger:       logger,
 	}
 }
@@ -69,13 +77,20 @@ func (s *SynthesizeSubtitleAudioStep) Execute(ctx context.Context, input interfa
 	successCount := 0
 	failedCount := 0
 	totalChars := 0
+	cacheHits := 0
 	tracker := GetProgressTracker(ctx)
 	voiceName := speechVoiceName(vctx.SpeechSynthesisConfig)
+	reportStepProvider(ctx, s.Name(), vctx.SpeechSynthesisConfig.GetProvider(), voiceName)
 
 	// 遍历已翻译的字幕（SubtitleAudios 由翻译步骤填充）
 	for i := range vctx.SubtitleAudios {
 		subtitle := &vctx.SubtitleAudios[i]
 
+		// 任务被取消时立即停止，不再逐条记为失败
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
 		// 优先使用翻译后的文本，如果没有则使用原始文本
 		text := subtitle.TranslatedText
 		if text == "" {
@@ -100,6 +115,19 @@ func (s *SynthesizeSubtitleAudioStep) Execute(ctx context.Context, input interfa
 			zap.Float64("startTime", subtitle.StartTime),
 			zap.Float64("endTime", subtitle.EndTime))
 
+		// 相同文本 + 音色 + 语速等参数的音频直接从缓存复制
+		cacheKey := ""
+		if s.cache != nil && subtitleAudioDir(vctx) != "" {
+			cacheKey = ttsCacheKey(text, vctx.SpeechSynthesisConfig)
+			cachedPath := filepath.Join(subtitleAudioDir(vctx), fmt.Sprintf("index_%04d.mp3", i))
+			if s.cache.GetFile(ctx, s.Name(), cacheKey, cachedPath) {
+				subtitle.AudioPath = cachedPath
+				successCount++
+				cacheHits++
+				continue
+			}
+		}
+
 		// 调用 TTS 服务合成音频
 		resp, err := s.ttsClient.SynthesizeSubtitleAudio(ctx, vctx.UserID, text, vctx.VideoID, i, subtitleAudioDir(vctx), vctx.SpeechSynthesisConfig)
 		if err != nil {
@@ -129,6 +157,9 @@ func (s *SynthesizeSubtitleAudioStep) Execute(ctx context.Context, input interfa
 		// 更新字幕音频信息（添加音频路径）
 		subtitle.AudioPath = audioPath
 		successCount++
+		if cacheKey != "" && strings.TrimSpace(resp.LocalPath) != "" {
+			s.cache.PutFile(ctx, s.Name(), cacheKey, resp.LocalPath)
+		}
 
 		s.logger.Info("字幕音频合成成功",
 			zap.Int("index", i),
@@ -145,7 +176,11 @@ func (s *SynthesizeSubtitleAudioStep) Execute(ctx context.Context, input interfa
 		zap.Int("total", len(vctx.SubtitleAudios)),
 		zap.Int("success", successCount),
 		zap.Int("failed", failedCount),
+		zap.Int("cache_hits", cacheHits),
 		zap.Int("total_chars", totalChars))
+	if s.cache != nil {
+		reportCacheResult(ctx, s.Name(), cacheHits, successCount+failedCount)
+	}
 
 	// 如果全部失败，返回警告但不中断流程
 	if successCount == 0 && len(vctx.SubtitleAudios) > 0 {
@@ -155,6 +190,15 @@ func (s *SynthesizeSubtitleAudioStep) Execute(ctx context.Context, input interfa
 	return vctx, nil
 }
 
+// ttsCacheKey 字幕配音缓存键：文本与所有影响音频的合成参数
+func ttsCacheKey(text string, config *SpeechSynthesisConfig) string {
+	return stepCacheKey(text,
+		config.GetProvider(), config.GetLanguage(), speechVoiceName(config), config.GetFormat(),
+		strconv.FormatFloat(config.GetRate(), 'f', -1, 64),
+		strconv.FormatFloat(config.GetVolume(), 'f', -1, 64),
+		strconv.FormatFloat(config.GetPitch(), 'f', -1, 64))
+}
+
 func speechVoiceName(config *SpeechSynthesisConfig) string {
 	if config != nil && strings.TrimSpace(config.VoiceName) != "" {
 		return strings.TrimSpace(config.VoiceName)
@@ -184,6 +228,13 @@ func (s *SynthesizeSubtitleAudioStep) ShouldSkip(ctx context.Context, input any)
 	return false
 }
 
+// RetryPolicy 实现 StepWithRetryPolicy：TTS 服务限流或 5xx 时退避重试
+func (s *SynthesizeSubtitleAudioStep) RetryPolicy() RetryPolicy {
+	policy := DefaultRetryPolicy()
+	policy.MaxAttempts = 2
+	return policy
+}
+
 func (s *SynthesizeSubtitleAudioStep) refreshTaskChainSettingsFromDB(ctx context.Context, vctx *VideoContext) {
 	if s.userSettings == nil || !s.userSettings.IsEnabled() || vctx == nil {
 		return
@@ -1,25 +1,65 @@
 package workflow
 
-import "sync"
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
 
+// ErrShutdown 服务停止时中断任务的原因：任务链在步骤边界停下，或宽限期结束后被取消。
+// 被中断的视频重新排队，从最后完成的步骤继续，不计为失败或取消。
+var ErrShutdown = errors.New("task interrupted by shutdown")
+
+// shutdownPollInterval 停止时检查任务是否已全部退出的间隔
+const shutdownPollInterval = 100 * time.Millisecond
+
+type drainContextKey struct{}
+
+// TaskRuntimeRegistry 记录正在运行的视频任务及其取消函数，供停止接口中断任务
 type TaskRuntimeRegistry struct {
-	mu      sync.Mutex
-	cancels map[string]func()
+	mu          sync.Mutex
+	cancels     map[string]*taskHandle
+	drain       chan struct{} // 服务停止时关闭，任务链在下一个步骤边界停下
+	drainOnce   sync.Once
+	interrupted map[string]struct{}
+}
+
+// taskHandle 用指针区分同一视频先后登记的两次运行，避免旧任务结束时注销新任务
+type taskHandle struct {
+	cancel context.CancelCauseFunc
 }
 
 func NewTaskRuntimeRegistry() *TaskRuntimeRegistry {
 	return &TaskRuntimeRegistry{
-		cancels: make(map[string]func()),
+		cancels:     make(map[string]*taskHandle),
+		drain:       make(chan struct{}),
+		interrupted: make(map[string]struct{}),
 	}
 }
 
-func (r *TaskRuntimeRegistry) Register(videoID string, cancel func()) {
-	if r == nil || videoID == "" || cancel == nil {
-		return
+// Track 从 parent 派生可取消的 context 并登记为 videoID 的当前任务。
+// 返回的 done 必须在任务结束时调用：释放 context，并仅在登记未被新任务替换时注销。
+// r 为 nil 时仍返回可用的 context。
+func (r *TaskRuntimeRegistry) Track(parent context.Context, videoID string) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(parent)
+	if r == nil || videoID == "" {
+		return ctx, func() { cancel(nil) }
 	}
+	ctx = context.WithValue(ctx, drainContextKey{}, (<-chan struct{})(r.drain))
+	handle := &taskHandle{cancel: cancel}
 	r.mu.Lock()
-	defer r.mu.Unlock()
-	r.cancels[videoID] = cancel
+	r.cancels[videoID] = handle
+	r.mu.Unlock()
+	return ctx, func() {
+		r.mu.Lock()
+		if r.cancels[videoID] == handle {
+			delete(r.cancels, videoID)
+		}
+		r.mu.Unlock()
+		cancel(nil)
+	}
 }
 
 func (r *TaskRuntimeRegistry) Cancel(videoID string) bool {
@@ -27,32 +67,132 @@ func (r *TaskRuntimeRegistry) Cancel(videoID string) bool {
 		return false
 	}
 	r.mu.Lock()
-	cancel, ok := r.cancels[videoID]
+	handle, ok := r.cancels[videoID]
 	if ok {
 		delete(r.cancels, videoID)
 	}
 	r.mu.Unlock()
 	if ok {
-		cancel()
+		handle.cancel(nil)
+	}
+	return ok
+}
+
+func (r *TaskRuntimeRegistry) Has(videoID string) bool {
+	if r == nil || videoID == "" {
+		return false
 	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.cancels[videoID]
 	return ok
 }
 
-func (r *TaskRuntimeRegistry) Finish(videoID string) {
+// Draining 服务是否正在停止；停止期间不应再接收新任务
+func (r *TaskRuntimeRegistry) Draining() bool {
+	if r == nil {
+		return false
+	}
+	select {
+	case <-r.drain:
+		return true
+	default:
+		return false
+	}
+}
+
+// Interrupted 记录因服务停止而中断、已重新排队的视频，由 Shutdown 汇总返回
+func (r *TaskRuntimeRegistry) Interrupted(videoID string) {
 	if r == nil || videoID == "" {
 		return
 	}
 	r.mu.Lock()
-	delete(r.cancels, videoID)
+	r.interrupted[videoID] = struct{}{}
 	r.mu.Unlock()
 }
 
-func (r *TaskRuntimeRegistry) Has(videoID string) bool {
-	if r == nil || videoID == "" {
-		return false
+// Shutdown 排空正在运行的任务，返回被中断的视频：
+//   - 通知所有任务链在下一个步骤边界停下（已完成的步骤写入检查点）；
+//   - grace 内仍未退出的任务以 ErrShutdown 取消，正在执行的外部进程随 context 终止；
+//   - 再等待最多 cancelWait，让任务持久化中断状态后退出。
+//
+// 可重复调用，每次都等待当前登记的任务退出。
+func (r *TaskRuntimeRegistry) Shutdown(grace, cancelWait time.Duration) []string {
+	if r == nil {
+		return nil
 	}
+	r.drainOnce.Do(func() { close(r.drain) })
+
+	if !r.waitIdle(grace) {
+		r.mu.Lock()
+		handles := make([]*taskHandle, 0, len(r.cancels))
+		for _, handle := range r.cancels {
+			handles = append(handles, handle)
+		}
+		r.mu.Unlock()
+		for _, handle := range handles {
+			handle.cancel(ErrShutdown)
+		}
+		r.waitIdle(cancelWait)
+	}
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	_, ok := r.cancels[videoID]
-	return ok
+	videoIDs := make([]string, 0, len(r.interrupted))
+	for videoID := range r.interrupted {
+		videoIDs = append(videoIDs, videoID)
+	}
+	sort.Strings(videoIDs)
+	return videoIDs
+}
+
+// Running 返回正在运行的视频任务
+func (r *TaskRuntimeRegistry) Running() []string {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	videoIDs := make([]string, 0, len(r.cancels))
+	for videoID := range r.cancels {
+		videoIDs = append(videoIDs, videoID)
+	}
+	sort.Strings(videoIDs)
+	return videoIDs
+}
+
+// waitIdle 等待所有登记的任务退出，超时返回 false
+func (r *TaskRuntimeRegistry) waitIdle(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		r.mu.Lock()
+		idle := len(r.cancels) == 0
+		r.mu.Unlock()
+		if idle {
+			return true
+		}
+		if !time.Now().Before(deadline) {
+			return false
+		}
+		time.Sleep(shutdownPollInterval)
+	}
+}
+
+// shutdownRequested 任务所属的服务是否正在停止：任务链据此在步骤边界停下
+func shutdownRequested(ctx context.Context) bool {
+	drain, ok := ctx.Value(drainContextKey{}).(<-chan struct{})
+	if !ok {
+		return false
+	}
+	select {
+	case <-drain:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsShutdownInterrupted 任务是否因服务停止而中断（步骤边界停下或宽限期后被取消）
+func IsShutdownInterrupted(ctx context.Context, err error) bool {
+	return errors.Is(err, ErrShutdown) || errors.Is(context.Cause(ctx), ErrShutdown)
 }
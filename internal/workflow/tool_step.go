@@ -109,6 +109,20 @@ func WithSkipOnError() ToolStepOption {
 	return func(s *ToolStep) { s.skipOnError = true }
 }
 
+// WithRetryPolicy 设置步骤级重试策略，使任务链对临时错误进行退避重试。
+func WithRetryPolicy(policy RetryPolicy) ToolStepOption {
+	return func(s *ToolStep) { s.retryPolicy = policy }
+}
+
+// WithResultCache 按 keyFunc 计算的内容哈希缓存工具输出；命中时跳过工具执行。
+// keyFunc 返回空键或错误时本次不使用缓存。
+func WithResultCache(cache *StepCache, keyFunc func(vctx *VideoContext) (string, error)) ToolStepOption {
+	return func(s *ToolStep) {
+		s.cache = cache
+		s.cacheKey = keyFunc
+	}
+}
+
 // ToolStep 将 ToolRunner 包装为 workflow.Step。
 type ToolStep struct {
 	BaseStep
@@ -121,6 +135,9 @@ type ToolStep struct {
 	onSuccess     func(ctx context.Context, output any) error
 	onError       func(ctx context.Context, err error) error
 	skipOnError bool
+	retryPolicy RetryPolicy
+	cache       *StepCache
+	cacheKey    func(vctx *VideoContext) (string, error)
 }
 
 // NewToolStep 创建 ToolStep。
@@ -167,6 +184,18 @@ func (s *ToolStep) Execute(ctx context.Context, input any) (any, error) {
 		}
 	}
 
+	cacheKey := ""
+	if s.cache != nil && s.cacheKey != nil {
+		if key, keyErr := s.cacheKey(vctx); keyErr == nil {
+			cacheKey = key
+		}
+		var cached string
+		if cacheKey != "" && s.cache.Get(ctx, s.Name(), cacheKey, &cached) && s.resultApplier(vctx, cached) == nil {
+			reportCacheResult(ctx, s.Name(), 1, 1)
+			return vctx, nil
+		}
+	}
+
 	result, err := s.runner.InvokableRun(ctx, args)
 	if err != nil {
 		if s.skipOnError {
@@ -190,6 +219,10 @@ func (s *ToolStep) Execute(ctx context.Context, input any) (any, error) {
 		return nil, fmt.Errorf("step %s: apply result failed: %w", s.Name(), err)
 	}
 
+	if cacheKey != "" {
+		s.cache.Put(ctx, s.Name(), cacheKey, result)
+		reportCacheResult(ctx, s.Name(), 0, 1)
+	}
 	return vctx, nil
 }
 
@@ -205,6 +238,23 @@ func (s *ToolStep) ShouldSkip(ctx context.Context, input any) bool {
 	return s.skipFunc(ctx, vctx)
 }
 
+// HasCachedResult 实现 StepWithCachedResult（仅当 WithResultCache 已设置时生效）。
+func (s *ToolStep) HasCachedResult(ctx context.Context, input any) bool {
+	if s.cache == nil || s.cacheKey == nil {
+		return false
+	}
+	vctx, ok := input.(*VideoContext)
+	if !ok {
+		return false
+	}
+	key, err := s.cacheKey(vctx)
+	if err != nil {
+		return false
+	}
+	_, ok = s.cache.lookup(ctx, s.Name(), key)
+	return ok
+}
+
 // OnSuccess 实现 StepWithHooks。
 func (s *ToolStep) OnSuccess(ctx context.Context, output any) error {
 	if s.onSuccess != nil {
@@ -213,6 +263,11 @@ func (s *ToolStep) OnSuccess(ctx context.Context, output any) error {
 	return nil
 }
 
+// RetryPolicy 实现 StepWithRetryPolicy（未设置 WithRetryPolicy 时只执行一次）。
+func (s *ToolStep) RetryPolicy() RetryPolicy {
+	return s.retryPolicy
+}
+
 // OnError 实现 StepWithHooks。
 func (s *ToolStep) OnError(ctx context.Context, err error) error {
 	if s.onError != nil {
@@ -4,12 +4,43 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
 
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/difyz9/ytb2bili/internal/config"
 	"github.com/difyz9/ytb2bili/pkg/tools"
 	"go.uber.org/fx"
 	"go.uber.org/zap"
 )
 
+const (
+	asrProviderBcut         = "bcut"
+	asrProviderWhisper      = "whisper"       // 配置 api_url 时走接口，否则本地 whisper-cli
+	asrProviderWhisperAPI   = "whisper_api"   // 强制 OpenAI 兼容接口
+	asrProviderWhisperLocal = "whisper_local" // 强制本地 whisper-cli
+	asrSourceCaptionsManual = "captions_manual"
+	asrSourceCaptionsAuto   = "captions_auto"
+)
+
+// 字幕来源优先级（[workflow.asr] source_order / 用户设置 transcript_source_order）
+const (
+	transcriptSourceManual = "manual" // 作者上传的平台字幕
+	transcriptSourceASR    = "asr"    // 转写引擎（含回退链）
+	transcriptSourceAuto   = "auto"   // 平台自动生成的字幕
+)
+
+var defaultTranscriptSourceOrder = []string{transcriptSourceManual, transcriptSourceASR, transcriptSourceAuto}
+
+// ASRSettings 用户指定的转写引擎与 whisper 参数
+type ASRSettings struct {
+	Provider       string               // 首选引擎，为空时使用 [workflow.asr] provider
+	Whisper        tools.WhisperOptions // 覆盖 [workflow.asr.whisper] 中的同名参数
+	SourceLanguage string               // 用户明确设置的源语言，用于检查识别语言是否一致
+	SourceOrder    []string             // 字幕来源优先级，为空时使用 [workflow.asr] source_order
+}
+
 // ============================================================================
 // 步骤 5: 转录音频（可选）
 // ============================================================================
@@ -20,23 +51,58 @@ type TranscribeStep struct {
 
 type TranscribeStepParams struct {
 	fx.In
-	Tool   *tools.BcutTranscriberTool
-	Logger *zap.Logger
+	Tool     *tools.BcutTranscriberTool
+	Whisper  *tools.WhisperASREngine  `optional:"true"`
+	Captions *tools.DownloadVideoTool `optional:"true"`
+	Cfg      config.WorkflowConfig    `optional:"true"`
+	Cache    *StepCache               `optional:"true"`
+	Guard    *ResourceGuard           `optional:"true"`
+	Logger   *zap.Logger
 }
 
 func NewTranscribeStep(params TranscribeStepParams) *TranscribeStep {
+	runner := newTranscribeRunner(params)
+	asrCfg := params.Cfg.ASR
 	return &TranscribeStep{
 		ToolStep: NewToolStep(
-			NewBaseStepWithOrder(StepNameTranscribe, false, 5),
-			StringCallRunner{Tool: params.Tool, JSONField: "audio_path", Name: StepNameTranscribe},
+			NewBaseStepWithOrder(StepNameTranscribe, false, 5).
+				WithDependsOn(StepNameExtractAudio).
+				WithContextAccess([]ContextField{FieldAudioPath, FieldVideoURL, FieldDuration, FieldChainSettings}, []ContextField{FieldTranscript}),
+			runner,
 			func(vctx *VideoContext) (string, error) {
-				return fmt.Sprintf(`{"audio_path":%q}`, vctx.AudioPath), nil
+				args, err := json.Marshal(transcribeArgs{
+					AudioPath:       vctx.AudioPath,
+					VideoURL:        vctx.VideoURL,
+					Engines:         resolveTranscriptSources(vctx, asrCfg),
+					DurationSeconds: vctx.DurationSeconds,
+					Language:        expectedASRLanguage(vctx, asrCfg),
+				})
+				return string(args), err
 			},
 			func(vctx *VideoContext, result string) error {
 				var transcript tools.TranscriptResult
 				if err := json.Unmarshal([]byte(result), &transcript); err != nil {
 					return fmt.Errorf("parse transcript failed: %w", err)
 				}
+				// 重新分段在结果缓存之后进行，调整分段参数不需要重新转写
+				if resegment := asrCfg.Resegment; !resegment.Disabled {
+					transcript = *tools.ResegmentTranscript(&transcript, tools.ResegmentConfig{
+						MaxLineChars:         resegment.MaxLineChars,
+						MaxLineCharsCJK:      resegment.MaxLineCharsCJK,
+						MaxCueSeconds:        resegment.MaxCueSeconds,
+						MinCueSeconds:        resegment.MinCueSeconds,
+						MinGapSeconds:        resegment.MinGapSeconds,
+						MaxCharsPerSecond:    resegment.MaxCharsPerSecond,
+						MaxCharsPerSecondCJK: resegment.MaxCharsPerSecondCJK,
+						MergeGapSeconds:      resegment.MergeGapSeconds,
+					})
+				}
+				// 结果来自缓存时 SRT 位于其他视频目录、whisper 与分段转写不生成 SRT、重新分段后原 SRT 失效，均在当前音频旁重新生成
+				if expected := strings.TrimSuffix(vctx.AudioPath, ".mp3") + ".srt"; transcript.SRTPath != expected {
+					if err := writeSRT(expected, buildSubtitleAudiosFromTranscript(collectTranscriptTextSegments(&transcript)), false); err == nil {
+						transcript.SRTPath = expected
+					}
+				}
 				vctx.Transcript = &transcript
 				return nil
 			},
@@ -44,12 +110,49 @@ func NewTranscribeStep(params TranscribeStepParams) *TranscribeStep {
 				settings := NormalizeTaskChainSettings(vctx.TaskChainSettings)
 				return !settings.Transcribe
 			}),
+			WithRunContext(func(ctx context.Context, vctx *VideoContext) (context.Context, error) {
+				ctx = withUserWhisperOptions(ctx, vctx)
+				primary := resolveTranscriptSources(vctx, asrCfg)[0]
+				reportStepProvider(ctx, StepNameTranscribe, primary, runner.modelName(ctx, primary))
+				tracker := GetProgressTracker(ctx)
+				workflowVideoID := GetVideoID(ctx)
+				return tools.WithASRProgressReporter(ctx, func(percent int) {
+					if tracker == nil || workflowVideoID == "" {
+						return
+					}
+					tracker.UpdateStepProgress(workflowVideoID, StepNameTranscribe, percent, fmt.Sprintf("语音转写中 %d%%", percent))
+				}), nil
+			}),
+			WithRetryPolicy(DefaultRetryPolicy()),
+			// 以音频内容哈希为键：同一视频重复提交或重新封装后音频不变时复用转写结果；
+			// 使用 whisper 或回退链时，结果还取决于引擎顺序、模型、语言与解码参数，一并计入键
+			WithResultCache(params.Cache, func(vctx *VideoContext) (string, error) {
+				audioHash, err := hashFile(vctx.AudioPath)
+				engines := resolveTranscriptSources(vctx, asrCfg)
+				if err != nil || (len(engines) == 1 && engines[0] == asrProviderBcut) {
+					return audioHash, err
+				}
+				parts := []string{audioHash, strings.Join(engines, ","), expectedASRLanguage(vctx, asrCfg)}
+				if params.Whisper != nil {
+					options, err := json.Marshal(params.Whisper.Options(withUserWhisperOptions(context.Background(), vctx)))
+					if err != nil {
+						return "", err
+					}
+					parts = append(parts, string(options))
+				}
+				return stepCacheKey(parts...), nil
+			}),
 			WithOnSuccess(func(ctx context.Context, output any) error {
 				vctx, ok := output.(*VideoContext)
 				if !ok || vctx.Transcript == nil {
 					return nil
 				}
+				// 记录实际产出结果的引擎（可能是回退引擎）
+				if engine := vctx.Transcript.Engine; engine != "" {
+					reportStepProvider(ctx, StepNameTranscribe, engine, runner.modelName(withUserWhisperOptions(ctx, vctx), engine))
+				}
 				params.Logger.Info("Audio transcribed",
+					zap.String("engine", vctx.Transcript.Engine),
 					zap.Int("segments", len(vctx.Transcript.Segments)),
 					zap.String("language", vctx.Transcript.Language))
 				return nil
@@ -59,4 +162,280 @@ func NewTranscribeStep(params TranscribeStepParams) *TranscribeStep {
 	}
 }
 
-// TranscribeStep 的包装结构
+// transcribeArgs 转写工具参数
+type transcribeArgs struct {
+	AudioPath       string   `json:"audio_path"`
+	VideoURL        string   `json:"video_url,omitempty"`        // 获取平台字幕
+	Engines         []string `json:"engines"`                    // 依次尝试的引擎与平台字幕
+	DurationSeconds float64  `json:"duration_seconds,omitempty"` // 视频时长，读取不到音频时长时用于检查字幕密度
+	Language        string   `json:"language,omitempty"`         // 期望的识别语言
+}
+
+// normalizeASRProvider 返回规范的引擎名，未知引擎返回空
+func normalizeASRProvider(provider string) string {
+	switch provider = strings.ToLower(strings.TrimSpace(provider)); provider {
+	case asrProviderBcut, asrProviderWhisper, asrProviderWhisperAPI, asrProviderWhisperLocal:
+		return provider
+	default:
+		return ""
+	}
+}
+
+// resolveASREngines 返回本次依次尝试的引擎：首选引擎（用户设置优先，其次 [workflow.asr] provider，默认 bcut）
+// 在前，其后为 fallback 中的其余引擎
+func resolveASREngines(vctx *VideoContext, cfg config.ASRConfig) []string {
+	primary := ""
+	if vctx.ASRSettings != nil {
+		primary = normalizeASRProvider(vctx.ASRSettings.Provider)
+	}
+	if primary == "" {
+		primary = normalizeASRProvider(cfg.Provider)
+	}
+	if primary == "" {
+		primary = asrProviderBcut
+	}
+
+	engines := []string{primary}
+	for _, name := range cfg.Fallback {
+		if name = normalizeASRProvider(name); name != "" && !slices.Contains(engines, name) {
+			engines = append(engines, name)
+		}
+	}
+	return engines
+}
+
+// resolveTranscriptSources 按字幕来源优先级展开本次依次尝试的来源：manual / auto 为平台字幕，
+// asr 展开为 resolveASREngines。非 YouTube 视频没有平台字幕，仅剩转写引擎
+func resolveTranscriptSources(vctx *VideoContext, cfg config.ASRConfig) []string {
+	order := cfg.SourceOrder
+	if vctx.ASRSettings != nil && len(vctx.ASRSettings.SourceOrder) > 0 {
+		order = vctx.ASRSettings.SourceOrder
+	}
+	if len(order) == 0 {
+		order = defaultTranscriptSourceOrder
+	}
+	captions := vctx.VideoURL != "" && (vctx.Platform == "" || vctx.Platform == PlatformYouTube)
+
+	var sources []string
+	add := func(names ...string) {
+		for _, name := range names {
+			if !slices.Contains(sources, name) {
+				sources = append(sources, name)
+			}
+		}
+	}
+	for _, source := range order {
+		switch strings.ToLower(strings.TrimSpace(source)) {
+		case transcriptSourceManual:
+			if captions {
+				add(asrSourceCaptionsManual)
+			}
+		case transcriptSourceAuto:
+			if captions {
+				add(asrSourceCaptionsAuto)
+			}
+		case transcriptSourceASR:
+			add(resolveASREngines(vctx, cfg)...)
+		}
+	}
+	if len(sources) == 0 {
+		return resolveASREngines(vctx, cfg)
+	}
+	return sources
+}
+
+// expectedASRLanguage 用户或配置明确指定的源语言；未指定时不检查识别语言
+func expectedASRLanguage(vctx *VideoContext, cfg config.ASRConfig) string {
+	if vctx.ASRSettings != nil {
+		if language := strings.TrimSpace(vctx.ASRSettings.Whisper.Language); language != "" {
+			return language
+		}
+	}
+	if language := strings.TrimSpace(cfg.Whisper.Language); language != "" {
+		return language
+	}
+	if vctx.ASRSettings != nil {
+		return strings.TrimSpace(vctx.ASRSettings.SourceLanguage)
+	}
+	return ""
+}
+
+func withUserWhisperOptions(ctx context.Context, vctx *VideoContext) context.Context {
+	if vctx.ASRSettings == nil {
+		return ctx
+	}
+	return tools.WithWhisperOptions(ctx, vctx.ASRSettings.Whisper)
+}
+
+// whisperEngineConfig 将 [workflow.asr.whisper] 转为引擎配置；mode 为空时按是否配置 api_url 决定
+func whisperEngineConfig(cfg config.WorkflowConfig, mode string) tools.WhisperConfig {
+	whisper := cfg.ASR.Whisper
+	return tools.WhisperConfig{
+		WhisperOptions: tools.WhisperOptions{
+			Model:          whisper.Model,
+			ModelPath:      whisper.ModelPath,
+			Language:       whisper.Language,
+			Threads:        whisper.Threads,
+			BeamSize:       whisper.BeamSize,
+			Prompt:         whisper.Prompt,
+			WordTimestamps: &whisper.WordTimestamps,
+			VAD:            &whisper.VAD,
+			VADModel:       whisper.VADModel,
+			VADThreshold:   whisper.VADThreshold,
+		},
+		Mode:       mode,
+		BinaryPath: whisper.BinaryPath,
+		ModelDir:   whisper.ModelDir,
+		APIURL:     whisper.APIURL,
+		APIKey:     whisper.APIKey,
+		APIModel:   whisper.APIModel,
+	}
+}
+
+// transcribeRunner 按参数中的来源顺序获取平台字幕或转写，失败或结果不合格时换下一个；
+// 未关闭分段时长音频按静音切分后并行转写
+type transcribeRunner struct {
+	engines  map[string]tools.ASREngine
+	captions *tools.DownloadVideoTool
+	whisper  map[string]*tools.WhisperASREngine // 用于上报模型名
+	quality  tools.ASRQualityConfig
+	ffmpeg   string // 读取音频时长，供质量检查判断字幕密度
+	logger   *zap.Logger
+}
+
+func newTranscribeRunner(params TranscribeStepParams) transcribeRunner {
+	runner := transcribeRunner{
+		engines:  map[string]tools.ASREngine{},
+		captions: params.Captions,
+		whisper:  map[string]*tools.WhisperASREngine{},
+		quality: tools.ASRQualityConfig{
+			MinSegmentsPerMinute: params.Cfg.ASR.Quality.MinSegmentsPerMinute,
+			MaxRepeatRatio:       params.Cfg.ASR.Quality.MaxRepeatRatio,
+			MaxRepeatRun:         params.Cfg.ASR.Quality.MaxRepeatRun,
+			SkipLanguageCheck:    params.Cfg.ASR.Quality.SkipLanguageCheck,
+		},
+		ffmpeg: params.Cfg.FFmpegPath,
+		logger: params.Logger,
+	}
+	if runner.ffmpeg == "" {
+		runner.ffmpeg, _ = exec.LookPath("ffmpeg")
+	}
+	if params.Tool != nil {
+		runner.engines[asrProviderBcut] = tools.NewBcutASREngineWithTool(params.Tool)
+	}
+	if params.Whisper != nil {
+		runner.whisper[asrProviderWhisper] = params.Whisper
+		runner.whisper[asrProviderWhisperLocal] = tools.NewWhisperASREngine(whisperEngineConfig(params.Cfg, "local"))
+		if params.Cfg.ASR.Whisper.APIURL != "" {
+			runner.whisper[asrProviderWhisperAPI] = tools.NewWhisperASREngine(whisperEngineConfig(params.Cfg, "api"))
+		}
+	}
+	for name, engine := range runner.whisper {
+		runner.engines[name] = engine
+		if engine.Mode() == "local" {
+			runner.engines[name] = cpuBoundASREngine{ASREngine: engine, guard: params.Guard}
+		}
+	}
+
+	chunking := params.Cfg.ASR.Chunking
+	if chunking.Disabled {
+		return runner
+	}
+	chunkCfg := tools.ChunkedASRConfig{
+		FFmpegPath:      params.Cfg.FFmpegPath,
+		ChunkSeconds:    chunking.ChunkMinutes * 60,
+		MaxChunkSeconds: chunking.MaxChunkMinutes * 60,
+		SilenceNoiseDB:  chunking.SilenceNoiseDB,
+		SilenceSeconds:  chunking.SilenceSeconds,
+		OverlapSeconds:  chunking.OverlapSeconds,
+		Concurrency:     chunking.Concurrency,
+	}
+	for name, engine := range runner.engines {
+		runner.engines[name] = tools.NewChunkedASREngine(engine, chunkCfg, params.Logger)
+	}
+	return runner
+}
+
+// cpuBoundASREngine 本地 whisper 每次转写占用一个 CPU 密集名额。
+// 包在分段转写之内，长音频的每个片段各占一个名额，并行片段数不会超过 cpu_heavy_slots
+type cpuBoundASREngine struct {
+	tools.ASREngine
+	guard *ResourceGuard
+}
+
+func (e cpuBoundASREngine) Transcribe(ctx context.Context, audioPath string) (*tools.TranscriptResult, error) {
+	release, err := e.guard.AcquireCPU(ctx, StepNameTranscribe)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return e.ASREngine.Transcribe(ctx, audioPath)
+}
+
+// modelName 返回 whisper 引擎本次使用的模型，bcut 返回空
+func (r transcribeRunner) modelName(ctx context.Context, engine string) string {
+	if whisper, ok := r.whisper[engine]; ok {
+		return whisper.ModelName(ctx)
+	}
+	return ""
+}
+
+// audioDuration 质量检查使用的时长：优先读取音频文件本身，读取失败时使用视频时长
+func (r transcribeRunner) audioDuration(ctx context.Context, payload transcribeArgs) float64 {
+	if r.ffmpeg == "" || payload.AudioPath == "" {
+		return payload.DurationSeconds
+	}
+	duration, err := tools.ProbeAudioDuration(ctx, r.ffmpeg, payload.AudioPath)
+	if err != nil {
+		if r.logger != nil {
+			r.logger.Warn("Failed to read audio duration for transcript quality check",
+				zap.String("audio", payload.AudioPath),
+				zap.Error(err))
+		}
+		return payload.DurationSeconds
+	}
+	return duration
+}
+
+var captionKinds = map[string]tools.CaptionKind{
+	asrSourceCaptionsManual: tools.CaptionKindManual,
+	asrSourceCaptionsAuto:   tools.CaptionKindAuto,
+}
+
+func (r transcribeRunner) InvokableRun(ctx context.Context, args string, _ ...tool.Option) (string, error) {
+	var payload transcribeArgs
+	if err := json.Unmarshal([]byte(args), &payload); err != nil {
+		return "", fmt.Errorf("unmarshal %s args: %w", StepNameTranscribe, err)
+	}
+
+	var entries []tools.ASRFallbackEntry
+	for _, name := range payload.Engines {
+		if kind, ok := captionKinds[name]; ok && r.captions != nil && payload.VideoURL != "" {
+			entries = append(entries, tools.ASRFallbackEntry{
+				Name:   name,
+				Engine: tools.NewCaptionASREngine(r.captions, payload.VideoURL, kind, payload.Language),
+			})
+		} else if engine, ok := r.engines[name]; ok {
+			entries = append(entries, tools.ASRFallbackEntry{Name: name, Engine: engine})
+		} else if r.logger != nil {
+			r.logger.Warn("ASR engine is not configured, skipping", zap.String("engine", name))
+		}
+	}
+	if len(entries) == 0 {
+		return "", tools.PermanentError(fmt.Errorf("asr engines %v are not configured", payload.Engines))
+	}
+
+	ctx = tools.WithASRExpectation(ctx, tools.ASRExpectation{
+		DurationSeconds: r.audioDuration(ctx, payload),
+		Language:        payload.Language,
+	})
+	transcript, err := tools.NewFallbackASREngine(entries, r.quality, r.logger).Transcribe(ctx, payload.AudioPath)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(transcript)
+	if err != nil {
+		return "", fmt.Errorf("marshal transcript: %w", err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,90 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+
+	"github.com/difyz9/ytb2bili/pkg/tools"
+	"go.uber.org/zap/zaptest"
+)
+
+// sparseASREngine 返回固定数量的分段
+type sparseASREngine struct {
+	name     string
+	segments int
+}
+
+func (e *sparseASREngine) Name() string        { return e.name }
+func (e *sparseASREngine) Languages() []string { return []string{"en"} }
+
+func (e *sparseASREngine) Transcribe(ctx context.Context, audioPath string) (*tools.TranscriptResult, error) {
+	result := &tools.TranscriptResult{Language: "en"}
+	for i := 0; i < e.segments; i++ {
+		result.Segments = append(result.Segments, tools.TranscriptSegment{
+			Start: float64(i * 10), End: float64(i*10 + 5), Text: e.name + " line " + string(rune('a'+i%26)),
+		})
+	}
+	return result, nil
+}
+
+func TestTranscribeRunner_QualityCheckUsesAudioDuration(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	ffmpeg := filepath.Join(dir, "ffmpeg")
+	script := "#!/bin/sh\necho \"  Duration: 00:30:00.00, start: 0.000000, bitrate: 128 kb/s\" >&2\nexit 1\n"
+	if err := os.WriteFile(ffmpeg, []byte(script), 0o755); err != nil {
+		t.Fatalf("write fake ffmpeg: %v", err)
+	}
+
+	// 视频时长未知（0）：30 分钟的音频只有 3 条分段，应判为漏识别并回退到下一个引擎
+	runner := transcribeRunner{
+		engines: map[string]tools.ASREngine{
+			"sparse": &sparseASREngine{name: "sparse", segments: 3},
+			"dense":  &sparseASREngine{name: "dense", segments: 60},
+		},
+		ffmpeg: ffmpeg,
+		logger: zaptest.NewLogger(t),
+	}
+	args, _ := json.Marshal(transcribeArgs{
+		AudioPath: filepath.Join(dir, "audio.mp3"),
+		Engines:   []string{"sparse", "dense"},
+	})
+	out, err := runner.InvokableRun(context.Background(), string(args))
+	if err != nil {
+		t.Fatalf("InvokableRun: %v", err)
+	}
+	var transcript tools.TranscriptResult
+	if err := json.Unmarshal([]byte(out), &transcript); err != nil {
+		t.Fatalf("parse transcript: %v", err)
+	}
+	if transcript.Engine != "dense" {
+		t.Fatalf("expected sparse transcript to fail the density check, got engine %q", transcript.Engine)
+	}
+}
+
+func TestTranscribeStep_ConflictsWithSettingsAndURLWriters(t *testing.T) {
+	extract := &testStepWithSkip{BaseStep: NewBaseStepWithOrder(StepNameExtractAudio, true, 4).
+		WithDependsOn().
+		WithContextAccess([]ContextField{FieldVideoPath}, []ContextField{FieldAudioPath})}
+	transcribe := NewTranscribeStep(TranscribeStepParams{Logger: zaptest.NewLogger(t)})
+	settings := &testStepWithSkip{BaseStep: NewBaseStepWithOrder("settings", true, 6).
+		WithDependsOn(StepNameExtractAudio).
+		WithContextAccess([]ContextField{}, []ContextField{FieldChainSettings})}
+	resolve := &testStepWithSkip{BaseStep: NewBaseStepWithOrder("resolve", true, 7).
+		WithDependsOn(StepNameExtractAudio).
+		WithContextAccess([]ContextField{}, []ContextField{FieldVideoURL})}
+
+	graph := buildStepGraph([]Step{extract, transcribe, settings, resolve}, zaptest.NewLogger(t))
+	for i, name := range []string{"settings", "resolve"} {
+		if !slices.Contains(graph.deps[i+2], 1) {
+			t.Fatalf("expected %s to wait for Transcribe, got deps %v", name, graph.deps[i+2])
+		}
+	}
+}
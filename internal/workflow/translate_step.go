@@ -59,8 +59,12 @@ func (s *TranslateStep) Execute(ctx context.Context, input any) (any, error) {
 		originalText := segment.Text
 
 		// 调用翻译API
-		translatedText, err := s.translator.TranslateText(originalText, sourceLang, targetLang)
+		translatedText, err := s.translator.TranslateText(ctx, originalText, sourceLang, targetLang)
 		if err != nil {
+			// 任务被取消时直接返回，不能当作单句失败继续
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
 			s.logger.Warn("Failed to translate segment, skipping",
 				zap.String("text", originalText),
 				zap.Error(err))
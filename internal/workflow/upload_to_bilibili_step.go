@@ -48,6 +48,12 @@ func NewUploadToBilibiliStep(
 	}
 }
 
+// ShouldSkip 同一任务链中的审核关卡已把视频置为待审核时跳过，审核通过后再投稿
+func (s *UploadToBilibiliStep) ShouldSkip(ctx context.Context, input any) bool {
+	vctx, ok := input.(*VideoContext)
+	return ok && vctx != nil && vctx.AwaitingReview
+}
+
 // Execute 执行上传到B站
 func (s *UploadToBilibiliStep) Execute(ctx context.Context, input any) (any, error) {
 	vctx, err := mustVideoContext(input)
@@ -72,6 +72,9 @@ func applyLatestUserSettingsToVideoContext(ctx context.Context, userSettings *se
 	if taskChainSettings := parseWorkflowTaskChainSettings(settings[storemodel.UserSettingKeyTaskChainSettings]); taskChainSettings != nil {
 		vctx.TaskChainSettings = taskChainSettings
 	}
+	if asrSettings := parseWorkflowASRSettings(settings); asrSettings != nil {
+		vctx.ASRSettings = asrSettings
+	}
 	userExplicitTTS := false
 	if speechConfig := parseWorkflowSpeechSynthesisConfig(storemodel.ResolveSubtitleAudioTTSConfigValue(settings)); speechConfig != nil {
 		vctx.SpeechSynthesisConfig = speechConfig
@@ -296,6 +299,31 @@ func parseWorkflowTaskChainSettings(value string) *TaskChainSettings {
 	return NormalizeTaskChainSettings(&settings)
 }
 
+// parseWorkflowASRSettings 读取用户的转写引擎、whisper 参数、字幕来源优先级与明确设置的源语言，均未设置时返回 nil。
+// 模型与 VAD 模型只能来自服务端配置，用户设置中的文件路径会被忽略。
+func parseWorkflowASRSettings(settings map[string]string) *ASRSettings {
+	provider := strings.ToLower(strings.TrimSpace(settings[storemodel.UserSettingKeyASRProvider]))
+	rawOptions := strings.TrimSpace(settings[storemodel.UserSettingKeyWhisperOptions])
+	sourceLanguage := strings.TrimSpace(settings[storemodel.UserSettingKeyTranslationSourceLang])
+	sourceOrder := strings.TrimSpace(settings[storemodel.UserSettingKeyTranscriptSourceOrder])
+	if provider == "" && rawOptions == "" && sourceLanguage == "" && sourceOrder == "" {
+		return nil
+	}
+
+	asrSettings := &ASRSettings{Provider: provider, SourceLanguage: sourceLanguage}
+	if sourceOrder != "" {
+		asrSettings.SourceOrder = strings.Split(sourceOrder, ",")
+	}
+	if rawOptions != "" {
+		if err := json.Unmarshal([]byte(rawOptions), &asrSettings.Whisper); err != nil {
+			asrSettings.Whisper = tools.WhisperOptions{}
+		}
+		asrSettings.Whisper.ModelPath = ""
+		asrSettings.Whisper.VADModel = ""
+	}
+	return asrSettings
+}
+
 func normalizeWorkflowPreferredResolution(value string) string {
 	normalized := strings.ToLower(strings.TrimSpace(value))
 	if normalized == "" {
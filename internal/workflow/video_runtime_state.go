@@ -1,14 +1,30 @@
 package workflow
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/difyz9/ytb2bili/internal/events"
 	"github.com/difyz9/ytb2bili/pkg/store/model"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
-func MarkVideoStopped(db *gorm.DB, logger *zap.Logger, videoID string) {
+// partialFilePatterns 取消时需要清理的未完成文件：
+// yt-dlp 的 .part / .ytdl / 分片，抖音分块下载的 .partN 与断点状态，ffmpeg 写出的 .tmp 临时文件
+var partialFilePatterns = []string{
+	"*.part", "*.part-Frag*", "*.ytdl",
+	"*.part[0-9]*", "*.dl.json",
+	"*.tmp", "*.tmp.*",
+}
+
+// MarkVideoCancelled 将被取消的任务持久化为 cancelled：
+// 执行中的步骤标记为 cancelled，视频状态置为 VideoStatusCancelled，并清理 videoDir 下的未完成文件。
+// 已完成的步骤保持不变，继续处理时从被取消的步骤开始。
+func MarkVideoCancelled(db *gorm.DB, logger *zap.Logger, videoID, videoDir string) {
 	if db == nil || videoID == "" {
 		return
 	}
@@ -17,14 +33,106 @@ func MarkVideoStopped(db *gorm.DB, logger *zap.Logger, videoID string) {
 	if err := db.Model(&model.TaskStep{}).
 		Where("video_id = ? AND status = ?", videoID, model.TaskStepStatusRunning).
 		Updates(map[string]any{
-			"status":           model.TaskStepStatusPending,
+			"status":           model.TaskStepStatusCancelled,
 			"end_time":         &now,
 			"progress_percent": 0,
-			"progress_text":    "已停止，可重新开始",
+			"progress_text":    "已取消，可继续处理",
 			"error_msg":        "",
+			"can_retry":        true,
+		}).Error; err != nil && logger != nil {
+		logger.Warn("标记运行中步骤为已取消失败",
+			zap.String("video_id", videoID),
+			zap.Error(err))
+	}
+
+	transitionVideo(db, nil, logger, videoID, model.VideoTransition{
+		To: model.VideoStatusCancelled, Reason: "任务已取消", Actor: "system",
+	})
+
+	if removed := cleanupPartialFiles(videoDir); removed > 0 && logger != nil {
+		logger.Info("已清理取消任务的未完成文件",
+			zap.String("video_id", videoID),
+			zap.String("dir", videoDir),
+			zap.Int("removed", removed))
+	}
+}
+
+// MarkVideoInterrupted 将服务停止时中断的任务持久化：仍为 running 的步骤标记为 interrupted，
+// 并清理 videoDir 下的未完成文件。视频状态由调用方改回 queued（后台处理需同时释放租约），
+// 重新认领后从最后完成的步骤继续。
+func MarkVideoInterrupted(db *gorm.DB, logger *zap.Logger, videoID, videoDir string) {
+	if db == nil || videoID == "" {
+		return
+	}
+
+	now := time.Now()
+	if err := db.Model(&model.TaskStep{}).
+		Where("video_id = ? AND status = ?", videoID, model.TaskStepStatusRunning).
+		Updates(map[string]any{
+			"status":           model.TaskStepStatusInterrupted,
+			"end_time":         &now,
+			"progress_percent": 0,
+			"progress_text":    "服务停止时中断，将从此步骤继续",
+			"can_retry":        true,
 		}).Error; err != nil && logger != nil {
-		logger.Warn("标记运行中步骤为已停止失败",
+		logger.Warn("标记运行中步骤为已中断失败",
 			zap.String("video_id", videoID),
 			zap.Error(err))
 	}
+
+	if removed := cleanupPartialFiles(videoDir); removed > 0 && logger != nil {
+		logger.Info("已清理中断任务的未完成文件",
+			zap.String("video_id", videoID),
+			zap.String("dir", videoDir),
+			zap.Int("removed", removed))
+	}
+}
+
+// transitionVideo 按状态机变更视频状态，状态确有变化时发布事件（bus 可为 nil）。
+// 不满足 From 条件或变更不合法时只记录日志，返回是否已写入。
+func transitionVideo(db *gorm.DB, bus *events.Bus, logger *zap.Logger, videoID string, t model.VideoTransition) bool {
+	if db == nil || videoID == "" {
+		return false
+	}
+	previous, err := model.TransitionVideoStatus(db, videoID, t)
+	if err != nil {
+		if logger != nil {
+			level := logger.Warn
+			if errors.Is(err, model.ErrVideoStatusConflict) || errors.Is(err, gorm.ErrRecordNotFound) {
+				level = logger.Debug
+			}
+			level("变更视频状态失败",
+				zap.String("video_id", videoID),
+				zap.String("to", t.To),
+				zap.Error(err))
+		}
+		return false
+	}
+	if previous != model.NormalizeVideoStatus(t.To) {
+		bus.PublishVideoStatus(db, videoID, "", t.To)
+	}
+	return true
+}
+
+// cleanupPartialFiles 递归删除 dir 下匹配 partialFilePatterns 的文件，返回删除数量
+func cleanupPartialFiles(dir string) int {
+	if strings.TrimSpace(dir) == "" {
+		return 0
+	}
+	removed := 0
+	_ = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		for _, pattern := range partialFilePatterns {
+			if ok, _ := filepath.Match(pattern, d.Name()); ok {
+				if os.Remove(path) == nil {
+					removed++
+				}
+				break
+			}
+		}
+		return nil
+	})
+	return removed
 }
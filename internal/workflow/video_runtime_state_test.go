@@ -0,0 +1,109 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/difyz9/ytb2bili/pkg/store/model"
+	"go.uber.org/zap"
+)
+
+// blockingStep 阻塞直到 context 取消
+type blockingStep struct {
+	BaseStep
+	started chan struct{}
+}
+
+func (s blockingStep) Execute(ctx context.Context, input any) (any, error) {
+	close(s.started)
+	<-ctx.Done()
+	return nil, errors.New("signal: killed")
+}
+
+func TestCancelledRunPersistsCancelledState(t *testing.T) {
+	db := openCheckpointTestDB(t)
+	logger := zap.NewNop()
+	videoID := "cancel-1"
+	db.Create(&model.Video{VideoID: videoID, Status: model.VideoStatusProcessing})
+
+	started := make(chan struct{})
+	steps := []Step{
+		trackerTestStep{BaseStep: NewBaseStepWithOrder(StepNameInitialize, true, 1)},
+		blockingStep{BaseStep: NewBaseStepWithOrder(StepNameDownloadVideo, true, 2), started: started},
+		trackerTestStep{BaseStep: NewBaseStepWithOrder(StepNameTranscribe, true, 3)},
+	}
+	chain := NewChainFromSteps(steps, logger, "cancel")
+
+	registry := NewTaskRuntimeRegistry()
+	ctx, done := registry.Track(WithVideoID(context.Background(), videoID), videoID)
+	defer done()
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := RunChainWithTracking(ctx, chain, db, logger, videoID, &VideoContext{VideoID: videoID})
+		errCh <- err
+	}()
+	<-started
+	if !registry.Cancel(videoID) {
+		t.Fatal("expected tracked task to be cancellable")
+	}
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-time.After(2 * time.Second):
+		t.Fatal("chain did not stop after cancel")
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	dir := t.TempDir()
+	for _, name := range []string{"v.mp4.part", "v.f137.mp4.part-Frag3", "v.mp4.ytdl", "v.mp4"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	MarkVideoCancelled(db, logger, videoID, dir)
+
+	statusOf := func(step string) string {
+		var ts model.TaskStep
+		db.Where("video_id = ? AND step_name = ?", videoID, step).First(&ts)
+		return ts.Status
+	}
+	if got := statusOf(StepNameInitialize); got != model.TaskStepStatusCompleted {
+		t.Fatalf("expected finished step to stay completed, got %q", got)
+	}
+	if got := statusOf(StepNameDownloadVideo); got != model.TaskStepStatusCancelled {
+		t.Fatalf("expected interrupted step to be cancelled, got %q", got)
+	}
+	if got := statusOf(StepNameTranscribe); got != model.TaskStepStatusPending {
+		t.Fatalf("expected unstarted step to stay pending, got %q", got)
+	}
+
+	var video model.Video
+	db.Where("video_id = ?", videoID).First(&video)
+	if video.Status != model.VideoStatusCancelled {
+		t.Fatalf("expected video to be cancelled, got %q", video.Status)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 || entries[0].Name() != "v.mp4" {
+		t.Fatalf("expected only the finished file to remain, got %v", entries)
+	}
+
+	// 继续处理时被取消的步骤重新执行，已完成的步骤保留
+	if err := NewProgressTracker(db, logger).InitSteps(videoID, steps); err != nil {
+		t.Fatalf("init steps: %v", err)
+	}
+	if got := statusOf(StepNameDownloadVideo); got != model.TaskStepStatusPending {
+		t.Fatalf("expected cancelled step to be reset on resume, got %q", got)
+	}
+	if got := statusOf(StepNameInitialize); got != model.TaskStepStatusCompleted {
+		t.Fatalf("expected completed step to be kept on resume, got %q", got)
+	}
+}
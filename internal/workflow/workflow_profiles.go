@@ -0,0 +1,465 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/difyz9/ytb2bili/internal/config"
+	"github.com/difyz9/ytb2bili/internal/events"
+	"github.com/difyz9/ytb2bili/internal/service"
+	"github.com/difyz9/ytb2bili/pkg/store/model"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ── 声明式工作流 ─────────────────────────────────────────────────────────────
+// [[workflow.profiles]] 在配置中按名称组合步骤（顺序、是否必需、步骤参数），
+// 启动时对照步骤注册表校验并构建各自的任务链。提交、订阅、用户设置均可选择工作流；
+// 都未指定时使用 default_profile，仍为空则沿用 fx 步骤组构建的内置流程。
+
+const (
+	PlatformYouTube = "youtube"
+	PlatformDouyin  = "douyin"
+)
+
+// StepWithRegistryName 注册名与 Name() 不同的步骤（如以 LLMTranslate 名义展示的 DeepseekTranslate）
+type StepWithRegistryName interface {
+	Step
+	RegistryName() string
+}
+
+// StepRegistry 按注册名索引所有可用步骤
+type StepRegistry struct {
+	steps map[string]Step
+}
+
+// StepRegistryParams 注册表收集的步骤来源
+type StepRegistryParams struct {
+	fx.In
+	Steps       []Step `group:"steps"`
+	DouyinSteps []Step `group:"douyin_steps"`
+	ExtraSteps  []Step `group:"registry_steps"` // 不在默认流程中、仅供工作流配置引用的步骤
+	Plugins     []Step `group:"plugin_steps"`   // [[workflow.plugins]] 声明的外部程序步骤
+}
+
+// NewStepRegistry 创建步骤注册表，同名内置步骤以先注册者为准；外部程序步骤不能与已有步骤重名
+func NewStepRegistry(params StepRegistryParams) (*StepRegistry, error) {
+	registry := &StepRegistry{steps: make(map[string]Step)}
+	for _, group := range [][]Step{params.Steps, params.DouyinSteps, params.ExtraSteps} {
+		for _, step := range group {
+			registry.Register(step)
+		}
+	}
+	for _, plugin := range params.Plugins {
+		if _, exists := registry.Get(plugin.Name()); exists {
+			return nil, fmt.Errorf("workflow plugin %q conflicts with an existing step", plugin.Name())
+		}
+		registry.Register(plugin)
+	}
+	return registry, nil
+}
+
+// Register 注册步骤，已存在同名步骤时忽略
+func (r *StepRegistry) Register(step Step) {
+	name := registryName(step)
+	if _, exists := r.steps[name]; exists {
+		return
+	}
+	r.steps[name] = step
+}
+
+// Get 按注册名查找步骤
+func (r *StepRegistry) Get(name string) (Step, bool) {
+	step, ok := r.steps[name]
+	return step, ok
+}
+
+// Names 返回所有注册名（已排序）
+func (r *StepRegistry) Names() []string {
+	names := make([]string, 0, len(r.steps))
+	for name := range r.steps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func registryName(step Step) string {
+	if named, ok := step.(StepWithRegistryName); ok {
+		return named.RegistryName()
+	}
+	return step.Name()
+}
+
+// ── 步骤参数 ─────────────────────────────────────────────────────────────────
+
+const stepParamsContextKey contextKey = "workflow_step_params"
+
+// WithStepParams 将工作流配置中的步骤参数注入 context
+func WithStepParams(ctx context.Context, params map[string]any) context.Context {
+	return context.WithValue(ctx, stepParamsContextKey, params)
+}
+
+// StepParams 返回当前步骤的配置参数，未配置时返回 nil
+func StepParams(ctx context.Context) map[string]any {
+	params, _ := ctx.Value(stepParamsContextKey).(map[string]any)
+	return params
+}
+
+// StepParamString 读取字符串参数，缺失或为空时返回 fallback
+func StepParamString(ctx context.Context, key, fallback string) string {
+	value, ok := StepParams(ctx)[key]
+	if !ok {
+		return fallback
+	}
+	if s := strings.TrimSpace(fmt.Sprint(value)); s != "" {
+		return s
+	}
+	return fallback
+}
+
+// profileStep 以工作流配置覆盖步骤的顺序、必需标记，并在执行时注入参数。
+// 其余可选接口原样转发给被包装的步骤。
+type profileStep struct {
+	inner    Step
+	order    int
+	required bool
+	params   map[string]any
+}
+
+func (s *profileStep) Name() string     { return s.inner.Name() }
+func (s *profileStep) IsRequired() bool { return s.required }
+func (s *profileStep) Order() int       { return s.order }
+
+// Unwrap 返回被包装的步骤
+func (s *profileStep) Unwrap() Step { return s.inner }
+
+func (s *profileStep) Execute(ctx context.Context, input any) (any, error) {
+	if len(s.params) > 0 {
+		ctx = WithStepParams(ctx, s.params)
+	}
+	return s.inner.Execute(ctx, input)
+}
+
+func (s *profileStep) ShouldSkip(ctx context.Context, input any) bool {
+	skipStep, ok := s.inner.(StepWithSkip)
+	return ok && skipStep.ShouldSkip(ctx, input)
+}
+
+func (s *profileStep) HasCachedResult(ctx context.Context, input any) bool {
+	cachedStep, ok := s.inner.(StepWithCachedResult)
+	return ok && cachedStep.HasCachedResult(ctx, input)
+}
+
+func (s *profileStep) EstimateDiskBytes(ctx context.Context, input any) int64 {
+	estimator, ok := s.inner.(StepWithDiskEstimate)
+	if !ok {
+		return 0
+	}
+	return estimator.EstimateDiskBytes(ctx, input)
+}
+
+func (s *profileStep) OnSuccess(ctx context.Context, output any) error {
+	if hookStep, ok := s.inner.(StepWithHooks); ok {
+		return hookStep.OnSuccess(ctx, output)
+	}
+	return nil
+}
+
+func (s *profileStep) OnError(ctx context.Context, err error) error {
+	if hookStep, ok := s.inner.(StepWithHooks); ok {
+		return hookStep.OnError(ctx, err)
+	}
+	return nil
+}
+
+func (s *profileStep) DependsOn() []string { return declaredDependencies(s.inner) }
+
+func (s *profileStep) Reads() []ContextField {
+	if access, ok := s.inner.(StepWithContextAccess); ok {
+		return access.Reads()
+	}
+	return nil
+}
+
+func (s *profileStep) Writes() []ContextField {
+	if access, ok := s.inner.(StepWithContextAccess); ok {
+		return access.Writes()
+	}
+	return nil
+}
+
+func (s *profileStep) RetryPolicy() RetryPolicy { return resolveRetryPolicy(s.inner) }
+
+// ── 工作流 ───────────────────────────────────────────────────────────────────
+
+// WorkflowProfile 一个已校验的命名工作流
+type WorkflowProfile struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Platform    string   `json:"platform"`
+	Steps       []string `json:"steps"`
+	chain       *Chain
+}
+
+// Chain 返回该工作流的任务链
+func (p *WorkflowProfile) Chain() *Chain { return p.chain }
+
+// WorkflowProfiles 管理配置中的所有工作流，并按提交/订阅/用户设置解析出实际使用的工作流
+type WorkflowProfiles struct {
+	profiles     map[string]*WorkflowProfile
+	order        []string
+	defaultName  string
+	db           *gorm.DB
+	userSettings *service.UserSettingsClient
+	logger       *zap.Logger
+}
+
+// WorkflowProfilesParams 工作流管理器的依赖参数
+type WorkflowProfilesParams struct {
+	fx.In
+	Cfg          config.WorkflowConfig
+	Registry     *StepRegistry
+	DB           *gorm.DB                    `optional:"true"`
+	UserSettings *service.UserSettingsClient `optional:"true"`
+	Logger       *zap.Logger
+	Timeouts     *StepTimeouts     `optional:"true"`
+	Events       *events.Bus       `optional:"true"`
+	Resources    *ResourceGuard    `optional:"true"`
+	Interceptors []StepInterceptor `group:"step_interceptors"`
+}
+
+// NewWorkflowProfiles 校验并构建配置中的工作流；引用未注册步骤等配置错误会阻止启动
+func NewWorkflowProfiles(params WorkflowProfilesParams) (*WorkflowProfiles, error) {
+	wp := &WorkflowProfiles{
+		profiles:     make(map[string]*WorkflowProfile, len(params.Cfg.Profiles)),
+		defaultName:  strings.TrimSpace(params.Cfg.DefaultProfile),
+		db:           params.DB,
+		userSettings: params.UserSettings,
+		logger:       params.Logger,
+	}
+
+	for i, profileCfg := range params.Cfg.Profiles {
+		profile, err := buildWorkflowProfile(profileCfg, params)
+		if err != nil {
+			return nil, fmt.Errorf("workflow.profiles[%d]: %w", i, err)
+		}
+		if _, exists := wp.profiles[profile.Name]; exists {
+			return nil, fmt.Errorf("workflow.profiles[%d]: duplicate profile name %q", i, profile.Name)
+		}
+		wp.profiles[profile.Name] = profile
+		wp.order = append(wp.order, profile.Name)
+	}
+
+	if wp.defaultName != "" {
+		if _, ok := wp.profiles[wp.defaultName]; !ok {
+			return nil, fmt.Errorf("workflow.default_profile %q is not defined in workflow.profiles", wp.defaultName)
+		}
+	}
+
+	if len(wp.order) > 0 {
+		params.Logger.Info("Loaded workflow profiles",
+			zap.Strings("profiles", wp.order),
+			zap.String("default", wp.defaultName))
+	}
+	return wp, nil
+}
+
+func buildWorkflowProfile(cfg config.WorkflowProfileConfig, params WorkflowProfilesParams) (*WorkflowProfile, error) {
+	registry := params.Registry
+	name := strings.TrimSpace(cfg.Name)
+	if name == "" {
+		return nil, fmt.Errorf("profile name is required")
+	}
+	platform := strings.ToLower(strings.TrimSpace(cfg.Platform))
+	if platform == "" {
+		platform = PlatformYouTube
+	}
+	if platform != PlatformYouTube && platform != PlatformDouyin {
+		return nil, fmt.Errorf("profile %q: unsupported platform %q", name, cfg.Platform)
+	}
+	if len(cfg.Steps) == 0 {
+		return nil, fmt.Errorf("profile %q: no steps configured", name)
+	}
+
+	profile := &WorkflowProfile{
+		Name:        name,
+		Description: cfg.Description,
+		Platform:    platform,
+	}
+	steps := make([]Step, 0, len(cfg.Steps))
+	seen := make(map[string]struct{}, len(cfg.Steps))
+	for i, stepCfg := range cfg.Steps {
+		stepName := strings.TrimSpace(stepCfg.Name)
+		inner, ok := registry.Get(stepName)
+		if !ok {
+			return nil, fmt.Errorf("profile %q: unknown step %q (available: %s)",
+				name, stepCfg.Name, strings.Join(registry.Names(), ", "))
+		}
+		if _, dup := seen[stepName]; dup {
+			return nil, fmt.Errorf("profile %q: step %q listed more than once", name, stepName)
+		}
+		seen[stepName] = struct{}{}
+
+		order := stepCfg.Order
+		if order == 0 {
+			order = (i + 1) * 10
+		}
+		required := inner.IsRequired()
+		if stepCfg.Required != nil {
+			required = *stepCfg.Required
+		}
+		steps = append(steps, &profileStep{inner: inner, order: order, required: required, params: stepCfg.Params})
+		profile.Steps = append(profile.Steps, stepName)
+	}
+
+	profile.chain = NewChainFromSteps(steps, params.Logger, "profile:"+name).
+		WithTimeouts(params.Timeouts).
+		WithEvents(params.Events).
+		WithResources(params.Resources).
+		WithInterceptors(params.Interceptors...)
+	return profile, nil
+}
+
+// Get 按名称查找工作流
+func (wp *WorkflowProfiles) Get(name string) (*WorkflowProfile, bool) {
+	if wp == nil {
+		return nil, false
+	}
+	profile, ok := wp.profiles[strings.TrimSpace(name)]
+	return profile, ok
+}
+
+// List 按配置顺序返回所有工作流
+func (wp *WorkflowProfiles) List() []*WorkflowProfile {
+	if wp == nil {
+		return nil
+	}
+	profiles := make([]*WorkflowProfile, 0, len(wp.order))
+	for _, name := range wp.order {
+		profiles = append(profiles, wp.profiles[name])
+	}
+	return profiles
+}
+
+// DefaultName 返回 default_profile
+func (wp *WorkflowProfiles) DefaultName() string {
+	if wp == nil {
+		return ""
+	}
+	return wp.defaultName
+}
+
+// Validate 校验提交时指定的工作流名称；空名称表示不指定
+func (wp *WorkflowProfiles) Validate(name, platform string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil
+	}
+	profile, ok := wp.Get(name)
+	if !ok {
+		return fmt.Errorf("unknown workflow profile %q", name)
+	}
+	if platform != "" && profile.Platform != platform {
+		return fmt.Errorf("workflow profile %q is for %s, not %s", name, profile.Platform, platform)
+	}
+	return nil
+}
+
+// Resolve 解析视频实际使用的工作流，优先级：
+// 本次提交指定 > 视频记录 > 所属订阅频道 > 用户设置 > default_profile。
+// 均未命中时返回 nil，调用方使用内置流程。解析结果会写回视频记录，续跑时保持一致（计划预览除外）。
+func (wp *WorkflowProfiles) Resolve(ctx context.Context, platform, videoID, userID, requested string) *WorkflowProfile {
+	if wp == nil || len(wp.profiles) == 0 {
+		return nil
+	}
+	if platform == "" {
+		platform = PlatformYouTube
+	}
+
+	for _, candidate := range wp.candidates(ctx, videoID, userID, requested) {
+		profile, ok := wp.profiles[candidate.name]
+		if !ok {
+			wp.logger.Warn("Unknown workflow profile, ignoring",
+				zap.String("video_id", videoID),
+				zap.String("source", candidate.source),
+				zap.String("profile", candidate.name))
+			continue
+		}
+		if profile.Platform != platform {
+			wp.logger.Warn("Workflow profile platform mismatch, ignoring",
+				zap.String("video_id", videoID),
+				zap.String("source", candidate.source),
+				zap.String("profile", candidate.name),
+				zap.String("profile_platform", profile.Platform),
+				zap.String("platform", platform))
+			continue
+		}
+		if !isPlanMode(ctx) {
+			wp.remember(ctx, videoID, profile.Name)
+		}
+		return profile
+	}
+	return nil
+}
+
+type profileCandidate struct {
+	source string
+	name   string
+}
+
+func (wp *WorkflowProfiles) candidates(ctx context.Context, videoID, userID, requested string) []profileCandidate {
+	var candidates []profileCandidate
+	add := func(source, name string) {
+		if name = strings.TrimSpace(name); name != "" {
+			candidates = append(candidates, profileCandidate{source: source, name: name})
+		}
+	}
+
+	add("request", requested)
+
+	var video model.Video
+	if wp.db != nil && strings.TrimSpace(videoID) != "" {
+		if err := wp.db.WithContext(ctx).Select("workflow_profile", "channel_id", "user_id").
+			Where("video_id = ?", videoID).First(&video).Error; err == nil {
+			add("video", video.WorkflowProfile)
+			if strings.TrimSpace(userID) == "" {
+				userID = video.UserID
+			}
+		}
+	}
+
+	if wp.db != nil && strings.TrimSpace(video.ChannelId) != "" && strings.TrimSpace(userID) != "" {
+		var sub model.TbSubscription
+		if err := wp.db.WithContext(ctx).Select("workflow_profile").
+			Where("user_id = ? AND channel_id = ?", userID, video.ChannelId).First(&sub).Error; err == nil {
+			add("subscription", sub.WorkflowProfile)
+		}
+	}
+
+	if wp.userSettings != nil && strings.TrimSpace(userID) != "" {
+		if settings, err := wp.userSettings.GetSettings(ctx, userID); err == nil {
+			add("user_setting", settings[model.UserSettingKeyWorkflowProfile])
+		}
+	}
+
+	add("default", wp.defaultName)
+	return candidates
+}
+
+func (wp *WorkflowProfiles) remember(ctx context.Context, videoID, name string) {
+	if wp.db == nil || strings.TrimSpace(videoID) == "" {
+		return
+	}
+	if err := wp.db.WithContext(ctx).Model(&model.Video{}).
+		Where("video_id = ? AND (workflow_profile IS NULL OR workflow_profile <> ?)", videoID, name).
+		Update("workflow_profile", name).Error; err != nil {
+		wp.logger.Warn("Failed to record workflow profile",
+			zap.String("video_id", videoID),
+			zap.String("profile", name),
+			zap.Error(err))
+	}
+}
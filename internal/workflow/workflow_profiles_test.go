@@ -0,0 +1,229 @@
+package workflow
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/difyz9/ytb2bili/internal/config"
+	"github.com/difyz9/ytb2bili/internal/service"
+	"github.com/difyz9/ytb2bili/pkg/store/model"
+	"go.uber.org/zap"
+)
+
+// paramRecordingStep 记录执行时收到的步骤参数
+type paramRecordingStep struct {
+	BaseStep
+	gotText string
+	calls   int
+}
+
+func (s *paramRecordingStep) Execute(ctx context.Context, input any) (any, error) {
+	s.calls++
+	s.gotText = StepParamString(ctx, "text", "default")
+	return input, nil
+}
+
+func newProfileTestRegistry() (*StepRegistry, map[string]*paramRecordingStep) {
+	steps := map[string]*paramRecordingStep{
+		StepNameInitialize:    {BaseStep: NewBaseStepWithOrder(StepNameInitialize, true, 1)},
+		StepNameTranscribe:    {BaseStep: NewBaseStepWithOrder(StepNameTranscribe, false, 5)},
+		StepNameAddWatermark:  {BaseStep: NewBaseStepWithOrder(StepNameAddWatermark, true, 8)},
+		StepNameSaveDatabase:  {BaseStep: NewBaseStepWithOrder(StepNameSaveDatabase, true, 9)},
+		StepNameDownloadVideo: {BaseStep: NewBaseStepWithOrder(StepNameDownloadVideo, true, 2)},
+	}
+	registry, _ := NewStepRegistry(StepRegistryParams{
+		Steps:      []Step{steps[StepNameInitialize], steps[StepNameDownloadVideo], steps[StepNameTranscribe], steps[StepNameSaveDatabase]},
+		ExtraSteps: []Step{steps[StepNameAddWatermark]},
+	})
+	return registry, steps
+}
+
+func boolPtr(v bool) *bool { return &v }
+
+func TestWorkflowProfiles_BuildsChainFromConfig(t *testing.T) {
+	registry, steps := newProfileTestRegistry()
+	profiles, err := NewWorkflowProfiles(WorkflowProfilesParams{
+		Cfg: config.WorkflowConfig{
+			Profiles: []config.WorkflowProfileConfig{{
+				Name: "watermark-first",
+				Steps: []config.WorkflowProfileStepConfig{
+					{Name: StepNameInitialize},
+					{Name: StepNameAddWatermark, Required: boolPtr(false), Params: map[string]any{"text": "hello"}},
+					{Name: StepNameSaveDatabase},
+				},
+			}},
+		},
+		Registry: registry,
+		Logger:   zap.NewNop(),
+	})
+	if err != nil {
+		t.Fatalf("NewWorkflowProfiles: %v", err)
+	}
+
+	profile, ok := profiles.Get("watermark-first")
+	if !ok {
+		t.Fatal("profile not found")
+	}
+	if profile.Platform != PlatformYouTube {
+		t.Fatalf("expected default platform youtube, got %q", profile.Platform)
+	}
+
+	chainSteps := profile.Chain().GetSteps()
+	var names []string
+	for _, s := range chainSteps {
+		names = append(names, s.Name())
+	}
+	if got := strings.Join(names, ","); got != "Initialize,AddWatermark,SaveDatabase" {
+		t.Fatalf("expected list order to override step order, got %s", got)
+	}
+	if chainSteps[1].IsRequired() {
+		t.Fatal("expected required=false from config to override step default")
+	}
+
+	result := profile.Chain().Run(context.Background(), &VideoContext{})
+	if !result.Success {
+		t.Fatalf("chain failed: %v", result.Error)
+	}
+	if steps[StepNameAddWatermark].gotText != "hello" {
+		t.Fatalf("expected step param to reach step, got %q", steps[StepNameAddWatermark].gotText)
+	}
+	if steps[StepNameSaveDatabase].gotText != "default" {
+		t.Fatalf("expected steps without params to see fallback, got %q", steps[StepNameSaveDatabase].gotText)
+	}
+	if steps[StepNameTranscribe].calls != 0 {
+		t.Fatal("steps not listed in the profile must not run")
+	}
+}
+
+func TestWorkflowProfiles_UploadStepSkipsWhileAwaitingReview(t *testing.T) {
+	registry, _ := newProfileTestRegistry()
+	registry.Register(NewUploadToBilibiliStep(nil, nil, nil, zap.NewNop()))
+	profiles, err := NewWorkflowProfiles(WorkflowProfilesParams{
+		Cfg: config.WorkflowConfig{
+			Profiles: []config.WorkflowProfileConfig{{
+				Name: "dub-watermark-upload",
+				Steps: []config.WorkflowProfileStepConfig{
+					{Name: StepNameInitialize},
+					{Name: StepNameAddWatermark},
+					{Name: StepNameSaveDatabase},
+					{Name: StepNameUploadToBilibili},
+				},
+			}},
+		},
+		Registry: registry,
+		Logger:   zap.NewNop(),
+	})
+	if err != nil {
+		t.Fatalf("NewWorkflowProfiles: %v", err)
+	}
+	profile, ok := profiles.Get("dub-watermark-upload")
+	if !ok {
+		t.Fatal("profile not found")
+	}
+	chainSteps := profile.Chain().GetSteps()
+	if last := chainSteps[len(chainSteps)-1]; last.Name() != StepNameUploadToBilibili || !last.IsRequired() {
+		t.Fatalf("expected required upload step last, got %s", last.Name())
+	}
+
+	// 上传步骤没有账号服务，执行即失败：审核关卡停住视频时必须跳过
+	result := profile.Chain().Run(context.Background(), &VideoContext{AwaitingReview: true})
+	if !result.Success {
+		t.Fatalf("expected upload to be skipped while awaiting review, got %v", result.Error)
+	}
+	if result := profile.Chain().Run(context.Background(), &VideoContext{}); result.Success {
+		t.Fatal("expected upload step to run once review is not pending")
+	}
+}
+
+func TestWorkflowProfiles_RejectsInvalidConfig(t *testing.T) {
+	registry, _ := newProfileTestRegistry()
+	cases := map[string]config.WorkflowConfig{
+		"unknown step": {Profiles: []config.WorkflowProfileConfig{{
+			Name: "p", Steps: []config.WorkflowProfileStepConfig{{Name: "NoSuchStep"}},
+		}}},
+		"duplicate step": {Profiles: []config.WorkflowProfileConfig{{
+			Name: "p", Steps: []config.WorkflowProfileStepConfig{{Name: StepNameInitialize}, {Name: StepNameInitialize}},
+		}}},
+		"unknown platform": {Profiles: []config.WorkflowProfileConfig{{
+			Name: "p", Platform: "vimeo", Steps: []config.WorkflowProfileStepConfig{{Name: StepNameInitialize}},
+		}}},
+		"missing default": {DefaultProfile: "nope"},
+	}
+	for name, cfg := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewWorkflowProfiles(WorkflowProfilesParams{Cfg: cfg, Registry: registry, Logger: zap.NewNop()}); err == nil {
+				t.Fatal("expected configuration error")
+			}
+		})
+	}
+}
+
+func TestWorkflowProfiles_ResolvePrecedence(t *testing.T) {
+	db := openCheckpointTestDB(t)
+	if err := db.AutoMigrate(&model.TbSubscription{}, &model.UserSettings{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	registry, _ := newProfileTestRegistry()
+	profileCfg := func(name, platform string) config.WorkflowProfileConfig {
+		return config.WorkflowProfileConfig{Name: name, Platform: platform,
+			Steps: []config.WorkflowProfileStepConfig{{Name: StepNameInitialize}}}
+	}
+	logger := zap.NewNop()
+	profiles, err := NewWorkflowProfiles(WorkflowProfilesParams{
+		Cfg: config.WorkflowConfig{
+			DefaultProfile: "fallback",
+			Profiles: []config.WorkflowProfileConfig{
+				profileCfg("fallback", ""), profileCfg("by-user", ""), profileCfg("by-sub", ""),
+				profileCfg("by-request", ""), profileCfg("douyin-only", PlatformDouyin),
+			},
+		},
+		Registry:     registry,
+		DB:           db,
+		UserSettings: service.NewUserSettingsClient(db, logger),
+		Logger:       logger,
+	})
+	if err != nil {
+		t.Fatalf("NewWorkflowProfiles: %v", err)
+	}
+
+	ctx := context.Background()
+	resolve := func(videoID, requested string) string {
+		if p := profiles.Resolve(ctx, PlatformYouTube, videoID, "u1", requested); p != nil {
+			return p.Name
+		}
+		return ""
+	}
+
+	if got := resolve("", ""); got != "fallback" {
+		t.Fatalf("expected default profile, got %q", got)
+	}
+
+	settings := &model.UserSettings{UserID: "u1"}
+	if err := settings.ApplySettingsPatch(map[string]string{model.UserSettingKeyWorkflowProfile: "by-user"}); err != nil {
+		t.Fatalf("patch settings: %v", err)
+	}
+	db.Create(settings)
+	if got := resolve("", ""); got != "by-user" {
+		t.Fatalf("expected user setting to win over default, got %q", got)
+	}
+
+	db.Create(&model.Video{VideoID: "v1", UserID: "u1", ChannelId: "c1"})
+	db.Create(&model.TbSubscription{UserID: "u1", ChannelID: "c1", Platform: "youtube", WorkflowProfile: "by-sub"})
+	if got := resolve("v1", ""); got != "by-sub" {
+		t.Fatalf("expected subscription to win over user setting, got %q", got)
+	}
+
+	var video model.Video
+	db.Where("video_id = ?", "v1").First(&video)
+	if video.WorkflowProfile != "by-sub" {
+		t.Fatalf("expected resolved profile to be recorded on the video, got %q", video.WorkflowProfile)
+	}
+
+	if got := resolve("v1", "by-request"); got != "by-request" {
+		t.Fatalf("expected explicit request to win, got %q", got)
+	}
+	if got := resolve("v1", "douyin-only"); got != "by-request" {
+		t.Fatalf("expected platform mismatch to fall through to the recorded profile, got %q", got)
+	}
+}
@@ -22,6 +22,9 @@ import (
 var YouTubeWorkflowModule = fx.Module("youtube_workflow",
 	// 提供配置（从 AppConfig 中提取）
 	fx.Provide(provideWorkflowConfig),
+	fx.Provide(NewStepTimeouts),
+	fx.Provide(NewStepCache),
+	fx.Provide(NewResourceGuard),
 	fx.Provide(NewTaskRuntimeRegistry),
 
 	// 提供工具
@@ -29,6 +32,7 @@ var YouTubeWorkflowModule = fx.Module("youtube_workflow",
 	fx.Provide(provideDownloadThumbnailTool),
 	fx.Provide(provideExtractAudioTool),
 	fx.Provide(provideTranscriberTool),
+	fx.Provide(provideWhisperASREngine),
 	fx.Provide(provideLLMBatchTranslatorTool),
 	fx.Provide(provideTTSClientTool),
 
@@ -48,8 +52,18 @@ var YouTubeWorkflowModule = fx.Module("youtube_workflow",
 		NewSynthesizeSubtitleAudioStep,
 		// NewAddWatermarkStep,
 		NewSaveDatabaseStep,
+		NewReviewGateStep,
 	)...),
 
+	// 仅供 [[workflow.profiles]] 引用、不在默认流程中的步骤
+	fx.Options(StepProvidersForGroup("registry_steps",
+		NewAddWatermarkStep,
+		NewUploadToBilibiliStep, // 工作流内投稿，审核关卡停住视频时跳过
+	)...),
+	fx.Provide(fx.Annotate(NewPluginSteps, fx.ResultTags(`group:"plugin_steps,flatten"`))),
+	fx.Provide(NewStepRegistry),
+	fx.Provide(NewWorkflowProfiles),
+
 	// 提供 YouTube 处理链
 	fx.Provide(NewYouTubeChain),
 )
@@ -177,8 +191,9 @@ type VideoContext struct {
 	Platform            string
 	VideoURL            string
 	VideoID             string
-	UserID              string // 用户ID（用于计费等）
-	PreferredResolution string // 期望下载分辨率: best/720p/1080p/1440p/2160p
+	UserID              string  // 用户ID（用于计费等）
+	PreferredResolution string  // 期望下载分辨率: best/720p/1080p/1440p/2160p
+	DurationSeconds     float64 // 视频时长（秒），未知为 0；用于按时长放宽步骤超时
 	VideoPath           string
 	ThumbnailPath       string
 	AudioPath           string
@@ -200,9 +215,14 @@ type VideoContext struct {
 	TranslationConfig     *TranslationConfig     // 翻译配置
 	SpeechSynthesisConfig *SpeechSynthesisConfig // 语音合成配置
 	TaskChainSettings     *TaskChainSettings     // 任务链步骤开关
+	ASRSettings           *ASRSettings           // 用户指定的转写引擎与 whisper 参数，为空时使用 [workflow.asr]
 	RestartFromStep       string                 // 指定续跑起点；起点之前的步骤在运行时严格跳过
+	WorkflowProfile       string                 // 本次提交指定的工作流配置名，空表示按订阅/用户设置/默认值解析
 	TranslationSkipped    bool                   // 当前字幕是否判定为无需翻译
+	ReviewFeedback        string                 // 人工审核退回意见，重跑时附加到翻译与元数据生成的提示词
+	AwaitingReview        bool                   // 审核关卡已把视频置为待审核，结束时不应再写回已完成
 	restartStepActivated  bool
+	restoredSteps         map[string]struct{} // 从检查点恢复、可直接跳过的已完成步骤
 }
 
 // ============================================================================
@@ -216,6 +236,13 @@ type YouTubeChain struct {
 	logger       *zap.Logger
 	downloadDir  string
 	workflowCfg  config.WorkflowConfig
+	profiles     *WorkflowProfiles
+	prober       videoProber // 视频时长未知时探测时长（计划预览与实际运行）
+}
+
+// videoProber 读取视频元信息而不下载，由 tools.DownloadVideoTool 实现
+type videoProber interface {
+	Probe(ctx context.Context, url string) (*tools.VideoProbe, error)
 }
 
 type YouTubeChainParams struct {
@@ -225,19 +252,41 @@ type YouTubeChainParams struct {
 	UserSettings *service.UserSettingsClient `optional:"true"`
 	Logger       *zap.Logger
 	Cfg          config.WorkflowConfig
+	Profiles     *WorkflowProfiles       `optional:"true"`
+	Downloader   *tools.DownloadVideoTool `optional:"true"`
 }
 
 
 func (yc *YouTubeChain) getChain() *Chain { return yc.chain }
 func NewYouTubeChain(params YouTubeChainParams) *YouTubeChain {
-	return &YouTubeChain{
+	yc := &YouTubeChain{
 		chain:        params.Chain,
 		db:           params.DB,
 		userSettings: params.UserSettings,
 		logger:       params.Logger,
 		downloadDir:  params.Cfg.DownloadDir,
 		workflowCfg:  params.Cfg,
+		profiles:     params.Profiles,
+	}
+	if params.Downloader != nil {
+		yc.prober = params.Downloader
+	}
+	return yc
+}
+
+// chainFor 返回本次运行使用的任务链：命中工作流配置时使用其任务链，否则使用内置流程
+func (yc *YouTubeChain) chainFor(ctx context.Context, vctx *VideoContext, videoID string) *Chain {
+	requested, userID := "", ""
+	if vctx != nil {
+		requested, userID = vctx.WorkflowProfile, vctx.UserID
+	}
+	if profile := yc.profiles.Resolve(ctx, PlatformYouTube, videoID, userID, requested); profile != nil {
+		if vctx != nil {
+			vctx.WorkflowProfile = profile.Name
+		}
+		return profile.Chain()
 	}
+	return yc.chain
 }
 
 
@@ -302,8 +351,48 @@ func (yc *YouTubeChain) ProcessContextWithTracking(ctx context.Context, initialC
 	}
 	applyLatestUserSettingsToVideoContext(ctx, yc.userSettings, yc.logger, initialCtx)
 	ctx = withPreferencesApplied(ctx)
+	NewCheckpointStore(yc.db, yc.logger).Restore(videoID, initialCtx)
+	// 提交时不记录时长，需在第一个步骤计算超时前得到时长，否则长视频的下载与转写仍按基础超时执行
+	yc.resolveVideoDuration(ctx, videoID, initialCtx)
 
-	return RunChainWithTracking(ctx, yc.chain, yc.db, yc.logger, videoID, initialCtx)
+	return RunChainWithTracking(ctx, yc.chainFor(ctx, initialCtx, videoID), yc.db, yc.logger, videoID, initialCtx)
+}
+
+// resolveVideoDuration 上下文未携带视频时长时，先读 tb_videos.duration，仍未知且为远程视频时用 yt-dlp 探测（不下载），
+// 探测到的时长写回 tb_videos，之后的重试与计划预览直接复用。
+// 返回时长来源（未新得到时长时为空）与探测失败说明（供计划预览展示）。
+func (yc *YouTubeChain) resolveVideoDuration(ctx context.Context, videoID string, vctx *VideoContext) (source, note string) {
+	if vctx.DurationSeconds > 0 {
+		return "", ""
+	}
+	fillVideoDuration(yc.db, videoID, vctx)
+	if vctx.DurationSeconds > 0 {
+		return durationSourceRecord, ""
+	}
+	remote := strings.HasPrefix(vctx.VideoURL, "http://") || strings.HasPrefix(vctx.VideoURL, "https://")
+	if videoDurationSeconds(vctx) > 0 || vctx.VideoPath != "" || !remote || yc.prober == nil {
+		return "", ""
+	}
+
+	probeCtx, cancel := context.WithTimeout(ctx, planProbeTimeout)
+	probe, err := yc.prober.Probe(probeCtx, vctx.VideoURL)
+	cancel()
+	if err != nil {
+		yc.logger.Warn("Failed to probe video duration",
+			zap.String("video_id", videoID),
+			zap.Error(err))
+		return "", "读取视频时长失败：" + err.Error()
+	}
+	if probe == nil || probe.DurationSeconds <= 0 {
+		return "", ""
+	}
+	vctx.DurationSeconds = probe.DurationSeconds
+	if err := saveVideoDuration(yc.db, videoID, probe.DurationSeconds); err != nil {
+		yc.logger.Warn("Failed to save probed video duration",
+			zap.String("video_id", videoID),
+			zap.Error(err))
+	}
+	return durationSourceProbe, ""
 }
 
 // ProcessLocalWithTracking 处理本地视频文件并将步骤进度持久化到数据库
@@ -330,8 +419,40 @@ func (yc *YouTubeChain) ProcessLocalContextWithTracking(ctx context.Context, ini
 	}
 	applyLatestUserSettingsToVideoContext(ctx, yc.userSettings, yc.logger, initialCtx)
 	ctx = withPreferencesApplied(ctx)
+	NewCheckpointStore(yc.db, yc.logger).Restore(videoID, initialCtx)
+
+	return RunChainWithTracking(ctx, yc.chainFor(ctx, initialCtx, videoID), yc.db, yc.logger, videoID, initialCtx)
+}
+
+// Plan 预览处理该视频将执行的步骤与预估用量。
+// 与 ProcessContextWithTracking 使用相同的用户设置、检查点与工作流解析，但不执行步骤；
+// 视频时长未知时通过 yt-dlp 读取元信息（不下载），读到的时长写入视频记录（唯一的数据库写入），
+// 之后的运行直接使用，计划中的步骤超时即为运行时的超时。
+func (yc *YouTubeChain) Plan(ctx context.Context, initialCtx *VideoContext, videoID, userID string) (*ChainPlan, error) {
+	if initialCtx == nil {
+		initialCtx = yc.defaultVideoContext()
+	}
+	ctx = withPlanMode(ctx)
+	resolvedUserID := yc.resolveUserIDForRun(ctx, videoID, userID, initialCtx)
+	ctx = WithVideoID(ctx, videoID)
+	if resolvedUserID != "" {
+		ctx = WithUserID(ctx, resolvedUserID)
+		initialCtx.UserID = resolvedUserID
+	}
+	applyLatestUserSettingsToVideoContext(ctx, yc.userSettings, yc.logger, initialCtx)
+	ctx = withPreferencesApplied(ctx)
+	NewCheckpointStore(yc.db, yc.logger).Restore(videoID, initialCtx)
+
+	// 与实际运行使用同一时长来源；探测到的时长写入视频记录，运行时按同样的时长放宽超时
+	durationSource, note := yc.resolveVideoDuration(ctx, videoID, initialCtx)
+	var notes []string
+	if note != "" {
+		notes = append(notes, note)
+	}
 
-	return RunChainWithTracking(ctx, yc.chain, yc.db, yc.logger, videoID, initialCtx)
+	plan := buildChainPlan(ctx, yc.chainFor(ctx, initialCtx, videoID), PlatformYouTube, videoID, initialCtx, durationSource)
+	plan.Estimate.Notes = append(notes, plan.Estimate.Notes...)
+	return plan, nil
 }
 
 func (yc *YouTubeChain) resolveUserIDForRun(ctx context.Context, videoID, requestedUserID string, initialCtx *VideoContext) string {
@@ -371,9 +492,10 @@ func (yc *YouTubeChain) resolveUserIDForRun(ctx context.Context, videoID, reques
 
 // RetryStepByName 直接重试指定名称的步骤，从数据库与磁盘重建上下文，不触发完整流程
 func (yc *YouTubeChain) RetryStepByName(ctx context.Context, video *model.Video, stepName string) error {
-	// 在链中按名称查找步骤
+	// 在视频所用工作流的链中按名称查找步骤
+	chain := yc.chainFor(ctx, &VideoContext{WorkflowProfile: video.WorkflowProfile, UserID: video.UserID}, video.VideoID)
 	var targetStep Step
-	for _, s := range yc.chain.GetSteps() {
+	for _, s := range chain.GetSteps() {
 		if s.Name() == stepName {
 			targetStep = s
 			break
@@ -391,6 +513,7 @@ func (yc *YouTubeChain) RetryStepByName(ctx context.Context, video *model.Video,
 	vctx.VideoID = video.VideoID
 	vctx.VideoURL = videoURL
 	vctx.VideoPath = video.VideoPath
+	vctx.DurationSeconds = video.Duration
 
 	// 对于「下载视频」步骤的特殊处理
 	if stepName == StepNameDownloadVideo {
@@ -400,10 +523,12 @@ func (yc *YouTubeChain) RetryStepByName(ctx context.Context, video *model.Video,
 				yc.logger.Info("本地视频文件已存在，跳过重新下载",
 					zap.String("video_id", video.VideoID),
 					zap.String("path", video.VideoPath))
-				tracker := NewProgressTracker(yc.db, yc.logger)
+				tracker := NewProgressTracker(yc.db, yc.logger).WithEvents(yc.chain.events)
 				ctx = WithVideoID(ctx, video.VideoID)
+				tracker.StartRun(video.VideoID, model.TaskRunTriggerStepRetry)
 				tracker.BeforeStep(video.VideoID, stepName)
 				tracker.AfterStep(video.VideoID, stepName, model.TaskStepStatusCompleted, "")
+				tracker.FinishRun(model.TaskRunStatusCompleted, "")
 				return nil
 			}
 		}
@@ -417,7 +542,7 @@ func (yc *YouTubeChain) RetryStepByName(ctx context.Context, video *model.Video,
 
 	yc.restoreTranscriptFromSavedSubtitles(video, vctx)
 
-	tracker := NewProgressTracker(yc.db, yc.logger)
+	tracker := NewProgressTracker(yc.db, yc.logger).WithEvents(yc.chain.events)
 	ctx = WithVideoID(ctx, video.VideoID)
 	// 将用户ID注入context，供上传到B站等需要鉴权的步骤使用
 	if video.UserID != "" {
@@ -425,14 +550,23 @@ func (yc *YouTubeChain) RetryStepByName(ctx context.Context, video *model.Video,
 		vctx.UserID = video.UserID
 	}
 	applyLatestUserSettingsToVideoContext(ctx, yc.userSettings, yc.logger, vctx)
+	yc.resolveVideoDuration(ctx, video.VideoID, vctx)
 
+	tracker.StartRun(video.VideoID, model.TaskRunTriggerStepRetry)
 	tracker.BeforeStep(video.VideoID, stepName)
-	_, err := targetStep.Execute(ctx, vctx)
+	timeout := chain.timeouts.For(stepName, videoDurationSeconds(vctx))
+	_, err := chain.executeWithTimeout(WithProgressTracker(ctx, tracker), targetStep, vctx, timeout)
 	if err != nil {
-		tracker.AfterStep(video.VideoID, stepName, model.TaskStepStatusFailed, err.Error())
+		status := model.TaskStepStatusFailed
+		if IsStepTimeout(err) {
+			status = model.TaskStepStatusTimeout
+		}
+		tracker.AfterStep(video.VideoID, stepName, status, err.Error())
+		tracker.FinishRun(runStatus(ctx, err), err.Error())
 		return err
 	}
 	tracker.AfterStep(video.VideoID, stepName, model.TaskStepStatusCompleted, "")
+	tracker.FinishRun(model.TaskRunStatusCompleted, "")
 	return nil
 }
 
@@ -470,9 +604,14 @@ func (yc *YouTubeChain) resumeProcessing(ctx context.Context, video *model.Video
 		initialCtx.Title = video.Title
 	}
 	initialCtx.UserID = video.UserID
+	if strings.TrimSpace(initialCtx.WorkflowProfile) == "" {
+		initialCtx.WorkflowProfile = video.WorkflowProfile
+	}
 	if refreshUserSettings {
 		applyLatestUserSettingsToVideoContext(ctx, yc.userSettings, yc.logger, initialCtx)
 	}
+	// 优先用检查点恢复完整上下文；检查点缺失时再从磁盘上的字幕文件重建
+	NewCheckpointStore(yc.db, yc.logger).Restore(video.VideoID, initialCtx)
 	yc.restoreTranscriptFromSavedSubtitles(video, initialCtx)
 	yc.restoreSubtitleAudiosFromSavedSubtitles(initialCtx)
 
@@ -481,7 +620,7 @@ func (yc *YouTubeChain) resumeProcessing(ctx context.Context, video *model.Video
 		zap.String("video_path", videoPath),
 		zap.String("video_url", videoURL))
 
-	_, err := RunChainWithTracking(ctx, yc.chain, yc.db, yc.logger, video.VideoID, initialCtx)
+	_, err := RunChainWithTracking(ctx, yc.chainFor(ctx, initialCtx, video.VideoID), yc.db, yc.logger, video.VideoID, initialCtx)
 	return err
 }
 
@@ -723,6 +862,14 @@ func (yc *YouTubeChain) restoreSubtitleAudiosFromSavedSubtitles(vctx *VideoConte
 	}
 }
 
+// VideoDir 返回视频的工作目录 downloadDir/{videoID}，未配置下载目录时为空。
+func (yc *YouTubeChain) VideoDir(videoID string) string {
+	if yc == nil || yc.downloadDir == "" || videoID == "" {
+		return ""
+	}
+	return filepath.Join(yc.downloadDir, videoID)
+}
+
 // findLocalVideoFile 在 downloadDir/{videoID}/ 中查找已下载的视频文件。
 func (yc *YouTubeChain) findLocalVideoFile(videoID string) string {
 	if yc.downloadDir == "" || videoID == "" {
@@ -785,6 +932,7 @@ func provideDownloadVideoTool(cfg config.WorkflowConfig, logger *zap.Logger) (*t
 		YtDlpPath:   cfg.YtDlpPath,
 		CookiesFile: cfg.CookiesFile,
 		ProxyURL:    cfg.ProxyURL,
+		RateLimit:   cfg.Resources.DownloadRateLimit,
 	}, logger)
 }
 
@@ -800,6 +948,11 @@ func provideTranscriberTool(logger *zap.Logger) *tools.BcutTranscriberTool {
 	return tools.NewBcutTranscriberTool(logger)
 }
 
+// provideWhisperASREngine 按 [workflow.asr.whisper] 构建 whisper 引擎；未选用 whisper 时不会被调用
+func provideWhisperASREngine(cfg config.WorkflowConfig) *tools.WhisperASREngine {
+	return tools.NewWhisperASREngine(whisperEngineConfig(cfg, ""))
+}
+
 func provideTranslatorTool(appCfg *config.AppConfig) *tools.MicrosoftTranslator {
 	return tools.NewMicrosoftTranslatorFromAppConfig(appCfg)
 }
@@ -7,7 +7,9 @@ import (
 
 	_ "github.com/difyz9/ytb2bili/docs" // Swagger docs
 	"github.com/difyz9/ytb2bili/internal/bootstrap"
+	"github.com/difyz9/ytb2bili/internal/config"
 	"github.com/difyz9/ytb2bili/internal/handler"
+	"go.uber.org/fx"
 )
 
 // 构建信息（通过 -ldflags 注入）
@@ -45,7 +47,8 @@ func main() {
 	log.Printf("🚀 YTB2BILI 启动中... 版本: %s, 构建时间: %s\n", Version, BuildTime)
 	
 	// 创建应用（配置会自动加载）
-	app := bootstrap.NewApp()
+	var appCfg *config.AppConfig
+	app := bootstrap.NewApp(fx.Populate(&appCfg))
 
 	// 启动应用（带超时）
 	startCtx, startCancel := context.WithTimeout(context.Background(), 15*time.Second)
@@ -61,8 +64,13 @@ func main() {
 	<-app.Done()
 	log.Println("\n🛑 收到终止信号，正在优雅关闭...")
 
-	// 停止应用（带超时）
-	stopCtx, stopCancel := context.WithTimeout(context.Background(), 15*time.Second)
+	// 停止应用（带超时）：后台任务先在步骤边界停下并写入检查点，
+	// 超过 [worker] shutdown_grace_seconds 仍未结束的步骤被取消，中断的视频重新排队
+	stopTimeout := 15 * time.Second
+	if appCfg != nil {
+		stopTimeout = appCfg.Worker.ShutdownTimeout()
+	}
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), stopTimeout)
 	defer stopCancel()
 
 	if err := app.Stop(stopCtx); err != nil {
@@ -1,10 +1,12 @@
 package bilibili
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/difyz9/ytb2bili/pkg/store/model"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 // UploadResult captures the persisted outcome of a successful Bilibili upload.
@@ -37,9 +39,25 @@ func (s *Service) PersistUploadResult(video *model.Video, result UploadResult) e
 		updates["generated_tags"] = result.Tags
 	}
 
-	if err := s.db.Model(video).Updates(updates).Error; err != nil {
+	_, err := model.TransitionVideoStatus(s.db, video.VideoID, model.VideoTransition{
+		To:      model.VideoStatusPublished,
+		Scope:   videoRow(video),
+		Updates: updates,
+		Reason:  "投稿成功 " + result.BiliBVID,
+		Actor:   video.UserID,
+	})
+	var invalid *model.InvalidVideoTransitionError
+	if errors.As(err, &invalid) {
+		// the upload already happened, so the result is stored even if the status cannot follow
+		s.logger.Warn("投稿后无法变更视频状态", zap.String("video_id", video.VideoID), zap.Error(err))
+		err = s.db.Model(video).Updates(updates).Error
+	}
+	if err != nil {
 		return fmt.Errorf("更新B站上传结果失败: %w", err)
 	}
+	if invalid == nil {
+		video.Status = model.VideoStatusPublished
+	}
 
 	video.BiliBVID = result.BiliBVID
 	video.BiliAID = result.BiliAID
@@ -56,3 +74,54 @@ func (s *Service) PersistUploadResult(video *model.Video, result UploadResult) e
 		zap.Bool("subtitle_pending", !video.BiliSubtitleUploaded))
 	return nil
 }
+
+// BeginUpload marks the video as uploading and returns the status it had before,
+// which AbortUpload restores when the upload fails.
+func (s *Service) BeginUpload(video *model.Video) (string, error) {
+	if video == nil {
+		return "", fmt.Errorf("视频不存在")
+	}
+	previous, err := model.TransitionVideoStatus(s.db, video.VideoID, model.VideoTransition{
+		To:     model.VideoStatusUploading,
+		Scope:  videoRow(video),
+		Reason: "开始投稿",
+		Actor:  video.UserID,
+	})
+	if err != nil {
+		return "", err
+	}
+	video.Status = model.VideoStatusUploading
+	return previous, nil
+}
+
+// AbortUpload restores the status recorded by BeginUpload after a failed upload.
+func (s *Service) AbortUpload(video *model.Video, previous string, cause error) {
+	if video == nil || previous == "" {
+		return
+	}
+	reason := "投稿失败"
+	if cause != nil {
+		reason += ": " + cause.Error()
+	}
+	if _, err := model.TransitionVideoStatus(s.db, video.VideoID, model.VideoTransition{
+		To:     previous,
+		From:   []string{model.VideoStatusUploading},
+		Scope:  videoRow(video),
+		Reason: reason,
+		Actor:  video.UserID,
+	}); err != nil {
+		s.logger.Warn("恢复投稿前的视频状态失败", zap.String("video_id", video.VideoID), zap.Error(err))
+		return
+	}
+	video.Status = previous
+}
+
+// videoRow limits a status transition to the given row, since several users may share a video_id.
+func videoRow(video *model.Video) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if video.ID == 0 {
+			return db
+		}
+		return db.Where("id = ?", video.ID)
+	}
+}
@@ -67,7 +67,19 @@ func (c *EinoChatClient) ChatWithOptions(ctx context.Context, messages []Message
 
 	resp, err := chatModel.Generate(ctx, toEinoMessages(messages))
 	if err != nil {
-		return "", err
+		if ctx.Err() != nil {
+			return "", err
+		}
+		modelName := c.modelName
+		if opts.Model != "" {
+			modelName = opts.Model
+		}
+		return "", &ProviderError{
+			Provider: c.baseURL,
+			Model:    modelName,
+			Reason:   ClassifyFailoverReason(err),
+			Original: err,
+		}
 	}
 	if resp == nil {
 		return "", fmt.Errorf("chat model returned nil response")
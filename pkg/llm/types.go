@@ -1,6 +1,12 @@
 package llm
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
 
 // ChatOptions controls per-request generation settings.
 type ChatOptions struct {
@@ -87,6 +93,46 @@ func (e *ProviderError) Error() string {
 		e.Provider, e.Model, e.Reason, e.Original)
 }
 
+func (e *ProviderError) Unwrap() error {
+	return e.Original
+}
+
+// ClassifyFailoverReason 根据底层错误推断失败原因
+func ClassifyFailoverReason(err error) FailoverReason {
+	if err == nil {
+		return FailoverOther
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return FailoverTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return FailoverTimeout
+		}
+		return FailoverNetwork
+	}
+
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "429") || strings.Contains(msg, "rate limit") || strings.Contains(msg, "too many requests"):
+		return FailoverRateLimit
+	case strings.Contains(msg, "401") || strings.Contains(msg, "403") || strings.Contains(msg, "unauthorized") || strings.Contains(msg, "invalid api key"):
+		return FailoverAuth
+	case strings.Contains(msg, "402") || strings.Contains(msg, "insufficient") || strings.Contains(msg, "quota"):
+		return FailoverBilling
+	case strings.Contains(msg, "context length") || strings.Contains(msg, "maximum context") || strings.Contains(msg, "context_length_exceeded"):
+		return FailoverContext
+	case strings.Contains(msg, "timeout") || strings.Contains(msg, "timed out"):
+		return FailoverTimeout
+	case strings.Contains(msg, "connection") || strings.Contains(msg, "eof") ||
+		strings.Contains(msg, "500") || strings.Contains(msg, "502") || strings.Contains(msg, "503") || strings.Contains(msg, "504"):
+		return FailoverNetwork
+	default:
+		return FailoverOther
+	}
+}
+
 // 常用 Vendor 前缀
 const (
 	VendorOpenAI     = "openai"
@@ -16,6 +16,11 @@ func MigrateDatabase(db *gorm.DB) error {
 		&model.User{},
 		&model.Video{},             // 视频元数据
 		&model.TaskStep{},          // 任务步骤
+		&model.TaskRun{},           // 任务链运行历史
+		&model.TaskStepAttempt{},   // 步骤执行历史
+		&model.VideoStatusTransition{}, // 视频状态变更历史
+		&model.VideoCheckpoint{},   // 视频处理上下文检查点
+		&model.StepCacheEntry{},    // 步骤结果缓存
 		&model.App{},               // 应用
 		&model.UserToken{},         // 用户令牌
 		&model.UserPreference{},    // 用户偏好设置
@@ -49,6 +54,11 @@ func MigrateDatabase(db *gorm.DB) error {
 		fmt.Printf("⚠️  添加 bili_subtitle_uploaded 列时跳过: %v\n", err)
 	}
 
+	// 2c. 旧状态码（001-004、paused 等）迁移为状态机中的状态
+	if err := model.MigrateLegacyVideoStatuses(db); err != nil {
+		return fmt.Errorf("迁移视频状态失败: %w", err)
+	}
+
 	// 3. 初始化种子数据（如初始用户）
 	fmt.Println("📝 检查初始数据...")
 	return seedInitialData(db)
@@ -88,10 +88,18 @@ type Video struct {
 	Description string     `gorm:"type:text" json:"description"`                  // 视频描述
 	Thumbnail   string     `gorm:"size:500" json:"thumbnail"`                     // 缩略图URL
 	Duration    float64    `gorm:"type:float" json:"duration"`                    // 视频时长（秒）
-	Status      string     `gorm:"size:20;index" json:"status"`                   // 处理状态: 001=待处理/002=处理中/003=已完成/004=失败
+	Status      string     `gorm:"size:20;index" json:"status"`                   // 处理状态，见 VideoStatus* 与 TransitionVideoStatus
 	RetryCount  int        `gorm:"default:0" json:"retry_count"`                  // 重试次数
+	Priority    int        `gorm:"default:0;index" json:"priority"`               // 调度优先级，越大越先处理，见 VideoPriority*
 	PublishedAt *time.Time `gorm:"column:published_at;index" json:"published_at"` // 视频发布时间（YouTube等平台的原始发布时间）
 
+	StatusChangedAt *time.Time `gorm:"column:status_changed_at" json:"status_changed_at,omitempty"` // 最近一次状态变更时间，历史见 tb_video_status_transitions
+
+	// 多实例租约：认领视频的 worker 与租约到期时间，处理中定期续约
+	LeaseOwner       string     `gorm:"column:lease_owner;size:128;index" json:"lease_owner,omitempty"`
+	LeaseExpiresAt   *time.Time `gorm:"column:lease_expires_at;index" json:"lease_expires_at,omitempty"`
+	LeaseHeartbeatAt *time.Time `gorm:"column:lease_heartbeat_at" json:"lease_heartbeat_at,omitempty"`
+
 	// AI生成的元数据
 	GeneratedTitle  string `gorm:"size:500" json:"generated_title"`                          // AI生成的标题
 	GeneratedDesc   string `gorm:"type:text" json:"generated_desc"`                          // AI生成的描述
@@ -104,13 +112,21 @@ type Video struct {
 	BiliAID              int64  `gorm:"column:bili_aid;index" json:"bili_aid"`                                     // B站AID
 	BiliSubtitleUploaded bool   `gorm:"column:bili_subtitle_uploaded;default:false" json:"bili_subtitle_uploaded"` // 字幕是否已上传到B站
 
+	// 发布前人工审核：状态见 ReviewStatus*，退回意见在重跑时传给翻译与元数据生成的提示词
+	ReviewStatus   string     `gorm:"column:review_status;size:20;index" json:"review_status"`
+	ReviewFeedback string     `gorm:"column:review_feedback;type:text" json:"review_feedback"`
+	ReviewedBy     string     `gorm:"column:reviewed_by;size:128" json:"reviewed_by,omitempty"`
+	ReviewedAt     *time.Time `gorm:"column:reviewed_at" json:"reviewed_at,omitempty"`
+
 	// 文件路径
 	VideoPath           string `gorm:"column:video_path;size:500" json:"video_path"`                    // 本地视频文件路径
 	VideoSizeBytes      int64  `gorm:"column:video_size_bytes;default:0" json:"video_size_bytes"`       // 本地视频文件大小（字节）
 	SubtitlePath        string `gorm:"column:subtitle_path;size:500" json:"subtitle_path"`              // 字幕文件路径
+	TranscriptSource    string `gorm:"column:transcript_source;size:32" json:"transcript_source"`       // 字幕来源：平台字幕（captions_manual/captions_auto）或实际使用的转写引擎（bcut/whisper/whisper_api/whisper_local）
 	PreferredResolution string `gorm:"column:preferred_resolution;size:20" json:"preferred_resolution"` // 期望下载分辨率: best/720p/1080p/1440p/2160p
 	SpeechVoiceName     string `gorm:"column:speech_voice_name;size:100" json:"speech_voice_name"`      // 本次任务使用的字幕配音音色
 	TaskChainSettings   string `gorm:"column:task_chain_settings;type:text" json:"-"`                   // 提交时任务链快照
+	WorkflowProfile     string `gorm:"column:workflow_profile;size:64" json:"workflow_profile"`         // 使用的工作流配置名，空表示内置流程
 
 	// 用户提交的额外字段
 	OperationType string `gorm:"column:operation_type;size:50" json:"operation_type"` // 操作类型
@@ -125,6 +141,12 @@ func (Video) TableName() string {
 	return "tb_videos"
 }
 
+// HasActiveLease 视频是否正被某个 worker 以未过期的租约处理
+func (v *Video) HasActiveLease(now time.Time) bool {
+	return IsVideoStatusActive(v.Status) && v.LeaseOwner != "" &&
+		v.LeaseExpiresAt != nil && v.LeaseExpiresAt.After(now)
+}
+
 // App 应用/客户端模型 - API密钥管理
 type App struct {
 	BaseModel
@@ -182,6 +204,7 @@ type TbSubscription struct {
 	SubscribedAt        time.Time `json:"subscribed_at"`                                              // 订阅时间
 	Status              string    `gorm:"size:20;default:active" json:"status"`                       // 状态: active/inactive
 	SyncedAt            time.Time `json:"synced_at"`                                                  // 最后同步时间
+	WorkflowProfile     string    `gorm:"size:64" json:"workflow_profile"`                            // 该频道视频使用的工作流配置名
 }
 
 // TableName 指定表名
@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// StepCacheEntry 步骤结果缓存
+// 以步骤输入的内容哈希为键（如音频文件哈希、字幕文本 + 语言对 + 模型），
+// 相同输入再次出现时直接复用结果，跳过 ASR、翻译、TTS 等耗时步骤。
+type StepCacheEntry struct {
+	BaseModel
+	StepName  string     `gorm:"size:100;uniqueIndex:idx_step_cache_key;not null" json:"step_name"` // 步骤名
+	CacheKey  string     `gorm:"size:64;uniqueIndex:idx_step_cache_key;not null" json:"cache_key"`  // 输入内容的 SHA-256
+	Payload   string     `gorm:"type:mediumtext" json:"-"`                                          // 结果（JSON）
+	FilePath  string     `gorm:"size:500" json:"file_path"`                                         // 磁盘上的缓存文件（如 TTS 音频），可为空
+	SizeBytes int64      `json:"size_bytes"`                                                        // 结果 + 缓存文件大小
+	HitCount  int64      `gorm:"default:0" json:"hit_count"`                                        // 命中次数
+	LastHitAt *time.Time `json:"last_hit_at,omitempty"`                                             // 最近一次命中时间
+}
+
+// TableName 指定表名
+func (StepCacheEntry) TableName() string {
+	return "tb_step_cache"
+}
@@ -0,0 +1,61 @@
+package model
+
+import "time"
+
+// TaskRun 一次任务链运行（首次处理、续跑或单步重试），tb_task_steps 只保留最新状态，历史记录在这里
+type TaskRun struct {
+	BaseModel
+	RunID     string     `gorm:"size:64;uniqueIndex;not null" json:"run_id"` // 运行 ID
+	VideoID   string     `gorm:"size:100;index;not null" json:"video_id"`    // 关联的视频ID
+	Trigger   string     `gorm:"size:20" json:"trigger"`                     // 触发方式: process/resume/step_retry
+	Status    string     `gorm:"size:20;not null" json:"status"`             // 状态: running/completed/failed/cancelled/interrupted
+	StartTime *time.Time `json:"start_time"`                                 // 开始时间
+	EndTime   *time.Time `json:"end_time"`                                   // 结束时间
+	Duration  int64      `gorm:"default:0" json:"duration"`                  // 执行时长（毫秒）
+	ErrorMsg  string     `gorm:"type:text" json:"error_msg"`                 // 错误信息
+
+	Attempts []TaskStepAttempt `gorm:"-" json:"attempts,omitempty"` // 本次运行的步骤尝试，查询时填充
+}
+
+// TableName 指定表名
+func (TaskRun) TableName() string {
+	return "tb_task_runs"
+}
+
+// TaskStepAttempt 步骤的一次执行记录；Attempt 为该视频该步骤的累计执行序号
+type TaskStepAttempt struct {
+	BaseModel
+	RunID        string     `gorm:"size:64;index;not null" json:"run_id"`                           // 所属运行
+	VideoID      string     `gorm:"size:100;index:idx_attempt_video_step;not null" json:"video_id"` // 关联的视频ID
+	StepName     string     `gorm:"size:100;index:idx_attempt_video_step;not null" json:"step_name"`
+	Attempt      int        `gorm:"not null" json:"attempt"`        // 第几次执行（从 1 开始）
+	Status       string     `gorm:"size:20;not null" json:"status"` // 状态: running/completed/failed/skipped/timeout/cancelled/interrupted
+	StartTime    *time.Time `gorm:"index" json:"start_time"`        // 开始时间
+	EndTime      *time.Time `json:"end_time"`                       // 结束时间
+	Duration     int64      `gorm:"default:0" json:"duration"`      // 执行时长（毫秒）
+	Provider     string     `gorm:"size:64" json:"provider"`        // 使用的服务商（如 bcut、llm、azure）
+	Model        string     `gorm:"size:128" json:"model"`          // 使用的模型或音色
+	ProgressText string     `gorm:"size:255" json:"progress_text"`  // 完成说明（如缓存命中）
+	ErrorMsg     string     `gorm:"type:text" json:"error_msg"`     // 错误信息
+}
+
+// TableName 指定表名
+func (TaskStepAttempt) TableName() string {
+	return "tb_task_step_attempts"
+}
+
+// 运行状态常量
+const (
+	TaskRunStatusRunning     = "running"
+	TaskRunStatusCompleted   = "completed"
+	TaskRunStatusFailed      = "failed"
+	TaskRunStatusCancelled   = "cancelled"
+	TaskRunStatusInterrupted = "interrupted" // 服务停止或进程退出时仍在运行
+)
+
+// 运行触发方式
+const (
+	TaskRunTriggerProcess   = "process"    // 首次处理
+	TaskRunTriggerResume    = "resume"     // 续跑（含重启恢复、审核退回）
+	TaskRunTriggerStepRetry = "step_retry" // 单步重试
+)
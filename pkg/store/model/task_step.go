@@ -8,7 +8,7 @@ type TaskStep struct {
 	VideoID         string     `gorm:"size:100;index;not null" json:"video_id"` // 关联的视频ID
 	StepName        string     `gorm:"size:100;not null" json:"step_name"`      // 步骤名称
 	StepOrder       int        `gorm:"not null" json:"step_order"`              // 步骤顺序
-	Status          string     `gorm:"size:20;not null" json:"status"`          // 状态: pending/running/completed/failed/skipped
+	Status          string     `gorm:"size:20;not null" json:"status"`          // 状态: pending/running/completed/failed/skipped/timeout/cancelled/interrupted
 	StartTime       *time.Time `json:"start_time"`                              // 开始时间
 	EndTime         *time.Time `json:"end_time"`                                // 结束时间
 	Duration        int64      `gorm:"default:0" json:"duration"`               // 执行时长（毫秒）
@@ -25,9 +25,12 @@ func (TaskStep) TableName() string {
 
 // 任务步骤状态常量
 const (
-	TaskStepStatusPending   = "pending"   // 待执行
-	TaskStepStatusRunning   = "running"   // 执行中
-	TaskStepStatusCompleted = "completed" // 已完成
-	TaskStepStatusFailed    = "failed"    // 失败
-	TaskStepStatusSkipped   = "skipped"   // 跳过
+	TaskStepStatusPending     = "pending"     // 待执行
+	TaskStepStatusRunning     = "running"     // 执行中
+	TaskStepStatusCompleted   = "completed"   // 已完成
+	TaskStepStatusFailed      = "failed"      // 失败
+	TaskStepStatusSkipped     = "skipped"     // 跳过
+	TaskStepStatusTimeout     = "timeout"     // 超时
+	TaskStepStatusCancelled   = "cancelled"   // 已取消（执行中被停止）
+	TaskStepStatusInterrupted = "interrupted" // 服务停止或进程退出时仍在执行，重新排队后从此步骤继续
 )
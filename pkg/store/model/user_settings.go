@@ -3,6 +3,8 @@ package model
 import (
 	"encoding/json"
 	"fmt"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -29,6 +31,11 @@ const (
 	UserSettingKeyBIDDefaultTone           = "bid_default_tone"
 	UserSettingKeyBIDTemplateStyle         = "bid_template_style"
 	UserSettingKeyAssistantSystemPrompt    = "assistant_system_prompt"
+	UserSettingKeyWorkflowProfile          = "workflow_profile"
+	UserSettingKeyReviewBeforeUpload       = "review_before_upload"
+	UserSettingKeyASRProvider              = "asr_provider"
+	UserSettingKeyWhisperOptions           = "whisper_options"
+	UserSettingKeyTranscriptSourceOrder    = "transcript_source_order"
 	// LLM provider settings (user-configurable)
 	UserSettingKeyLLMProvider    = "llm_provider"
 	UserSettingKeyLLMBaseURL     = "llm_base_url"
@@ -87,6 +94,24 @@ var allowedUserSettingKeys = map[string]struct{}{
 	UserSettingKeyBIDDefaultTone:           {},
 	UserSettingKeyBIDTemplateStyle:         {},
 	UserSettingKeyAssistantSystemPrompt:    {},
+	UserSettingKeyWorkflowProfile:          {},
+	UserSettingKeyReviewBeforeUpload:       {},
+	UserSettingKeyASRProvider:              {},
+	UserSettingKeyWhisperOptions:           {},
+	UserSettingKeyTranscriptSourceOrder:    {},
+}
+
+var allowedASRProviders = map[string]struct{}{
+	"bcut":          {},
+	"whisper":       {},
+	"whisper_api":   {},
+	"whisper_local": {},
+}
+
+var allowedTranscriptSources = map[string]struct{}{
+	"manual": {}, // 作者上传的平台字幕
+	"asr":    {}, // 语音识别
+	"auto":   {}, // 平台自动生成的字幕
 }
 
 type UserSettings struct {
@@ -227,6 +252,47 @@ func (settings *UserSettings) ApplySettingsPatch(patch map[string]string) error
 				return fmt.Errorf("invalid watermark promo value: %s", value)
 			}
 			extra[key] = boolToSettingValue(enabled)
+		case UserSettingKeyReviewBeforeUpload:
+			// 为空表示沿用服务端 [workflow.review] 的默认值
+			if value == "" {
+				delete(extra, key)
+				continue
+			}
+			enabled, err := parseBoolSettingValue(value)
+			if err != nil {
+				return fmt.Errorf("invalid review before upload value: %s", value)
+			}
+			extra[key] = boolToSettingValue(enabled)
+		case UserSettingKeyASRProvider:
+			// 为空表示沿用服务端 [workflow.asr] 的默认引擎
+			if value == "" {
+				delete(extra, key)
+				continue
+			}
+			if _, ok := allowedASRProviders[strings.ToLower(value)]; !ok {
+				return fmt.Errorf("unsupported asr provider: %s", value)
+			}
+			extra[key] = strings.ToLower(value)
+		case UserSettingKeyWhisperOptions:
+			if value == "" {
+				delete(extra, key)
+				continue
+			}
+			if err := validateWhisperOptionsJSON(value); err != nil {
+				return err
+			}
+			extra[key] = value
+		case UserSettingKeyTranscriptSourceOrder:
+			// 为空表示沿用服务端 [workflow.asr] source_order
+			if value == "" {
+				delete(extra, key)
+				continue
+			}
+			normalized, err := normalizeTranscriptSourceOrder(value)
+			if err != nil {
+				return err
+			}
+			extra[key] = normalized
 		default:
 			extra[key] = value
 		}
@@ -307,6 +373,69 @@ func isValidTaskChainSettingsJSON(value string) bool {
 		payload.SynthesizeSubtitleAudio != nil
 }
 
+var whisperModelNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// validateWhisperOptionsJSON 校验用户的 whisper 参数。模型只能按名称选择服务端模型目录中的文件，
+// 不接受模型或 VAD 模型的文件路径。
+func validateWhisperOptionsJSON(value string) error {
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(value), &payload); err != nil {
+		return fmt.Errorf("invalid whisper options payload")
+	}
+	var options struct {
+		Model          string  `json:"model"`
+		Language       string  `json:"language"`
+		Threads        int     `json:"threads"`
+		BeamSize       int     `json:"beam_size"`
+		Prompt         string  `json:"prompt"`
+		WordTimestamps *bool   `json:"word_timestamps"`
+		VAD            *bool   `json:"vad"`
+		VADThreshold   float64 `json:"vad_threshold"`
+	}
+	for field := range payload {
+		switch field {
+		case "model", "language", "threads", "beam_size", "prompt", "word_timestamps", "vad", "vad_threshold":
+		default:
+			return fmt.Errorf("unsupported whisper option: %s", field)
+		}
+	}
+	if err := json.Unmarshal([]byte(value), &options); err != nil {
+		return fmt.Errorf("invalid whisper options payload")
+	}
+	if options.Model != "" && !whisperModelNamePattern.MatchString(options.Model) {
+		return fmt.Errorf("invalid whisper model: %s", options.Model)
+	}
+	if options.Threads < 0 || options.Threads > 64 {
+		return fmt.Errorf("invalid whisper threads: %d", options.Threads)
+	}
+	if options.BeamSize < 0 || options.BeamSize > 16 {
+		return fmt.Errorf("invalid whisper beam size: %d", options.BeamSize)
+	}
+	if options.VADThreshold < 0 || options.VADThreshold > 1 {
+		return fmt.Errorf("invalid whisper vad threshold: %v", options.VADThreshold)
+	}
+	if len([]rune(options.Prompt)) > 1000 {
+		return fmt.Errorf("whisper prompt too long")
+	}
+	return nil
+}
+
+// normalizeTranscriptSourceOrder 校验字幕来源优先级（逗号分隔的 manual / asr / auto），返回规范化的值
+func normalizeTranscriptSourceOrder(value string) (string, error) {
+	var sources []string
+	for _, source := range strings.Split(value, ",") {
+		source = strings.ToLower(strings.TrimSpace(source))
+		if _, ok := allowedTranscriptSources[source]; !ok {
+			return "", fmt.Errorf("unsupported transcript source: %s", source)
+		}
+		if slices.Contains(sources, source) {
+			return "", fmt.Errorf("duplicate transcript source: %s", source)
+		}
+		sources = append(sources, source)
+	}
+	return strings.Join(sources, ","), nil
+}
+
 func isValidPlaylistSubmissionConfigJSON(value string) bool {
 	var payload struct {
 		Enabled    *bool `json:"enabled"`
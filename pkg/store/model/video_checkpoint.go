@@ -0,0 +1,17 @@
+package model
+
+// VideoCheckpoint 视频处理上下文检查点
+// 任务链每完成一个步骤即覆盖写入，用于进程重启或单步重试时恢复 VideoContext，
+// 避免重复执行 ASR、翻译等耗时步骤。
+type VideoCheckpoint struct {
+	BaseModel
+	VideoID        string `gorm:"size:100;uniqueIndex;not null" json:"video_id"` // 关联的视频ID
+	LastStep       string `gorm:"size:100" json:"last_step"`                     // 最近一次写入检查点的步骤
+	CompletedSteps string `gorm:"type:text" json:"completed_steps"`              // 已完成步骤（JSON 数组）
+	Payload        string `gorm:"type:mediumtext" json:"-"`                      // VideoContext 快照（JSON）
+}
+
+// TableName 指定表名
+func (VideoCheckpoint) TableName() string {
+	return "tb_video_checkpoints"
+}
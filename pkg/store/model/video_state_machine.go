@@ -0,0 +1,215 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ============================================================================
+// 视频状态机
+// ============================================================================
+//
+// tb_videos.status 的所有变更都经过 TransitionVideoStatus：校验变更是否合法、
+// 以当前状态为条件更新（并发写入时只有一方成功），并在 tb_video_status_transitions 记录历史。
+//
+//	synced ──▶ queued ──▶ downloading ──▶ processing ──▶ ready ──▶ uploading ──▶ published
+//	                                          │            ▲           │
+//	                                          └──▶ awaiting_review ─────┘（审核通过）
+//	任意执行中状态 ──▶ failed / cancelled ──▶ queued / processing（续跑）
+
+// videoStatusTransitions 允许的状态变更，键为当前状态
+var videoStatusTransitions = map[string][]string{
+	VideoStatusSynced: {VideoStatusQueued, VideoStatusDownloading, VideoStatusProcessing, VideoStatusCancelled},
+	VideoStatusQueued: {VideoStatusDownloading, VideoStatusProcessing, VideoStatusFailed, VideoStatusCancelled},
+	VideoStatusDownloading: {
+		VideoStatusProcessing, VideoStatusQueued, VideoStatusFailed, VideoStatusCancelled,
+	},
+	VideoStatusProcessing: {
+		VideoStatusDownloading, VideoStatusAwaitingReview, VideoStatusReady, VideoStatusPublished,
+		VideoStatusQueued, VideoStatusFailed, VideoStatusCancelled,
+	},
+	VideoStatusAwaitingReview: {
+		VideoStatusReady, VideoStatusUploading, VideoStatusQueued, VideoStatusDownloading, VideoStatusProcessing,
+		VideoStatusFailed, VideoStatusCancelled,
+	},
+	VideoStatusReady: {
+		VideoStatusUploading, VideoStatusQueued, VideoStatusDownloading, VideoStatusProcessing, VideoStatusCancelled,
+	},
+	VideoStatusUploading: {VideoStatusPublished, VideoStatusReady, VideoStatusAwaitingReview, VideoStatusFailed, VideoStatusCancelled},
+	VideoStatusPublished: {
+		VideoStatusUploading, VideoStatusQueued, VideoStatusDownloading, VideoStatusProcessing,
+	},
+	VideoStatusFailed: {
+		VideoStatusQueued, VideoStatusDownloading, VideoStatusProcessing, VideoStatusCancelled,
+	},
+	VideoStatusCancelled: {
+		VideoStatusQueued, VideoStatusDownloading, VideoStatusProcessing, VideoStatusFailed,
+	},
+}
+
+// CanTransitionVideoStatus 是否允许从 from 变更到 to；状态不变视为允许
+func CanTransitionVideoStatus(from, to string) bool {
+	from, to = NormalizeVideoStatus(from), NormalizeVideoStatus(to)
+	if from == to {
+		return true
+	}
+	if from == "" {
+		// 没有状态的旧记录可进入任意状态
+		_, known := videoStatusTransitions[to]
+		return known
+	}
+	return containsStatus(videoStatusTransitions[from], to)
+}
+
+// ErrVideoStatusConflict 当前状态不满足变更条件（已被其他请求或实例改变）
+var ErrVideoStatusConflict = errors.New("video status changed concurrently")
+
+// InvalidVideoTransitionError 不允许的状态变更
+type InvalidVideoTransitionError struct {
+	VideoID string
+	From    string
+	To      string
+}
+
+func (e *InvalidVideoTransitionError) Error() string {
+	return fmt.Sprintf("视频 %s 不能从 %s 变为 %s", e.VideoID, e.From, e.To)
+}
+
+// VideoStatusTransition 状态变更历史
+type VideoStatusTransition struct {
+	BaseModel
+	VideoRowID uint   `gorm:"index;not null" json:"video_row_id"`      // tb_videos.id
+	VideoID    string `gorm:"size:100;index;not null" json:"video_id"` // 关联的视频ID
+	FromStatus string `gorm:"size:20" json:"from_status"`
+	ToStatus   string `gorm:"size:20;not null" json:"to_status"`
+	Reason     string `gorm:"size:255" json:"reason"` // 变更原因
+	Actor      string `gorm:"size:128" json:"actor"`  // 触发方：用户ID、实例ID 或 system
+}
+
+// TableName 指定表名
+func (VideoStatusTransition) TableName() string {
+	return "tb_video_status_transitions"
+}
+
+// VideoTransition 一次状态变更请求
+type VideoTransition struct {
+	To      string
+	From    []string                // 非空时仅当当前状态属于其中才变更，否则返回 ErrVideoStatusConflict
+	Scope   func(*gorm.DB) *gorm.DB // 额外的行条件（如 id、租约持有者），无匹配行时返回 ErrVideoStatusConflict
+	Updates map[string]interface{}  // 同时写入的其他列
+	Reason  string
+	Actor   string
+}
+
+// TransitionVideoStatus 按状态机变更 video_id 对应视频的状态（同一 video_id 可能有多个用户的记录，逐条变更），
+// 返回变更前的状态。状态不变时只写入 Updates，不记录历史。
+func TransitionVideoStatus(db *gorm.DB, videoID string, t VideoTransition) (string, error) {
+	to := NormalizeVideoStatus(t.To)
+	if videoID == "" || to == "" {
+		return "", fmt.Errorf("video id and target status are required")
+	}
+	var previous string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(&Video{}).Select("id", "status").Where("video_id = ?", videoID)
+		if t.Scope != nil {
+			query = query.Scopes(t.Scope)
+		}
+		var rows []Video
+		if err := query.Find(&rows).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			if t.Scope != nil {
+				return ErrVideoStatusConflict
+			}
+			return gorm.ErrRecordNotFound
+		}
+
+		now := time.Now()
+		for _, row := range rows {
+			from := NormalizeVideoStatus(row.Status)
+			previous = from
+			if len(t.From) > 0 && !containsStatus(normalizeStatuses(t.From), from) {
+				return ErrVideoStatusConflict
+			}
+			if !CanTransitionVideoStatus(from, to) {
+				return &InvalidVideoTransitionError{VideoID: videoID, From: from, To: to}
+			}
+
+			values := make(map[string]interface{}, len(t.Updates)+2)
+			for key, value := range t.Updates {
+				values[key] = value
+			}
+			values["status"] = to
+			if from != to {
+				values["status_changed_at"] = now
+			}
+			// 以读取时的原始状态为条件，期间被其他写入改变时放弃
+			update := tx.Model(&Video{}).Where("id = ? AND status = ?", row.ID, row.Status)
+			if t.Scope != nil {
+				update = update.Scopes(t.Scope)
+			}
+			result := update.Updates(values)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrVideoStatusConflict
+			}
+			if from == to {
+				continue
+			}
+			if err := tx.Create(&VideoStatusTransition{
+				VideoRowID: row.ID,
+				VideoID:    videoID,
+				FromStatus: from,
+				ToStatus:   to,
+				Reason:     truncateStatusText(t.Reason, 255),
+				Actor:      truncateStatusText(t.Actor, 128),
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return previous, err
+}
+
+func normalizeStatuses(statuses []string) []string {
+	out := make([]string, len(statuses))
+	for i, s := range statuses {
+		out[i] = NormalizeVideoStatus(s)
+	}
+	return out
+}
+
+// truncateStatusText 按字符截断到列长度（varchar 按字符计），不会截断半个多字节字符产生非法 UTF-8
+func truncateStatusText(s string, max int) string {
+	s = strings.TrimSpace(s)
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max])
+}
+
+// MigrateLegacyVideoStatuses 把旧状态码（001-004、paused、ready 等）改写为当前状态；
+// 已投稿的已完成视频迁移为 published。可重复执行。
+func MigrateLegacyVideoStatuses(db *gorm.DB) error {
+	done := []string{"003", "completed", "processed", VideoStatusReady}
+	if err := db.Model(&Video{}).
+		Where("status IN ? AND bili_bvid <> '' AND bili_bvid IS NOT NULL", done).
+		Update("status", VideoStatusPublished).Error; err != nil {
+		return err
+	}
+	for legacy, current := range legacyVideoStatuses {
+		if err := db.Model(&Video{}).Where("status = ?", legacy).Update("status", current).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package model
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func openStatusTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&Video{}, &VideoStatusTransition{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func TestCanTransitionVideoStatus(t *testing.T) {
+	allowed := [][2]string{
+		{VideoStatusQueued, VideoStatusProcessing},
+		{VideoStatusProcessing, VideoStatusAwaitingReview},
+		{VideoStatusAwaitingReview, VideoStatusReady},
+		{VideoStatusReady, VideoStatusUploading},
+		{VideoStatusUploading, VideoStatusPublished},
+		{VideoStatusCancelled, VideoStatusQueued},
+		{"004", VideoStatusProcessing}, // 旧状态码按当前状态判断
+		{"", VideoStatusQueued},
+	}
+	for _, c := range allowed {
+		if !CanTransitionVideoStatus(c[0], c[1]) {
+			t.Errorf("expected %s -> %s to be allowed", c[0], c[1])
+		}
+	}
+	denied := [][2]string{
+		{VideoStatusQueued, VideoStatusPublished},
+		{VideoStatusReady, VideoStatusFailed},
+		{VideoStatusPublished, VideoStatusCancelled},
+		{VideoStatusProcessing, "unknown"},
+	}
+	for _, c := range denied {
+		if CanTransitionVideoStatus(c[0], c[1]) {
+			t.Errorf("expected %s -> %s to be rejected", c[0], c[1])
+		}
+	}
+}
+
+func TestTransitionVideoStatus_RecordsHistory(t *testing.T) {
+	db := openStatusTestDB(t)
+	db.Create(&Video{VideoID: "v1", UserID: "u1", Status: VideoStatusQueued})
+
+	previous, err := TransitionVideoStatus(db, "v1", VideoTransition{
+		To: VideoStatusProcessing, Updates: map[string]interface{}{"retry_count": 1}, Reason: "claim", Actor: "worker-a",
+	})
+	if err != nil || previous != VideoStatusQueued {
+		t.Fatalf("transition: previous=%q err=%v", previous, err)
+	}
+	var video Video
+	db.Where("video_id = ?", "v1").First(&video)
+	if video.Status != VideoStatusProcessing || video.RetryCount != 1 || video.StatusChangedAt == nil {
+		t.Fatalf("unexpected video after transition: %+v", video)
+	}
+
+	// 状态不变只写入其余字段，不记录历史
+	if _, err := TransitionVideoStatus(db, "v1", VideoTransition{To: VideoStatusProcessing, Updates: map[string]interface{}{"retry_count": 2}}); err != nil {
+		t.Fatalf("same-status transition: %v", err)
+	}
+	var history []VideoStatusTransition
+	db.Where("video_id = ?", "v1").Find(&history)
+	if len(history) != 1 || history[0].FromStatus != VideoStatusQueued || history[0].ToStatus != VideoStatusProcessing || history[0].Actor != "worker-a" {
+		t.Fatalf("unexpected history: %+v", history)
+	}
+}
+
+func TestTransitionVideoStatus_RejectsInvalidAndConflicting(t *testing.T) {
+	db := openStatusTestDB(t)
+	db.Create(&Video{VideoID: "v2", Status: VideoStatusReady})
+
+	_, err := TransitionVideoStatus(db, "v2", VideoTransition{To: VideoStatusFailed})
+	var invalid *InvalidVideoTransitionError
+	if !errors.As(err, &invalid) || invalid.From != VideoStatusReady {
+		t.Fatalf("expected invalid transition error, got %v", err)
+	}
+
+	if _, err := TransitionVideoStatus(db, "v2", VideoTransition{
+		To: VideoStatusReady, From: []string{VideoStatusAwaitingReview},
+	}); !errors.Is(err, ErrVideoStatusConflict) {
+		t.Fatalf("expected conflict for unmet From, got %v", err)
+	}
+	if _, err := TransitionVideoStatus(db, "v2", VideoTransition{
+		To:    VideoStatusUploading,
+		Scope: func(db *gorm.DB) *gorm.DB { return db.Where("lease_owner = ?", "other") },
+	}); !errors.Is(err, ErrVideoStatusConflict) {
+		t.Fatalf("expected conflict for unmatched scope, got %v", err)
+	}
+	if _, err := TransitionVideoStatus(db, "missing", VideoTransition{To: VideoStatusQueued}); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected record not found, got %v", err)
+	}
+
+	var video Video
+	db.Where("video_id = ?", "v2").First(&video)
+	if video.Status != VideoStatusReady {
+		t.Fatalf("rejected transitions must not change status, got %q", video.Status)
+	}
+}
+
+func TestTransitionVideoStatus_TruncatesLongReasonByCharacter(t *testing.T) {
+	db := openStatusTestDB(t)
+	db.Create(&Video{VideoID: "v3", Status: VideoStatusProcessing})
+
+	// 中文字符占 3 字节，按字节截断会切在字符中间
+	reason := "投稿失败: " + strings.Repeat("上传分片时服务器返回错误，", 40)
+	if _, err := TransitionVideoStatus(db, "v3", VideoTransition{To: VideoStatusFailed, Reason: reason, Actor: "system"}); err != nil {
+		t.Fatalf("transition: %v", err)
+	}
+	var history VideoStatusTransition
+	if err := db.Where("video_id = ?", "v3").First(&history).Error; err != nil {
+		t.Fatalf("load history: %v", err)
+	}
+	if !utf8.ValidString(history.Reason) {
+		t.Fatalf("expected valid UTF-8 reason, got %q", history.Reason)
+	}
+	if n := utf8.RuneCountInString(history.Reason); n != 255 {
+		t.Fatalf("expected reason truncated to 255 characters, got %d", n)
+	}
+	if !strings.HasPrefix(reason, history.Reason) {
+		t.Fatalf("expected reason prefix to be kept, got %q", history.Reason)
+	}
+}
+
+func TestMigrateLegacyVideoStatuses(t *testing.T) {
+	db := openStatusTestDB(t)
+	db.Create(&Video{VideoID: "a", Status: "001"})
+	db.Create(&Video{VideoID: "b", Status: "003"})
+	db.Create(&Video{VideoID: "c", Status: "003", BiliBVID: "BV1xx"})
+	db.Create(&Video{VideoID: "d", Status: "paused"})
+	db.Create(&Video{VideoID: "e", Status: VideoStatusSynced})
+
+	for i := 0; i < 2; i++ {
+		if err := MigrateLegacyVideoStatuses(db); err != nil {
+			t.Fatalf("migrate: %v", err)
+		}
+	}
+	want := map[string]string{
+		"a": VideoStatusQueued,
+		"b": VideoStatusReady,
+		"c": VideoStatusPublished,
+		"d": VideoStatusCancelled,
+		"e": VideoStatusSynced,
+	}
+	for videoID, status := range want {
+		var video Video
+		db.Where("video_id = ?", videoID).First(&video)
+		if video.Status != status {
+			t.Errorf("%s: expected %q, got %q", videoID, status, video.Status)
+		}
+	}
+}
@@ -1,28 +1,131 @@
 package model
 
-// 视频处理状态常量
+import "strings"
+
+// 视频处理状态，状态之间的合法变更见 video_state_machine.go
 const (
-	VideoStatusPending    = "001" // 待处理
-	VideoStatusProcessing = "002" // 处理中
-	VideoStatusCompleted  = "003" // 已完成
-	VideoStatusFailed     = "004" // 失败
-	VideoStatusPaused     = "paused"
+	VideoStatusSynced         = "synced"          // 订阅同步发现，尚未加入处理队列
+	VideoStatusQueued         = "queued"          // 排队等待处理
+	VideoStatusDownloading    = "downloading"     // 正在下载源视频
+	VideoStatusProcessing     = "processing"      // 处理中（转写、翻译、配音等）
+	VideoStatusAwaitingReview = "awaiting_review" // 处理完成，等待人工审核后才会自动投稿
+	VideoStatusReady          = "ready"           // 处理完成，可投稿
+	VideoStatusUploading      = "uploading"       // 正在上传到B站
+	VideoStatusPublished      = "published"       // 已投稿到B站
+	VideoStatusFailed         = "failed"          // 失败
+	VideoStatusCancelled      = "cancelled"       // 已取消（用户主动停止，可继续处理）
+
+	// 兼容旧名称
+	VideoStatusPending   = VideoStatusQueued
+	VideoStatusCompleted = VideoStatusReady
 )
 
-// VideoStatusText 返回状态码对应的文本描述
+// legacyVideoStatuses 旧版本写入的状态值到当前状态的映射，迁移与查询参数兼容时使用
+var legacyVideoStatuses = map[string]string{
+	"001":       VideoStatusQueued,
+	"pending":   VideoStatusQueued,
+	"002":       VideoStatusProcessing,
+	"003":       VideoStatusReady,
+	"completed": VideoStatusReady,
+	"processed": VideoStatusReady,
+	"004":       VideoStatusFailed,
+	"paused":    VideoStatusCancelled,
+}
+
+// 按阶段分组的状态，列表页签与后台任务查询时使用
+var (
+	// VideoActiveStatuses 有任务正在执行的状态
+	VideoActiveStatuses = []string{VideoStatusDownloading, VideoStatusProcessing, VideoStatusUploading}
+	// VideoInProgressStatuses 排队中或执行中
+	VideoInProgressStatuses = []string{VideoStatusQueued, VideoStatusDownloading, VideoStatusProcessing, VideoStatusUploading}
+	// VideoDoneStatuses 处理已完成（含已投稿）
+	VideoDoneStatuses = []string{VideoStatusReady, VideoStatusPublished, VideoStatusSynced}
+	// VideoStoppedStatuses 失败或已取消，可继续处理
+	VideoStoppedStatuses = []string{VideoStatusFailed, VideoStatusCancelled}
+)
+
+// NormalizeVideoStatus 把旧版本的状态码（001-004、paused 等）转换为当前状态
+func NormalizeVideoStatus(status string) string {
+	status = strings.TrimSpace(status)
+	if current, ok := legacyVideoStatuses[status]; ok {
+		return current
+	}
+	return status
+}
+
+// IsVideoStatusActive 是否有任务正在执行
+func IsVideoStatusActive(status string) bool {
+	return containsStatus(VideoActiveStatuses, NormalizeVideoStatus(status))
+}
+
+// IsVideoStatusSettled 任务链是否已结束（完成、待审核、失败或取消），事件流据此结束
+func IsVideoStatusSettled(status string) bool {
+	switch NormalizeVideoStatus(status) {
+	case VideoStatusReady, VideoStatusPublished, VideoStatusAwaitingReview, VideoStatusFailed, VideoStatusCancelled:
+		return true
+	}
+	return false
+}
+
+func containsStatus(statuses []string, status string) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// VideoStatusText 返回状态对应的文本描述
 func VideoStatusText(status string) string {
-	switch status {
-	case VideoStatusPending:
+	switch NormalizeVideoStatus(status) {
+	case VideoStatusSynced:
+		return "已同步"
+	case VideoStatusQueued:
 		return "待处理"
+	case VideoStatusDownloading:
+		return "下载中"
 	case VideoStatusProcessing:
 		return "处理中"
-	case VideoStatusCompleted:
+	case VideoStatusAwaitingReview:
+		return "待审核"
+	case VideoStatusReady:
 		return "已完成"
+	case VideoStatusUploading:
+		return "投稿中"
+	case VideoStatusPublished:
+		return "已投稿"
 	case VideoStatusFailed:
 		return "失败"
-	case VideoStatusPaused:
-		return "已停止"
+	case VideoStatusCancelled:
+		return "已取消"
 	default:
 		return "未知状态"
 	}
 }
+
+// 发布前人工审核状态（tb_videos.review_status），空表示未经过审核关卡
+const (
+	ReviewStatusPending  = "pending"
+	ReviewStatusApproved = "approved"
+	ReviewStatusRejected = "rejected" // 已退回，按审核意见重跑所选步骤
+)
+
+// 视频调度优先级：数值越大越先被定时任务取出，同一优先级内按用户轮转
+const (
+	VideoPriorityAutoSync = -10 // 订阅同步、播放列表等批量导入
+	VideoPriorityNormal   = 0
+	VideoPriorityManual   = 10 // 用户手动提交的单个视频
+)
+
+// VideoPriorityFor 按提交方式给出默认调度优先级
+func VideoPriorityFor(operationType, playlistID string) int {
+	if strings.TrimSpace(operationType) == "auto_sync" || strings.TrimSpace(playlistID) != "" {
+		return VideoPriorityAutoSync
+	}
+	switch strings.TrimSpace(operationType) {
+	case "manual", "feishu":
+		return VideoPriorityManual
+	}
+	return VideoPriorityNormal
+}
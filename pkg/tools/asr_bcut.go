@@ -16,9 +16,12 @@ type BcutASREngine struct {
 }
 
 func NewBcutASREngine(logger *zap.Logger) *BcutASREngine {
-	return &BcutASREngine{
-		inner: NewBcutTranscriberTool(logger),
-	}
+	return NewBcutASREngineWithTool(NewBcutTranscriberTool(logger))
+}
+
+// NewBcutASREngineWithTool adapts an existing BcutTranscriberTool to ASREngine.
+func NewBcutASREngineWithTool(tool *BcutTranscriberTool) *BcutASREngine {
+	return &BcutASREngine{inner: tool}
 }
 
 func (e *BcutASREngine) Name() string {
@@ -30,8 +33,8 @@ func (e *BcutASREngine) Languages() []string {
 }
 
 func (e *BcutASREngine) Transcribe(ctx context.Context, audioPath string) (*TranscriptResult, error) {
-	input := fmt.Sprintf(`{"audio_path":%q}`, audioPath)
-	result, err := e.inner.Call(ctx, input)
+	// Call takes the audio path itself, not a JSON payload
+	result, err := e.inner.Call(ctx, audioPath)
 	if err != nil {
 		return nil, fmt.Errorf("bcut asr failed: %w", err)
 	}
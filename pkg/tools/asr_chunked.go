@@ -0,0 +1,576 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/difyz9/ytb2bili/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// ── Chunked ASR Orchestrator ────────────────────────────────────────────────
+// Long recordings (multi-hour streams) are too large for a single ASR request:
+// bcut uploads time out and whisper runs for hours on one core. ChunkedASREngine
+// wraps any ASREngine and
+//   1. finds silences with ffmpeg silencedetect,
+//   2. cuts the audio near every ChunkSeconds at the closest silence (or with a
+//      small overlap when there is none),
+//   3. transcribes the chunks concurrently,
+//   4. stitches the segments back with global offsets, dropping text repeated
+//      across an overlapping boundary.
+// Audio no longer than MaxChunkSeconds is passed to the inner engine unchanged.
+
+const (
+	defaultASRChunkSeconds   = 600.0
+	defaultASRSilenceNoiseDB = -35.0
+	defaultASRSilenceSeconds = 0.4
+	defaultASROverlapSeconds = 2.0
+	defaultASRConcurrency    = 3
+)
+
+var (
+	ffmpegDurationPattern = regexp.MustCompile(`Duration:\s*(\d+):(\d+):(\d+(?:\.\d+)?)`)
+	silenceStartPattern   = regexp.MustCompile(`silence_start:\s*(-?\d+(?:\.\d+)?)`)
+	silenceEndPattern     = regexp.MustCompile(`silence_end:\s*(-?\d+(?:\.\d+)?)`)
+)
+
+// ChunkedASRConfig configures silence-aware chunking. Zero values use the defaults.
+type ChunkedASRConfig struct {
+	FFmpegPath      string  // ffmpeg executable, looked up in PATH when empty
+	ChunkSeconds    float64 // target chunk length, default 600
+	MaxChunkSeconds float64 // longest chunk when no silence is near the target, default 1.5 × ChunkSeconds
+	SilenceNoiseDB  float64 // silencedetect noise floor in dB, default -35
+	SilenceSeconds  float64 // minimum silence length, default 0.4
+	OverlapSeconds  float64 // overlap added around cuts that do not fall in silence, default 2
+	Concurrency     int     // chunks transcribed in parallel, default 3
+	WorkDir         string  // parent directory of the temporary chunk files, default the audio file's directory
+}
+
+func (c ChunkedASRConfig) withDefaults() ChunkedASRConfig {
+	if c.ChunkSeconds <= 0 {
+		c.ChunkSeconds = defaultASRChunkSeconds
+	}
+	if c.MaxChunkSeconds < c.ChunkSeconds {
+		c.MaxChunkSeconds = c.ChunkSeconds * 1.5
+	}
+	if c.SilenceNoiseDB == 0 {
+		c.SilenceNoiseDB = defaultASRSilenceNoiseDB
+	}
+	if c.SilenceSeconds <= 0 {
+		c.SilenceSeconds = defaultASRSilenceSeconds
+	}
+	if c.OverlapSeconds < 0 {
+		c.OverlapSeconds = 0
+	} else if c.OverlapSeconds == 0 {
+		c.OverlapSeconds = defaultASROverlapSeconds
+	}
+	if c.Concurrency <= 0 {
+		c.Concurrency = defaultASRConcurrency
+	}
+	return c
+}
+
+// ChunkedASREngine splits long audio at silences and transcribes the chunks in parallel.
+type ChunkedASREngine struct {
+	engine     ASREngine
+	cfg        ChunkedASRConfig
+	ffmpegPath string
+	logger     *zap.Logger
+}
+
+// NewChunkedASREngine wraps engine. Without ffmpeg the audio is always transcribed in one piece.
+func NewChunkedASREngine(engine ASREngine, cfg ChunkedASRConfig, logger *zap.Logger) *ChunkedASREngine {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	cfg = cfg.withDefaults()
+	ffmpegPath := cfg.FFmpegPath
+	if ffmpegPath == "" {
+		if path, err := exec.LookPath("ffmpeg"); err == nil {
+			ffmpegPath = path
+		} else {
+			logger.Warn("ffmpeg not found, long audio will be transcribed without chunking")
+		}
+	}
+	return &ChunkedASREngine{engine: engine, cfg: cfg, ffmpegPath: ffmpegPath, logger: logger}
+}
+
+func (e *ChunkedASREngine) Name() string {
+	return e.engine.Name()
+}
+
+func (e *ChunkedASREngine) Languages() []string {
+	return e.engine.Languages()
+}
+
+// audioChunk is one piece of the source audio. [Start, End) is the range cut from
+// the file; Boundary is where the chunk's own part begins, Start < Boundary when
+// the chunk overlaps the previous one.
+type audioChunk struct {
+	Index    int
+	Start    float64
+	End      float64
+	Boundary float64
+}
+
+type silenceInterval struct {
+	Start float64
+	End   float64
+}
+
+func (e *ChunkedASREngine) Transcribe(ctx context.Context, audioPath string) (*TranscriptResult, error) {
+	if e.ffmpegPath == "" {
+		return e.engine.Transcribe(ctx, audioPath)
+	}
+
+	duration, err := e.probeDuration(ctx, audioPath)
+	if err != nil || duration <= e.cfg.MaxChunkSeconds {
+		if err != nil {
+			e.logger.Warn("Failed to probe audio duration, transcribing without chunking",
+				zap.String("audio", audioPath), zap.Error(err))
+		}
+		return e.engine.Transcribe(ctx, audioPath)
+	}
+
+	silences, err := e.detectSilences(ctx, audioPath, duration)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		// Without silence data, still hard-cut at the target length (with overlap); that beats submitting the whole file
+		e.logger.Warn("Silence detection failed, cutting at fixed positions", zap.Error(err))
+	}
+	chunks := planAudioChunks(duration, silences, e.cfg)
+	e.logger.Info("Transcribing audio in chunks",
+		zap.String("engine", e.engine.Name()),
+		zap.Float64("duration_seconds", duration),
+		zap.Int("silences", len(silences)),
+		zap.Int("chunks", len(chunks)),
+		zap.Int("concurrency", e.cfg.Concurrency))
+
+	parentDir := e.cfg.WorkDir
+	if parentDir == "" {
+		parentDir = filepath.Dir(audioPath)
+	}
+	workDir, err := os.MkdirTemp(parentDir, "asr-chunks-*")
+	if err != nil {
+		return nil, fmt.Errorf("asr chunking: create work dir: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	results, err := e.transcribeChunks(ctx, audioPath, workDir, chunks)
+	if err != nil {
+		return nil, err
+	}
+	return stitchChunkTranscripts(chunks, results), nil
+}
+
+// transcribeChunks cuts and transcribes chunks with bounded concurrency; the first
+// failure cancels the remaining chunks.
+func (e *ChunkedASREngine) transcribeChunks(ctx context.Context, audioPath, workDir string, chunks []audioChunk) ([]*TranscriptResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]*TranscriptResult, len(chunks))
+	progress := newChunkProgress(ctx, chunks)
+	sem := make(chan struct{}, e.cfg.Concurrency)
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	for _, chunk := range chunks {
+		wg.Add(1)
+		go func(chunk audioChunk) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-sem }()
+
+			chunkPath, err := e.cutChunk(ctx, audioPath, workDir, chunk)
+			if err != nil {
+				fail(err)
+				return
+			}
+			defer os.Remove(chunkPath)
+
+			chunkCtx := WithASRProgressReporter(ctx, func(percent int) {
+				progress.update(chunk.Index, percent)
+			})
+			result, err := e.engine.Transcribe(chunkCtx, chunkPath)
+			if err != nil {
+				fail(fmt.Errorf("asr chunk %d/%d (%.0fs-%.0fs): %w", chunk.Index+1, len(chunks), chunk.Start, chunk.End, err))
+				return
+			}
+			results[chunk.Index] = result
+			progress.update(chunk.Index, 100)
+		}(chunk)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// probeDuration reads the "Duration:" line ffmpeg prints for the input.
+func (e *ChunkedASREngine) probeDuration(ctx context.Context, audioPath string) (float64, error) {
+	return ProbeAudioDuration(ctx, e.ffmpegPath, audioPath)
+}
+
+// ProbeAudioDuration returns the length in seconds of a media file from the
+// "Duration:" line ffmpeg prints for the input.
+func ProbeAudioDuration(ctx context.Context, ffmpegPath, audioPath string) (float64, error) {
+	// ffmpeg exits non-zero without an output file; the header is printed anyway
+	output, _ := utils.CommandContext(ctx, ffmpegPath, "-hide_banner", "-nostdin", "-i", audioPath).CombinedOutput()
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+	duration, ok := parseFFmpegDuration(string(output))
+	if !ok {
+		return 0, fmt.Errorf("no duration in ffmpeg output")
+	}
+	return duration, nil
+}
+
+// detectSilences runs silencedetect over the whole file.
+func (e *ChunkedASREngine) detectSilences(ctx context.Context, audioPath string, duration float64) ([]silenceInterval, error) {
+	filter := fmt.Sprintf("silencedetect=noise=%sdB:d=%s",
+		strconv.FormatFloat(e.cfg.SilenceNoiseDB, 'f', -1, 64),
+		strconv.FormatFloat(e.cfg.SilenceSeconds, 'f', -1, 64))
+	cmd := utils.CommandContext(ctx, e.ffmpegPath, "-hide_banner", "-nostdin", "-nostats",
+		"-i", audioPath, "-vn", "-af", filter, "-f", "null", "-")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg silencedetect failed: %w", err)
+	}
+	return parseSilences(string(output), duration), nil
+}
+
+// cutChunk copies [Start, End) of the source into its own file without re-encoding.
+func (e *ChunkedASREngine) cutChunk(ctx context.Context, audioPath, workDir string, chunk audioChunk) (string, error) {
+	ext := filepath.Ext(audioPath)
+	if ext == "" {
+		ext = ".mp3"
+	}
+	chunkPath := filepath.Join(workDir, fmt.Sprintf("chunk_%04d%s", chunk.Index, ext))
+	args := []string{"-hide_banner", "-nostdin", "-y",
+		"-ss", strconv.FormatFloat(chunk.Start, 'f', 3, 64),
+		"-t", strconv.FormatFloat(chunk.End-chunk.Start, 'f', 3, 64),
+		"-i", audioPath, "-vn", "-c", "copy", chunkPath}
+	if output, err := utils.CommandContext(ctx, e.ffmpegPath, args...).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("cut audio chunk %d: %w\n%s", chunk.Index+1, err, output)
+	}
+	return chunkPath, nil
+}
+
+func parseFFmpegDuration(output string) (float64, bool) {
+	match := ffmpegDurationPattern.FindStringSubmatch(output)
+	if match == nil {
+		return 0, false
+	}
+	hours, _ := strconv.Atoi(match[1])
+	minutes, _ := strconv.Atoi(match[2])
+	seconds, _ := strconv.ParseFloat(match[3], 64)
+	return float64(hours*3600+minutes*60) + seconds, true
+}
+
+// parseSilences pairs silence_start / silence_end lines; a silence still open at
+// the end of the file ends at duration.
+func parseSilences(output string, duration float64) []silenceInterval {
+	var silences []silenceInterval
+	open := -1.0
+	for _, line := range strings.Split(output, "\n") {
+		if match := silenceStartPattern.FindStringSubmatch(line); match != nil {
+			start, _ := strconv.ParseFloat(match[1], 64)
+			open = max(start, 0)
+			continue
+		}
+		if match := silenceEndPattern.FindStringSubmatch(line); match != nil && open >= 0 {
+			end, _ := strconv.ParseFloat(match[1], 64)
+			silences = append(silences, silenceInterval{Start: open, End: end})
+			open = -1
+		}
+	}
+	if open >= 0 && duration > open {
+		silences = append(silences, silenceInterval{Start: open, End: duration})
+	}
+	return silences
+}
+
+// planAudioChunks picks cut points near every ChunkSeconds. A cut goes to the middle
+// of the silence closest to the target within [ChunkSeconds/2, MaxChunkSeconds] of
+// the previous cut; without one it is made at the target and the next chunk starts
+// OverlapSeconds earlier so words on the cut are not lost.
+func planAudioChunks(duration float64, silences []silenceInterval, cfg ChunkedASRConfig) []audioChunk {
+	cfg = cfg.withDefaults()
+	var chunks []audioChunk
+	start, boundary := 0.0, 0.0
+	for duration-boundary > cfg.MaxChunkSeconds {
+		target := boundary + cfg.ChunkSeconds
+		lo, hi := boundary+cfg.ChunkSeconds/2, boundary+cfg.MaxChunkSeconds
+		cut, found := 0.0, false
+		for _, silence := range silences {
+			mid := (silence.Start + silence.End) / 2
+			if mid < lo || mid > hi {
+				continue
+			}
+			if !found || math.Abs(mid-target) < math.Abs(cut-target) {
+				cut, found = mid, true
+			}
+		}
+
+		if found {
+			chunks = append(chunks, audioChunk{Index: len(chunks), Start: start, End: cut, Boundary: boundary})
+			start, boundary = cut, cut
+			continue
+		}
+		cut = target
+		chunks = append(chunks, audioChunk{Index: len(chunks), Start: start, End: min(cut+cfg.OverlapSeconds, duration), Boundary: boundary})
+		start, boundary = max(cut-cfg.OverlapSeconds, 0), cut
+	}
+	return append(chunks, audioChunk{Index: len(chunks), Start: start, End: duration, Boundary: boundary})
+}
+
+// stitchChunkTranscripts shifts chunk-local timestamps to global ones. In overlapping
+// regions a segment belongs to the chunk that owns its midpoint; text repeated at the
+// start of a chunk (the tail of the previous chunk heard again) is removed.
+func stitchChunkTranscripts(chunks []audioChunk, results []*TranscriptResult) *TranscriptResult {
+	stitched := &TranscriptResult{}
+	languageSeconds := map[string]float64{}
+	var texts []string
+
+	for i, chunk := range chunks {
+		result := results[i]
+		if result == nil {
+			continue
+		}
+		nextBoundary := math.Inf(1)
+		if i+1 < len(chunks) {
+			nextBoundary = chunks[i+1].Boundary
+		}
+
+		for _, segment := range result.Segments {
+			segment = shiftSegment(segment, chunk.Start)
+			mid := (segment.Start + segment.End) / 2
+			if mid < chunk.Boundary || mid >= nextBoundary {
+				continue
+			}
+			if n := len(stitched.Segments); n > 0 {
+				prev := stitched.Segments[n-1]
+				var ok bool
+				if segment, ok = dedupBoundaryText(prev, segment); !ok {
+					continue
+				}
+			}
+			stitched.Segments = append(stitched.Segments, segment)
+			texts = append(texts, segment.Text)
+			if result.Language != "" {
+				languageSeconds[result.Language] += segment.End - segment.Start
+			}
+		}
+	}
+
+	stitched.FullText = strings.Join(texts, " ")
+	stitched.Language = dominantLanguage(languageSeconds, results)
+	return stitched
+}
+
+func shiftSegment(segment TranscriptSegment, offset float64) TranscriptSegment {
+	segment.Start += offset
+	segment.End += offset
+	if len(segment.Words) > 0 {
+		words := make([]TranscriptWord, len(segment.Words))
+		for i, word := range segment.Words {
+			word.Start += offset
+			word.End += offset
+			words[i] = word
+		}
+		segment.Words = words
+	}
+	return segment
+}
+
+// dedupBoundaryText removes the longest prefix of next that repeats the end of prev
+// (at least two words, or two characters for CJK) and keeps timestamps monotonic.
+// It returns false when nothing of next is left.
+func dedupBoundaryText(prev, next TranscriptSegment) (TranscriptSegment, bool) {
+	// Only compare segments that are adjacent in time; the same phrase further away (e.g. a repeated catchphrase) is not a cut artifact
+	if next.Start-prev.End > defaultASROverlapSeconds {
+		return next, true
+	}
+	prevTokens := boundaryTokens(prev.Text)
+	nextTokens := boundaryTokens(next.Text)
+	overlap := 0
+	for k := min(len(prevTokens), len(nextTokens)); k >= 2; k-- {
+		if tokensEqual(prevTokens[len(prevTokens)-k:], nextTokens[:k]) {
+			overlap = k
+			break
+		}
+	}
+	if overlap > 0 {
+		if overlap == len(nextTokens) {
+			return next, false
+		}
+		next.Text = strings.TrimLeftFunc(next.Text[nextTokens[overlap-1].end:], func(r rune) bool {
+			return unicode.IsSpace(r) || unicode.IsPunct(r)
+		})
+		if next.Text == "" {
+			return next, false
+		}
+		var words []TranscriptWord
+		for _, word := range next.Words {
+			if word.Start >= prev.End {
+				words = append(words, word)
+			}
+		}
+		next.Words = words
+	}
+	if next.Start < prev.End {
+		next.Start = prev.End
+	}
+	if next.End < next.Start {
+		next.End = next.Start
+	}
+	return next, true
+}
+
+type boundaryToken struct {
+	norm string
+	end  int // byte offset just past the token in the original text
+}
+
+// boundaryTokens splits text into comparable tokens: words for space-separated
+// scripts, single characters for CJK. Case and punctuation are ignored.
+func boundaryTokens(text string) []boundaryToken {
+	var tokens []boundaryToken
+	var word strings.Builder
+	wordEnd := 0
+	flush := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, boundaryToken{norm: word.String(), end: wordEnd})
+			word.Reset()
+		}
+	}
+	for i, r := range text {
+		size := len(string(r))
+		switch {
+		case isCJKRune(r):
+			flush()
+			tokens = append(tokens, boundaryToken{norm: string(r), end: i + size})
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word.WriteRune(unicode.ToLower(r))
+			wordEnd = i + size
+		case r == '\'':
+			// Keep contractions (don't) as one word
+		default:
+			flush()
+		}
+	}
+	flush()
+	return tokens
+}
+
+func tokensEqual(a, b []boundaryToken) bool {
+	for i := range a {
+		if a[i].norm != b[i].norm {
+			return false
+		}
+	}
+	return true
+}
+
+func isCJKRune(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+// dominantLanguage picks the language covering the most speech, falling back to the
+// first chunk that reported one.
+func dominantLanguage(languageSeconds map[string]float64, results []*TranscriptResult) string {
+	languages := make([]string, 0, len(languageSeconds))
+	for language := range languageSeconds {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	best := ""
+	for _, language := range languages {
+		if best == "" || languageSeconds[language] > languageSeconds[best] {
+			best = language
+		}
+	}
+	if best != "" {
+		return best
+	}
+	for _, result := range results {
+		if result != nil && result.Language != "" {
+			return result.Language
+		}
+	}
+	return ""
+}
+
+// chunkProgress aggregates per-chunk progress weighted by chunk length.
+type chunkProgress struct {
+	mu      sync.Mutex
+	ctx     context.Context
+	weights []float64
+	total   float64
+	percent []int
+	last    int
+}
+
+func newChunkProgress(ctx context.Context, chunks []audioChunk) *chunkProgress {
+	progress := &chunkProgress{ctx: ctx, weights: make([]float64, len(chunks)), percent: make([]int, len(chunks)), last: -1}
+	for i, chunk := range chunks {
+		progress.weights[i] = chunk.End - chunk.Start
+		progress.total += progress.weights[i]
+	}
+	return progress
+}
+
+func (p *chunkProgress) update(index, percent int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if percent > p.percent[index] {
+		p.percent[index] = percent
+	}
+	done := 0.0
+	for i, weight := range p.weights {
+		done += weight * float64(p.percent[i]) / 100
+	}
+	overall := 0
+	if p.total > 0 {
+		overall = int(done / p.total * 100)
+	}
+	if overall != p.last {
+		p.last = overall
+		reportASRProgress(p.ctx, overall)
+	}
+}
+
+var _ ASREngine = (*ChunkedASREngine)(nil)
@@ -0,0 +1,242 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestPlanAudioChunks_PrefersSilenceNearTarget(t *testing.T) {
+	cfg := ChunkedASRConfig{ChunkSeconds: 100, MaxChunkSeconds: 150, OverlapSeconds: 2}
+	silences := []silenceInterval{
+		{Start: 40, End: 42},   // too early, less than half a chunk
+		{Start: 93, End: 95},   // closest to the target of 100
+		{Start: 120, End: 121}, // also in the window but farther away
+		// no silence between 194 and 250, so the second cut is a hard cut
+	}
+	chunks := planAudioChunks(320, silences, cfg)
+
+	want := []audioChunk{
+		{Index: 0, Start: 0, End: 94, Boundary: 0},
+		{Index: 1, Start: 94, End: 196, Boundary: 94},
+		{Index: 2, Start: 192, End: 320, Boundary: 194},
+	}
+	if fmt.Sprint(chunks) != fmt.Sprint(want) {
+		t.Fatalf("unexpected plan:\n got %v\nwant %v", chunks, want)
+	}
+
+	if single := planAudioChunks(140, nil, cfg); len(single) != 1 || single[0].End != 140 {
+		t.Fatalf("audio shorter than the max chunk must not be split, got %v", single)
+	}
+}
+
+func TestParseSilencesAndDuration(t *testing.T) {
+	output := `Input #0, mp3, from 'audio.mp3':
+  Duration: 01:02:03.50, start: 0.025057, bitrate: 192 kb/s
+[silencedetect @ 0x1] silence_start: 12.5
+[silencedetect @ 0x1] silence_end: 13.75 | silence_duration: 1.25
+[silencedetect @ 0x1] silence_start: -0.01
+[silencedetect @ 0x1] silence_end: 0.5 | silence_duration: 0.51
+[silencedetect @ 0x1] silence_start: 3720
+`
+	duration, ok := parseFFmpegDuration(output)
+	if !ok || duration != 3723.5 {
+		t.Fatalf("expected duration 3723.5, got %v %v", duration, ok)
+	}
+	silences := parseSilences(output, duration)
+	want := []silenceInterval{{12.5, 13.75}, {0, 0.5}, {3720, 3723.5}}
+	if fmt.Sprint(silences) != fmt.Sprint(want) {
+		t.Fatalf("unexpected silences: %v", silences)
+	}
+}
+
+func TestStitchChunkTranscripts_OffsetsAndBoundaryDedup(t *testing.T) {
+	chunks := []audioChunk{
+		{Index: 0, Start: 0, End: 102, Boundary: 0},
+		{Index: 1, Start: 98, End: 200, Boundary: 100},
+		{Index: 2, Start: 200, End: 260, Boundary: 200},
+	}
+	results := []*TranscriptResult{
+		{Language: "en", Segments: []TranscriptSegment{
+			{Start: 1, End: 5, Text: "Welcome back everyone."},
+			{Start: 95, End: 100.5, Text: "Today we talk about"},
+			{Start: 100.8, End: 101.9, Text: "the"}, // midpoint is past 100, so it belongs to the next chunk
+		}},
+		{Language: "en", Segments: []TranscriptSegment{
+			{Start: 0, End: 1.5, Text: "talk"}, // 中点 98.75，在上一片段范围内
+			{Start: 1.5, End: 6, Text: "We talk about the weather",
+				Words: []TranscriptWord{{Start: 1.5, End: 2, Text: "We"}, {Start: 2, End: 2.4, Text: "talk"}, {Start: 2.4, End: 3, Text: "about"}, {Start: 3.1, End: 3.5, Text: "the"}, {Start: 3.6, End: 6, Text: "weather"}}},
+		}},
+		{Language: "zh", Segments: []TranscriptSegment{
+			{Start: 0, End: 2, Text: "天气不错"},
+		}},
+	}
+
+	stitched := stitchChunkTranscripts(chunks, results)
+	got := make([]string, 0, len(stitched.Segments))
+	for _, segment := range stitched.Segments {
+		got = append(got, fmt.Sprintf("%.1f-%.1f %s", segment.Start, segment.End, segment.Text))
+	}
+	want := []string{
+		"1.0-5.0 Welcome back everyone.",
+		"95.0-100.5 Today we talk about",
+		"100.5-104.0 the weather",
+		"200.0-202.0 天气不错",
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected stitched segments:\n got %v\nwant %v", got, want)
+	}
+	if words := stitched.Segments[2].Words; len(words) != 2 || words[0].Text != "the" || words[0].Start != 101.1 {
+		t.Fatalf("expected words shifted and trimmed to the kept text, got %+v", words)
+	}
+	if stitched.Language != "en" {
+		t.Fatalf("expected dominant language en, got %s", stitched.Language)
+	}
+	if stitched.FullText != "Welcome back everyone. Today we talk about the weather 天气不错" {
+		t.Fatalf("unexpected full text %q", stitched.FullText)
+	}
+}
+
+func TestDedupBoundaryText_CJK(t *testing.T) {
+	prev := TranscriptSegment{Start: 0, End: 5, Text: "今天我们来聊一聊天气"}
+	next, ok := dedupBoundaryText(prev, TranscriptSegment{Start: 4, End: 8, Text: "聊一聊天气，明天会下雨"})
+	if !ok || next.Text != "明天会下雨" || next.Start != 5 {
+		t.Fatalf("expected repeated CJK prefix removed, got %+v ok=%v", next, ok)
+	}
+	if _, ok := dedupBoundaryText(prev, TranscriptSegment{Start: 4.5, End: 5.2, Text: "天气。"}); ok {
+		t.Fatal("a segment fully repeating the previous tail must be dropped")
+	}
+	far, ok := dedupBoundaryText(prev, TranscriptSegment{Start: 30, End: 32, Text: "天气不错"})
+	if !ok || far.Text != "天气不错" {
+		t.Fatalf("distant segments must not be deduplicated, got %+v", far)
+	}
+}
+
+// fakeFFmpeg 模拟 ffmpeg：探测时输出时长，silencedetect 输出静音，切分时生成片段文件
+const fakeFFmpeg = `#!/bin/sh
+case "$*" in
+*silencedetect*)
+	echo "[silencedetect @ 0x1] silence_start: 590" >&2
+	echo "[silencedetect @ 0x1] silence_end: 591 | silence_duration: 1" >&2
+	echo "[silencedetect @ 0x1] silence_start: 1190" >&2
+	echo "[silencedetect @ 0x1] silence_end: 1191 | silence_duration: 1" >&2
+	;;
+*" -c copy "*)
+	for last; do :; done
+	echo chunk > "$last"
+	;;
+*)
+	echo "  Duration: 00:25:00.00, start: 0.000000, bitrate: 128 kb/s" >&2
+	exit 1
+	;;
+esac
+`
+
+// chunkEngine 按片段文件名返回预置结果，记录并发度
+type chunkEngine struct {
+	mu      sync.Mutex
+	calls   []string
+	active  int
+	maxSeen int
+	fail    string
+}
+
+func (e *chunkEngine) Name() string        { return "fake" }
+func (e *chunkEngine) Languages() []string { return []string{"en"} }
+
+func (e *chunkEngine) Transcribe(ctx context.Context, audioPath string) (*TranscriptResult, error) {
+	name := filepath.Base(audioPath)
+	e.mu.Lock()
+	e.calls = append(e.calls, name)
+	e.active++
+	if e.active > e.maxSeen {
+		e.maxSeen = e.active
+	}
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		e.active--
+		e.mu.Unlock()
+	}()
+
+	if name == e.fail {
+		return nil, errors.New("rate limited")
+	}
+	reportASRProgress(ctx, 50)
+	return &TranscriptResult{Language: "en", Segments: []TranscriptSegment{
+		{Start: 10, End: 12, Text: "line of " + strings.TrimSuffix(name, ".mp3")},
+	}}, nil
+}
+
+func newFakeFFmpeg(t *testing.T) (string, string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	ffmpeg := filepath.Join(dir, "ffmpeg")
+	if err := os.WriteFile(ffmpeg, []byte(fakeFFmpeg), 0o755); err != nil {
+		t.Fatalf("write fake ffmpeg: %v", err)
+	}
+	audio := filepath.Join(dir, "audio.mp3")
+	if err := os.WriteFile(audio, []byte("audio"), 0o644); err != nil {
+		t.Fatalf("write audio: %v", err)
+	}
+	return ffmpeg, audio
+}
+
+func TestChunkedASREngine_TranscribesChunksInParallel(t *testing.T) {
+	ffmpeg, audio := newFakeFFmpeg(t)
+	inner := &chunkEngine{}
+	engine := NewChunkedASREngine(inner, ChunkedASRConfig{FFmpegPath: ffmpeg, Concurrency: 2}, nil)
+
+	var mu sync.Mutex
+	var progress []int
+	ctx := WithASRProgressReporter(context.Background(), func(percent int) {
+		mu.Lock()
+		progress = append(progress, percent)
+		mu.Unlock()
+	})
+	result, err := engine.Transcribe(ctx, audio)
+	if err != nil {
+		t.Fatalf("Transcribe: %v", err)
+	}
+
+	var got []string
+	for _, segment := range result.Segments {
+		got = append(got, fmt.Sprintf("%.1f %s", segment.Start, segment.Text))
+	}
+	want := []string{"10.0 line of chunk_0000", "600.5 line of chunk_0001", "1200.5 line of chunk_0002"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected segments:\n got %v\nwant %v", got, want)
+	}
+	if len(inner.calls) != 3 || inner.maxSeen > 2 {
+		t.Fatalf("expected 3 chunk calls with concurrency <= 2, got %v (max %d)", inner.calls, inner.maxSeen)
+	}
+	if len(progress) == 0 || progress[len(progress)-1] != 100 {
+		t.Fatalf("expected aggregated progress ending at 100, got %v", progress)
+	}
+	if entries, _ := filepath.Glob(filepath.Join(filepath.Dir(audio), "asr-chunks-*")); len(entries) != 0 {
+		t.Fatalf("chunk work dir must be removed, found %v", entries)
+	}
+
+	inner = &chunkEngine{fail: "chunk_0001.mp3"}
+	engine = NewChunkedASREngine(inner, ChunkedASRConfig{FFmpegPath: ffmpeg, Concurrency: 1}, nil)
+	if _, err := engine.Transcribe(context.Background(), audio); err == nil || !strings.Contains(err.Error(), "asr chunk 2/3") {
+		t.Fatalf("expected chunk failure to surface, got %v", err)
+	}
+}
+
+func TestProbeAudioDuration(t *testing.T) {
+	ffmpeg, audio := newFakeFFmpeg(t)
+	duration, err := ProbeAudioDuration(context.Background(), ffmpeg, audio)
+	if err != nil || duration != 1500 {
+		t.Fatalf("expected 1500s, got %v %v", duration, err)
+	}
+}
@@ -0,0 +1,283 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"go.uber.org/zap"
+)
+
+// ── ASR Fallback Chain ──────────────────────────────────────────────────────
+// FallbackASREngine tries an ordered list of engines (e.g. bcut → whisper API →
+// local whisper). An engine error or a transcript failing the quality checks
+// moves on to the next engine; the engine that produced the returned transcript
+// is recorded in TranscriptResult.Engine.
+
+const (
+	defaultMinSegmentsPerMinute = 1.0
+	defaultMaxRepeatRatio       = 0.5
+	defaultMaxRepeatRun         = 4
+	// minQualityCheckSeconds shorter audio (intros, music clips) is not held to the segment density check
+	minQualityCheckSeconds = 120.0
+	// minRepeatRatioSegments the repeat ratio is only meaningful with enough segments
+	minRepeatRatioSegments = 8
+)
+
+// ErrTranscriptLowQuality marks a transcript rejected by CheckTranscriptQuality.
+var ErrTranscriptLowQuality = errors.New("transcript failed quality check")
+
+// ASRQualityConfig thresholds of the quality checks. Zero values use the defaults,
+// negative values disable a check.
+type ASRQualityConfig struct {
+	MinSegmentsPerMinute float64 // fewer segments per minute of audio means the engine missed speech, default 1
+	MaxRepeatRatio       float64 // share of segments with the most frequent text, default 0.5
+	MaxRepeatRun         int     // consecutive segments with identical text, default 4
+	SkipLanguageCheck    bool    // do not compare the detected language with the expected one
+}
+
+func (c ASRQualityConfig) withDefaults() ASRQualityConfig {
+	if c.MinSegmentsPerMinute == 0 {
+		c.MinSegmentsPerMinute = defaultMinSegmentsPerMinute
+	}
+	if c.MaxRepeatRatio == 0 {
+		c.MaxRepeatRatio = defaultMaxRepeatRatio
+	}
+	if c.MaxRepeatRun == 0 {
+		c.MaxRepeatRun = defaultMaxRepeatRun
+	}
+	return c
+}
+
+// ASRExpectation is what the caller knows about the audio; unknown fields skip the related check.
+type ASRExpectation struct {
+	DurationSeconds float64 // audio length
+	Language        string  // expected source language, empty or "auto" when unknown
+}
+
+type asrExpectationContextKey struct{}
+
+// WithASRExpectation stores the expected duration and language used by the quality checks.
+func WithASRExpectation(ctx context.Context, expectation ASRExpectation) context.Context {
+	return context.WithValue(ctx, asrExpectationContextKey{}, expectation)
+}
+
+func asrExpectationFromContext(ctx context.Context) ASRExpectation {
+	expectation, _ := ctx.Value(asrExpectationContextKey{}).(ASRExpectation)
+	return expectation
+}
+
+// CheckTranscriptQuality detects the typical ASR failures: empty output, far too few
+// segments for the audio length, a language other than the expected one, and
+// hallucinated loops repeating the same text. It returns an error wrapping
+// ErrTranscriptLowQuality, or nil.
+func CheckTranscriptQuality(result *TranscriptResult, expectation ASRExpectation, cfg ASRQualityConfig) error {
+	cfg = cfg.withDefaults()
+	lowQuality := func(format string, args ...any) error {
+		return fmt.Errorf("%w: %s", ErrTranscriptLowQuality, fmt.Sprintf(format, args...))
+	}
+
+	var texts []string
+	if result != nil {
+		for _, segment := range result.Segments {
+			if text := normalizeTranscriptText(segment.Text); text != "" {
+				texts = append(texts, text)
+			}
+		}
+	}
+	if len(texts) == 0 {
+		return lowQuality("empty transcript")
+	}
+
+	if minutes := expectation.DurationSeconds / 60; cfg.MinSegmentsPerMinute > 0 && expectation.DurationSeconds >= minQualityCheckSeconds {
+		if perMinute := float64(len(texts)) / minutes; perMinute < cfg.MinSegmentsPerMinute {
+			return lowQuality("%.2f segments per minute, expected at least %.2f", perMinute, cfg.MinSegmentsPerMinute)
+		}
+	}
+
+	if !cfg.SkipLanguageCheck {
+		want, got := baseLanguageCode(expectation.Language), baseLanguageCode(result.Language)
+		if want != "" && got != "" && want != got {
+			return lowQuality("detected language %s, expected %s", result.Language, expectation.Language)
+		}
+	}
+
+	if cfg.MaxRepeatRun > 0 {
+		run := 1
+		for i := 1; i < len(texts); i++ {
+			if texts[i] != texts[i-1] {
+				run = 1
+				continue
+			}
+			if run++; run > cfg.MaxRepeatRun {
+				return lowQuality("%q repeated %d times in a row", texts[i], run)
+			}
+		}
+	}
+	if cfg.MaxRepeatRatio > 0 && len(texts) >= minRepeatRatioSegments {
+		counts := map[string]int{}
+		top, topText := 0, ""
+		for _, text := range texts {
+			counts[text]++
+			if counts[text] > top {
+				top, topText = counts[text], text
+			}
+		}
+		if ratio := float64(top) / float64(len(texts)); ratio > cfg.MaxRepeatRatio {
+			return lowQuality("%q makes up %.0f%% of the segments", topText, ratio*100)
+		}
+	}
+	return nil
+}
+
+func transcriptHasText(result *TranscriptResult) bool {
+	for _, segment := range result.Segments {
+		if normalizeTranscriptText(segment.Text) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeTranscriptText lowercases text and drops spaces and punctuation so that
+// "Thank you." and "thank you" compare equal.
+func normalizeTranscriptText(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
+
+var languageNameCodes = map[string]string{
+	"chinese": "zh", "mandarin": "zh", "cmn": "zh", "yue": "zh", "cantonese": "zh",
+	"english": "en", "japanese": "ja", "korean": "ko", "french": "fr", "german": "de",
+	"spanish": "es", "russian": "ru", "arabic": "ar", "portuguese": "pt", "italian": "it",
+}
+
+// baseLanguageCode reduces "zh-CN", "en_US" or "English" to "zh" / "en"; "auto" and empty give "".
+func baseLanguageCode(language string) string {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if language == "" || language == "auto" {
+		return ""
+	}
+	if code, ok := languageNameCodes[language]; ok {
+		return code
+	}
+	if i := strings.IndexAny(language, "-_"); i > 0 {
+		language = language[:i]
+	}
+	if code, ok := languageNameCodes[language]; ok {
+		return code
+	}
+	return language
+}
+
+// ASRFallbackEntry is one engine of the fallback chain. Name identifies the engine
+// in logs and on the video, and may differ from Engine.Name() (e.g. whisper_api).
+type ASRFallbackEntry struct {
+	Name   string
+	Engine ASREngine
+}
+
+// FallbackASREngine transcribes with the first engine whose output passes the quality checks.
+type FallbackASREngine struct {
+	entries []ASRFallbackEntry
+	quality ASRQualityConfig
+	logger  *zap.Logger
+}
+
+func NewFallbackASREngine(entries []ASRFallbackEntry, quality ASRQualityConfig, logger *zap.Logger) *FallbackASREngine {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &FallbackASREngine{entries: entries, quality: quality, logger: logger}
+}
+
+func (e *FallbackASREngine) Name() string {
+	if len(e.entries) == 0 {
+		return "fallback"
+	}
+	return e.entries[0].Name
+}
+
+func (e *FallbackASREngine) Languages() []string {
+	seen := map[string]struct{}{}
+	var languages []string
+	for _, entry := range e.entries {
+		for _, language := range entry.Engine.Languages() {
+			if _, ok := seen[language]; !ok {
+				seen[language] = struct{}{}
+				languages = append(languages, language)
+			}
+		}
+	}
+	return languages
+}
+
+// Transcribe tries the engines in order. When every transcript fails the quality
+// checks, the first non-empty one is returned rather than failing the step; when
+// every engine errors, the error is transient if any engine failed transiently.
+func (e *FallbackASREngine) Transcribe(ctx context.Context, audioPath string) (*TranscriptResult, error) {
+	if len(e.entries) == 0 {
+		return nil, PermanentError(fmt.Errorf("no asr engine configured"))
+	}
+	expectation := asrExpectationFromContext(ctx)
+
+	var (
+		failures []error
+		usable   *TranscriptResult
+	)
+	for i, entry := range e.entries {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		result, err := entry.Engine.Transcribe(ctx, audioPath)
+		if err == nil && result == nil {
+			err = fmt.Errorf("%w: empty transcript", ErrTranscriptLowQuality)
+		}
+		if err == nil {
+			result.Engine = entry.Name
+			err = CheckTranscriptQuality(result, expectation, e.quality)
+			if err == nil {
+				if i > 0 {
+					e.logger.Info("ASR fallback engine succeeded",
+						zap.String("engine", entry.Name), zap.Int("attempt", i+1))
+				}
+				return result, nil
+			}
+			if usable == nil && transcriptHasText(result) {
+				usable = result
+			}
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		failures = append(failures, fmt.Errorf("%s: %w", entry.Name, err))
+		if i+1 < len(e.entries) {
+			e.logger.Warn("ASR engine failed, trying next engine",
+				zap.String("engine", entry.Name),
+				zap.String("next", e.entries[i+1].Name),
+				zap.Error(err))
+		}
+	}
+
+	if usable != nil {
+		e.logger.Warn("All ASR engines failed the quality checks, using the first usable transcript",
+			zap.String("engine", usable.Engine), zap.Error(errors.Join(failures...)))
+		return usable, nil
+	}
+	err := fmt.Errorf("all asr engines failed: %w", errors.Join(failures...))
+	for _, failure := range failures {
+		if ClassifyError(failure) == ErrorClassTransient {
+			return nil, TransientError(err)
+		}
+	}
+	return nil, err
+}
+
+var _ ASREngine = (*FallbackASREngine)(nil)
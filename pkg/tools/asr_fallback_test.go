@@ -0,0 +1,134 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func segmentsOf(texts ...string) []TranscriptSegment {
+	segments := make([]TranscriptSegment, 0, len(texts))
+	for i, text := range texts {
+		segments = append(segments, TranscriptSegment{Start: float64(i * 5), End: float64(i*5 + 4), Text: text})
+	}
+	return segments
+}
+
+func TestCheckTranscriptQuality(t *testing.T) {
+	varied := make([]string, 0, 20)
+	for i := range 20 {
+		varied = append(varied, fmt.Sprintf("line %d", i))
+	}
+	hallucinated := append(segmentsOf("intro", "topic", "detail"), segmentsOf("Thank you.", "thank you", "Thank you!", "thank you.", "Thank you.")...)
+	looping := segmentsOf("a", "Subscribe", "b", "Subscribe", "c", "Subscribe", "Subscribe", "d", "Subscribe", "Subscribe")
+
+	tests := []struct {
+		name        string
+		result      *TranscriptResult
+		expectation ASRExpectation
+		cfg         ASRQualityConfig
+		wantErr     string
+	}{
+		{name: "good", result: &TranscriptResult{Language: "en-US", Segments: segmentsOf(varied...)}, expectation: ASRExpectation{DurationSeconds: 600, Language: "English"}},
+		{name: "nil", result: nil, wantErr: "empty transcript"},
+		{name: "blank segments", result: &TranscriptResult{Segments: segmentsOf("  ", "...")}, wantErr: "empty transcript"},
+		{name: "too sparse", result: &TranscriptResult{Segments: segmentsOf("only", "two")}, expectation: ASRExpectation{DurationSeconds: 600}, wantErr: "segments per minute"},
+		{name: "short audio skips density", result: &TranscriptResult{Segments: segmentsOf("hi")}, expectation: ASRExpectation{DurationSeconds: 90}},
+		{name: "density disabled", result: &TranscriptResult{Segments: segmentsOf("hi")}, expectation: ASRExpectation{DurationSeconds: 600}, cfg: ASRQualityConfig{MinSegmentsPerMinute: -1}},
+		{name: "language mismatch", result: &TranscriptResult{Language: "zh", Segments: segmentsOf(varied...)}, expectation: ASRExpectation{Language: "ja"}, wantErr: "detected language zh"},
+		{name: "language check skipped", result: &TranscriptResult{Language: "zh", Segments: segmentsOf(varied...)}, expectation: ASRExpectation{Language: "ja"}, cfg: ASRQualityConfig{SkipLanguageCheck: true}},
+		{name: "auto language", result: &TranscriptResult{Language: "zh", Segments: segmentsOf(varied...)}, expectation: ASRExpectation{Language: "auto"}},
+		{name: "repeat run", result: &TranscriptResult{Segments: hallucinated}, wantErr: "repeated 5 times"},
+		{name: "repeat ratio", result: &TranscriptResult{Segments: looping}, wantErr: "makes up 60%"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckTranscriptQuality(tt.result, tt.expectation, tt.cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("expected transcript to pass, got %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrTranscriptLowQuality) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected low quality error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// scriptedEngine 返回预置的结果或错误，记录调用次数
+type scriptedEngine struct {
+	result *TranscriptResult
+	err    error
+	calls  int
+}
+
+func (e *scriptedEngine) Name() string        { return "scripted" }
+func (e *scriptedEngine) Languages() []string { return []string{"en"} }
+
+func (e *scriptedEngine) Transcribe(context.Context, string) (*TranscriptResult, error) {
+	e.calls++
+	if e.err != nil {
+		return nil, e.err
+	}
+	copied := *e.result
+	return &copied, nil
+}
+
+func TestFallbackASREngine_TriesNextEngineAndRecordsIt(t *testing.T) {
+	bcut := &scriptedEngine{err: errors.New("upload failed")}
+	api := &scriptedEngine{result: &TranscriptResult{Language: "zh", Segments: segmentsOf("wrong", "language")}}
+	local := &scriptedEngine{result: &TranscriptResult{Language: "en", Segments: segmentsOf("right", "language")}}
+	unused := &scriptedEngine{result: &TranscriptResult{Language: "en", Segments: segmentsOf("unused")}}
+
+	engine := NewFallbackASREngine([]ASRFallbackEntry{
+		{Name: "bcut", Engine: bcut},
+		{Name: "whisper_api", Engine: api},
+		{Name: "whisper_local", Engine: local},
+		{Name: "extra", Engine: unused},
+	}, ASRQualityConfig{}, nil)
+
+	ctx := WithASRExpectation(context.Background(), ASRExpectation{Language: "en"})
+	result, err := engine.Transcribe(ctx, "audio.mp3")
+	if err != nil {
+		t.Fatalf("Transcribe: %v", err)
+	}
+	if result.Engine != "whisper_local" || result.Segments[0].Text != "right" {
+		t.Fatalf("expected whisper_local transcript, got %+v", result)
+	}
+	if bcut.calls != 1 || api.calls != 1 || local.calls != 1 || unused.calls != 0 {
+		t.Fatalf("unexpected call counts: %d %d %d %d", bcut.calls, api.calls, local.calls, unused.calls)
+	}
+}
+
+func TestFallbackASREngine_AllEnginesFail(t *testing.T) {
+	sparse := &scriptedEngine{result: &TranscriptResult{Segments: segmentsOf("only one")}}
+	empty := &scriptedEngine{result: &TranscriptResult{}}
+	engine := NewFallbackASREngine([]ASRFallbackEntry{
+		{Name: "bcut", Engine: empty},
+		{Name: "whisper", Engine: sparse},
+	}, ASRQualityConfig{}, nil)
+
+	ctx := WithASRExpectation(context.Background(), ASRExpectation{DurationSeconds: 1800})
+	result, err := engine.Transcribe(ctx, "audio.mp3")
+	if err != nil || result.Engine != "whisper" {
+		t.Fatalf("expected the first usable transcript when every engine fails the checks, got %+v %v", result, err)
+	}
+
+	engine = NewFallbackASREngine([]ASRFallbackEntry{
+		{Name: "bcut", Engine: &scriptedEngine{err: TransientError(errors.New("rate limited"))}},
+		{Name: "whisper", Engine: &scriptedEngine{err: PermanentError(errors.New("model missing"))}},
+	}, ASRQualityConfig{}, nil)
+	_, err = engine.Transcribe(context.Background(), "audio.mp3")
+	if err == nil || ClassifyError(err) != ErrorClassTransient {
+		t.Fatalf("expected transient error when any engine failed transiently, got %v", err)
+	}
+	for _, want := range []string{"bcut: rate limited", "whisper: model missing"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q in %v", want, err)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -9,47 +10,189 @@ import (
 	"mime/multipart"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/difyz9/ytb2bili/pkg/utils"
 )
 
 // ── Whisper ASR Engine ───────────────────────────────────────────────────────
 // Supports two modes:
-//   1. Local mode: calls whisper-cpp CLI
+//   1. Local mode: calls whisper.cpp CLI (whisper-cli)
 //   2. API mode: calls OpenAI-compatible Whisper API (WhisperX, OpenAI, etc.)
 //
-// Configuration via ASRConfig:
-//   provider = "whisper"
-//   model_path = "/path/to/models"  (local mode)
+// Configuration via [workflow.asr.whisper]:
+//   model_dir = "/path/to/models"  (local mode, holds ggml-<model>.bin)
+//   model = "large-v3"             (local mode)
 //   api_url = "http://localhost:9000/v1" (API mode, optional)
+//
+// Per-call overrides (e.g. from user settings) are passed with WithWhisperOptions.
+
+const (
+	defaultWhisperModel    = "base"
+	defaultWhisperAPIModel = "whisper-1"
+)
+
+// whisperProgressPattern matches whisper-cli --print-progress lines on stderr:
+// "whisper_print_progress_callback: progress =  45%"
+var whisperProgressPattern = regexp.MustCompile(`progress\s*=\s*(\d+)%`)
+
+// WhisperOptions are the decoding options of a transcription. Zero values fall back
+// to the engine defaults; pointer fields distinguish "disabled" from "not set".
+type WhisperOptions struct {
+	Model          string  `json:"model,omitempty"`           // model name (base, small, large-v3...), resolved to <ModelDir>/ggml-<model>.bin
+	ModelPath      string  `json:"model_path,omitempty"`      // explicit model file, takes precedence over Model
+	Language       string  `json:"language,omitempty"`        // source language code; empty or "auto" detects it
+	Threads        int     `json:"threads,omitempty"`         // decoding threads
+	BeamSize       int     `json:"beam_size,omitempty"`       // beam search width
+	Prompt         string  `json:"prompt,omitempty"`          // initial prompt: names, terms, punctuation style
+	WordTimestamps *bool   `json:"word_timestamps,omitempty"` // fill TranscriptSegment.Words
+	VAD            *bool   `json:"vad,omitempty"`             // skip non-speech with voice activity detection
+	VADModel       string  `json:"vad_model,omitempty"`       // VAD model file, required when VAD is enabled
+	VADThreshold   float64 `json:"vad_threshold,omitempty"`   // speech probability threshold (0-1)
+}
+
+// merge returns o with every field set in override replacing the default.
+func (o WhisperOptions) merge(override WhisperOptions) WhisperOptions {
+	if override.Model != "" {
+		o.Model = override.Model
+		o.ModelPath = ""
+	}
+	if override.ModelPath != "" {
+		o.ModelPath = override.ModelPath
+	}
+	if override.Language != "" {
+		o.Language = override.Language
+	}
+	if override.Threads > 0 {
+		o.Threads = override.Threads
+	}
+	if override.BeamSize > 0 {
+		o.BeamSize = override.BeamSize
+	}
+	if override.Prompt != "" {
+		o.Prompt = override.Prompt
+	}
+	if override.WordTimestamps != nil {
+		o.WordTimestamps = override.WordTimestamps
+	}
+	if override.VAD != nil {
+		o.VAD = override.VAD
+	}
+	if override.VADModel != "" {
+		o.VADModel = override.VADModel
+	}
+	if override.VADThreshold > 0 {
+		o.VADThreshold = override.VADThreshold
+	}
+	return o
+}
+
+// ModelName returns the model label used for logging, progress and cache keys.
+func (o WhisperOptions) ModelName() string {
+	if o.ModelPath != "" {
+		return strings.TrimSuffix(strings.TrimPrefix(filepath.Base(o.ModelPath), "ggml-"), ".bin")
+	}
+	if o.Model != "" {
+		return o.Model
+	}
+	return defaultWhisperModel
+}
+
+func (o WhisperOptions) autoLanguage() bool {
+	lang := strings.ToLower(strings.TrimSpace(o.Language))
+	return lang == "" || lang == "auto"
+}
+
+type whisperOptionsContextKey struct{}
+
+// WithWhisperOptions stores per-call option overrides (e.g. user settings) in context.
+func WithWhisperOptions(ctx context.Context, opts WhisperOptions) context.Context {
+	return context.WithValue(ctx, whisperOptionsContextKey{}, opts)
+}
+
+func whisperOptionsFromContext(ctx context.Context) WhisperOptions {
+	opts, _ := ctx.Value(whisperOptionsContextKey{}).(WhisperOptions)
+	return opts
+}
+
+// ASRProgressReporter receives transcription progress in percent (0-100).
+type ASRProgressReporter func(percent int)
+
+type asrProgressContextKey struct{}
+
+// WithASRProgressReporter stores a progress callback in context.
+func WithASRProgressReporter(ctx context.Context, reporter ASRProgressReporter) context.Context {
+	if reporter == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, asrProgressContextKey{}, reporter)
+}
+
+func reportASRProgress(ctx context.Context, percent int) {
+	reporter, ok := ctx.Value(asrProgressContextKey{}).(ASRProgressReporter)
+	if !ok || reporter == nil {
+		return
+	}
+	reporter(percent)
+}
 
 type WhisperASREngine struct {
-	mode     string // "local" or "api"
-	modelDir string
-	apiURL   string
-	apiKey   string
-	client   *http.Client
+	mode       string // "local" or "api"
+	binaryPath string
+	modelDir   string
+	apiURL     string
+	apiKey     string
+	apiModel   string
+	defaults   WhisperOptions
+	client     *http.Client
 }
 
 type WhisperConfig struct {
-	ModelDir string // local whisper.cpp models directory
-	APIURL   string // OpenAI-compatible API endpoint (e.g. WhisperX)
-	APIKey   string
+	WhisperOptions        // default decoding options
+	Mode           string // "local" or "api"; empty picks api when APIURL is set
+	BinaryPath     string // whisper-cli executable; defaults to <ModelDir>/whisper-cli if present, else whisper-cli in PATH
+	ModelDir       string // local whisper.cpp models directory
+	APIURL         string // OpenAI-compatible API endpoint (e.g. WhisperX)
+	APIKey         string
+	APIModel       string // model sent to the API, defaults to whisper-1
 }
 
 func NewWhisperASREngine(cfg WhisperConfig) *WhisperASREngine {
-	mode := "local"
-	if cfg.APIURL != "" {
-		mode = "api"
+	mode := cfg.Mode
+	if mode == "" {
+		mode = "local"
+		if cfg.APIURL != "" {
+			mode = "api"
+		}
+	}
+	binaryPath := cfg.BinaryPath
+	if binaryPath == "" {
+		binaryPath = "whisper-cli"
+		if cfg.ModelDir != "" {
+			if local := filepath.Join(cfg.ModelDir, "whisper-cli"); isRegularFile(local) {
+				binaryPath = local
+			}
+		}
+	}
+	apiModel := cfg.APIModel
+	if apiModel == "" {
+		apiModel = defaultWhisperAPIModel
 	}
 	return &WhisperASREngine{
-		mode:     mode,
-		modelDir: cfg.ModelDir,
-		apiURL:   strings.TrimRight(cfg.APIURL, "/"),
-		apiKey:   cfg.APIKey,
-		client:   &http.Client{Timeout: 10 * time.Minute},
+		mode:       mode,
+		binaryPath: binaryPath,
+		modelDir:   cfg.ModelDir,
+		apiURL:     strings.TrimRight(cfg.APIURL, "/"),
+		apiKey:     cfg.APIKey,
+		apiModel:   apiModel,
+		defaults:   cfg.WhisperOptions,
+		client:     &http.Client{Timeout: 10 * time.Minute},
 	}
 }
 
@@ -61,6 +204,24 @@ func (e *WhisperASREngine) Languages() []string {
 	return []string{"zh", "en", "ja", "ko", "fr", "de", "es", "ru", "ar"}
 }
 
+// Mode returns "local" or "api".
+func (e *WhisperASREngine) Mode() string {
+	return e.mode
+}
+
+// Options returns the engine defaults merged with the overrides stored in ctx.
+func (e *WhisperASREngine) Options(ctx context.Context) WhisperOptions {
+	return e.defaults.merge(whisperOptionsFromContext(ctx))
+}
+
+// ModelName returns the model that a call with ctx would use.
+func (e *WhisperASREngine) ModelName(ctx context.Context) string {
+	if e.mode == "api" {
+		return e.apiModel
+	}
+	return e.Options(ctx).ModelName()
+}
+
 func (e *WhisperASREngine) Transcribe(ctx context.Context, audioPath string) (*TranscriptResult, error) {
 	if _, err := os.Stat(audioPath); err != nil {
 		return nil, fmt.Errorf("whisper asr: audio file not found: %w", err)
@@ -76,35 +237,143 @@ func (e *WhisperASREngine) Transcribe(ctx context.Context, audioPath string) (*T
 	}
 }
 
-// transcribeLocal uses whisper-cpp CLI: ./whisper-cli --file audio.mp3 --model base --output-json
-func (e *WhisperASREngine) transcribeLocal(ctx context.Context, audioPath string) (*TranscriptResult, error) {
-	// Check for whisper-cli in PATH or model directory
-	whisperBin := "whisper-cli"
-	if e.modelDir != "" {
-		whisperBin = filepath.Join(e.modelDir, "whisper-cli")
+// resolveModelPath picks the explicit model file, or <ModelDir>/ggml-<model>.bin.
+func (e *WhisperASREngine) resolveModelPath(opts WhisperOptions) (string, error) {
+	modelPath := opts.ModelPath
+	if modelPath == "" {
+		name := filepath.Base(strings.TrimSpace(opts.Model))
+		if name == "" || name == "." {
+			name = defaultWhisperModel
+		}
+		modelPath = filepath.Join(e.modelDir, "ggml-"+name+".bin")
+	}
+	if _, err := os.Stat(modelPath); err != nil {
+		return "", PermanentError(fmt.Errorf("whisper asr: model not found: %w", err))
+	}
+	return modelPath, nil
+}
+
+// localArgs builds the whisper-cli command line. The full JSON (with per-token
+// timestamps) is written to <outputPrefix>.json.
+func (e *WhisperASREngine) localArgs(audioPath, outputPrefix string, opts WhisperOptions) ([]string, error) {
+	modelPath, err := e.resolveModelPath(opts)
+	if err != nil {
+		return nil, err
 	}
 
-	// Output JSON to stdout
+	language := "auto"
+	if !opts.autoLanguage() {
+		language = strings.ToLower(strings.TrimSpace(opts.Language))
+	}
 	args := []string{
+		"--model", modelPath,
 		"--file", audioPath,
-		"--model", "base",
-		"--output-json",
-		"--print-progress", "false",
+		"--language", language,
+		"--output-json-full",
+		"--output-file", outputPrefix,
+		"--print-progress",
+	}
+	if opts.Threads > 0 {
+		args = append(args, "--threads", strconv.Itoa(opts.Threads))
+	}
+	if opts.BeamSize > 0 {
+		args = append(args, "--beam-size", strconv.Itoa(opts.BeamSize))
+	}
+	if prompt := strings.TrimSpace(opts.Prompt); prompt != "" {
+		args = append(args, "--prompt", prompt)
+	}
+	if opts.VAD != nil && *opts.VAD {
+		if opts.VADModel == "" {
+			return nil, PermanentError(fmt.Errorf("whisper asr: vad enabled but no vad model configured"))
+		}
+		args = append(args, "--vad", "--vad-model", opts.VADModel)
+		if opts.VADThreshold > 0 {
+			args = append(args, "--vad-threshold", strconv.FormatFloat(opts.VADThreshold, 'f', -1, 64))
+		}
+	}
+	return args, nil
+}
+
+// transcribeLocal runs whisper-cli, feeding --print-progress lines into the
+// progress reporter and reading the JSON result file it writes.
+func (e *WhisperASREngine) transcribeLocal(ctx context.Context, audioPath string) (*TranscriptResult, error) {
+	opts := e.Options(ctx)
+
+	workDir, err := os.MkdirTemp("", "whisper-*")
+	if err != nil {
+		return nil, fmt.Errorf("whisper asr: create temp dir: %w", err)
 	}
+	defer os.RemoveAll(workDir)
+	outputPrefix := filepath.Join(workDir, "transcript")
 
-	cmd := exec.CommandContext(ctx, whisperBin, args...)
-	output, err := cmd.Output()
+	args, err := e.localArgs(audioPath, outputPrefix, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := utils.CommandContext(ctx, e.binaryPath, args...)
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("whisper asr: stderr pipe: %w", err)
+	}
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("whisper-cli start failed: %w", err)
+	}
+
+	// Keep the tail of stderr for error messages; progress lines are consumed here.
+	var tail []string
+	lastPercent := -1
+	scanner := bufio.NewScanner(stderr)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if match := whisperProgressPattern.FindStringSubmatch(line); match != nil {
+			if percent, err := strconv.Atoi(match[1]); err == nil && percent != lastPercent {
+				lastPercent = percent
+				reportASRProgress(ctx, percent)
+			}
+			continue
+		}
+		tail = append(tail, line)
+		if len(tail) > 20 {
+			tail = tail[1:]
+		}
+	}
+
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("whisper-cli execution failed: %w\n%s", err, strings.Join(tail, "\n"))
+	}
+
+	data, err := os.ReadFile(outputPrefix + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("whisper-cli produced no json output: %w\n%s", err, strings.Join(tail, "\n"))
+	}
+	result, err := parseWhisperCppOutput(data, opts.WordTimestamps != nil && *opts.WordTimestamps)
 	if err != nil {
-		return nil, fmt.Errorf("whisper-cli execution failed: %w\n%s", err, string(output))
+		return nil, err
+	}
+	if result.Language == "" && !opts.autoLanguage() {
+		result.Language = strings.ToLower(strings.TrimSpace(opts.Language))
 	}
+	return result, nil
+}
 
-	return e.parseWhisperOutput(output)
+// isRegularFile reports whether path exists and is not a directory.
+func isRegularFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
 }
 
 // transcribeAPI uses OpenAI-compatible Whisper API:
 // POST /v1/audio/transcriptions with multipart form
 func (e *WhisperASREngine) transcribeAPI(ctx context.Context, audioPath string) (*TranscriptResult, error) {
 	endpoint := e.apiURL + "/audio/transcriptions"
+	opts := e.Options(ctx)
 
 	file, err := os.Open(audioPath)
 	if err != nil {
@@ -116,7 +385,7 @@ func (e *WhisperASREngine) transcribeAPI(ctx context.Context, audioPath string)
 	w := multipart.NewWriter(&buf)
 
 	// Add model field
-	if err := w.WriteField("model", "whisper-1"); err != nil {
+	if err := w.WriteField("model", e.apiModel); err != nil {
 		return nil, fmt.Errorf("whisper api: write model field: %w", err)
 	}
 
@@ -125,6 +394,25 @@ func (e *WhisperASREngine) transcribeAPI(ctx context.Context, audioPath string)
 		return nil, fmt.Errorf("whisper api: write format: %w", err)
 	}
 
+	// Optional decoding hints; unset language lets the API detect it
+	fields := [][2]string{}
+	if !opts.autoLanguage() {
+		fields = append(fields, [2]string{"language", strings.ToLower(strings.TrimSpace(opts.Language))})
+	}
+	if prompt := strings.TrimSpace(opts.Prompt); prompt != "" {
+		fields = append(fields, [2]string{"prompt", prompt})
+	}
+	if opts.WordTimestamps != nil && *opts.WordTimestamps {
+		fields = append(fields,
+			[2]string{"timestamp_granularities[]", "segment"},
+			[2]string{"timestamp_granularities[]", "word"})
+	}
+	for _, field := range fields {
+		if err := w.WriteField(field[0], field[1]); err != nil {
+			return nil, fmt.Errorf("whisper api: write %s: %w", field[0], err)
+		}
+	}
+
 	// Add audio file
 	part, err := w.CreateFormFile("file", filepath.Base(audioPath))
 	if err != nil {
@@ -159,6 +447,7 @@ func (e *WhisperASREngine) transcribeAPI(ctx context.Context, audioPath string)
 		return nil, fmt.Errorf("whisper api: status %d: %s", resp.StatusCode, string(body))
 	}
 
+	reportASRProgress(ctx, 100)
 	return e.parseWhisperOutput(body)
 }
 
@@ -172,10 +461,17 @@ type whisperSegment struct {
 	CompressionRatio float64 `json:"compression_ratio"`
 }
 
+type whisperWord struct {
+	Word  string  `json:"word"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
 type whisperResponse struct {
 	Text     string           `json:"text"`
 	Language string           `json:"language"`
 	Segments []whisperSegment `json:"segments"`
+	Words    []whisperWord    `json:"words"`
 }
 
 func (e *WhisperASREngine) parseWhisperOutput(data []byte) (*TranscriptResult, error) {
@@ -196,9 +492,122 @@ func (e *WhisperASREngine) parseWhisperOutput(data []byte) (*TranscriptResult, e
 		})
 	}
 
+	// verbose_json returns words as a flat list; attach them to the segment they fall in
+	for _, word := range resp.Words {
+		text := strings.TrimSpace(word.Word)
+		if text == "" {
+			continue
+		}
+		for i := range segments {
+			if (word.Start >= segments[i].Start && word.Start < segments[i].End) || i == len(segments)-1 {
+				segments[i].Words = append(segments[i].Words, TranscriptWord{Start: word.Start, End: word.End, Text: text})
+				break
+			}
+		}
+	}
+
 	return &TranscriptResult{
 		Language: resp.Language,
 		FullText: strings.TrimSpace(resp.Text),
 		Segments: segments,
 	}, nil
 }
+
+// whisper.cpp --output-json-full format; offsets are in milliseconds.
+type whisperCppOffsets struct {
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+}
+
+type whisperCppToken struct {
+	Text    string            `json:"text"`
+	Offsets whisperCppOffsets `json:"offsets"`
+}
+
+type whisperCppSegment struct {
+	Offsets whisperCppOffsets `json:"offsets"`
+	Text    string            `json:"text"`
+	Tokens  []whisperCppToken `json:"tokens"`
+}
+
+type whisperCppResponse struct {
+	Result struct {
+		Language string `json:"language"`
+	} `json:"result"`
+	Transcription []whisperCppSegment `json:"transcription"`
+}
+
+func parseWhisperCppOutput(data []byte, withWords bool) (*TranscriptResult, error) {
+	var resp whisperCppResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("whisper asr: parse whisper-cli json: %w", err)
+	}
+
+	segments := make([]TranscriptSegment, 0, len(resp.Transcription))
+	texts := make([]string, 0, len(resp.Transcription))
+	for _, s := range resp.Transcription {
+		text := strings.TrimSpace(s.Text)
+		if text == "" {
+			continue
+		}
+		segment := TranscriptSegment{
+			Start: float64(s.Offsets.From) / 1000,
+			End:   float64(s.Offsets.To) / 1000,
+			Text:  text,
+		}
+		if withWords {
+			segment.Words = whisperCppWords(s.Tokens)
+		}
+		segments = append(segments, segment)
+		texts = append(texts, text)
+	}
+
+	return &TranscriptResult{
+		Language: resp.Result.Language,
+		FullText: strings.Join(texts, " "),
+		Segments: segments,
+	}, nil
+}
+
+// whisperCppWords joins sub-word tokens into words: a token starting with a space
+// begins a new word, special tokens such as [_BEG_] and [_TT_150] are dropped.
+// Chinese and Japanese tokens carry no leading space, so every token starting with
+// a Han or Kana character (or a Latin token right after one) begins a word as well.
+func whisperCppWords(tokens []whisperCppToken) []TranscriptWord {
+	var words []TranscriptWord
+	for _, token := range tokens {
+		if token.Text == "" || strings.HasPrefix(token.Text, "[_") {
+			continue
+		}
+		start := float64(token.Offsets.From) / 1000
+		end := float64(token.Offsets.To) / 1000
+		if len(words) == 0 || strings.HasPrefix(token.Text, " ") || startsUnspacedWord(words[len(words)-1].Text, token.Text) {
+			if strings.TrimSpace(token.Text) == "" {
+				continue
+			}
+			words = append(words, TranscriptWord{Start: start, End: end, Text: strings.TrimSpace(token.Text)})
+			continue
+		}
+		last := &words[len(words)-1]
+		last.Text += token.Text
+		if end > last.End {
+			last.End = end
+		}
+	}
+	return words
+}
+
+// startsUnspacedWord reports whether token begins a new word in text written without
+// spaces: it starts with a Han or Kana character, or is a letter or digit following one.
+func startsUnspacedWord(prev, token string) bool {
+	first, _ := utf8.DecodeRuneInString(token)
+	if isHanOrKana(first) {
+		return true
+	}
+	last, _ := utf8.DecodeLastRuneInString(prev)
+	return isHanOrKana(last) && (unicode.IsLetter(first) || unicode.IsDigit(first))
+}
+
+func isHanOrKana(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r)
+}
@@ -0,0 +1,185 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeWhisperCLI 模拟 whisper-cli：记录参数，向 stderr 打印进度，按 --output-file 写出完整 JSON
+const fakeWhisperCLI = `#!/bin/sh
+printf '%s\n' "$@" > "$(dirname "$0")/args.txt"
+out=""
+while [ $# -gt 0 ]; do
+	if [ "$1" = "--output-file" ]; then out="$2"; fi
+	shift
+done
+echo "whisper_init_from_file_with_params_no_state: loading model" >&2
+echo "whisper_print_progress_callback: progress =  10%" >&2
+echo "whisper_print_progress_callback: progress =  55%" >&2
+echo "whisper_print_progress_callback: progress = 100%" >&2
+cat > "$out.json" <<'JSON'
+{
+  "result": {"language": "ja"},
+  "transcription": [
+    {"offsets": {"from": 0, "to": 2500}, "text": " Hello world.",
+     "tokens": [
+       {"text": "[_BEG_]", "offsets": {"from": 0, "to": 0}},
+       {"text": " Hel", "offsets": {"from": 0, "to": 400}},
+       {"text": "lo", "offsets": {"from": 400, "to": 900}},
+       {"text": " world", "offsets": {"from": 1000, "to": 2000}},
+       {"text": ".", "offsets": {"from": 2000, "to": 2500}},
+       {"text": "[_TT_125]", "offsets": {"from": 2500, "to": 2500}}
+     ]},
+    {"offsets": {"from": 2500, "to": 3000}, "text": "  ", "tokens": []},
+    {"offsets": {"from": 3000, "to": 5200}, "text": " Second line", "tokens": []},
+    {"offsets": {"from": 5200, "to": 8000}, "text": "大家好欢迎回到我的频道，今天用iPhone",
+     "tokens": [
+       {"text": "大家", "offsets": {"from": 5200, "to": 5500}},
+       {"text": "好", "offsets": {"from": 5500, "to": 5700}},
+       {"text": "欢迎", "offsets": {"from": 5700, "to": 6000}},
+       {"text": "回到", "offsets": {"from": 6000, "to": 6300}},
+       {"text": "我的频道", "offsets": {"from": 6300, "to": 6900}},
+       {"text": "，", "offsets": {"from": 6900, "to": 7000}},
+       {"text": "今天", "offsets": {"from": 7000, "to": 7300}},
+       {"text": "用", "offsets": {"from": 7300, "to": 7400}},
+       {"text": "i", "offsets": {"from": 7400, "to": 7600}},
+       {"text": "Phone", "offsets": {"from": 7600, "to": 8000}}
+     ]}
+  ]
+}
+JSON
+`
+
+func newFakeWhisperEngine(t *testing.T, defaults WhisperOptions) (*WhisperASREngine, string, string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake whisper-cli requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "whisper-cli"), []byte(fakeWhisperCLI), 0o755); err != nil {
+		t.Fatalf("write fake whisper-cli: %v", err)
+	}
+	for _, name := range []string{"ggml-base.bin", "ggml-large-v3.bin", "silero.bin"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("model"), 0o644); err != nil {
+			t.Fatalf("write model: %v", err)
+		}
+	}
+	audio := filepath.Join(dir, "audio.mp3")
+	if err := os.WriteFile(audio, []byte("audio"), 0o644); err != nil {
+		t.Fatalf("write audio: %v", err)
+	}
+	return NewWhisperASREngine(WhisperConfig{WhisperOptions: defaults, ModelDir: dir}), dir, audio
+}
+
+func readFakeWhisperArgs(t *testing.T, dir string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, "args.txt"))
+	if err != nil {
+		t.Fatalf("read args: %v", err)
+	}
+	return strings.ReplaceAll(strings.TrimSpace(string(data)), "\n", " ")
+}
+
+func TestWhisperLocal_OptionsProgressAndWords(t *testing.T) {
+	enabled := true
+	engine, dir, audio := newFakeWhisperEngine(t, WhisperOptions{
+		Threads:        4,
+		VAD:            &enabled,
+		VADModel:       "silero.bin",
+		VADThreshold:   0.6,
+		WordTimestamps: &enabled,
+	})
+
+	var progress []int
+	ctx := WithASRProgressReporter(context.Background(), func(percent int) {
+		progress = append(progress, percent)
+	})
+	ctx = WithWhisperOptions(ctx, WhisperOptions{Model: "large-v3", Language: "JA", BeamSize: 5, Prompt: "固有名詞"})
+
+	result, err := engine.Transcribe(ctx, audio)
+	if err != nil {
+		t.Fatalf("Transcribe: %v", err)
+	}
+
+	args := readFakeWhisperArgs(t, dir)
+	for _, want := range []string{
+		"--model " + filepath.Join(dir, "ggml-large-v3.bin"),
+		"--file " + audio,
+		"--language ja",
+		"--output-json-full",
+		"--print-progress",
+		"--threads 4",
+		"--beam-size 5",
+		"--prompt 固有名詞",
+		"--vad --vad-model silero.bin --vad-threshold 0.6",
+	} {
+		if !strings.Contains(args, want) {
+			t.Fatalf("expected args to contain %q, got %s", want, args)
+		}
+	}
+	if fmt.Sprint(progress) != "[10 55 100]" {
+		t.Fatalf("expected progress 10,55,100, got %v", progress)
+	}
+
+	if result.Language != "ja" || len(result.Segments) != 3 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	first := result.Segments[0]
+	if first.Text != "Hello world." || first.Start != 0 || first.End != 2.5 {
+		t.Fatalf("unexpected first segment: %+v", first)
+	}
+	if len(first.Words) != 2 || first.Words[0].Text != "Hello" || first.Words[0].End != 0.9 ||
+		first.Words[1].Text != "world." || first.Words[1].Start != 1 || first.Words[1].End != 2.5 {
+		t.Fatalf("unexpected words: %+v", first.Words)
+	}
+	if second := result.Segments[1]; second.Start != 3 || second.End != 5.2 || len(second.Words) != 0 {
+		t.Fatalf("unexpected second segment: %+v", second)
+	}
+	var zhWords []string
+	for _, word := range result.Segments[2].Words {
+		zhWords = append(zhWords, word.Text)
+	}
+	if got := strings.Join(zhWords, "|"); got != "大家|好|欢迎|回到|我的频道，|今天|用|iPhone" {
+		t.Fatalf("expected one word per CJK token, got %s", got)
+	}
+	if words := result.Segments[2].Words; words[4].Start != 6.3 || words[4].End != 7 {
+		t.Fatalf("expected punctuation to extend the previous word, got %+v", words[4])
+	}
+	if result.FullText != "Hello world. Second line 大家好欢迎回到我的频道，今天用iPhone" {
+		t.Fatalf("unexpected full text %q", result.FullText)
+	}
+	if engine.ModelName(ctx) != "large-v3" {
+		t.Fatalf("expected model name large-v3, got %s", engine.ModelName(ctx))
+	}
+}
+
+func TestWhisperLocal_DefaultsAndMissingModel(t *testing.T) {
+	engine, dir, audio := newFakeWhisperEngine(t, WhisperOptions{})
+
+	result, err := engine.Transcribe(context.Background(), audio)
+	if err != nil {
+		t.Fatalf("Transcribe: %v", err)
+	}
+	args := readFakeWhisperArgs(t, dir)
+	if !strings.Contains(args, "--model "+filepath.Join(dir, "ggml-base.bin")) || !strings.Contains(args, "--language auto") {
+		t.Fatalf("expected base model and auto language, got %s", args)
+	}
+	for _, unwanted := range []string{"--threads", "--beam-size", "--prompt", "--vad"} {
+		if strings.Contains(args, unwanted) {
+			t.Fatalf("unexpected %s in default args: %s", unwanted, args)
+		}
+	}
+	if len(result.Segments[0].Words) != 0 {
+		t.Fatalf("words must only be filled when word timestamps are enabled")
+	}
+
+	ctx := WithWhisperOptions(context.Background(), WhisperOptions{Model: "medium"})
+	if _, err := engine.Transcribe(ctx, audio); err == nil || !IsPermanentError(err) {
+		t.Fatalf("expected permanent error for missing model, got %v", err)
+	}
+}
@@ -330,6 +330,9 @@ func (t *BatchTranslator) translateGroup(ctx context.Context, texts []string, pr
 		len(texts),
 		sentenceBreak,
 		getLangName(runConfig.TargetLang))
+	if instructions := strings.TrimSpace(runConfig.Instructions); instructions != "" {
+		systemPrompt += "\n\n" + instructions
+	}
 
 	combinedText := strings.Join(fullTexts, "\n"+sentenceBreak+"\n")
 
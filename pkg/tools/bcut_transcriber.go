@@ -46,6 +46,14 @@ func (t *BcutTranscriberTool) Description() string { return t.desc }
 
 // TranscriptSegment 转录片段
 type TranscriptSegment struct {
+	Start float64          `json:"start"`
+	End   float64          `json:"end"`
+	Text  string           `json:"text"`
+	Words []TranscriptWord `json:"words,omitempty"` // 词级时间戳，仅部分引擎提供
+}
+
+// TranscriptWord 词级时间戳
+type TranscriptWord struct {
 	Start float64 `json:"start"`
 	End   float64 `json:"end"`
 	Text  string  `json:"text"`
@@ -57,6 +65,7 @@ type TranscriptResult struct {
 	FullText string              `json:"full_text"`
 	Segments []TranscriptSegment `json:"segments"`
 	SRTPath  string              `json:"srt_path,omitempty"` // SRT 字幕文件路径
+	Engine   string              `json:"engine,omitempty"`   // 实际产出结果的转写引擎（多引擎回退时记录）
 }
 
 // uploadResponse 上传响应
@@ -142,12 +151,12 @@ func NewBcutTranscriberTool(logger *zap.Logger) *BcutTranscriberTool {
 func (t *BcutTranscriberTool) Call(ctx context.Context, input string) (string, error) {
 	filePath := strings.TrimSpace(input)
 	if filePath == "" {
-		return "", fmt.Errorf("file path cannot be empty")
+		return "", PermanentError(fmt.Errorf("file path cannot be empty"))
 	}
 
 	// 检查文件是否存在
 	if _, err := os.Stat(filePath); err != nil {
-		return "", fmt.Errorf("audio file not found: %w", err)
+		return "", PermanentError(fmt.Errorf("audio file not found: %w", err))
 	}
 
 	t.logger.Info("Starting BCut transcription",
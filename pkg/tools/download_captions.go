@@ -0,0 +1,411 @@
+package tools
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bytedance/sonic"
+	"github.com/difyz9/ytb2bili/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// ── Platform Captions ────────────────────────────────────────────────────────
+// FetchCaptions downloads the captions YouTube already has for a video
+// (creator-uploaded or auto-generated) through yt-dlp and parses them into a
+// TranscriptResult, so that ASR can be skipped when good captions exist.
+
+// CaptionKind distinguishes creator-uploaded captions from auto-generated ones.
+type CaptionKind string
+
+const (
+	CaptionKindManual CaptionKind = "manual" // uploaded by the creator
+	CaptionKindAuto   CaptionKind = "auto"   // generated by the platform's own ASR
+)
+
+// captionFormats preferred subtitle formats: srv3 carries word timings, vtt is the fallback.
+const captionFormats = "srv3/vtt/best"
+
+// ErrNoCaptions the video has no captions of the requested kind and language.
+var ErrNoCaptions = errors.New("no captions available")
+
+// CaptionRequest selects the captions to fetch.
+type CaptionRequest struct {
+	Input    string      // video URL or ID
+	Kind     CaptionKind // manual or auto
+	Language string      // source language; empty uses the video's original language
+}
+
+type ytDLPCaptionInfo struct {
+	ID                string                          `json:"id"`
+	Language          string                          `json:"language"`
+	Subtitles         map[string][]ytDLPCaptionFormat `json:"subtitles"`
+	AutomaticCaptions map[string][]ytDLPCaptionFormat `json:"automatic_captions"`
+}
+
+type ytDLPCaptionFormat struct {
+	Ext string `json:"ext"`
+}
+
+// FetchCaptions downloads one caption track (srv3, else vtt) next to the video and parses it.
+// It returns an error wrapping ErrNoCaptions when no suitable track exists.
+func (t *DownloadVideoTool) FetchCaptions(ctx context.Context, req CaptionRequest) (*TranscriptResult, error) {
+	req.Input = strings.TrimSpace(req.Input)
+	if req.Input == "" {
+		return nil, fmt.Errorf("video URL or ID cannot be empty")
+	}
+	if latest := findLatestCookiesFile(t.cookiesDir); latest != "" && latest != t.cookiesFile {
+		t.cookiesFile = latest
+	}
+	url := normalizeURL(req.Input)
+
+	var baseArgs []string
+	if t.proxyURL != "" {
+		baseArgs = append(baseArgs, "--proxy", t.proxyURL)
+	}
+	if t.cookiesFile != "" {
+		baseArgs = append(baseArgs, "--cookies", t.cookiesFile)
+	}
+
+	infoArgs := append(copyArgs(baseArgs), "--dump-single-json", "--skip-download", "--no-playlist", "--no-warnings", url)
+	out, err := utils.CommandContext(ctx, t.ytdlpPath, infoArgs...).CombinedOutput()
+	if err != nil {
+		return nil, handleDownloadError(err, string(out))
+	}
+	var info ytDLPCaptionInfo
+	if err := sonic.UnmarshalString(strings.TrimSpace(string(out)), &info); err != nil {
+		return nil, fmt.Errorf("parse caption list: %w", err)
+	}
+
+	tracks, writeFlag := info.Subtitles, "--write-subs"
+	if req.Kind == CaptionKindAuto {
+		tracks, writeFlag = info.AutomaticCaptions, "--write-auto-subs"
+	}
+	track := selectCaptionTrack(tracks, req.Kind, req.Language, info.Language)
+	if track == "" {
+		return nil, PermanentError(fmt.Errorf("%w: %s captions in %q", ErrNoCaptions, req.Kind, firstNonEmpty(req.Language, info.Language, "any language")))
+	}
+
+	videoID := strings.TrimSpace(info.ID)
+	if videoID == "" {
+		videoID = extractVideoID(req.Input)
+	}
+	dir := filepath.Join(t.downloadDir, videoID, "captions")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create captions directory: %w", err)
+	}
+	args := append(copyArgs(baseArgs),
+		"--skip-download", "--no-playlist", "--no-warnings",
+		writeFlag,
+		"--sub-langs", "^"+regexp.QuoteMeta(track)+"$",
+		"--sub-format", captionFormats,
+		"-P", dir,
+		"-o", "%(id)s.%(ext)s",
+		url,
+	)
+	if out, err := utils.CommandContext(ctx, t.ytdlpPath, args...).CombinedOutput(); err != nil {
+		return nil, handleDownloadError(err, string(out))
+	}
+
+	path, format := findCaptionFile(dir, videoID, track)
+	if path == "" {
+		return nil, fmt.Errorf("caption download completed but %s track %s not found", req.Kind, track)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read captions: %w", err)
+	}
+
+	var segments []TranscriptSegment
+	switch format {
+	case "srv3":
+		segments, err = parseSRV3Captions(data)
+	default:
+		segments = parseVTTCaptions(string(data))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse %s captions: %w", format, err)
+	}
+
+	texts := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		texts = append(texts, segment.Text)
+	}
+	t.logger.Info("Captions fetched",
+		zap.String("video_id", videoID),
+		zap.String("kind", string(req.Kind)),
+		zap.String("track", track),
+		zap.String("format", format),
+		zap.Int("segments", len(segments)))
+	return &TranscriptResult{
+		Language: strings.TrimSuffix(track, "-orig"),
+		FullText: strings.Join(texts, " "),
+		Segments: segments,
+	}, nil
+}
+
+// selectCaptionTrack picks the track in the wanted language, falling back to the
+// video's original language. Auto captions only use the original-language ASR
+// track ("<lang>-orig" or the original language itself), never YouTube's machine
+// translations of it.
+func selectCaptionTrack(tracks map[string][]ytDLPCaptionFormat, kind CaptionKind, want, original string) string {
+	keys := make([]string, 0, len(tracks))
+	for key, formats := range tracks {
+		if key != "live_chat" && len(formats) > 0 {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	want, original = baseLanguageCode(want), baseLanguageCode(original)
+	matches := func(key, language string) bool {
+		return language != "" && baseLanguageCode(strings.TrimSuffix(key, "-orig")) == language
+	}
+
+	if kind == CaptionKindAuto {
+		for _, key := range keys {
+			if !strings.HasSuffix(key, "-orig") {
+				continue
+			}
+			if want == "" || matches(key, want) {
+				return key
+			}
+			original = firstNonEmpty(original, baseLanguageCode(strings.TrimSuffix(key, "-orig")))
+		}
+		// every other auto track is a machine translation of the original-language one
+		if want != "" && original != "" && want != original {
+			return ""
+		}
+		language := firstNonEmpty(original, want)
+		for _, key := range keys {
+			if matches(key, language) {
+				return key
+			}
+		}
+		return ""
+	}
+
+	language := firstNonEmpty(want, original)
+	if language == "" {
+		// with no known language only a single track is unambiguous
+		if len(keys) == 1 {
+			return keys[0]
+		}
+		return ""
+	}
+	for _, key := range keys {
+		// an exact code wins over a regional variant (en before en-GB)
+		if strings.EqualFold(key, language) {
+			return key
+		}
+	}
+	for _, key := range keys {
+		if matches(key, language) {
+			return key
+		}
+	}
+	return ""
+}
+
+func findCaptionFile(dir, videoID, track string) (string, string) {
+	for _, format := range []string{"srv3", "vtt"} {
+		path := filepath.Join(dir, videoID+"."+track+"."+format)
+		if isRegularFile(path) {
+			return path, format
+		}
+	}
+	return "", ""
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// ── SRV3 ─────────────────────────────────────────────────────────────────────
+
+type srv3Document struct {
+	Paragraphs []srv3Paragraph `xml:"body>p"`
+}
+
+type srv3Paragraph struct {
+	Start    int        `xml:"t,attr"` // ms
+	Duration int        `xml:"d,attr"` // ms
+	Append   int        `xml:"a,attr"` // 1 = line break appended to the previous cue, no text of its own
+	Text     string     `xml:",chardata"`
+	Spans    []srv3Span `xml:"s"`
+}
+
+type srv3Span struct {
+	Offset int    `xml:"t,attr"` // ms relative to the paragraph
+	Text   string `xml:",chardata"`
+}
+
+// parseSRV3Captions parses YouTube's timedtext format 3. Auto captions carry one
+// <s> span per word with its offset, which become the segment's word timings.
+func parseSRV3Captions(data []byte) ([]TranscriptSegment, error) {
+	var doc srv3Document
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	segments := make([]TranscriptSegment, 0, len(doc.Paragraphs))
+	for _, p := range doc.Paragraphs {
+		if p.Append == 1 {
+			continue
+		}
+		start := float64(p.Start) / 1000
+		end := float64(p.Start+p.Duration) / 1000
+
+		if len(p.Spans) == 0 {
+			if text := strings.Join(strings.Fields(p.Text), " "); text != "" {
+				segments = append(segments, TranscriptSegment{Start: start, End: end, Text: text})
+			}
+			continue
+		}
+
+		var raw strings.Builder
+		words := make([]TranscriptWord, 0, len(p.Spans))
+		for _, span := range p.Spans {
+			raw.WriteString(span.Text)
+			if word := strings.TrimSpace(span.Text); word != "" {
+				words = append(words, TranscriptWord{Start: start + float64(span.Offset)/1000, Text: word})
+			}
+		}
+		text := strings.Join(strings.Fields(raw.String()), " ")
+		if text == "" {
+			continue
+		}
+		for i := range words {
+			words[i].End = end
+			if i+1 < len(words) {
+				words[i].End = words[i+1].Start
+			}
+		}
+		segments = append(segments, TranscriptSegment{Start: start, End: end, Text: text, Words: words})
+	}
+	return trimCaptionOverlaps(segments), nil
+}
+
+// ── WebVTT ───────────────────────────────────────────────────────────────────
+
+var (
+	vttTagPattern    = regexp.MustCompile(`<[^>]*>`)
+	vttTimingPattern = regexp.MustCompile(`^((?:\d+:)?\d{2}:\d{2}\.\d{3})\s+-->\s+((?:\d+:)?\d{2}:\d{2}\.\d{3})`)
+)
+
+// minCaptionCueSeconds YouTube's auto-caption VTT inserts 10ms cues while a line scrolls up.
+const minCaptionCueSeconds = 0.05
+
+// parseVTTCaptions parses WebVTT. YouTube's auto-caption VTT is "rolling": every
+// cue repeats the previous line above the new one, so lines already emitted are dropped.
+func parseVTTCaptions(data string) []TranscriptSegment {
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	var (
+		segments []TranscriptSegment
+		lastLine string
+	)
+	for _, block := range strings.Split(data, "\n\n") {
+		lines := strings.Split(strings.TrimSpace(block), "\n")
+		timing := -1
+		for i, line := range lines {
+			if vttTimingPattern.MatchString(strings.TrimSpace(line)) {
+				timing = i
+				break
+			}
+		}
+		if timing < 0 {
+			continue
+		}
+		match := vttTimingPattern.FindStringSubmatch(strings.TrimSpace(lines[timing]))
+		start, end := parseVTTTimestamp(match[1]), parseVTTTimestamp(match[2])
+		if end-start < minCaptionCueSeconds {
+			continue
+		}
+
+		var texts []string
+		for _, line := range lines[timing+1:] {
+			line = strings.Join(strings.Fields(html.UnescapeString(vttTagPattern.ReplaceAllString(line, ""))), " ")
+			if line == "" || line == lastLine {
+				continue
+			}
+			texts = append(texts, line)
+			lastLine = line
+		}
+		if len(texts) > 0 {
+			segments = append(segments, TranscriptSegment{Start: start, End: end, Text: strings.Join(texts, " ")})
+		}
+	}
+	return trimCaptionOverlaps(segments)
+}
+
+// parseVTTTimestamp parses "HH:MM:SS.mmm" or "MM:SS.mmm" into seconds.
+func parseVTTTimestamp(value string) float64 {
+	parts := strings.Split(value, ":")
+	var seconds float64
+	for _, part := range parts {
+		n, _ := strconv.ParseFloat(part, 64)
+		seconds = seconds*60 + n
+	}
+	return seconds
+}
+
+// trimCaptionOverlaps ends each cue where the next one starts; rolling captions overlap otherwise.
+func trimCaptionOverlaps(segments []TranscriptSegment) []TranscriptSegment {
+	for i := 0; i+1 < len(segments); i++ {
+		if next := segments[i+1].Start; segments[i].End > next && next > segments[i].Start {
+			segments[i].End = next
+			words := segments[i].Words
+			for j := range words {
+				words[j].End = min(words[j].End, next)
+			}
+		}
+	}
+	return segments
+}
+
+// ── ASREngine adapter ────────────────────────────────────────────────────────
+
+// CaptionASREngine exposes one kind of platform captions as an ASREngine, so that
+// captions take part in the ASR fallback chain and its quality checks. The audio
+// path is ignored; captions are fetched for the configured video.
+type CaptionASREngine struct {
+	tool     *DownloadVideoTool
+	input    string
+	kind     CaptionKind
+	language string
+}
+
+func NewCaptionASREngine(tool *DownloadVideoTool, input string, kind CaptionKind, language string) *CaptionASREngine {
+	return &CaptionASREngine{tool: tool, input: input, kind: kind, language: language}
+}
+
+func (e *CaptionASREngine) Name() string {
+	return "captions_" + string(e.kind)
+}
+
+func (e *CaptionASREngine) Languages() []string {
+	return nil
+}
+
+func (e *CaptionASREngine) Transcribe(ctx context.Context, _ string) (*TranscriptResult, error) {
+	result, err := e.tool.FetchCaptions(ctx, CaptionRequest{Input: e.input, Kind: e.kind, Language: e.language})
+	if err != nil {
+		return nil, err
+	}
+	reportASRProgress(ctx, 100)
+	return result, nil
+}
+
+var _ ASREngine = (*CaptionASREngine)(nil)
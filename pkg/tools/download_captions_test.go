@@ -0,0 +1,161 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestParseSRV3Captions(t *testing.T) {
+	data := []byte(`<?xml version="1.0" encoding="utf-8" ?><timedtext format="3">
+<head><ws id="0"/></head>
+<body>
+<p t="1200" d="3000" w="1"><s ac="0">hello</s><s t="480" ac="0"> world</s><s t="1000" ac="0"> it&#39;s</s></p>
+<p t="3000" d="10" w="1" a="1">
+</p>
+<p t="3010" d="2500">Manual line
+continues here</p>
+</body></timedtext>`)
+
+	segments, err := parseSRV3Captions(data)
+	if err != nil {
+		t.Fatalf("parseSRV3Captions: %v", err)
+	}
+	if len(segments) != 2 {
+		t.Fatalf("expected 2 segments, got %+v", segments)
+	}
+	first := segments[0]
+	if first.Text != "hello world it's" || first.Start != 1.2 || first.End != 3.01 {
+		t.Fatalf("unexpected first segment: %+v", first)
+	}
+	if got := fmt.Sprint(first.Words); got != "[{1.2 1.68 hello} {1.68 2.2 world} {2.2 3.01 it's}]" {
+		t.Fatalf("unexpected words: %s", got)
+	}
+	if second := segments[1]; second.Text != "Manual line continues here" || second.End != 5.51 || len(second.Words) != 0 {
+		t.Fatalf("unexpected second segment: %+v", second)
+	}
+}
+
+func TestParseVTTCaptions_RollingAutoCaptions(t *testing.T) {
+	data := "WEBVTT\nKind: captions\nLanguage: en\n\n" +
+		"00:00:00.160 --> 00:00:02.590 align:start position:0%\n \nwelcome<00:00:00.640><c> back</c><00:00:01.120><c> everyone</c>\n\n" +
+		"00:00:02.590 --> 00:00:02.600 align:start position:0%\nwelcome back everyone\n \n\n" +
+		"00:00:02.600 --> 00:00:05.000 align:start position:0%\nwelcome back everyone\ntoday<00:00:03.000><c> we</c><c> talk</c>\n\n" +
+		"1:00:05.000 --> 1:00:07.000\nTom &amp; Jerry\n"
+
+	segments := parseVTTCaptions(data)
+	var got []string
+	for _, segment := range segments {
+		got = append(got, fmt.Sprintf("%.2f-%.2f %s", segment.Start, segment.End, segment.Text))
+	}
+	want := []string{
+		"0.16-2.59 welcome back everyone",
+		"2.60-5.00 today we talk",
+		"3605.00-3607.00 Tom & Jerry",
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected segments:\n got %v\nwant %v", got, want)
+	}
+}
+
+func TestSelectCaptionTrack(t *testing.T) {
+	tracks := func(keys ...string) map[string][]ytDLPCaptionFormat {
+		m := map[string][]ytDLPCaptionFormat{}
+		for _, key := range keys {
+			m[key] = []ytDLPCaptionFormat{{Ext: "srv3"}}
+		}
+		return m
+	}
+	tests := []struct {
+		name     string
+		tracks   map[string][]ytDLPCaptionFormat
+		kind     CaptionKind
+		want     string
+		original string
+		expected string
+	}{
+		{name: "manual exact", tracks: tracks("en-GB", "en", "fr"), kind: CaptionKindManual, want: "en", expected: "en"},
+		{name: "manual regional", tracks: tracks("en-GB", "fr"), kind: CaptionKindManual, want: "en-US", expected: "en-GB"},
+		{name: "manual original language", tracks: tracks("ja", "en"), kind: CaptionKindManual, original: "ja", expected: "ja"},
+		{name: "manual other language only", tracks: tracks("es"), kind: CaptionKindManual, want: "en", expected: ""},
+		{name: "manual single unknown", tracks: tracks("de", "live_chat"), kind: CaptionKindManual, expected: "de"},
+		{name: "manual ambiguous", tracks: tracks("de", "fr"), kind: CaptionKindManual, expected: ""},
+		{name: "auto orig", tracks: tracks("de", "en", "en-orig", "fr"), kind: CaptionKindAuto, expected: "en-orig"},
+		{name: "auto original", tracks: tracks("de", "ko", "zh-Hans"), kind: CaptionKindAuto, original: "ko", expected: "ko"},
+		{name: "auto translation rejected", tracks: tracks("de", "en", "ko"), kind: CaptionKindAuto, want: "en", original: "ko", expected: ""},
+		{name: "auto orig other language", tracks: tracks("en", "ko-orig"), kind: CaptionKindAuto, want: "en", expected: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := selectCaptionTrack(tt.tracks, tt.kind, tt.want, tt.original); got != tt.expected {
+				t.Fatalf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+// fakeYtDLPCaptions 模拟 yt-dlp：--dump-single-json 输出字幕列表，--write-auto-subs 写出 srv3 文件
+const fakeYtDLPCaptions = `#!/bin/sh
+case "$*" in
+*--dump-single-json*)
+	echo '{"id":"abc123","language":"en","subtitles":{},"automatic_captions":{"en-orig":[{"ext":"srv3"}],"de":[{"ext":"srv3"}]}}'
+	;;
+*--write-auto-subs*)
+	dir=""
+	while [ $# -gt 0 ]; do
+		if [ "$1" = "-P" ]; then dir="$2"; fi
+		shift
+	done
+	echo '<timedtext format="3"><body><p t="0" d="1500"><s>auto</s><s t="700"> caption</s></p></body></timedtext>' > "$dir/abc123.en-orig.srv3"
+	;;
+*)
+	exit 1
+	;;
+esac
+`
+
+func TestFetchCaptions_AutoCaptionsViaYtDLP(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake yt-dlp requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	ytdlp := filepath.Join(dir, "yt-dlp")
+	if err := os.WriteFile(ytdlp, []byte(fakeYtDLPCaptions), 0o755); err != nil {
+		t.Fatalf("write fake yt-dlp: %v", err)
+	}
+	tool, err := NewDownloadVideoTool(DownloadVideoConfig{
+		YtDlpPath:   ytdlp,
+		DownloadDir: filepath.Join(dir, "downloads"),
+		CookiesDir:  filepath.Join(dir, "cookies"),
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewDownloadVideoTool: %v", err)
+	}
+
+	_, err = tool.FetchCaptions(context.Background(), CaptionRequest{Input: "abc123", Kind: CaptionKindManual})
+	if !errors.Is(err, ErrNoCaptions) || !IsPermanentError(err) {
+		t.Fatalf("expected permanent ErrNoCaptions without manual captions, got %v", err)
+	}
+
+	engine := NewCaptionASREngine(tool, "abc123", CaptionKindAuto, "")
+	result, err := engine.Transcribe(context.Background(), "ignored.mp3")
+	if err != nil {
+		t.Fatalf("Transcribe: %v", err)
+	}
+	if engine.Name() != "captions_auto" || result.Language != "en" || result.FullText != "auto caption" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if len(result.Segments) != 1 || len(result.Segments[0].Words) != 2 || result.Segments[0].Words[1].Start != 0.7 {
+		t.Fatalf("unexpected segments: %+v", result.Segments)
+	}
+	if !isRegularFile(filepath.Join(dir, "downloads", "abc123", "captions", "abc123.en-orig.srv3")) {
+		t.Fatal("expected captions stored next to the video")
+	}
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/bytedance/sonic"
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/schema"
+	"github.com/difyz9/ytb2bili/pkg/utils"
 	"go.uber.org/zap"
 )
 
@@ -29,6 +30,7 @@ type DownloadVideoTool struct {
 	cookiesDir  string // downloadDir/cookies – where the browser extension saves files
 	cookiesFile string
 	proxyURL    string
+	rateLimit   string
 	logger      *zap.Logger
 }
 
@@ -70,6 +72,14 @@ type PlaylistResult struct {
 	Entries    []PlaylistEntry
 }
 
+// VideoProbe 不下载视频、仅由 yt-dlp 读取的元信息（用于处理前预估）
+type VideoProbe struct {
+	VideoID         string
+	Title           string
+	DurationSeconds float64
+	SizeBytes       int64 // 默认格式的预估大小（视频 + 音频），未知时为 0
+}
+
 type DownloadResult struct {
 	VideoPath  string
 	Client     string
@@ -109,6 +119,9 @@ type ytDLPProbe struct {
 	Width              int                    `json:"width"`
 	Height             int                    `json:"height"`
 	Ext                string                 `json:"ext"`
+	Duration           float64                `json:"duration"`
+	Filesize           float64                `json:"filesize"`
+	FilesizeApprox     float64                `json:"filesize_approx"`
 	Entries            []ytDLPPlaylistEntry   `json:"entries"`
 	RequestedFormats   []ytDLPRequestedFormat `json:"requested_formats"`
 	RequestedDownloads []ytDLPRequestedFormat `json:"requested_downloads"`
@@ -130,6 +143,9 @@ type ytDLPRequestedFormat struct {
 	Ext        string `json:"ext"`
 	VCodec     string `json:"vcodec"`
 	ACodec     string `json:"acodec"`
+
+	Filesize       float64 `json:"filesize"`
+	FilesizeApprox float64 `json:"filesize_approx"`
 }
 
 type downloadArgs struct {
@@ -161,6 +177,7 @@ type DownloadVideoConfig struct {
 	CookiesDir  string // directory scanned for *.txt cookie files; defaults to /tmp/cookies
 	CookiesFile string
 	ProxyURL    string
+	RateLimit   string // passed to yt-dlp --limit-rate, e.g. "5M"; empty means unlimited
 }
 
 // NewDownloadVideoTool creates a DownloadVideoTool.
@@ -203,6 +220,7 @@ func NewDownloadVideoTool(config DownloadVideoConfig, logger *zap.Logger) (*Down
 		cookiesDir:  cookiesDir,
 		cookiesFile: cookiesFile,
 		proxyURL:    config.ProxyURL,
+		rateLimit:   strings.TrimSpace(config.RateLimit),
 		logger:      logger,
 	}, nil
 }
@@ -305,7 +323,7 @@ func (t *DownloadVideoTool) ListPlaylistEntries(ctx context.Context, input strin
 	}
 	args = append(args, normalizePlaylistURL(input, playlistID))
 
-	cmd := exec.CommandContext(ctx, t.ytdlpPath, args...)
+	cmd := utils.CommandContext(ctx, t.ytdlpPath, args...)
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		return nil, handleDownloadError(err, string(out))
@@ -402,6 +420,9 @@ func (t *DownloadVideoTool) download(ctx context.Context, videoID string, req Do
 	if t.proxyURL != "" {
 		baseArgs = append(baseArgs, "--proxy", t.proxyURL)
 	}
+	if t.rateLimit != "" {
+		baseArgs = append(baseArgs, "--limit-rate", t.rateLimit)
+	}
 	url := normalizeURL(req.Input)
 	requiredMinHeight := minimumHeightForResolution(preferredResolution)
 
@@ -632,7 +653,7 @@ func (t *DownloadVideoTool) runDownloadCommand(ctx context.Context, label, video
 		"--newline",
 		"--progress-template", "download:"+ytDLPProgressPrefix+"%(progress._percent_str)s|%(progress.downloaded_bytes)s|%(progress.total_bytes)s|%(progress.total_bytes_estimate)s|%(progress.eta)s",
 	)
-	cmd := exec.CommandContext(ctx, t.ytdlpPath, progressArgs...)
+	cmd := utils.CommandContext(ctx, t.ytdlpPath, progressArgs...)
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
@@ -716,7 +737,7 @@ func parseDownloadProgressLine(line, client string, selection ytDLPSelection) (D
 		messageParts = append(messageParts, selection.resolutionLabel())
 	}
 	if downloaded > 0 && total > 0 {
-		messageParts = append(messageParts, humanizeBytes(downloaded)+"/"+humanizeBytes(total))
+		messageParts = append(messageParts, HumanizeBytes(downloaded)+"/"+HumanizeBytes(total))
 	}
 	if eta != "" && eta != "NA" {
 		messageParts = append(messageParts, "ETA "+eta+"s")
@@ -758,7 +779,8 @@ func parseProgressInt64(value string) int64 {
 	return int64(floatValue)
 }
 
-func humanizeBytes(value int64) string {
+// HumanizeBytes formats a byte count as B/KB/MB/GB/TB.
+func HumanizeBytes(value int64) string {
 	if value <= 0 {
 		return "0B"
 	}
@@ -775,11 +797,65 @@ func humanizeBytes(value int64) string {
 	return fmt.Sprintf("%.1f%s", floatValue, units[unitIndex])
 }
 
+// Probe 读取单个视频的元信息（标题、时长），不下载任何文件
+func (t *DownloadVideoTool) Probe(ctx context.Context, url string) (*VideoProbe, error) {
+	if latest := findLatestCookiesFile(t.cookiesDir); latest != "" && latest != t.cookiesFile {
+		t.cookiesFile = latest
+	}
+
+	args := []string{"--dump-single-json", "--skip-download", "--no-playlist", "--no-warnings"}
+	if t.proxyURL != "" {
+		args = append(args, "--proxy", t.proxyURL)
+	}
+	if t.cookiesFile != "" {
+		args = append(args, "--cookies", t.cookiesFile)
+	}
+	args = append(args, url)
+
+	cmd := utils.CommandContext(ctx, t.ytdlpPath, args...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, handleDownloadError(err, string(out))
+	}
+
+	var probe ytDLPProbe
+	if err := sonic.UnmarshalString(strings.TrimSpace(string(out)), &probe); err != nil {
+		return nil, fmt.Errorf("parse video metadata: %w", err)
+	}
+	return &VideoProbe{
+		VideoID:         strings.TrimSpace(probe.ID),
+		Title:           strings.TrimSpace(probe.Title),
+		DurationSeconds: probe.Duration,
+		SizeBytes:       probe.estimatedSize(),
+	}, nil
+}
+
+// estimatedSize 默认格式的预估大小：分离的视频/音频流相加，否则取单文件大小
+func (p ytDLPProbe) estimatedSize() int64 {
+	var total float64
+	for _, f := range p.RequestedFormats {
+		total += firstPositiveSize(f.Filesize, f.FilesizeApprox)
+	}
+	if total > 0 {
+		return int64(total)
+	}
+	return int64(firstPositiveSize(p.Filesize, p.FilesizeApprox))
+}
+
+func firstPositiveSize(values ...float64) float64 {
+	for _, v := range values {
+		if v > 0 {
+			return v
+		}
+	}
+	return 0
+}
+
 func (t *DownloadVideoTool) probeDownloadSelection(ctx context.Context, strategyArgs []string, url string) (ytDLPSelection, error) {
 	probeArgs := append(copyArgs(strategyArgs), "--dump-single-json", "--skip-download", "--no-warnings")
 	probeArgs = append(probeArgs, url)
 
-	cmd := exec.CommandContext(ctx, t.ytdlpPath, probeArgs...)
+	cmd := utils.CommandContext(ctx, t.ytdlpPath, probeArgs...)
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		return ytDLPSelection{}, handleDownloadError(err, string(out))
@@ -892,22 +968,39 @@ func parseResolutionHeight(resolution string) int {
 func handleDownloadError(err error, output string) error {
 	switch {
 	case strings.Contains(output, "Sign in to confirm") || strings.Contains(output, "not a bot"):
-		return fmt.Errorf("YouTube bot-detection triggered: export cookies from a logged-in browser " +
+		return PermanentError(fmt.Errorf("YouTube bot-detection triggered: export cookies from a logged-in browser " +
 			"(Chrome/Firefox → 'Get cookies.txt LOCALLY' extension) and place the .txt file in the cookies_dir; " +
-			"also run: yt-dlp -U to ensure yt-dlp is up to date")
+			"also run: yt-dlp -U to ensure yt-dlp is up to date"))
 	case strings.Contains(output, "cookies are no longer valid") || strings.Contains(output, "HTTP Error 401"):
-		return fmt.Errorf("cookies expired - re-export browser cookies and replace the file in cookies_dir")
+		return PermanentError(fmt.Errorf("cookies expired - re-export browser cookies and replace the file in cookies_dir"))
 	case strings.Contains(output, "Video unavailable"):
-		return fmt.Errorf("video unavailable or deleted")
+		return PermanentError(fmt.Errorf("video unavailable or deleted"))
 	case strings.Contains(output, "Private video"):
-		return fmt.Errorf("private video - login required")
+		return PermanentError(fmt.Errorf("private video - login required"))
 	case strings.Contains(output, "n challenge") || strings.Contains(output, "JS Challenge") || strings.Contains(output, "no solutions"):
-		return fmt.Errorf("YouTube n-challenge failed: yt-dlp needs update or JS runtime (deno/node) in PATH")
+		return PermanentError(fmt.Errorf("YouTube n-challenge failed: yt-dlp needs update or JS runtime (deno/node) in PATH"))
+	case isTransientYtDLPOutput(output):
+		return TransientError(fmt.Errorf("download failed: %w\noutput: %s", err, output))
 	default:
 		return fmt.Errorf("download failed: %w\noutput: %s", err, output)
 	}
 }
 
+// isTransientYtDLPOutput 判断 yt-dlp 输出是否属于可重试的网络类错误（分片失败、限流、5xx 等）
+func isTransientYtDLPOutput(output string) bool {
+	lower := strings.ToLower(output)
+	for _, marker := range []string{
+		"http error 429", "http error 500", "http error 502", "http error 503", "http error 504",
+		"fragment", "timed out", "connection reset", "read timed out",
+		"temporary failure in name resolution", "unable to download webpage",
+	} {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	return false
+}
+
 func isCookiesError(err error) bool {
 	if err == nil {
 		return false
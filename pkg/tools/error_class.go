@@ -0,0 +1,135 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/difyz9/ytb2bili/pkg/llm"
+)
+
+// ErrorClass 工具错误分类，用于决定步骤是否值得重试
+type ErrorClass int
+
+const (
+	ErrorClassUnknown   ErrorClass = iota // 无法判断
+	ErrorClassTransient                   // 临时错误：限流、超时、网络抖动、5xx
+	ErrorClassPermanent                   // 永久错误：鉴权失败、资源不存在、参数错误
+)
+
+func (c ErrorClass) String() string {
+	switch c {
+	case ErrorClassTransient:
+		return "transient"
+	case ErrorClassPermanent:
+		return "permanent"
+	default:
+		return "unknown"
+	}
+}
+
+// ClassifiedError 携带分类信息的错误
+type ClassifiedError struct {
+	Class ErrorClass
+	Err   error
+}
+
+func (e *ClassifiedError) Error() string {
+	if e == nil || e.Err == nil {
+		return ""
+	}
+	return e.Err.Error()
+}
+
+func (e *ClassifiedError) Unwrap() error {
+	if e == nil {
+		return nil
+	}
+	return e.Err
+}
+
+// TransientError 将错误标记为临时错误
+func TransientError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ClassifiedError{Class: ErrorClassTransient, Err: err}
+}
+
+// PermanentError 将错误标记为永久错误
+func PermanentError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ClassifiedError{Class: ErrorClassPermanent, Err: err}
+}
+
+var httpStatusPattern = regexp.MustCompile(`(?i)(?:status(?: code)?|HTTP Error)[:\s]+(\d{3})`)
+
+// ClassifyError 判断错误是临时的还是永久的。
+// 优先使用显式标记（ClassifiedError、llm.ProviderError），其次根据网络错误类型与 HTTP 状态码推断。
+func ClassifyError(err error) ErrorClass {
+	if err == nil {
+		return ErrorClassUnknown
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return ErrorClassPermanent
+	}
+
+	var classified *ClassifiedError
+	if errors.As(err, &classified) && classified.Class != ErrorClassUnknown {
+		return classified.Class
+	}
+
+	var providerErr *llm.ProviderError
+	if errors.As(err, &providerErr) {
+		switch providerErr.Reason {
+		case llm.FailoverRateLimit, llm.FailoverTimeout, llm.FailoverNetwork:
+			return ErrorClassTransient
+		case llm.FailoverAuth, llm.FailoverBilling, llm.FailoverContext:
+			return ErrorClassPermanent
+		}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return ErrorClassTransient
+	}
+
+	message := err.Error()
+	if match := httpStatusPattern.FindStringSubmatch(message); len(match) == 2 {
+		if code, convErr := strconv.Atoi(match[1]); convErr == nil {
+			switch {
+			case code == 408 || code == 425 || code == 429 || code >= 500:
+				return ErrorClassTransient
+			case code >= 400:
+				return ErrorClassPermanent
+			}
+		}
+	}
+
+	lower := strings.ToLower(message)
+	for _, marker := range []string{
+		"timeout", "timed out", "connection reset", "connection refused",
+		"temporarily unavailable", "too many requests", "rate limit",
+		"unexpected eof", "broken pipe", "temporary failure in name resolution",
+	} {
+		if strings.Contains(lower, marker) {
+			return ErrorClassTransient
+		}
+	}
+	return ErrorClassUnknown
+}
+
+// IsTransientError 错误是否为临时错误（可重试）
+func IsTransientError(err error) bool {
+	return ClassifyError(err) == ErrorClassTransient
+}
+
+// IsPermanentError 错误是否为永久错误（重试无意义）
+func IsPermanentError(err error) bool {
+	return ClassifyError(err) == ErrorClassPermanent
+}
@@ -0,0 +1,44 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/difyz9/ytb2bili/pkg/llm"
+)
+
+func TestClassifyError(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want ErrorClass
+	}{
+		{"nil", nil, ErrorClassUnknown},
+		{"canceled", fmt.Errorf("run: %w", context.Canceled), ErrorClassPermanent},
+		{"marked transient", TransientError(errors.New("boom")), ErrorClassTransient},
+		{"marked permanent", fmt.Errorf("wrap: %w", PermanentError(errors.New("boom"))), ErrorClassPermanent},
+		{"llm rate limit", &llm.ProviderError{Reason: llm.FailoverRateLimit, Original: errors.New("x")}, ErrorClassTransient},
+		{"llm auth", &llm.ProviderError{Reason: llm.FailoverAuth, Original: errors.New("x")}, ErrorClassPermanent},
+		{"http 503", errors.New("edge-tts returned status 503: busy"), ErrorClassTransient},
+		{"http 404", errors.New("upload part 1 failed: status 404"), ErrorClassPermanent},
+		{"yt-dlp 429", errors.New("ERROR: HTTP Error 429: Too Many Requests"), ErrorClassTransient},
+		{"plain", errors.New("something odd"), ErrorClassUnknown},
+	}
+	for _, tc := range cases {
+		if got := ClassifyError(tc.err); got != tc.want {
+			t.Errorf("%s: ClassifyError() = %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestHandleDownloadErrorClassification(t *testing.T) {
+	base := errors.New("exit status 1")
+	if !IsPermanentError(handleDownloadError(base, "ERROR: Video unavailable")) {
+		t.Fatal("expected unavailable video to be permanent")
+	}
+	if !IsTransientError(handleDownloadError(base, "ERROR: fragment 12 not found, unable to continue")) {
+		t.Fatal("expected fragment failure to be transient")
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/schema"
 	"github.com/difyz9/ytb2bili/internal/config"
+	"github.com/difyz9/ytb2bili/pkg/utils"
 	"go.uber.org/zap"
 )
 
@@ -111,7 +112,7 @@ func (t *ExtractAudioTool) ExtractWAV(ctx context.Context, videoPath string) (st
 		return audioPath, nil
 	}
 	args := []string{"-i", videoPath, "-vn", "-acodec", "pcm_s16le", "-ar", "16000", "-ac", "1", "-y", audioPath}
-	cmd := exec.CommandContext(ctx, t.ffmpegPath, args...)
+	cmd := utils.CommandContext(ctx, t.ffmpegPath, args...)
 	if out, err := cmd.CombinedOutput(); err != nil {
 		return "", fmt.Errorf("WAV extraction failed: %w\noutput: %s", err, out)
 	}
@@ -145,7 +146,7 @@ func (t *ExtractAudioTool) extract(ctx context.Context, videoPath string, format
 		ffmpegArgs = []string{"-i", videoPath, "-vn", "-acodec", "libmp3lame", "-ab", "192k", "-ar", "44100", "-ac", "2", "-y", audioPath}
 	}
 
-	cmd := exec.CommandContext(ctx, t.ffmpegPath, ffmpegArgs...)
+	cmd := utils.CommandContext(ctx, t.ffmpegPath, ffmpegArgs...)
 	if out, err := cmd.CombinedOutput(); err != nil {
 		return "", fmt.Errorf("audio extraction failed: %w\noutput: %s", err, out)
 	}
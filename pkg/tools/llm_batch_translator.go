@@ -56,6 +56,8 @@ type TranslationRunConfig struct {
 	TargetLang string
 	ModelName  string
 	UserID     string
+	// Instructions 追加到系统提示词末尾的要求（如人工审核退回意见），为空不追加
+	Instructions string
 }
 
 // NewLLMBatchTranslator creates an LLM-backed subtitle batch translator.
@@ -445,6 +447,9 @@ func (t *LLMBatchTranslator) translateGroupWithContext(ctx context.Context, text
 		len(texts),
 		t.getLanguageName(runConfig.TargetLang),
 		t.getLanguageName(runConfig.TargetLang))
+	if instructions := strings.TrimSpace(runConfig.Instructions); instructions != "" {
+		systemPrompt += "\n\n" + instructions
+	}
 
 	// 组合输入文本
 	combinedText := strings.Join(fullTexts, "\n###SENTENCE_BREAK###\n")
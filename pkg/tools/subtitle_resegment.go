@@ -0,0 +1,399 @@
+package tools
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// ── Subtitle Resegmentation ──────────────────────────────────────────────────
+// ASR engines return segments of arbitrary length: bcut utterances, whisper
+// segments, one-word fragments. ResegmentTranscript turns them into readable
+// cues: long segments are split at sentence/clause punctuation, pauses or word
+// boundaries (using word timestamps when the engine provides them), short
+// fragments are merged with a close neighbour, and cue timings respect the
+// minimum duration, reading speed and gap limits. CJK text (no spaces, denser
+// characters) uses its own line length and reading speed limits.
+
+const (
+	defaultResegmentMaxLineChars         = 42
+	defaultResegmentMaxLineCharsCJK      = 18
+	defaultResegmentMaxCueSeconds        = 7.0
+	defaultResegmentMinCueSeconds        = 1.0
+	defaultResegmentMinGapSeconds        = 0.08
+	defaultResegmentMaxCharsPerSecond    = 20.0
+	defaultResegmentMaxCharsPerSecondCJK = 9.0
+	defaultResegmentMergeGapSeconds      = 0.8
+	// resegmentPauseSeconds a silence between two words at least this long is a good place to cut
+	resegmentPauseSeconds = 0.3
+)
+
+// ResegmentConfig limits of the produced cues. Zero values use the defaults.
+type ResegmentConfig struct {
+	MaxLineChars         int     // characters per cue for Latin text, default 42
+	MaxLineCharsCJK      int     // characters per cue for CJK text, default 18
+	MaxCueSeconds        float64 // longest cue, default 7
+	MinCueSeconds        float64 // shorter cues are fragments to merge or extend, default 1
+	MinGapSeconds        float64 // gap kept between consecutive cues, default 0.08
+	MaxCharsPerSecond    float64 // reading speed for Latin text, default 20
+	MaxCharsPerSecondCJK float64 // reading speed for CJK text, default 9
+	MergeGapSeconds      float64 // fragments are only merged across gaps up to this, default 0.8
+}
+
+func (c ResegmentConfig) withDefaults() ResegmentConfig {
+	if c.MaxLineChars <= 0 {
+		c.MaxLineChars = defaultResegmentMaxLineChars
+	}
+	if c.MaxLineCharsCJK <= 0 {
+		c.MaxLineCharsCJK = defaultResegmentMaxLineCharsCJK
+	}
+	if c.MaxCueSeconds <= 0 {
+		c.MaxCueSeconds = defaultResegmentMaxCueSeconds
+	}
+	if c.MinCueSeconds <= 0 {
+		c.MinCueSeconds = defaultResegmentMinCueSeconds
+	}
+	if c.MinGapSeconds <= 0 {
+		c.MinGapSeconds = defaultResegmentMinGapSeconds
+	}
+	if c.MaxCharsPerSecond <= 0 {
+		c.MaxCharsPerSecond = defaultResegmentMaxCharsPerSecond
+	}
+	if c.MaxCharsPerSecondCJK <= 0 {
+		c.MaxCharsPerSecondCJK = defaultResegmentMaxCharsPerSecondCJK
+	}
+	if c.MergeGapSeconds <= 0 {
+		c.MergeGapSeconds = defaultResegmentMergeGapSeconds
+	}
+	return c
+}
+
+// breakClass how good a cut after a token is; higher is better.
+type breakClass int
+
+const (
+	breakWord breakClass = iota + 1
+	breakPause
+	breakClause
+	breakSentence
+)
+
+type resegmentToken struct {
+	text       string
+	start, end float64
+	brk        breakClass // quality of a cut after this token
+	timed      bool       // timings come from the engine's word timestamps
+}
+
+type resegmentCue []resegmentToken
+
+func (c resegmentCue) start() float64 { return c[0].start }
+func (c resegmentCue) end() float64   { return c[len(c)-1].end }
+
+func (c resegmentCue) text() string {
+	var b strings.Builder
+	for i, token := range c {
+		if i > 0 && needsSpace(c[i-1].text, token.text) {
+			b.WriteByte(' ')
+		}
+		b.WriteString(token.text)
+	}
+	return b.String()
+}
+
+// ResegmentTranscript returns a copy of result with readable cues. SRTPath is
+// cleared because the engine's SRT no longer matches the segments.
+func ResegmentTranscript(result *TranscriptResult, cfg ResegmentConfig) *TranscriptResult {
+	if result == nil {
+		return nil
+	}
+	r := resegmenter{cfg: cfg.withDefaults()}
+
+	var cues []resegmentCue
+	for _, segment := range result.Segments {
+		tokens := tokenizeSegment(segment)
+		if len(tokens) == 0 {
+			continue
+		}
+		cues = append(cues, r.split(tokens)...)
+	}
+	cues = r.mergeFragments(cues)
+
+	out := *result
+	out.SRTPath = ""
+	out.Segments = make([]TranscriptSegment, 0, len(cues))
+	for i, cue := range cues {
+		nextStart := math.Inf(1)
+		if i+1 < len(cues) {
+			nextStart = cues[i+1].start()
+		}
+		out.Segments = append(out.Segments, r.toSegment(cue, nextStart))
+	}
+	return &out
+}
+
+type resegmenter struct {
+	cfg ResegmentConfig
+}
+
+func (r resegmenter) maxChars(text string) int {
+	if isCJKText(text) {
+		return r.cfg.MaxLineCharsCJK
+	}
+	return r.cfg.MaxLineChars
+}
+
+func (r resegmenter) maxCharsPerSecond(text string) float64 {
+	if isCJKText(text) {
+		return r.cfg.MaxCharsPerSecondCJK
+	}
+	return r.cfg.MaxCharsPerSecond
+}
+
+func (r resegmenter) fits(cue resegmentCue) bool {
+	text := cue.text()
+	return runeCount(text) <= r.maxChars(text) && cue.end()-cue.start() <= r.cfg.MaxCueSeconds
+}
+
+// split cuts a segment that is too long into balanced cues, preferring the best
+// break class and, within it, the cut closest to an even split.
+func (r resegmenter) split(tokens resegmentCue) []resegmentCue {
+	var cues []resegmentCue
+	for len(tokens) > 0 {
+		if r.fits(tokens) {
+			return append(cues, tokens)
+		}
+		// longest prefix that still fits; a single oversized token is a cue of its own
+		n := 1
+		for n < len(tokens) && r.fits(tokens[:n+1]) {
+			n++
+		}
+
+		text := tokens.text()
+		parts := max(
+			math.Ceil(float64(runeCount(text))/float64(r.maxChars(text))),
+			math.Ceil((tokens.end()-tokens.start())/r.cfg.MaxCueSeconds),
+			2,
+		)
+		target := float64(runeCount(text)) / parts
+
+		best, bestClass, bestDistance := n, breakClass(0), math.Inf(1)
+		for i := 1; i <= n; i++ {
+			chars := float64(runeCount(tokens[:i].text()))
+			if chars < target/2 && i < n {
+				continue
+			}
+			class, distance := tokens[i-1].brk, math.Abs(chars-target)
+			if class > bestClass || (class == bestClass && distance < bestDistance) {
+				best, bestClass, bestDistance = i, class, distance
+			}
+		}
+		cues = append(cues, tokens[:best])
+		tokens = tokens[best:]
+	}
+	return cues
+}
+
+// mergeFragments joins cues that are too short to read with the closer neighbour,
+// as long as the gap is small and the merged cue still fits.
+func (r resegmenter) mergeFragments(cues []resegmentCue) []resegmentCue {
+	for i := 0; i < len(cues); {
+		if !r.isFragment(cues[i]) {
+			i++
+			continue
+		}
+		prevGap, nextGap := math.Inf(1), math.Inf(1)
+		if i > 0 && r.fits(concatCues(cues[i-1], cues[i])) {
+			prevGap = cues[i].start() - cues[i-1].end()
+		}
+		if i+1 < len(cues) && r.fits(concatCues(cues[i], cues[i+1])) {
+			nextGap = cues[i+1].start() - cues[i].end()
+		}
+
+		switch {
+		case nextGap <= r.cfg.MergeGapSeconds && nextGap <= prevGap:
+			cues[i] = concatCues(cues[i], cues[i+1])
+			cues = append(cues[:i+1], cues[i+2:]...)
+		case prevGap <= r.cfg.MergeGapSeconds:
+			cues[i-1] = concatCues(cues[i-1], cues[i])
+			cues = append(cues[:i], cues[i+1:]...)
+			i--
+		default:
+			i++
+		}
+	}
+	return cues
+}
+
+func (r resegmenter) isFragment(cue resegmentCue) bool {
+	text := cue.text()
+	return cue.end()-cue.start() < r.cfg.MinCueSeconds || runeCount(text) <= r.maxChars(text)/4
+}
+
+// toSegment applies the timing limits: a cue is extended towards its minimum
+// duration and reading time, but always ends MinGapSeconds before the next cue.
+func (r resegmenter) toSegment(cue resegmentCue, nextStart float64) TranscriptSegment {
+	text := cue.text()
+	start, end := cue.start(), cue.end()
+	limit := nextStart - r.cfg.MinGapSeconds
+
+	need := max(r.cfg.MinCueSeconds, float64(runeCount(text))/r.maxCharsPerSecond(text))
+	if end-start < need {
+		end = max(end, min(start+need, limit))
+	}
+	if end > limit && limit > start {
+		end = limit
+	}
+
+	segment := TranscriptSegment{Start: start, End: end, Text: text}
+	for _, token := range cue {
+		if token.timed {
+			segment.Words = append(segment.Words, TranscriptWord{Start: token.start, End: min(token.end, end), Text: token.text})
+		}
+	}
+	return segment
+}
+
+func concatCues(a, b resegmentCue) resegmentCue {
+	out := make(resegmentCue, 0, len(a)+len(b))
+	return append(append(out, a...), b...)
+}
+
+// tokenizeSegment breaks a segment into words (CJK: single characters) with timings.
+// Engine word timestamps are used when present, otherwise the segment's time is
+// spread over the tokens by character count. Timed words containing Chinese or
+// Japanese text are split into characters the same way, within the word's time.
+func tokenizeSegment(segment TranscriptSegment) resegmentCue {
+	var tokens resegmentCue
+	if len(segment.Words) > 0 {
+		for _, word := range segment.Words {
+			text := strings.TrimSpace(word.Text)
+			if text == "" {
+				continue
+			}
+			if !strings.ContainsFunc(text, isHanOrKana) {
+				tokens = append(tokens, resegmentToken{text: text, start: word.Start, end: word.End, timed: true})
+				continue
+			}
+			parts := splitResegmentTokens(text)
+			chars := make(resegmentCue, 0, len(parts))
+			for _, part := range parts {
+				chars = append(chars, resegmentToken{text: part, timed: true})
+			}
+			spreadTokenTimes(chars, word.Start, word.End)
+			tokens = append(tokens, chars...)
+		}
+	} else {
+		for _, text := range splitResegmentTokens(segment.Text) {
+			tokens = append(tokens, resegmentToken{text: text})
+		}
+		spreadTokenTimes(tokens, segment.Start, segment.End)
+	}
+
+	for i := range tokens {
+		tokens[i].brk = trailingBreakClass(tokens[i].text)
+		if i+1 < len(tokens) && tokens[i+1].start-tokens[i].end >= resegmentPauseSeconds {
+			tokens[i].brk = max(tokens[i].brk, breakPause)
+		}
+	}
+	if len(tokens) > 0 {
+		// the engine's own segment boundary is at least as good as a clause break
+		tokens[len(tokens)-1].brk = max(tokens[len(tokens)-1].brk, breakClause)
+	}
+	return tokens
+}
+
+// splitResegmentTokens splits on spaces; Chinese and Japanese characters are
+// tokens of their own, punctuation sticks to the preceding token.
+func splitResegmentTokens(text string) []string {
+	var (
+		tokens []string
+		word   strings.Builder
+	)
+	flush := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, word.String())
+			word.Reset()
+		}
+	}
+	for _, r := range text {
+		switch {
+		case unicode.IsSpace(r):
+			flush()
+		case unicode.IsPunct(r) && word.Len() == 0 && len(tokens) > 0:
+			tokens[len(tokens)-1] += string(r)
+		case isUnspacedRune(r):
+			flush()
+			word.WriteRune(r)
+			flush()
+		default:
+			word.WriteRune(r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+func spreadTokenTimes(tokens resegmentCue, start, end float64) {
+	total := 0
+	for _, token := range tokens {
+		total += runeCount(token.text)
+	}
+	if total == 0 {
+		return
+	}
+	perRune := max(end-start, 0) / float64(total)
+	at := start
+	for i := range tokens {
+		tokens[i].start = at
+		at += perRune * float64(runeCount(tokens[i].text))
+		tokens[i].end = at
+	}
+}
+
+func trailingBreakClass(text string) breakClass {
+	trimmed := strings.TrimRightFunc(text, func(r rune) bool {
+		return strings.ContainsRune(`"'”’）)」』】`, r)
+	})
+	runes := []rune(trimmed)
+	if len(runes) == 0 {
+		return breakWord
+	}
+	switch last := runes[len(runes)-1]; {
+	case strings.ContainsRune(".!?。！？…", last):
+		return breakSentence
+	case strings.ContainsRune(",;:，；：、—", last):
+		return breakClause
+	default:
+		return breakWord
+	}
+}
+
+// needsSpace Chinese and Japanese are written without spaces; everything else,
+// including Korean, separates words with spaces.
+func needsSpace(prev, next string) bool {
+	prevRunes, nextRunes := []rune(prev), []rune(next)
+	return !isUnspacedRune(prevRunes[len(prevRunes)-1]) && !isUnspacedRune(nextRunes[0])
+}
+
+func isUnspacedRune(r rune) bool {
+	return isHanOrKana(r) || strings.ContainsRune("。！？，；：、「」『』（）【】…", r)
+}
+
+// isCJKText reports whether CJK characters make up at least a third of the letters.
+func isCJKText(text string) bool {
+	cjk, letters := 0, 0
+	for _, r := range text {
+		if isCJKRune(r) {
+			cjk++
+			letters++
+		} else if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			letters++
+		}
+	}
+	return letters > 0 && cjk*3 >= letters
+}
+
+func runeCount(text string) int {
+	return len([]rune(text))
+}
@@ -0,0 +1,119 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func describeSegments(segments []TranscriptSegment) string {
+	lines := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		lines = append(lines, fmt.Sprintf("%.2f-%.2f %s", segment.Start, segment.End, segment.Text))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestResegmentTranscript_SplitsLongLatinSegmentAtPunctuation(t *testing.T) {
+	result := ResegmentTranscript(&TranscriptResult{Language: "en", SRTPath: "/tmp/engine.srt", Segments: []TranscriptSegment{{
+		Start: 0, End: 12,
+		Text: "Today we are going to look at three things, starting with the weather. Then we talk about the markets and finally sports.",
+	}}}, ResegmentConfig{})
+
+	want := strings.Join([]string{
+		// "...three things," is 43 characters, one over the limit: cut at the last word that fits
+		"0.00-3.28 Today we are going to look at three",
+		// the sentence end wins over word boundaries closer to an even split
+		"3.36-6.88 things, starting with the weather.",
+		"6.96-9.04 Then we talk about the",
+		"9.12-12.00 markets and finally sports.",
+	}, "\n")
+	if got := describeSegments(result.Segments); got != want {
+		t.Fatalf("unexpected cues:\n%s\nwant\n%s", got, want)
+	}
+	if result.SRTPath != "" || result.Language != "en" {
+		t.Fatalf("expected SRT path cleared and language kept, got %+v", result)
+	}
+}
+
+func TestResegmentTranscript_UsesWordTimestampsAndPauses(t *testing.T) {
+	words := []TranscriptWord{
+		{0.0, 0.4, "So"}, {0.4, 0.9, "the"}, {0.9, 1.6, "first"}, {1.6, 2.2, "thing"}, {2.2, 2.6, "you"},
+		{2.6, 3.0, "need"}, {3.0, 3.4, "is"}, {3.4, 3.8, "a"}, {3.8, 4.6, "plan"},
+		// 1.2s pause: the natural place to cut even without punctuation
+		{5.8, 6.2, "and"}, {6.2, 6.6, "then"}, {6.6, 7.0, "you"}, {7.0, 7.6, "stick"}, {7.6, 7.9, "to"}, {7.9, 8.5, "it"},
+	}
+	result := ResegmentTranscript(&TranscriptResult{Segments: []TranscriptSegment{{
+		Start: 0, End: 8.5, Text: "So the first thing you need is a plan and then you stick to it", Words: words,
+	}}}, ResegmentConfig{})
+
+	want := "0.00-4.60 So the first thing you need is a plan\n5.80-8.50 and then you stick to it"
+	if got := describeSegments(result.Segments); got != want {
+		t.Fatalf("unexpected cues:\n%s\nwant\n%s", got, want)
+	}
+	if len(result.Segments[1].Words) != 6 || result.Segments[1].Words[0].Start != 5.8 {
+		t.Fatalf("expected words to follow their cue, got %+v", result.Segments[1].Words)
+	}
+}
+
+func TestResegmentTranscript_CJK(t *testing.T) {
+	result := ResegmentTranscript(&TranscriptResult{Language: "zh", Segments: []TranscriptSegment{
+		{Start: 0, End: 8, Text: "大家好欢迎回到我的频道，今天我们来聊一聊如何用iPhone拍出好看的照片。"},
+		{Start: 8.2, End: 8.6, Text: "对"},
+		{Start: 8.7, End: 10, Text: "首先要注意光线"},
+	}}, ResegmentConfig{})
+
+	want := strings.Join([]string{
+		"0.00-2.51 大家好欢迎回到我的频道，",
+		"2.59-4.89 今天我们来聊一聊如何用",
+		"4.97-8.00 iPhone拍出好看的照片。",
+		// the one-character fragment joins the next sentence
+		"8.20-10.00 对首先要注意光线",
+	}, "\n")
+	if got := describeSegments(result.Segments); got != want {
+		t.Fatalf("unexpected cues:\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestResegmentTranscript_CJKWordTimestamps(t *testing.T) {
+	// local whisper reports whole words, or a whole line as one "word" from older engines
+	words := []TranscriptWord{
+		{0, 0.6, "大家好"}, {0.6, 1.2, "欢迎"}, {1.2, 1.8, "回到"}, {1.8, 3.0, "我的频道，"},
+		{3.0, 9.0, "今天我们来聊一聊如何用手机拍出好看的照片。"},
+	}
+	result := ResegmentTranscript(&TranscriptResult{Language: "zh", Segments: []TranscriptSegment{{
+		Start: 0, End: 9, Text: "大家好欢迎回到我的频道，今天我们来聊一聊如何用手机拍出好看的照片。", Words: words,
+	}}}, ResegmentConfig{})
+
+	want := strings.Join([]string{
+		// cut at the engine word boundary, keeping the minimum gap before the next cue
+		"0.00-2.92 大家好欢迎回到我的频道，",
+		// the 21-character word is split into characters and balanced over two cues
+		"3.00-5.78 今天我们来聊一聊如何",
+		"5.86-9.00 用手机拍出好看的照片。",
+	}, "\n")
+	if got := describeSegments(result.Segments); got != want {
+		t.Fatalf("unexpected cues:\n%s\nwant\n%s", got, want)
+	}
+	if first := result.Segments[0].Words; len(first) != 11 || first[1].Text != "家" || fmt.Sprintf("%.2f", first[1].Start) != "0.20" || first[10].Text != "道，" {
+		t.Fatalf("expected per-character words within the engine word's time, got %+v", first)
+	}
+}
+
+func TestResegmentTranscript_TimingLimits(t *testing.T) {
+	result := ResegmentTranscript(&TranscriptResult{Segments: []TranscriptSegment{
+		{Start: 0, End: 1.0, Text: "This sentence has far too many characters for one second."},
+		{Start: 5, End: 6.5, Text: "Next cue starts right after,"},
+		{Start: 6.5, End: 8, Text: "so the gap must be kept."},
+	}}, ResegmentConfig{MaxLineChars: 60})
+
+	want := strings.Join([]string{
+		// 57 characters at 20 cps need 2.85s; the next cue is far enough away
+		"0.00-2.85 This sentence has far too many characters for one second.",
+		"5.00-6.42 Next cue starts right after,",
+		"6.50-8.00 so the gap must be kept.",
+	}, "\n")
+	if got := describeSegments(result.Segments); got != want {
+		t.Fatalf("unexpected cues:\n%s\nwant\n%s", got, want)
+	}
+}
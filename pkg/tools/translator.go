@@ -2,6 +2,7 @@ package tools
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -20,7 +21,7 @@ type TranslatorConfig struct {
 
 // Translator 接口
 type Translator interface {
-	TranslateText(text, from, to string) (string, error)
+	TranslateText(ctx context.Context, text, from, to string) (string, error)
 }
 
 // MicrosoftTranslator 实现
@@ -46,7 +47,7 @@ func NewMicrosoftTranslatorFromAppConfig(appCfg *config.AppConfig) *MicrosoftTra
 }
 
 // TranslateText 调用微软翻译API
-func (t *MicrosoftTranslator) TranslateText(text, from, to string) (string, error) {
+func (t *MicrosoftTranslator) TranslateText(ctx context.Context, text, from, to string) (string, error) {
 	uri := fmt.Sprintf("%s/translate?api-version=3.0", t.Config.Endpoint)
 	u, err := url.Parse(uri)
 	if err != nil {
@@ -65,7 +66,7 @@ func (t *MicrosoftTranslator) TranslateText(text, from, to string) (string, erro
 		return "", fmt.Errorf("failed to encode request body: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", u.String(), bytes.NewBuffer(b))
+	req, err := http.NewRequestWithContext(ctx, "POST", u.String(), bytes.NewBuffer(b))
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
@@ -34,7 +34,7 @@ func (e *AzureTTSEngine) Voices(ctx context.Context, locale string) ([]VoiceInfo
 
 func (e *AzureTTSEngine) Synthesize(ctx context.Context, text, voice string, rate, volume, pitch float64) ([]byte, error) {
 	if strings.TrimSpace(text) == "" {
-		return nil, fmt.Errorf("azure-tts: text is empty")
+		return nil, PermanentError(fmt.Errorf("azure-tts: text is empty"))
 	}
 	if strings.TrimSpace(voice) == "" {
 		voice = "zh-CN-XiaoxiaoNeural"
@@ -181,7 +181,7 @@ func (c *TTSClient) SynthesizeSpeech(ctx context.Context, req TTSRequest) (*TTSR
 	}
 
 	if strings.TrimSpace(req.Text) == "" {
-		return nil, fmt.Errorf("TTS text is empty")
+		return nil, PermanentError(fmt.Errorf("TTS text is empty"))
 	}
 
 	profile, err := c.resolveProfile(ctx, req, effectiveConfig)
@@ -237,7 +237,7 @@ func (c *TTSClient) synthesizeAzure(ctx context.Context, config TTSConfig, profi
 	subKey := strings.TrimSpace(config.AzureSubscriptionKey)
 	region := strings.TrimSpace(config.AzureRegion)
 	if subKey == "" || region == "" {
-		return nil, fmt.Errorf("Azure TTS requires subscription_key and region; configure [azure_tts] in config.toml or user settings")
+		return nil, PermanentError(fmt.Errorf("Azure TTS requires subscription_key and region; configure [azure_tts] in config.toml or user settings"))
 	}
 
 	endpoint := fmt.Sprintf("https://%s.tts.speech.microsoft.com/cognitiveservices/v1", region)
@@ -284,7 +284,7 @@ func (c *TTSClient) synthesizeTencent(ctx context.Context, config TTSConfig, pro
 	region := strings.TrimSpace(config.TencentRegion)
 
 	if secretID == "" || secretKey == "" {
-		return nil, fmt.Errorf("Tencent TTS requires secret_id and secret_key; configure [tencent_tts] in config.toml or user settings")
+		return nil, PermanentError(fmt.Errorf("Tencent TTS requires secret_id and secret_key; configure [tencent_tts] in config.toml or user settings"))
 	}
 	if region == "" {
 		region = "ap-guangzhou"
@@ -501,7 +501,7 @@ func (c *TTSClient) resolveAutoProfile(config TTSConfig, locale, requestedVoice,
 		}
 	}
 
-	return ttsProfile{}, fmt.Errorf("no available TTS provider; configure [azure_tts] or [tencent_tts] in config.toml with valid credentials")
+	return ttsProfile{}, PermanentError(fmt.Errorf("no available TTS provider; configure [azure_tts] or [tencent_tts] in config.toml with valid credentials"))
 }
 
 // resolveVoiceForProvider resolves a voice for the given provider using the embedded catalog.
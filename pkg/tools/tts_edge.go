@@ -66,7 +66,7 @@ func (e *EdgeTTSEngine) Voices(ctx context.Context, locale string) ([]VoiceInfo,
 
 func (e *EdgeTTSEngine) Synthesize(ctx context.Context, text, voice string, rate, volume, pitch float64) ([]byte, error) {
 	if strings.TrimSpace(text) == "" {
-		return nil, fmt.Errorf("edge-tts: text is empty")
+		return nil, PermanentError(fmt.Errorf("edge-tts: text is empty"))
 	}
 	if strings.TrimSpace(voice) == "" {
 		voice = "zh-CN-XiaoxiaoNeural"
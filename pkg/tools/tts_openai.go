@@ -75,10 +75,10 @@ type openAITTSSpeechReq struct {
 
 func (e *OpenAITTSEngine) Synthesize(ctx context.Context, text, voice string, rate, volume, pitch float64) ([]byte, error) {
 	if strings.TrimSpace(e.apiKey) == "" {
-		return nil, fmt.Errorf("openai-tts: API key not configured")
+		return nil, PermanentError(fmt.Errorf("openai-tts: API key not configured"))
 	}
 	if strings.TrimSpace(text) == "" {
-		return nil, fmt.Errorf("openai-tts: text is empty")
+		return nil, PermanentError(fmt.Errorf("openai-tts: text is empty"))
 	}
 	if strings.TrimSpace(voice) == "" {
 		voice = "alloy"
@@ -44,7 +44,7 @@ func (e *TencentTTSEngine) Voices(ctx context.Context, locale string) ([]VoiceIn
 
 func (e *TencentTTSEngine) Synthesize(ctx context.Context, text, voice string, rate, volume, pitch float64) ([]byte, error) {
 	if strings.TrimSpace(text) == "" {
-		return nil, fmt.Errorf("tencent-tts: text is empty")
+		return nil, PermanentError(fmt.Errorf("tencent-tts: text is empty"))
 	}
 
 	voiceType := resolveTencentVoiceType(voice)
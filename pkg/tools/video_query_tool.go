@@ -9,6 +9,7 @@ import (
 
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/schema"
+	"github.com/difyz9/ytb2bili/pkg/store/model"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
@@ -38,7 +39,7 @@ func (t *VideoQueryTool) Info(_ context.Context) (*schema.ToolInfo, error) {
 		ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
 			"status": {
 				Type: schema.String,
-				Desc: "按状态筛选: completed(已完成)、processing(处理中)、failed(失败)、pending(待处理)，留空返回全部",
+				Desc: "按状态筛选: completed(已完成，含已投稿)、processing(排队或处理中)、failed(失败或已取消)、awaiting_review(待审核)、published(已投稿)，留空返回全部",
 			},
 			"platform": {
 				Type: schema.String,
@@ -56,18 +57,12 @@ func (t *VideoQueryTool) Info(_ context.Context) (*schema.ToolInfo, error) {
 	}, nil
 }
 
-var videoStatusCodeMap = map[string]string{
-	"completed":  "003",
-	"processing": "002",
-	"pending":    "001",
-	"failed":     "004",
-}
-
-var videoStatusLabelMap = map[string]string{
-	"001": "待处理",
-	"002": "处理中",
-	"003": "已完成",
-	"004": "失败",
+// videoStatusGroups 工具参数中的状态分组，其余取值按单个状态（兼容旧状态码）筛选
+var videoStatusGroups = map[string][]string{
+	"completed":  model.VideoDoneStatuses,
+	"processing": model.VideoInProgressStatuses,
+	"pending":    {model.VideoStatusQueued},
+	"failed":     model.VideoStoppedStatuses,
 }
 
 type queryParams struct {
@@ -109,10 +104,10 @@ func (t *VideoQueryTool) InvokableRun(ctx context.Context, args string, opts ...
 	}
 
 	if s := strings.ToLower(params.Status); s != "" {
-		if code, ok := videoStatusCodeMap[s]; ok {
-			q = q.Where("status = ?", code)
+		if group, ok := videoStatusGroups[s]; ok {
+			q = q.Where("status IN ?", group)
 		} else {
-			q = q.Where("status = ?", s)
+			q = q.Where("status = ?", model.NormalizeVideoStatus(s))
 		}
 	}
 	if params.Platform != "" {
@@ -145,10 +140,7 @@ func (t *VideoQueryTool) InvokableRun(ctx context.Context, args string, opts ...
 
 	out := make([]display, len(rows))
 	for i, r := range rows {
-		label := videoStatusLabelMap[r.Status]
-		if label == "" {
-			label = r.Status
-		}
+		label := model.VideoStatusText(r.Status)
 		dur := ""
 		if r.Duration > 0 {
 			m := int(r.Duration) / 60
@@ -92,7 +92,7 @@ func TranscodeVideoWithOptions(ctx context.Context, ffmpegPath string, opts Vide
 	}
 	args = append(args, opts.OutputPath)
 
-	cmd := exec.CommandContext(ctx, resolvedFFmpegPath, args...)
+	cmd := CommandContext(ctx, resolvedFFmpegPath, args...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("ffmpeg transcode failed: %w\noutput: %s", err, strings.TrimSpace(string(output)))
@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"context"
+	"os/exec"
+	"time"
+)
+
+// processWaitDelay is how long Wait keeps waiting for stdout/stderr to drain
+// after the process group has been killed.
+const processWaitDelay = 5 * time.Second
+
+// CommandContext is exec.CommandContext with cancellation that reaches the
+// whole process tree: the command runs in its own process group and the group
+// is killed when ctx is done, so helpers spawned by yt-dlp (ffmpeg, aria2c)
+// do not outlive a cancelled task.
+func CommandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	KillProcessTreeOnCancel(cmd)
+	return cmd
+}
+
+// KillProcessTreeOnCancel configures a command created with exec.CommandContext
+// to kill its entire process tree on cancellation. Call it before Start.
+func KillProcessTreeOnCancel(cmd *exec.Cmd) {
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return killProcessGroup(cmd)
+	}
+	cmd.WaitDelay = processWaitDelay
+}
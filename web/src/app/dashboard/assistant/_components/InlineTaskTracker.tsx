@@ -35,7 +35,7 @@ const STEP_LABEL_KEYS: Record<string, string> = {
 
 function StepIcon({ status }: { status: string }) {
   if (status === 'completed') return <CheckCircle className="w-3.5 h-3.5 text-green-500 shrink-0" />;
-  if (status === 'failed')    return <XCircle    className="w-3.5 h-3.5 text-red-500 shrink-0" />;
+  if (status === 'failed' || status === 'timeout') return <XCircle className="w-3.5 h-3.5 text-red-500 shrink-0" />;
   if (status === 'running')   return <div className="w-3.5 h-3.5 shrink-0 rounded-full border-2 border-blue-500 border-t-transparent animate-spin" />;
   if (status === 'skipped')   return <div className="w-3.5 h-3.5 shrink-0 rounded-full bg-gray-300" />;
   return <div className="w-3.5 h-3.5 shrink-0 rounded-full border-2 border-gray-300" />;
@@ -108,7 +108,7 @@ export default function InlineTaskTracker({ videoId, onStatusChange }: Props) {
   const allStepsFinished = steps.length > 0 && steps.every(
     (step) => step.status === 'completed' || step.status === 'skipped',
   );
-  const isDone   = videoStatus === '003' || videoStatus === 'completed' || allStepsFinished;
+  const isDone   = ['003', 'completed', 'ready', 'published', 'awaiting_review'].includes(videoStatus) || allStepsFinished;
   const isFailed = videoStatus === '004' || videoStatus === 'failed';
   const completedCount = steps.filter(s => s.status === 'completed' || s.status === 'skipped').length;
   const runningStep = steps.find(step => step.status === 'running');
@@ -181,7 +181,7 @@ export default function InlineTaskTracker({ videoId, onStatusChange }: Props) {
               {step.status === 'completed' && step.duration != null && step.duration > 0 && (
                 <span className="ml-auto text-muted-foreground">{(step.duration / 1000).toFixed(1)}s</span>
               )}
-              {step.status === 'failed' && step.error_msg && (
+              {(step.status === 'failed' || step.status === 'timeout') && step.error_msg && (
                 <span className="ml-auto text-red-500 truncate max-w-[160px]" title={step.error_msg}>{step.error_msg}</span>
               )}
             </div>
@@ -266,6 +266,15 @@ const STATUS_META: Record<string, { labelKey: string; color: string }> = {
   '002': { labelKey: 'Processing', color: 'text-blue-600 bg-blue-50 border-blue-200' },
   '003': { labelKey: 'Completed', color: 'text-green-600 bg-green-50 border-green-200' },
   '004': { labelKey: 'Failed',   color: 'text-red-600 bg-red-50 border-red-200' },
+  queued:          { labelKey: 'Queued',          color: 'text-yellow-600 bg-yellow-50 border-yellow-200' },
+  downloading:     { labelKey: 'Downloading',     color: 'text-sky-600 bg-sky-50 border-sky-200' },
+  processing:      { labelKey: 'Processing',      color: 'text-blue-600 bg-blue-50 border-blue-200' },
+  awaiting_review: { labelKey: 'Awaiting review', color: 'text-violet-600 bg-violet-50 border-violet-200' },
+  ready:           { labelKey: 'Completed',       color: 'text-green-600 bg-green-50 border-green-200' },
+  uploading:       { labelKey: 'Uploading',       color: 'text-pink-600 bg-pink-50 border-pink-200' },
+  published:       { labelKey: 'Published',       color: 'text-purple-600 bg-purple-50 border-purple-200' },
+  failed:          { labelKey: 'Failed',          color: 'text-red-600 bg-red-50 border-red-200' },
+  cancelled:       { labelKey: 'Cancelled',       color: 'text-amber-600 bg-amber-50 border-amber-200' },
 };
 function statusMeta(s: string) {
   const meta = STATUS_META[s];
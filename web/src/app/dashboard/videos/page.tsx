@@ -36,10 +36,16 @@ const STATUS_MAP: Record<string, { label: string; color: string }> = {
   '003': { label: 'Completed', color: 'bg-green-500' },
   '004': { label: 'Failed', color: 'bg-red-500' },
   pending:    { label: 'Pending', color: 'bg-yellow-500' },
+  queued:     { label: 'Queued', color: 'bg-yellow-500' },
+  downloading: { label: 'Downloading', color: 'bg-sky-500' },
   processing: { label: 'Processing', color: 'bg-blue-500' },
+  awaiting_review: { label: 'Awaiting review', color: 'bg-violet-500' },
   completed:  { label: 'Completed', color: 'bg-green-500' },
   ready:      { label: 'Ready', color: 'bg-green-500' },
+  uploading:  { label: 'Uploading', color: 'bg-pink-500' },
+  published:  { label: 'Published', color: 'bg-purple-500' },
   failed:     { label: 'Failed', color: 'bg-red-500' },
+  cancelled:  { label: 'Cancelled', color: 'bg-amber-500' },
   uploaded:   { label: 'Uploaded', color: 'bg-purple-500' },
 };
 
@@ -64,7 +70,7 @@ export default function VideosPage() {
         page: String(page),
         limit: String(PAGE_SIZE),
       });
-      if (statusFilter) params.set('status', statusFilter);
+      if (statusFilter) params.set('tab', statusFilter);
 
       const res = await fetch(`/api/v1/videos?${params}`);
       const json = await res.json();
@@ -144,10 +150,10 @@ export default function VideosPage() {
             className="px-3 py-1.5 text-sm border border-border rounded-full bg-background hover:bg-accent transition-colors cursor-pointer"
           >
             <option value="">{t('All statuses')}</option>
-            <option value="001">{t('Pending')}</option>
-            <option value="002">{t('Processing')}</option>
-            <option value="003">{t('Completed')}</option>
-            <option value="004">{t('Failed')}</option>
+            <option value="processing">{t('Processing')}</option>
+            <option value="review">{t('Awaiting review')}</option>
+            <option value="completed">{t('Completed')}</option>
+            <option value="failed">{t('Failed')}</option>
           </select>
           <span className="text-sm text-muted-foreground">{t('{count} videos', { count: total })}</span>
         </div>
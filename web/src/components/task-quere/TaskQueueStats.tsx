@@ -156,22 +156,33 @@ const VIDEO_STATUS: Record<string, { label: string; color: string; dot: string;
   '003': { label: 'Completed', color: 'bg-emerald-50 text-emerald-700 border border-emerald-200', dot: 'bg-emerald-500', tab: 'completed' },
   '004': { label: 'Task failed', color: 'bg-red-50 text-red-700 border border-red-200', dot: 'bg-red-500', tab: 'failed' },
   pending:    { label: 'Pending',  color: 'bg-gray-100 text-gray-700 border border-gray-200',    dot: 'bg-gray-400', tab: 'processing' },
+  queued:     { label: 'Queued',  color: 'bg-gray-100 text-gray-700 border border-gray-200',    dot: 'bg-gray-400', tab: 'processing' },
+  downloading:{ label: 'Downloading',  color: 'bg-sky-50 text-sky-700 border border-sky-200',     dot: 'bg-sky-500 animate-pulse', tab: 'processing' },
   processing: { label: 'Processing',  color: 'bg-blue-50 text-blue-700 border border-blue-200',     dot: 'bg-blue-500 animate-pulse', tab: 'processing' },
+  awaiting_review: { label: 'Awaiting review', color: 'bg-violet-50 text-violet-700 border border-violet-200', dot: 'bg-violet-500', tab: 'completed' },
+  uploading:  { label: 'Uploading',  color: 'bg-pink-50 text-pink-700 border border-pink-200',     dot: 'bg-pink-500 animate-pulse', tab: 'processing' },
+  published:  { label: 'Published',  color: 'bg-teal-50 text-teal-700 border border-teal-200', dot: 'bg-teal-500', tab: 'completed' },
   processed:  { label: 'Processed',  color: 'bg-cyan-50 text-cyan-700 border border-cyan-200',     dot: 'bg-cyan-500', tab: 'completed' },
   ready:      { label: 'Ready',color: 'bg-green-50 text-green-700 border border-green-200', dot: 'bg-green-500', tab: 'completed' },
   completed:  { label: 'Completed',  color: 'bg-emerald-50 text-emerald-700 border border-emerald-200', dot: 'bg-emerald-500', tab: 'completed' },
   failed:     { label: 'Task failed',color: 'bg-red-50 text-red-700 border border-red-200',       dot: 'bg-red-500', tab: 'failed' },
   paused:     { label: 'Stopped',  color: 'bg-amber-50 text-amber-700 border border-amber-200', dot: 'bg-amber-500', tab: 'failed' },
+  cancelled:  { label: 'Cancelled',  color: 'bg-amber-50 text-amber-700 border border-amber-200', dot: 'bg-amber-500', tab: 'failed' },
   synced:     { label: 'Synced',  color: 'bg-teal-50 text-teal-700 border border-teal-200',    dot: 'bg-teal-500', tab: 'completed' },
 };
+// 有任务正在执行的状态（含旧状态码 002）
+const ACTIVE_STATUSES = ['002', 'queued', 'downloading', 'processing', 'uploading'];
 const getVideoStatus = (s: string) =>
   VIDEO_STATUS[s] ?? { label: s || 'Unknown', color: 'bg-gray-100 text-gray-700 border border-gray-200', dot: 'bg-gray-400', tab: 'all' as TabType };
 
 const STEP_STYLE: Record<string, { badge: string; label: string; icon: React.ReactNode }> = {
   completed: { badge: 'bg-green-100 text-green-800',  label: 'Completed', icon: <CheckCircle className="w-3.5 h-3.5 text-green-600" /> },
   failed:    { badge: 'bg-red-100 text-red-800',      label: 'Failed',   icon: <XCircle className="w-3.5 h-3.5 text-red-600" /> },
+  timeout:   { badge: 'bg-orange-100 text-orange-800', label: 'Timed out', icon: <Clock className="w-3.5 h-3.5 text-orange-600" /> },
   running:   { badge: 'bg-blue-100 text-blue-800',    label: 'Running', icon: <Play className="w-3.5 h-3.5 text-blue-600" /> },
   skipped:   { badge: 'bg-gray-100 text-gray-500',    label: 'Skipped', icon: <ChevronRight className="w-3.5 h-3.5 text-gray-400" /> },
+  cancelled: { badge: 'bg-amber-100 text-amber-800',  label: 'Cancelled', icon: <XCircle className="w-3.5 h-3.5 text-amber-600" /> },
+  interrupted: { badge: 'bg-amber-100 text-amber-800', label: 'Interrupted', icon: <RotateCcw className="w-3.5 h-3.5 text-amber-600" /> },
   pending:   { badge: 'bg-gray-100 text-gray-600',    label: 'Pending execution', icon: <Clock className="w-3.5 h-3.5 text-gray-400" /> },
 };
 const getStepStyle = (s: string) => STEP_STYLE[s] ?? { badge: 'bg-gray-100 text-gray-600', label: s, icon: <Clock className="w-3.5 h-3.5 text-gray-400" /> };
@@ -249,6 +260,7 @@ function TaskStepList({ steps, onRetry }: { steps: TaskStep[]; onRetry: (name: s
               <div className={`w-5 h-5 rounded-full flex items-center justify-center border-2 ${
                 step.status === 'completed' ? 'border-green-400 bg-green-50' :
                 step.status === 'failed'    ? 'border-red-400 bg-red-50' :
+                step.status === 'timeout'   ? 'border-orange-400 bg-orange-50' :
                 step.status === 'running'   ? 'border-blue-400 bg-blue-50' :
                 step.status === 'skipped'   ? 'border-gray-300 bg-gray-50' :
                 'border-gray-300 bg-white'
@@ -261,6 +273,7 @@ function TaskStepList({ steps, onRetry }: { steps: TaskStep[]; onRetry: (name: s
             {/* step card */}
             <div className={`flex-1 mb-1.5 rounded-lg border px-3 py-2 ${
               step.status === 'failed'  ? 'border-red-100 bg-red-50/50' :
+              step.status === 'timeout' ? 'border-orange-100 bg-orange-50/50' :
               step.status === 'running' ? 'border-blue-100 bg-blue-50/50' :
               step.status === 'completed' ? 'border-green-100 bg-green-50/30' :
               'border-gray-100 bg-white'
@@ -273,7 +286,7 @@ function TaskStepList({ steps, onRetry }: { steps: TaskStep[]; onRetry: (name: s
                     <span className="text-xs text-gray-400">{formatDuration(step.duration)}</span>
                   )}
                 </div>
-                {step.can_retry && step.status === 'failed' && (
+                {step.can_retry && (step.status === 'failed' || step.status === 'timeout') && (
                   <button
                     onClick={() => onRetry(step.step_name)}
                     className="flex items-center gap-1 text-xs text-blue-600 hover:text-blue-800 bg-blue-50 hover:bg-blue-100 px-2 py-1 rounded transition-colors"
@@ -494,7 +507,7 @@ const fmtAccountLastUsed = (value?: string) => {
 
   // Dynamic polling: fast (5s) when processing, slow (30s) otherwise
   useEffect(() => {
-    const hasActive = videos.some(v => v.status === '002' || v.status === 'processing');
+    const hasActive = videos.some(v => ACTIVE_STATUSES.includes(v.status));
     const interval = setInterval(() => { fetchVideos(); fetchCounts(); }, hasActive ? 5000 : 30000);
     return () => clearInterval(interval);
   }, [videos, fetchVideos, fetchCounts]);
@@ -692,9 +705,9 @@ const fmtAccountLastUsed = (value?: string) => {
     const steps = v.task_steps ?? [];
     const running = steps.find(s => s.status === 'running');
     if (running) return running.progress_text || t('Running: {step}', { step: t(stepLabelKey(running.step_name)) });
-    const failed = steps.find(s => s.status === 'failed');
+    const failed = steps.find(s => s.status === 'failed' || s.status === 'timeout');
     if (failed) return t('Preparation failed. Check the task steps.');
-    if (v.status === 'paused') return t('The task was stopped. You can continue or rerun it with a different config.');
+    if (v.status === 'paused' || v.status === 'cancelled') return t('The task was stopped. You can continue or rerun it with a different config.');
     if (steps.length > 0 && steps.every(s => s.status === 'completed' || s.status === 'skipped'))
       return t('All steps completed');
     return t('Waiting to start');
@@ -852,7 +865,7 @@ const fmtAccountLastUsed = (value?: string) => {
                     )}
 
                     {/* 重新处理按钮：任务失败时显示 */}
-                    {(video.status === '004' || video.status === 'failed' || video.status === 'paused') && (
+                    {(video.status === '004' || video.status === 'failed' || video.status === 'paused' || video.status === 'cancelled') && (
                       <div className="flex flex-col items-end gap-1 shrink-0">
                         <button
                           disabled={resumeStates[video.id] === 'resuming'}
@@ -899,7 +912,7 @@ const fmtAccountLastUsed = (value?: string) => {
                       )}
                     </div>
 
-                    {(video.status === '002' || video.status === 'processing') && (
+                    {ACTIVE_STATUSES.includes(video.status) && video.status !== 'queued' && (
                       <button
                         disabled={stopStates[video.id] === 'stopping'}
                         onClick={() => handleStop(video)}
@@ -182,6 +182,11 @@ const zhCN: MessageCatalog = {
   'Failed': '失败',
   'Cancelled': '已取消',
   'Error': '错误',
+  'Queued': '排队中',
+  'Downloading': '下载中',
+  'Awaiting review': '待审核',
+  'Published': '已投稿',
+  'Interrupted': '已中断',
   'Loading pricing center...': '正在加载定价中心…',
   'Back to dashboard': '返回工作台',
   'Auto best': '自动最佳',
@@ -617,6 +622,7 @@ const zhCN: MessageCatalog = {
   'Unknown': '未知',
   'Running': '执行中',
   'Skipped': '已跳过',
+  'Timed out': '已超时',
   'Pending execution': '待执行',
   'Task steps': '任务步骤',
   'Error:': '错误:',
@@ -995,6 +1001,11 @@ const enUS: MessageCatalog = {
   'Failed': 'Failed',
   'Cancelled': 'Cancelled',
   'Error': 'Error',
+  'Queued': 'Queued',
+  'Downloading': 'Downloading',
+  'Awaiting review': 'Awaiting review',
+  'Published': 'Published',
+  'Interrupted': 'Interrupted',
   'Loading pricing center...': 'Loading pricing center...',
   'Back to dashboard': 'Back to dashboard',
   'Auto best': 'Auto best',
@@ -1627,6 +1638,7 @@ const enUS: MessageCatalog = {
   'Unknown': 'Unknown',
   'Running': 'Running',
   'Skipped': 'Skipped',
+  'Timed out': 'Timed out',
   'Pending execution': 'Pending execution',
   'Task steps': 'Task steps',
   'Error:': 'Error:',